@@ -0,0 +1,106 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// AuditCapabilities represents the capabilities-related fields of a Linux
+// kernel audit record, such as a CAPSET or PATH record. Fields not present in
+// the audit record are left as nil sets and zero values.
+type AuditCapabilities struct {
+	Inheritable     CapabilitiesSet // process inheritable set ("cap_pi")
+	Permitted       CapabilitiesSet // process permitted set ("cap_pp")
+	Effective       CapabilitiesSet // process effective set ("cap_pe")
+	Ambient         CapabilitiesSet // process ambient set ("cap_pa")
+	FileVersion     uint32          // file capabilities revision ("cap_fver"), such as 2 or 3
+	FileInheritable CapabilitiesSet // file inheritable set ("cap_fi")
+	FilePermitted   CapabilitiesSet // file permitted set ("cap_fp")
+	FileEffective   bool            // file effective bit ("cap_fe")
+	FileRootID      uint32          // root user ID of the file's user namespace ("cap_frootid")
+}
+
+// FromAuditRecord decodes the capabilities-related “cap_*” fields of a Linux
+// kernel audit record into their corresponding capabilities sets and values,
+// given the record's fields as a map of field names to their raw (not
+// interpreted) values, as logged by the kernel. Capabilities sets are logged
+// in hexadecimal notation, as are the file capabilities revisions, while the
+// file effective bit and the root user ID are logged in decimal notation.
+//
+// Fields other than “cap_pi”, “cap_pp”, “cap_pe”, “cap_pa”, “cap_fver”,
+// “cap_fi”, “cap_fp”, “cap_fe”, and “cap_frootid” are ignored. If any of these
+// fields cannot be decoded, an error is returned instead.
+func FromAuditRecord(fields map[string]string) (AuditCapabilities, error) {
+	var ac AuditCapabilities
+	for _, field := range []struct {
+		name string
+		set  *CapabilitiesSet
+	}{
+		{"cap_pi", &ac.Inheritable},
+		{"cap_pp", &ac.Permitted},
+		{"cap_pe", &ac.Effective},
+		{"cap_pa", &ac.Ambient},
+		{"cap_fi", &ac.FileInheritable},
+		{"cap_fp", &ac.FilePermitted},
+	} {
+		value, ok := fields[field.name]
+		if !ok {
+			continue
+		}
+		caps, err := CapabilitiesFromHex(value)
+		if err != nil {
+			return AuditCapabilities{}, fmt.Errorf("invalid %s audit field: %w", field.name, err)
+		}
+		*field.set = caps
+	}
+	if value, ok := fields["cap_fver"]; ok {
+		fver, err := strconv.ParseUint(value, 16, 32)
+		if err != nil {
+			return AuditCapabilities{}, fmt.Errorf("invalid cap_fver audit field: %w", err)
+		}
+		ac.FileVersion = uint32(fver)
+	}
+	if value, ok := fields["cap_fe"]; ok {
+		switch value {
+		case "0":
+		case "1":
+			ac.FileEffective = true
+		default:
+			return AuditCapabilities{}, fmt.Errorf("invalid cap_fe audit field %q", value)
+		}
+	}
+	if value, ok := fields["cap_frootid"]; ok {
+		rootid, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return AuditCapabilities{}, fmt.Errorf("invalid cap_frootid audit field: %w", err)
+		}
+		ac.FileRootID = uint32(rootid)
+	}
+	return ac, nil
+}
+
+// TaskCapabilities returns the process effective, permitted, and inheritable
+// capabilities sets of the audit record.
+func (ac AuditCapabilities) TaskCapabilities() TaskCapabilities {
+	return TaskCapabilities{
+		Effective:   ac.Effective,
+		Permitted:   ac.Permitted,
+		Inheritable: ac.Inheritable,
+	}
+}
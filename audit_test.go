@@ -0,0 +1,74 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+// auditFields returns the fields of a raw audit record as a map.
+func auditFields(record string) map[string]string {
+	fields := map[string]string{}
+	for _, field := range strings.Fields(record) {
+		if name, value, ok := strings.Cut(field, "="); ok {
+			fields[name] = value
+		}
+	}
+	return fields
+}
+
+var _ = Describe("audit records", func() {
+
+	It("decodes a CAPSET record", func() {
+		ac := Successful(FromAuditRecord(auditFields(
+			"type=CAPSET msg=audit(1700000000.000:42): pid=4242 " +
+				"cap_pi=0000000000000000 cap_pp=000001ffffffffff cap_pe=0000000000003000 cap_pa=0000000000000400")))
+		Expect(ac.Inheritable.Names()).To(BeEmpty())
+		Expect(ac.Permitted.Numbers()).To(HaveLen(CAP_CHECKPOINT_RESTORE + 1))
+		Expect(ac.Effective.Names()).To(ConsistOf("CAP_NET_ADMIN", "CAP_NET_RAW"))
+		Expect(ac.Ambient.Names()).To(ConsistOf("CAP_NET_BIND_SERVICE"))
+		Expect(ac.FilePermitted).To(BeNil())
+		Expect(ac.TaskCapabilities().Effective).To(Equal(ac.Effective))
+	})
+
+	It("decodes a PATH record", func() {
+		ac := Successful(FromAuditRecord(auditFields(
+			"type=PATH msg=audit(1700000000.000:43): item=0 name=\"/usr/bin/ping\" " +
+				"cap_fp=0000000000002000 cap_fi=0000000000000000 cap_fe=1 cap_fver=3 cap_frootid=100000")))
+		Expect(ac.FilePermitted.Names()).To(ConsistOf("CAP_NET_RAW"))
+		Expect(ac.FileInheritable.Names()).To(BeEmpty())
+		Expect(ac.FileEffective).To(BeTrue())
+		Expect(ac.FileVersion).To(Equal(uint32(3)))
+		Expect(ac.FileRootID).To(Equal(uint32(100000)))
+		Expect(ac.Effective).To(BeNil())
+	})
+
+	DescribeTable("rejects invalid fields",
+		func(name, value string) {
+			Expect(FromAuditRecord(map[string]string{name: value})).Error().To(
+				MatchError(ContainSubstring(name)))
+		},
+		Entry(nil, "cap_pe", "foo"),
+		Entry(nil, "cap_fp", "0"),
+		Entry(nil, "cap_fver", "x"),
+		Entry(nil, "cap_fe", "2"),
+		Entry(nil, "cap_frootid", "-1"),
+	)
+
+})
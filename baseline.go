@@ -0,0 +1,104 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProcessBaseline is the capabilities-related state of all threads of this
+// process before this package modified capabilities for the first time.
+type ProcessBaseline struct {
+	Captured time.Time     // when the baseline was captured
+	Threads  map[int]State // states of all threads, keyed by their TIDs
+}
+
+var (
+	baselineMu sync.Mutex // serializes capturing the baseline
+	baseline   atomic.Pointer[ProcessBaseline]
+)
+
+// Baseline returns the capabilities-related state of all threads of this
+// process as it was right before the first modification of capabilities
+// through this package, so that audit and restore features have a trustworthy
+// reference point. Baseline returns false if no capabilities have been
+// modified through this package yet, or if the baseline could not be captured
+// so far; in the latter case, the next modification tries again.
+func Baseline() (ProcessBaseline, bool) {
+	b := baseline.Load()
+	if b == nil {
+		return ProcessBaseline{}, false
+	}
+	threads := make(map[int]State, len(b.Threads))
+	for tid, state := range b.Threads {
+		threads[tid] = State{
+			TaskCapabilities: state.TaskCapabilities.Clone(),
+			Bounding:         state.Bounding.Clone(),
+			Ambient:          state.Ambient.Clone(),
+			Securebits:       state.Securebits,
+			NoNewPrivs:       state.NoNewPrivs,
+			NSpid:            append([]int(nil), state.NSpid...),
+			NStgid:           append([]int(nil), state.NStgid...),
+		}
+	}
+	return ProcessBaseline{Captured: b.Captured, Threads: threads}, true
+}
+
+// ensureBaseline captures the baseline of this process, unless it has been
+// captured before. It must be called before modifying capabilities. If the
+// baseline cannot be captured, the next modification tries again.
+func ensureBaseline() {
+	if baseline.Load() != nil {
+		return
+	}
+	baselineMu.Lock()
+	defer baselineMu.Unlock()
+	if baseline.Load() == nil {
+		baseline.Store(captureBaseline())
+	}
+}
+
+// captureBaseline returns the states of all threads of this process, or nil if
+// the threads cannot be determined. Threads terminating while capturing are
+// skipped. The threads are keyed by their task IDs in the caller's own PID
+// namespace, as returned by gettid(2), independent of [Config.ProcRoot].
+func captureBaseline() *ProcessBaseline {
+	entries, err := os.ReadDir(ownProcPath("self/task"))
+	if err != nil {
+		return nil
+	}
+	b := &ProcessBaseline{
+		Captured: time.Now(),
+		Threads:  make(map[int]State, len(entries)),
+	}
+	for _, entry := range entries {
+		tid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		state, err := ownStateOf(tid)
+		if err != nil {
+			continue
+		}
+		b.Threads[tid] = state
+	}
+	return b
+}
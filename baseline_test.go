@@ -0,0 +1,86 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"runtime"
+
+	"github.com/thediveo/caps/capstest"
+	"golang.org/x/sys/unix"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("process baseline", func() {
+
+	BeforeEach(func() {
+		cfg := CurrentConfig()
+		DeferCleanup(func() { Configure(cfg) })
+	})
+
+	It("captures all threads", func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		b := captureBaseline()
+		Expect(b).NotTo(BeNil())
+		Expect(b.Captured).NotTo(BeZero())
+		Expect(b.Threads).To(HaveKey(unix.Getpid()))
+		Expect(b.Threads).To(HaveKey(unix.Gettid()))
+		Expect(b.Threads[unix.Gettid()].Effective).To(Equal(Successful(OfThisTask()).Effective))
+	})
+
+	It("captures the threads of the own PID namespace", func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		Configure(Config{ProcRoot: GinkgoT().TempDir()})
+		b := captureBaseline()
+		Expect(b).NotTo(BeNil())
+		Expect(b.Threads).To(HaveKey(unix.Gettid()))
+	})
+
+	It("doesn't capture without threads", func() {
+		useProc(GinkgoT().TempDir())
+		Expect(captureBaseline()).To(BeNil())
+	})
+
+	It("returns the baseline after the first modification", func() {
+		capstest.RequirePermitted(GinkgoT(), CAP_CHOWN)
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			// Never unlock this thread, so that it gets thrown away when this
+			// Go routine finishes.
+			runtime.LockOSThread()
+			Expect(SetEffectiveCaps(CAP_CHOWN)).Error().NotTo(HaveOccurred())
+		}()
+		<-done
+		b, ok := Baseline()
+		Expect(ok).To(BeTrue())
+		Expect(b.Threads).To(HaveKey(unix.Getpid()))
+		Expect(b.Threads[unix.Getpid()].Permitted.Has(CAP_CHOWN)).To(BeTrue())
+
+		By("returning independent copies")
+		permitted := b.Threads[unix.Getpid()].Permitted
+		for idx := range permitted {
+			permitted[idx] = 0
+		}
+		again, _ := Baseline()
+		Expect(again.Threads[unix.Getpid()].Permitted.Has(CAP_CHOWN)).To(BeTrue())
+	})
+
+})
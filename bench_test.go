@@ -0,0 +1,160 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"runtime"
+	"testing"
+)
+
+func BenchmarkOfTask(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := OfThisTask(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSetForThisTask(b *testing.B) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	taskcaps, err := OfThisTask()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := SetForThisTask(taskcaps); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAddDrop(b *testing.B) {
+	caps := NewCapabilitiesSet()
+	caps.Add(MaxCapabilityNumber)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		caps.Add(CAP_NET_RAW, CAP_SYS_ADMIN)
+		caps.Drop(CAP_NET_RAW, CAP_SYS_ADMIN)
+	}
+}
+
+func BenchmarkHex(b *testing.B) {
+	caps := AllCapabilities()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = caps.Hex()
+	}
+}
+
+func BenchmarkCapabilitiesFromHex(b *testing.B) {
+	h := AllCapabilities().Hex()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := CapabilitiesFromHex(h); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNames(b *testing.B) {
+	caps := AllCapabilities()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = caps.Names()
+	}
+}
+
+func benchmarkSets() []CapabilitiesSet {
+	sets := make([]CapabilitiesSet, 10000)
+	for idx := range sets {
+		sets[idx] = NewCapabilitiesSet()
+		sets[idx].Add(idx%(MaxCapabilityNumber+1), (idx*7)%(MaxCapabilityNumber+1))
+	}
+	return sets
+}
+
+func BenchmarkAggregateUnion(b *testing.B) {
+	sets := benchmarkSets()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = AggregateUnion(sets)
+	}
+}
+
+func BenchmarkAggregateIntersection(b *testing.B) {
+	sets := benchmarkSets()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = AggregateIntersection(sets)
+	}
+}
+
+func BenchmarkIntern(b *testing.B) {
+	i := NewInterner()
+	caps := AllCapabilities()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_ = i.Intern(caps)
+	}
+}
+
+func BenchmarkStateOf(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		state, err := StateOf(0)
+		if err != nil {
+			b.Fatal(err)
+		}
+		state.Release()
+	}
+}
+
+func BenchmarkCache(b *testing.B) {
+	b.Run("hit", func(b *testing.B) {
+		cache := NewCache(0)
+		if _, err := cache.OfTask(0); err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := cache.OfTask(0); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("miss", func(b *testing.B) {
+		cache := NewCache(0)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			cache.InvalidateAll()
+			if _, err := cache.OfTask(0); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
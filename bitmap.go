@@ -0,0 +1,63 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// CapabilitiesFromBitmap decodes a capabilities set from its binary kernel
+// representation as found in binary kernel interfaces, such as in netlink
+// messages: consecutive 32-bit words with the least significant word first and
+// each word in the specified byte order, usually the host's byte order. The
+// length of the bitmap must be a multiple of 4, otherwise an error is returned
+// instead.
+//
+// Please note that neither the taskstats nor the proc connector netlink
+// interfaces expose capabilities; audit records expose them in textual form
+// only, see [FromAuditRecord].
+func CapabilitiesFromBitmap(b []byte, order binary.ByteOrder) (CapabilitiesSet, error) {
+	if len(b)%4 != 0 {
+		return nil, fmt.Errorf("invalid capabilities bitmap length %d, not a multiple of 4", len(b))
+	}
+	caps := make(CapabilitiesSet, len(b)/4)
+	for idx := range caps {
+		caps[idx] = order.Uint32(b[4*idx:])
+	}
+	return caps, nil
+}
+
+// CapabilitiesFromUint64 returns the capabilities set for the specified 64-bit
+// value, as used by the kernel's kernel_cap_t since Linux 6.3, and thus as
+// read by BPF-based monitoring from a task's credentials.
+func CapabilitiesFromUint64(v uint64) CapabilitiesSet {
+	return CapabilitiesSet{uint32(v), uint32(v >> 32)}
+}
+
+// Uint64 returns the capabilities set as a 64-bit value, as used by the
+// kernel's kernel_cap_t since Linux 6.3. Capabilities beyond 63 are ignored.
+func (c CapabilitiesSet) Uint64() uint64 {
+	var v uint64
+	for idx, w := range c {
+		if idx >= 2 {
+			break
+		}
+		v |= uint64(w) << (32 * idx)
+	}
+	return v
+}
@@ -0,0 +1,50 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"encoding/binary"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("binary capabilities bitmaps", func() {
+
+	It("decodes bitmaps", func() {
+		caps := Successful(CapabilitiesFromBitmap(
+			[]byte{0x00, 0x30, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00}, binary.LittleEndian))
+		Expect(caps.Names()).To(ConsistOf("CAP_NET_ADMIN", "CAP_NET_RAW", "CAP_CHECKPOINT_RESTORE"))
+
+		caps = Successful(CapabilitiesFromBitmap(
+			[]byte{0x00, 0x00, 0x30, 0x00, 0x00, 0x00, 0x01, 0x00}, binary.BigEndian))
+		Expect(caps.Names()).To(ConsistOf("CAP_NET_ADMIN", "CAP_NET_RAW", "CAP_CHECKPOINT_RESTORE"))
+
+		Expect(CapabilitiesFromBitmap(nil, binary.LittleEndian)).To(BeEmpty())
+		Expect(CapabilitiesFromBitmap([]byte{1, 2, 3}, binary.LittleEndian)).Error().To(HaveOccurred())
+	})
+
+	It("converts from and to 64-bit values", func() {
+		caps := CapabilitiesFromUint64(1<<CAP_NET_RAW | 1<<CAP_CHECKPOINT_RESTORE | 1<<63)
+		Expect(caps.Numbers()).To(ConsistOf(CAP_NET_RAW, CAP_CHECKPOINT_RESTORE, 63))
+		Expect(caps.Uint64()).To(Equal(uint64(1<<CAP_NET_RAW | 1<<CAP_CHECKPOINT_RESTORE | 1<<63)))
+
+		caps.Add(MaxCapabilityNumber + 64)
+		Expect(caps.Uint64()).To(Equal(uint64(1<<CAP_NET_RAW | 1<<CAP_CHECKPOINT_RESTORE | 1<<63)))
+		Expect(CapabilitiesSet(nil).Uint64()).To(BeZero())
+	})
+
+})
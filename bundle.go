@@ -0,0 +1,114 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// bundles maps the (uppercase) names of user-registered capability bundles
+// to their capabilities.
+var bundles = struct {
+	sync.RWMutex
+	m map[string]CapabilitiesSet
+}{m: map[string]CapabilitiesSet{}}
+
+// RegisterBundle registers a named bundle of capabilities, such as
+// "observability" for CAP_SYS_PTRACE, CAP_PERFMON, and CAP_BPF. Registered
+// bundles can then be used in capabilities expressions (see [EvalExpression])
+// and in policy evaluations (see [EvaluatePolicy]) alongside the built-in
+// “all” keyword, as well as in lists of capability names (see
+// [CapabilitiesFromNames], [TaskCapabilitiesFromText], and
+// [ParseSystemdCapabilities]). Bundle names are matched case-insensitively. As
+// libcap's textual representation uses “=”, “+”, and “-” as operators, bundle
+// names containing these characters cannot be used with
+// [TaskCapabilitiesFromText].
+//
+// RegisterBundle returns an error if the name is empty, contains commas,
+// whitespace, or a leading “+”, “-”, or “~”, clashes with a capability name
+// (with or without its “CAP_” prefix) or the “all” keyword, or if a bundle
+// with the same name has already been registered.
+func RegisterBundle(name string, capno int, morecapnos ...int) error {
+	if name == "" || strings.ContainsAny(name, ", \t\n") || strings.ContainsAny(name[:1], "+-~") {
+		return fmt.Errorf("invalid capabilities bundle name %q", name)
+	}
+	if strings.EqualFold(name, "all") {
+		return fmt.Errorf("capabilities bundle name %q clashes with built-in keyword", name)
+	}
+	if _, err := CapabilityByName(ociCapabilityName(name)); err == nil {
+		return fmt.Errorf("capabilities bundle name %q clashes with capability name", name)
+	}
+	caps := NewCapabilitiesSet()
+	caps.Add(capno, morecapnos...)
+	uname := strings.ToUpper(name)
+	bundles.Lock()
+	defer bundles.Unlock()
+	if _, ok := bundles.m[uname]; ok {
+		return fmt.Errorf("capabilities bundle %q already registered", name)
+	}
+	bundles.m[uname] = caps
+	return nil
+}
+
+// Bundle returns the capabilities of the registered bundle with the specified
+// name, and true; otherwise, it returns a nil set and false.
+func Bundle(name string) (CapabilitiesSet, bool) {
+	bundles.RLock()
+	defer bundles.RUnlock()
+	caps, ok := bundles.m[strings.ToUpper(name)]
+	return caps.Clone(), ok
+}
+
+// namedCapabilities returns the capability with the specified name as looked
+// up by byName, or otherwise the capabilities of the registered bundle with
+// this name. If there is neither, it returns the error of byName instead.
+func namedCapabilities(name string, byName func(string) (int, error)) (CapabilitiesSet, error) {
+	capno, err := byName(name)
+	if err != nil {
+		if bundle, ok := Bundle(name); ok {
+			return bundle, nil
+		}
+		return nil, err
+	}
+	caps := NewCapabilitiesSet()
+	caps.Add(capno)
+	return caps, nil
+}
+
+// Bundles returns the (uppercase) names of all registered bundles in
+// lexicographic order.
+func Bundles() []string {
+	bundles.RLock()
+	defer bundles.RUnlock()
+	names := make([]string, 0, len(bundles.m))
+	for name := range bundles.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// unregisterBundle removes the registered bundle with the specified name; it
+// is used in tests only.
+func unregisterBundle(name string) {
+	bundles.Lock()
+	defer bundles.Unlock()
+	delete(bundles.m, strings.ToUpper(name))
+}
@@ -0,0 +1,86 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("capabilities bundles", func() {
+
+	BeforeEach(func() {
+		Expect(RegisterBundle("observability", CAP_SYS_PTRACE, CAP_PERFMON, CAP_BPF)).To(Succeed())
+		DeferCleanup(func() { unregisterBundle("observability") })
+	})
+
+	It("registers bundles", func() {
+		Expect(Bundles()).To(ContainElement("OBSERVABILITY"))
+		caps, ok := Bundle("Observability")
+		Expect(ok).To(BeTrue())
+		Expect(caps.Names()).To(ConsistOf("CAP_SYS_PTRACE", "CAP_PERFMON", "CAP_BPF"))
+		caps.Drop(CAP_BPF)
+		caps, _ = Bundle("observability")
+		Expect(caps.Has(CAP_BPF)).To(BeTrue())
+		_, ok = Bundle("foobar")
+		Expect(ok).To(BeFalse())
+	})
+
+	DescribeTable("rejects invalid bundles",
+		func(name string) {
+			Expect(RegisterBundle(name, CAP_CHOWN)).NotTo(Succeed())
+		},
+		Entry("empty", ""),
+		Entry("comma", "foo,bar"),
+		Entry("whitespace", "foo bar"),
+		Entry("prefix", "-foo"),
+		Entry("all keyword", "ALL"),
+		Entry("capability name", "cap_chown"),
+		Entry("unprefixed capability name", "net_raw"),
+		Entry("already registered", "OBSERVABILITY"),
+	)
+
+	It("resolves bundles in expressions", func() {
+		caps := Successful(EvalExpression(nil, "observability,-CAP_BPF,+CAP_CHOWN"))
+		Expect(caps.Names()).To(ConsistOf("CAP_CHOWN", "CAP_SYS_PTRACE", "CAP_PERFMON"))
+	})
+
+	It("resolves bundles in lists of capability names", func() {
+		caps := Successful(CapabilitiesFromNames([]string{"CAP_CHOWN", "Observability"}))
+		Expect(caps.Names()).To(ConsistOf("CAP_CHOWN", "CAP_SYS_PTRACE", "CAP_PERFMON", "CAP_BPF"))
+		Expect(CapabilitiesFromNames([]string{"foobar"})).Error().To(HaveOccurred())
+
+		taskcaps := Successful(TaskCapabilitiesFromText("observability+ep cap_bpf-e"))
+		Expect(taskcaps.Effective.Names()).To(ConsistOf("CAP_SYS_PTRACE", "CAP_PERFMON"))
+		Expect(taskcaps.Permitted.Names()).To(ConsistOf("CAP_SYS_PTRACE", "CAP_PERFMON", "CAP_BPF"))
+		Expect(TaskCapabilitiesFromText("foobar+ep")).Error().To(HaveOccurred())
+
+		systemd := Successful(ParseSystemdCapabilities(
+			"AmbientCapabilities=observability\nCapabilityBoundingSet=~OBSERVABILITY CAP_CHOWN\n"))
+		Expect(systemd.Ambient.Names()).To(ConsistOf("CAP_SYS_PTRACE", "CAP_PERFMON", "CAP_BPF"))
+		for _, capno := range []int{CAP_SYS_PTRACE, CAP_PERFMON, CAP_BPF, CAP_CHOWN} {
+			Expect(systemd.Bounding.Has(capno)).To(BeFalse())
+		}
+		Expect(systemd.Bounding.Has(CAP_NET_RAW)).To(BeTrue())
+	})
+
+	It("resolves bundles in policies", func() {
+		policy := Policy{Allowed: Successful(EvalExpression(nil, "observability"))}
+		d := EvaluatePolicy(policy, OCICapabilities{Effective: []string{"OBSERVABILITY"}})
+		Expect(d.Allowed).To(BeTrue(), "%v", d.Reasons)
+	})
+
+})
@@ -0,0 +1,222 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/thediveo/caps/internal/diag"
+	"golang.org/x/sys/unix"
+)
+
+// Cache memoizes the capabilities of tasks, safe for concurrent use. Cached
+// task capabilities are identified not only by their task ID, but also by the
+// start time of the task, so that a task ID getting reused by a new task is
+// correctly detected and never returns stale capabilities.
+//
+// Entries of expired capabilities and of tasks that have terminated get swept
+// automatically whenever the number of cached entries has doubled since the
+// last sweep, so the cache doesn't grow without bounds when observing many
+// short-lived tasks. Use [Cache.Prune] to sweep explicitly.
+//
+// Please note that even a cache hit costs reading /proc/[tid]/stat of the
+// task in order to check its start time. This usually is considerably more
+// expensive than the capget(2) syscall a cache hit avoids (see the
+// BenchmarkCache and BenchmarkOfTask benchmarks), so a Cache doesn't speed up
+// querying capabilities, but instead returns the same capabilities of a task
+// until they expire.
+//
+// A Cache must be created using [NewCache].
+type Cache struct {
+	ttl     time.Duration
+	now     func() time.Time // for testing
+	mu      sync.RWMutex
+	entries map[int]cacheEntry
+	sweepAt int // number of entries triggering the next sweep
+}
+
+// minCacheSweep is the minimum number of cached entries before sweeping the
+// cache.
+const minCacheSweep = 256
+
+// cacheEntry is a cached set of task capabilities, together with the start
+// time of the task and the time when the capabilities were queried.
+type cacheEntry struct {
+	starttime uint64
+	fetched   time.Time
+	taskcaps  TaskCapabilities
+}
+
+// NewCache returns a new capabilities cache, where cached capabilities expire
+// after the specified TTL. If ttl isn't positive, cached capabilities never
+// expire but still are invalidated when task IDs get reused.
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: map[int]cacheEntry{},
+		sweepAt: minCacheSweep,
+	}
+}
+
+// OfTask returns the effective, permitted and inheritable capability sets for
+// the specified task, either from the cache or by querying the Linux kernel.
+// A task ID of zero refers to the current task, as with [OfTask]. If the sets
+// cannot be queried from the Linux kernel, then an error is returned instead
+// with a zero set of capabilities.
+func (c *Cache) OfTask(tid int) (TaskCapabilities, error) {
+	if tid == 0 {
+		tid = unix.Gettid()
+	}
+	starttime, err := taskStartTime(tid)
+	if err != nil {
+		diag.Log("skipping task without start time", "tid", tid, "error", err)
+		return TaskCapabilities{}, err
+	}
+	c.mu.RLock()
+	entry, ok := c.entries[tid]
+	c.mu.RUnlock()
+	if ok && entry.starttime == starttime &&
+		(c.ttl <= 0 || c.now().Sub(entry.fetched) < c.ttl) {
+		return entry.taskcaps.Clone(), nil
+	}
+
+	taskcaps, err := OfTask(tid)
+	if err != nil {
+		diag.Log("cannot query task capabilities", "tid", tid, "error", err)
+		return TaskCapabilities{}, err
+	}
+	// Make sure that the task ID hasn't been reused while we were busy
+	// querying the capabilities.
+	if st, err := taskStartTime(tid); err != nil || st != starttime {
+		c.Invalidate(tid)
+		diag.Log("skipping task that vanished or got reused while querying", "tid", tid)
+		return TaskCapabilities{}, syscall.ESRCH
+	}
+	c.mu.Lock()
+	c.entries[tid] = cacheEntry{
+		starttime: starttime,
+		fetched:   c.now(),
+		taskcaps:  taskcaps,
+	}
+	if len(c.entries) >= c.sweepAt {
+		c.sweep()
+	}
+	c.mu.Unlock()
+	return taskcaps.Clone(), nil
+}
+
+// OfTasks returns the effective, permitted and inheritable capability sets of
+// the specified tasks, indexed by task ID, as [Cache.OfTask] does. Tasks whose
+// capabilities cannot be queried, such as when they have terminated in the
+// meantime, are skipped instead of failing the whole query: OfTasks then
+// returns the capabilities of the remaining tasks together with a
+// [MultiError] of the skipped tasks' errors.
+func (c *Cache) OfTasks(tids ...int) (map[int]TaskCapabilities, error) {
+	taskcaps := make(map[int]TaskCapabilities, len(tids))
+	var errs MultiError
+	for _, tid := range tids {
+		caps, err := c.OfTask(tid)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("task %d: %w", tid, err))
+			continue
+		}
+		taskcaps[tid] = caps
+	}
+	return taskcaps, errs.errOrNil()
+}
+
+// Prune removes the cached capabilities that have expired, as well as the
+// cached capabilities of tasks that have terminated or whose task IDs got
+// reused in the meantime.
+func (c *Cache) Prune() {
+	c.mu.Lock()
+	c.sweep()
+	c.mu.Unlock()
+}
+
+// sweep removes expired and stale entries and then determines the number of
+// entries triggering the next sweep. The caller must hold the write lock.
+func (c *Cache) sweep() {
+	now := c.now()
+	for tid, entry := range c.entries {
+		if c.ttl > 0 && now.Sub(entry.fetched) >= c.ttl {
+			delete(c.entries, tid)
+			continue
+		}
+		if st, err := taskStartTime(tid); err != nil || st != entry.starttime {
+			delete(c.entries, tid)
+		}
+	}
+	c.sweepAt = 2 * len(c.entries)
+	if c.sweepAt < minCacheSweep {
+		c.sweepAt = minCacheSweep
+	}
+}
+
+// Invalidate removes the cached capabilities of the specified task, if any.
+func (c *Cache) Invalidate(tid int) {
+	c.mu.Lock()
+	delete(c.entries, tid)
+	c.mu.Unlock()
+}
+
+// InvalidateAll removes all cached task capabilities.
+func (c *Cache) InvalidateAll() {
+	c.mu.Lock()
+	c.entries = map[int]cacheEntry{}
+	c.sweepAt = minCacheSweep
+	c.mu.Unlock()
+}
+
+// taskStartTime returns the start time of the specified task in clock ticks
+// after system boot. The task ID is from the caller's own PID namespace.
+func taskStartTime(tid int) (uint64, error) {
+	stat, err := os.ReadFile(ownProcPath(strconv.Itoa(tid) + "/stat"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, syscall.ESRCH
+		}
+		return 0, err
+	}
+	return parseStartTime(string(stat))
+}
+
+// parseStartTime returns the start time from the contents of a
+// /proc/[pid]/stat file.
+func parseStartTime(stat string) (uint64, error) {
+	// The command name might contain spaces and even parentheses, so skip
+	// everything up to and including the final closing parenthesis. The
+	// remaining fields then start with field #3 (state), so the start time
+	// field #22 is at index 19.
+	commEnd := strings.LastIndexByte(stat, ')')
+	if commEnd < 0 {
+		return 0, errors.New("malformed task stat")
+	}
+	fields := strings.Fields(stat[commEnd+1:])
+	if len(fields) < 20 {
+		return 0, errors.New("malformed task stat")
+	}
+	return strconv.ParseUint(fields[19], 10, 64)
+}
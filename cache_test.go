@@ -0,0 +1,121 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"os"
+	"syscall"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("capabilities cache", func() {
+
+	It("parses task start times", func() {
+		Expect(parseStartTime("42 (foo) bar) S 1 42 42 0 -1 4194560 1 0 0 0 0 0 0 0 20 0 1 0 123456 0 0")).To(
+			Equal(uint64(123456)))
+		Expect(parseStartTime("42 foo")).Error().To(HaveOccurred())
+		Expect(parseStartTime("42 (foo) S 1 2 3")).Error().To(HaveOccurred())
+		Expect(parseStartTime("42 (foo) S 1 42 42 0 -1 4194560 1 0 0 0 0 0 0 0 20 0 1 0 abc 0 0")).Error().To(
+			HaveOccurred())
+	})
+
+	It("returns an error for non-existing tasks", func() {
+		cache := NewCache(0)
+		Expect(cache.OfTask(-1)).Error().To(MatchError(syscall.ESRCH))
+	})
+
+	It("skips tasks that cannot be queried", func() {
+		cache := NewCache(0)
+		pid := os.Getpid()
+		taskcaps, err := cache.OfTasks(pid, -1)
+		Expect(err).To(MatchError(syscall.ESRCH))
+		Expect(err).To(BeAssignableToTypeOf(MultiError{}))
+		Expect(err.(MultiError)).To(HaveLen(1))
+		Expect(taskcaps).To(HaveLen(1))
+		Expect(taskcaps[pid]).To(Equal(Successful(OfTask(pid))))
+
+		Expect(cache.OfTasks(pid)).To(HaveKey(pid))
+	})
+
+	It("caches task capabilities", func() {
+		now := time.Now()
+		cache := NewCache(time.Minute)
+		cache.now = func() time.Time { return now }
+
+		pid := os.Getpid()
+		taskcaps := Successful(cache.OfTask(pid))
+		Expect(taskcaps).To(Equal(Successful(OfTask(pid))))
+		Expect(cache.entries).To(HaveKey(pid))
+
+		By("returning independent cached task capabilities")
+		taskcaps.Effective.Add(MaxCapabilityNumber + 1)
+		Expect(Successful(cache.OfTask(pid)).Effective.Has(MaxCapabilityNumber + 1)).To(BeFalse())
+
+		By("detecting task ID reuse")
+		entry := cache.entries[pid]
+		entry.taskcaps.Effective = CapabilitiesSet{0x42}
+		entry.starttime++
+		cache.entries[pid] = entry
+		Expect(Successful(cache.OfTask(pid))).To(Equal(Successful(OfTask(pid))))
+
+		By("expiring cached task capabilities")
+		entry = cache.entries[pid]
+		entry.taskcaps.Effective = CapabilitiesSet{0x42}
+		cache.entries[pid] = entry
+		Expect(Successful(cache.OfTask(pid)).Effective).To(Equal(CapabilitiesSet{0x42}))
+		now = now.Add(time.Minute)
+		Expect(Successful(cache.OfTask(pid))).To(Equal(Successful(OfTask(pid))))
+
+		By("invalidating cached task capabilities")
+		Expect(Successful(cache.OfTask(0))).NotTo(BeZero())
+		Expect(cache.entries).NotTo(BeEmpty())
+		cache.Invalidate(pid)
+		Expect(cache.entries).NotTo(HaveKey(pid))
+		cache.InvalidateAll()
+		Expect(cache.entries).To(BeEmpty())
+	})
+
+	It("prunes expired and stale entries", func() {
+		now := time.Now()
+		cache := NewCache(time.Minute)
+		cache.now = func() time.Time { return now }
+
+		pid := os.Getpid()
+		Expect(cache.OfTask(pid)).Error().NotTo(HaveOccurred())
+		cache.entries[-1] = cacheEntry{starttime: 42, fetched: now}
+		cache.Prune()
+		Expect(cache.entries).To(HaveLen(1))
+		Expect(cache.entries).To(HaveKey(pid))
+
+		now = now.Add(time.Minute)
+		cache.Prune()
+		Expect(cache.entries).To(BeEmpty())
+	})
+
+	It("sweeps automatically when growing", func() {
+		cache := NewCache(0)
+		for tid := -minCacheSweep; tid < 0; tid++ {
+			cache.entries[tid] = cacheEntry{starttime: 42}
+		}
+		Expect(cache.OfTask(0)).Error().NotTo(HaveOccurred())
+		Expect(cache.entries).To(HaveLen(1))
+		Expect(cache.sweepAt).To(Equal(minCacheSweep))
+	})
+
+})
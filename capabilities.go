@@ -341,6 +341,12 @@ var CapabilityNameByNumber = map[int]string{
 	40: "CAP_CHECKPOINT_RESTORE",
 }
 
+const (
+	LINUX_CAPABILITY_VERSION_1 = 0x19980330
+	LINUX_CAPABILITY_VERSION_2 = 0x20071026
+	LINUX_CAPABILITY_VERSION_3 = 0x20080522
+)
+
 const (
 	LINUX_CAPABILITY_U32S_1 = 1
 	LINUX_CAPABILITY_U32S_2 = 2
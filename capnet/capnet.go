@@ -0,0 +1,58 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package capnet
+
+import (
+	"net"
+	"os"
+
+	"github.com/thediveo/caps"
+	"golang.org/x/sys/unix"
+)
+
+// NewRawSocket returns a new raw socket for the specified domain, such as
+// unix.AF_INET, and protocol, such as unix.IPPROTO_ICMP, raising CAP_NET_RAW
+// only while creating the socket. The socket is close-on-exec.
+func NewRawSocket(domain, proto int) (*os.File, error) {
+	var fd int
+	err := caps.Raised(func() (err error) {
+		fd, err = unix.Socket(domain, unix.SOCK_RAW|unix.SOCK_CLOEXEC, proto)
+		if err != nil {
+			return os.NewSyscallError("socket", err)
+		}
+		return nil
+	}, caps.CAP_NET_RAW)
+	if err != nil {
+		return nil, err
+	}
+	return os.NewFile(uintptr(fd), "raw-socket"), nil
+}
+
+// ListenPrivilegedPort announces on the specified local network address, such
+// as ":80", raising CAP_NET_BIND_SERVICE only while binding the listener. See
+// [net.Listen] for the supported networks and addresses.
+func ListenPrivilegedPort(network, addr string) (net.Listener, error) {
+	var l net.Listener
+	err := caps.Raised(func() (err error) {
+		l, err = net.Listen(network, addr)
+		return err
+	}, caps.CAP_NET_BIND_SERVICE)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
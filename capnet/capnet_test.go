@@ -0,0 +1,60 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package capnet
+
+import (
+	"errors"
+	"syscall"
+
+	"github.com/thediveo/caps/capstest"
+	"golang.org/x/sys/unix"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("network building blocks", func() {
+
+	It("creates raw sockets", func() {
+		capstest.RequirePermitted(GinkgoT(), unix.CAP_NET_RAW)
+		f := Successful(NewRawSocket(unix.AF_INET, unix.IPPROTO_ICMP))
+		defer f.Close()
+		typ := Successful(unix.GetsockoptInt(int(f.Fd()), unix.SOL_SOCKET, unix.SO_TYPE))
+		Expect(typ).To(Equal(unix.SOCK_RAW))
+
+		Expect(NewRawSocket(-1, 0)).Error().To(HaveOccurred())
+	})
+
+	It("listens on privileged ports", func() {
+		capstest.RequirePermitted(GinkgoT(), unix.CAP_NET_BIND_SERVICE)
+		for _, addr := range []string{"127.0.0.1:999", "127.0.0.1:998", "127.0.0.1:997"} {
+			l, err := ListenPrivilegedPort("tcp", addr)
+			if errors.Is(err, syscall.EADDRINUSE) {
+				continue
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(l.Addr().String()).To(Equal(addr))
+			Expect(l.Close()).To(Succeed())
+			return
+		}
+		Skip("no free privileged port")
+	})
+
+	It("reports listen errors", func() {
+		Expect(ListenPrivilegedPort("foo", ":80")).Error().To(HaveOccurred())
+	})
+
+})
@@ -0,0 +1,12 @@
+/*
+Package capnet provides network building blocks that need capabilities, such
+as raw sockets needing CAP_NET_RAW and listening on privileged ports needing
+CAP_NET_BIND_SERVICE. The capabilities are raised only while creating the
+socket, on a dedicated, throw-away OS-level thread, see also [caps.Raised]. As
+sockets belong to the process as a whole, they can afterwards be used from any
+Go routine without any capabilities.
+
+The capabilities must be in the permitted set of the calling thread, but
+don't need to be in the effective set.
+*/
+package capnet
@@ -0,0 +1,14 @@
+/*
+Package capshttp provides net/http middleware that runs selected handlers with
+raised effective capabilities, such as admin endpoints needing CAP_NET_ADMIN,
+while all other handlers keep running unprivileged.
+
+The intended setup is that the process drops the capabilities in question from
+the effective sets of all its threads at startup, while keeping them in the
+permitted sets. The wrapped handlers then get the capabilities raised only for
+the duration of a single request, on a dedicated OS-level thread that is
+thrown away afterwards, see also [caps.Raised]:
+
+	mux.Handle("/admin/links", capshttp.Handler(linksHandler, caps.CAP_NET_ADMIN))
+*/
+package capshttp
@@ -0,0 +1,47 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package capshttp
+
+import (
+	"net/http"
+
+	"github.com/thediveo/caps"
+	"github.com/thediveo/caps/internal/diag"
+)
+
+// Handler returns an http.Handler that serves requests using the specified
+// handler with the specified capabilities raised in the effective set. If the
+// capabilities cannot be raised, Handler responds with status code 500
+// (internal server error) without calling the wrapped handler.
+//
+// The wrapped handler runs on a separate, throw-away OS-level thread, so it
+// must not spawn Go routines relying on the raised capabilities.
+func Handler(h http.Handler, capno int, morecapnos ...int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called := false
+		err := caps.Raised(func() error {
+			called = true
+			h.ServeHTTP(w, r)
+			return nil
+		}, capno, morecapnos...)
+		if err != nil && !called {
+			diag.Log("cannot raise capabilities for HTTP handler",
+				"path", r.URL.Path, "error", err)
+			http.Error(w, "cannot raise capabilities", http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package capshttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/thediveo/caps"
+	"github.com/thediveo/caps/capstest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("HTTP handlers with raised capabilities", func() {
+
+	It("serves with raised capabilities", func() {
+		capstest.RequirePermitted(GinkgoT(), caps.CAP_NET_ADMIN)
+		h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !Successful(caps.OfThisTask()).Effective.Has(caps.CAP_NET_ADMIN) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.WriteHeader(http.StatusTeapot)
+		}), caps.CAP_NET_ADMIN)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		Expect(rec.Code).To(Equal(http.StatusTeapot))
+	})
+
+	It("fails when capabilities cannot be raised", func() {
+		h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}), 63)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		Expect(rec.Code).To(Equal(http.StatusInternalServerError))
+	})
+
+})
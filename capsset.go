@@ -19,6 +19,7 @@ package caps
 import (
 	"encoding/hex"
 	"fmt"
+	"math/bits"
 	"strconv"
 	"strings"
 	"unicode"
@@ -42,7 +43,7 @@ func NewCapabilitiesSet() CapabilitiesSet {
 // AllCapabilities returns a new set with all capabilities that the kernel
 // supports we're currently running on.
 func AllCapabilities() CapabilitiesSet {
-	maxindex, maxbitno := wordBitIndices(lastCapability)
+	maxindex, maxbitno := wordBitIndices(LastCapability())
 	c := make(CapabilitiesSet, maxindex+1)
 	for idx := 0; idx < maxindex; idx++ {
 		c[idx] = ^uint32(0)
@@ -65,23 +66,51 @@ func (c *CapabilitiesSet) Clear() {
 
 // Add (set) one or more effective capabilities identified by their numbers to a set.
 func (c *CapabilitiesSet) Add(capno int, morecapnos ...int) {
-	capnos := append([]int{capno}, morecapnos...)
-	for _, capno := range capnos {
-		wordindex, bitno := wordBitIndices(capno)
-		c.ensure(wordindex)
-		(*c)[wordindex] |= uint32(1) << bitno
+	c.add(capno)
+	for _, capno := range morecapnos {
+		c.add(capno)
 	}
 }
 
+// add (set) a single capability identified by its number to a set.
+func (c *CapabilitiesSet) add(capno int) {
+	wordindex, bitno := wordBitIndices(capno)
+	c.ensure(wordindex)
+	(*c)[wordindex] |= uint32(1) << bitno
+}
+
 // Drop (remove) one or more capabilities identified by their numbers to a set.
 func (c *CapabilitiesSet) Drop(capno int, morecapnos ...int) {
-	capnos := append([]int{capno}, morecapnos...)
-	for _, capno := range capnos {
-		wordindex, bitno := wordBitIndices(capno)
-		if wordindex >= len(*c) {
-			continue // no need to expand if the cap isn't in the set anyway.
+	c.drop(capno)
+	for _, capno := range morecapnos {
+		c.drop(capno)
+	}
+}
+
+// drop (remove) a single capability identified by its number from a set.
+func (c *CapabilitiesSet) drop(capno int) {
+	wordindex, bitno := wordBitIndices(capno)
+	if wordindex >= len(*c) {
+		return // no need to expand if the cap isn't in the set anyway.
+	}
+	(*c)[wordindex] &= ^(uint32(1) << bitno)
+}
+
+// addSet adds all capabilities in the specified set to this set.
+func (c *CapabilitiesSet) addSet(other CapabilitiesSet) {
+	for idx, w := range other {
+		c.ensure(idx)
+		(*c)[idx] |= w
+	}
+}
+
+// dropSet drops all capabilities in the specified set from this set.
+func (c *CapabilitiesSet) dropSet(other CapabilitiesSet) {
+	for idx, w := range other {
+		if idx >= len(*c) {
+			return
 		}
-		(*c)[wordindex] &= ^(uint32(1) << bitno)
+		(*c)[idx] &^= w
 	}
 }
 
@@ -95,31 +124,140 @@ func (c CapabilitiesSet) Has(capno int) bool {
 	return c[wordindex]&(uint32(1)<<bitno) != 0
 }
 
+// AggregateUnion returns a new set with the union of all specified sets, that
+// is, with the capabilities found in any of the sets. The sets are combined
+// word-wise, allocating only the resulting set.
+func AggregateUnion(sets []CapabilitiesSet) CapabilitiesSet {
+	size := 0
+	for _, c := range sets {
+		if len(c) > size {
+			size = len(c)
+		}
+	}
+	u := make(CapabilitiesSet, size)
+	for _, c := range sets {
+		u := u[:len(c)] // hint to the compiler that indices are in bounds.
+		for idx, w := range c {
+			u[idx] |= w
+		}
+	}
+	return u
+}
+
+// AggregateIntersection returns a new set with the intersection of all
+// specified sets, that is, with only those capabilities found in all the sets.
+// The intersection of no sets at all is the empty set. The sets are combined
+// word-wise, allocating only the resulting set.
+func AggregateIntersection(sets []CapabilitiesSet) CapabilitiesSet {
+	if len(sets) == 0 {
+		return NewCapabilitiesSet()
+	}
+	size := len(sets[0])
+	for _, c := range sets[1:] {
+		if len(c) < size {
+			size = len(c)
+		}
+	}
+	i := make(CapabilitiesSet, size)
+	copy(i, sets[0])
+	for _, c := range sets[1:] {
+		c := c[:size]
+		for idx, w := range c {
+			i[idx] &= w
+		}
+	}
+	return i
+}
+
 // Names returns the names of the capabilities in this set, sorted by increasing
 // bit number.
 func (c CapabilitiesSet) Names() []string {
-	names := []string{}
+	count := 0
+	for _, w := range c {
+		count += bits.OnesCount32(w)
+	}
+	names := make([]string, 0, count)
 	for idx, w := range c {
 		for bit := 0; bit <= 31; bit++ {
 			if w&(uint32(1)<<bit) != 0 {
-				capno := idx*32 + bit
-				name := CapabilityNameByNumber[capno]
-				if name == "" {
-					name = "CAP_" + strconv.Itoa(capno)
-				}
-				names = append(names, name)
+				names = append(names, capabilityName(idx*32+bit))
 			}
 		}
 	}
 	return names
 }
 
+// Numbers returns the numbers of the capabilities in this set, sorted by
+// increasing capability number.
+func (c CapabilitiesSet) Numbers() []int {
+	count := 0
+	for _, w := range c {
+		count += bits.OnesCount32(w)
+	}
+	capnos := make([]int, 0, count)
+	for idx, w := range c {
+		for bit := 0; bit <= 31; bit++ {
+			if w&(uint32(1)<<bit) != 0 {
+				capnos = append(capnos, idx*32+bit)
+			}
+		}
+	}
+	return capnos
+}
+
+// capabilityName returns the name of the capability with the specified
+// number, or an "anonymous" name in the form of "CAP_ddd" for capabilities
+// unknown to this package.
+func capabilityName(capno int) string {
+	if name, ok := CapabilityNameByNumber[capno]; ok {
+		return name
+	}
+	return "CAP_" + strconv.Itoa(capno)
+}
+
+// NamesByNumberDesc returns the names of the capabilities in this set, sorted
+// by decreasing bit number.
+func (c CapabilitiesSet) NamesByNumberDesc() []string {
+	names := c.Names()
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	return names
+}
+
+// NameOrder specifies how [CapabilitiesSet.SortedNamesBy] and
+// [CapabilitiesSet.StringOrdered] order capability names.
+type NameOrder int
+
+const (
+	// LexicographicOrder orders capability names lexicographically, but with
+	// "anonymous" capabilities (CAP_ddd) always sorted last. Anonymous
+	// capabilities are ordered lexicographically too, so CAP_100 comes before
+	// CAP_99.
+	LexicographicOrder NameOrder = iota
+	// NumericAnonymousOrder orders capability names lexicographically, but
+	// with "anonymous" capabilities (CAP_ddd) always sorted last and ordered
+	// by their capability numbers, so CAP_99 comes before CAP_100.
+	NumericAnonymousOrder
+)
+
 // SortedNames returns the names of the capabilities in this set in
 // lexicographic order, but with "anonymous" capabilities (CAP_ddd) always
 // sorted last.
 func (c CapabilitiesSet) SortedNames() []string {
+	return c.SortedNamesBy(LexicographicOrder)
+}
+
+// SortedNamesBy returns the names of the capabilities in this set in
+// lexicographic order, with "anonymous" capabilities (CAP_ddd) always sorted
+// last, ordering the anonymous capabilities as specified.
+func (c CapabilitiesSet) SortedNamesBy(order NameOrder) []string {
 	names := c.Names()
-	slices.SortFunc(names, cmpCapName)
+	if order == NumericAnonymousOrder {
+		slices.SortFunc(names, cmpCapNameNumeric)
+	} else {
+		slices.SortFunc(names, cmpCapName)
+	}
 	return names
 }
 
@@ -141,6 +279,17 @@ func cmpCapName(a, b string) int {
 	return strings.Compare(a, b)
 }
 
+// cmpCapNameNumeric orders capability names lexicographically, but with
+// "anonymous" capability names coming only after all known capability names,
+// and ordered by their numbers.
+func cmpCapNameNumeric(a, b string) int {
+	if isAnonymousCapability(a) && isAnonymousCapability(b) && len(a) != len(b) {
+		// without leading zeros, shorter numbers are smaller numbers.
+		return len(a) - len(b)
+	}
+	return cmpCapName(a, b)
+}
+
 // isAnonymousCapability returns true if the specified (uppercase) capability
 // name is an unknown capability in the form of "CAP_" followed only by digits.
 func isAnonymousCapability(name string) bool {
@@ -163,39 +312,122 @@ func (c CapabilitiesSet) String() string {
 	return strings.Join(names, ", ")
 }
 
+// StringOrdered returns a textual representation of the capabilities in this
+// set, sorted by capability (symbol) names in the specified order.
+func (c CapabilitiesSet) StringOrdered(order NameOrder) string {
+	return strings.Join(c.SortedNamesBy(order), ", ")
+}
+
 // Hex returns the hexadecimal representation of this capabilities set.
 func (c CapabilitiesSet) Hex() string {
-	h := ""
 	size := capDataElements
 	if l := len(c); l > size {
 		size = l
 	}
-	for idx := size - 1; idx >= 0; idx-- {
+	const hexdigits = "0123456789abcdef"
+	h := make([]byte, 8*size)
+	for idx := 0; idx < size; idx++ {
 		v := uint32(0)
 		if idx < len(c) {
 			v = c[idx]
 		}
-		h = h + fmt.Sprintf("%08x", v)
+		// the highest word comes first in the textual representation.
+		offset := 8 * (size - 1 - idx)
+		for pos := offset + 7; pos >= offset; pos-- {
+			h[pos] = hexdigits[v&0xf]
+			v >>= 4
+		}
+	}
+	return string(h)
+}
+
+// WordsBigEndianFirst returns a copy of the words of this capabilities set in
+// the order of the most significant word first, as used by textual
+// representations such as [CapabilitiesSet.Hex] and the capability fields in
+// /proc/[pid]/status.
+func (c CapabilitiesSet) WordsBigEndianFirst() []uint32 {
+	words := make([]uint32, len(c))
+	for idx, w := range c {
+		words[len(c)-1-idx] = w
 	}
-	return h
+	return words
+}
+
+// CapabilitiesFromWordsBigEndianFirst returns a new capabilities set from the
+// specified words in the order of the most significant word first, as
+// returned by [CapabilitiesSet.WordsBigEndianFirst].
+func CapabilitiesFromWordsBigEndianFirst(words []uint32) CapabilitiesSet {
+	c := make(CapabilitiesSet, len(words))
+	for idx, w := range words {
+		c[len(words)-1-idx] = w
+	}
+	return c
 }
 
 // CapabilitiesFromHex parses the given hexadecimal string into a capabilities
 // set. If the string representation is invalid then an error is returned
 // instead, together with a zero capabilities set.
 func CapabilitiesFromHex(h string) (CapabilitiesSet, error) {
-	b, err := hex.DecodeString(h)
-	if err != nil {
-		return nil, err
-	}
-	b = append([]byte{0x00, 0x00, 0x00}[:(4-len(b)&3)&3], b...)
-	c := CapabilitiesSet(make([]uint32, 0, len(b)>>2))
-	for idx := len(b) - 4; idx >= 0; idx -= 4 {
-		c = append(c,
-			(uint32(b[idx])<<24)+
-				(uint32(b[idx+1])<<16)+
-				(uint32(b[idx+2])<<8)+
-				uint32(b[idx+3]))
+	return capabilitiesFromHexInto(make(CapabilitiesSet, 0, (len(h)+7)/8), h)
+}
+
+// capabilitiesFromHexInto parses the given hexadecimal string into the
+// specified capabilities set, reusing its capacity where possible, and returns
+// the resulting set. If the string representation is invalid then an error is
+// returned instead, together with a zero capabilities set.
+func capabilitiesFromHexInto(c CapabilitiesSet, h string) (CapabilitiesSet, error) {
+	for idx := 0; idx < len(h); idx++ {
+		if _, ok := fromHexChar(h[idx]); !ok {
+			return nil, hex.InvalidByteError(h[idx])
+		}
+	}
+	if len(h)%2 != 0 {
+		return nil, hex.ErrLength
+	}
+	c = c[:0]
+	// the highest word comes first in the textual representation.
+	for end := len(h); end > 0; end -= 8 {
+		start := end - 8
+		if start < 0 {
+			start = 0
+		}
+		var w uint32
+		for idx := start; idx < end; idx++ {
+			nibble, _ := fromHexChar(h[idx])
+			w = w<<4 | uint32(nibble)
+		}
+		c = append(c, w)
+	}
+	return c, nil
+}
+
+// fromHexChar converts a hex character into its value and a success flag.
+func fromHexChar(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// CapabilitiesFromNames returns a new capabilities set with the capabilities
+// named in names, which is the inverse operation of [CapabilitiesSet.Names].
+// Names are matched case-insensitively and might be anonymous capability names
+// in the form of "CAP_42", or the names of registered bundles (see
+// [RegisterBundle]). If any name is unknown, an error is returned instead,
+// together with a zero capabilities set.
+func CapabilitiesFromNames(names []string) (CapabilitiesSet, error) {
+	c := NewCapabilitiesSet()
+	for _, name := range names {
+		caps, err := namedCapabilities(name, CapabilityByName)
+		if err != nil {
+			return nil, err
+		}
+		c.addSet(caps)
 	}
 	return c, nil
 }
@@ -209,6 +441,16 @@ func wordBitIndices(capno int) (wordindex, bitno int) {
 	return capno >> 5, capno & 31
 }
 
+// returns the capabilities set without any trailing zero words; the returned
+// set shares its underlying array with the original set.
+func (c CapabilitiesSet) normalized() CapabilitiesSet {
+	l := len(c)
+	for l > 0 && c[l-1] == 0 {
+		l--
+	}
+	return c[:l]
+}
+
 // ensures that are enough elements up to and including the element at
 // wordoffset.
 func (c *CapabilitiesSet) ensure(wordindex int) {
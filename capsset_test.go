@@ -54,6 +54,19 @@ var _ = Describe("capabilities sets", func() {
 		Entry(nil, "CAP_100", "CAP_99", -1), // sic!
 	)
 
+	DescribeTable("sorting anonymous capabilities numerically",
+		func(a, b string, order int) {
+			Expect(cmpCapNameNumeric(a, b)).To(BeNumerically("==", order))
+		},
+		Entry(nil, "CAP_FOO_BAR", "CAP_ZOO", -1),
+		Entry(nil, "CAP_42", "CAP_FOO", 1),
+		Entry(nil, "CAP_FOO", "CAP_100", -1),
+		Entry(nil, "CAP_42", "CAP_88", -1),
+		Entry(nil, "CAP_42", "CAP_42", 0),
+		Entry(nil, "CAP_100", "CAP_99", 1),
+		Entry(nil, "CAP_9", "CAP_10", -1),
+	)
+
 	It("sets all capabilities", func() {
 		max := LastCapability()
 		Expect(max).NotTo(BeZero())
@@ -111,6 +124,32 @@ var _ = Describe("capabilities sets", func() {
 		Expect(capsclone).NotTo(Equal(caps))
 	})
 
+	It("aggregates unions", func() {
+		Expect(AggregateUnion(nil)).To(BeEmpty())
+		Expect(AggregateUnion([]CapabilitiesSet{
+			{0x1},
+			{0x2, 0x80},
+			nil,
+			{0x4, 0, 0},
+		})).To(Equal(CapabilitiesSet{0x7, 0x80, 0}))
+	})
+
+	It("aggregates intersections", func() {
+		Expect(AggregateIntersection(nil)).To(BeEmpty())
+		Expect(AggregateIntersection([]CapabilitiesSet{
+			{0x3, 0x80},
+		})).To(Equal(CapabilitiesSet{0x3, 0x80}))
+		Expect(AggregateIntersection([]CapabilitiesSet{
+			{0x3, 0x80},
+			{0x6, 0x80, 0x1},
+			{0x7, 0xff},
+		})).To(Equal(CapabilitiesSet{0x2, 0x80}))
+		Expect(AggregateIntersection([]CapabilitiesSet{
+			{0x3, 0x80},
+			{},
+		})).To(BeEmpty())
+	})
+
 	It("returns capability names set ordered by capability number", func() {
 		caps := NewCapabilitiesSet()
 		caps.Add(CAP_SYS_ADMIN, CAP_SYS_CHROOT, MaxCapabilityNumber+1)
@@ -119,6 +158,25 @@ var _ = Describe("capabilities sets", func() {
 		}))
 	})
 
+	It("returns capability names ordered by descending capability number", func() {
+		caps := NewCapabilitiesSet()
+		Expect(caps.NamesByNumberDesc()).To(BeEmpty())
+		caps.Add(CAP_SYS_ADMIN, CAP_CHOWN, CAP_BPF)
+		Expect(caps.NamesByNumberDesc()).To(Equal([]string{
+			"CAP_BPF", "CAP_SYS_ADMIN", "CAP_CHOWN",
+		}))
+	})
+
+	It("converts to and from most significant word first order", func() {
+		caps := CapabilitiesSet{0x1, 0x2, 0x3}
+		words := caps.WordsBigEndianFirst()
+		Expect(words).To(Equal([]uint32{0x3, 0x2, 0x1}))
+		words[0] = 0x42
+		Expect(caps).To(Equal(CapabilitiesSet{0x1, 0x2, 0x3}))
+		Expect(CapabilitiesFromWordsBigEndianFirst([]uint32{0x3, 0x2, 0x1})).To(Equal(caps))
+		Expect(CapabilitiesFromWordsBigEndianFirst(nil)).To(BeEmpty())
+	})
+
 	It("returns a lexicographically sorted list of capability names", func() {
 		caps := NewCapabilitiesSet()
 		caps.Add(CAP_NET_ADMIN, CAP_SYS_ADMIN, CAP_SYS_CHROOT)
@@ -132,6 +190,25 @@ var _ = Describe("capabilities sets", func() {
 			fmt.Sprintf("CAP_%d", MaxCapabilityNumber+1)))
 	})
 
+	It("returns lists of capability names with anonymous capabilities ordered numerically", func() {
+		caps := NewCapabilitiesSet()
+		caps.Add(CAP_SYS_ADMIN, 100, 63, CAP_CHOWN)
+		Expect(caps.SortedNames()).To(Equal([]string{
+			"CAP_CHOWN", "CAP_SYS_ADMIN", "CAP_100", "CAP_63"}))
+		Expect(caps.SortedNamesBy(LexicographicOrder)).To(Equal(caps.SortedNames()))
+		Expect(caps.SortedNamesBy(NumericAnonymousOrder)).To(Equal([]string{
+			"CAP_CHOWN", "CAP_SYS_ADMIN", "CAP_63", "CAP_100"}))
+		Expect(caps.StringOrdered(NumericAnonymousOrder)).To(Equal(
+			"CAP_CHOWN, CAP_SYS_ADMIN, CAP_63, CAP_100"))
+	})
+
+	It("returns capability numbers", func() {
+		caps := NewCapabilitiesSet()
+		Expect(caps.Numbers()).To(BeEmpty())
+		caps.Add(CAP_SYS_ADMIN, 63, CAP_CHOWN)
+		Expect(caps.Numbers()).To(Equal([]int{CAP_CHOWN, CAP_SYS_ADMIN, 63}))
+	})
+
 	It("returns correct hexadecimal representation", func() {
 		Expect(CapabilitiesSet{}.Hex()).To(
 			Equal(strings.Repeat("00000000", capDataElements)))
@@ -151,6 +228,24 @@ var _ = Describe("capabilities sets", func() {
 		Expect(caps).To(Equal(CapabilitiesSet{0x80002001, 0x11}))
 	})
 
+	It("returns a set from capability names", func() {
+		caps := Successful(CapabilitiesFromNames([]string{"CAP_SYS_ADMIN", "cap_bpf", "CAP_63"}))
+		Expect(caps.Names()).To(Equal([]string{"CAP_SYS_ADMIN", "CAP_BPF", "CAP_63"}))
+		Expect(Successful(CapabilitiesFromNames(nil))).To(BeEmpty())
+		Expect(CapabilitiesFromNames([]string{"CAP_CHOWN", "CAP_FOOBAR"})).Error().To(HaveOccurred())
+
+		caps = NewCapabilitiesSet()
+		caps.Add(CAP_CHOWN, CAP_NET_RAW, MaxCapabilityNumber+1)
+		Expect(Successful(CapabilitiesFromNames(caps.Names()))).To(Equal(caps))
+	})
+
+	It("round-trips names of anonymous capabilities beyond 64 bits", func() {
+		caps := NewCapabilitiesSet()
+		caps.Add(CAP_CHOWN, 63, 64, 100, 1000)
+		Expect(caps.Names()).To(ContainElements("CAP_64", "CAP_100", "CAP_1000"))
+		Expect(Successful(CapabilitiesFromNames(caps.Names()))).To(Equal(caps))
+	})
+
 	It("returns errors for invalid hexadecimal capability set representations", func() {
 		Expect(CapabilitiesFromHex("0")).Error().To(HaveOccurred())
 		Expect(CapabilitiesFromHex("abcdefg")).Error().To(HaveOccurred())
@@ -0,0 +1,85 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// CapabilityStatus tells whether a capability is considered a well-defined
+// privilege, or rather a catch-all or meaningless one that policies shouldn't
+// grant.
+type CapabilityStatus int
+
+// Statuses of capabilities.
+const (
+	StatusUnknown    CapabilityStatus = iota // not a capability known to this package
+	StatusRegular                            // well-defined privilege
+	StatusOverloaded                         // catch-all for many unrelated privileges
+	StatusUnused                             // not checked by the kernel at all
+)
+
+var capabilityStatusNames = [...]string{
+	StatusUnknown:    "unknown",
+	StatusRegular:    "regular",
+	StatusOverloaded: "overloaded",
+	StatusUnused:     "unused",
+}
+
+// String returns the name of the status, such as "overloaded".
+func (s CapabilityStatus) String() string {
+	if s >= 0 && int(s) < len(capabilityStatusNames) {
+		return capabilityStatusNames[s]
+	}
+	return "CapabilityStatus(" + strconv.Itoa(int(s)) + ")"
+}
+
+// MarshalText returns the name of the status.
+func (s CapabilityStatus) MarshalText() ([]byte, error) {
+	if s < 0 || int(s) >= len(capabilityStatusNames) {
+		return nil, fmt.Errorf("invalid capability status %d", int(s))
+	}
+	return []byte(capabilityStatusNames[s]), nil
+}
+
+// capabilityStatus lists the capabilities that aren't regular, together with
+// notes explaining why. The capability numbers are stable, as they are part of
+// the kernel's ABI, so this list only needs updates when the kernel's usage of
+// capabilities changes.
+var capabilityStatus = map[int]struct {
+	status CapabilityStatus
+	note   string
+}{
+	CAP_SYS_ADMIN: {StatusOverloaded,
+		"catch-all capability granting many unrelated privileges; prefer CAP_BPF, CAP_PERFMON, CAP_CHECKPOINT_RESTORE, or CAP_SYSLOG where sufficient"},
+	CAP_NET_BROADCAST: {StatusUnused,
+		"not checked by the kernel, so granting it has no effect"},
+}
+
+// StatusOf returns the status of the specified capability, together with a
+// note explaining why the capability isn't regular, if so. Capabilities
+// unknown to this package have StatusUnknown.
+func StatusOf(capno int) (CapabilityStatus, string) {
+	if st, ok := capabilityStatus[capno]; ok {
+		return st.status, Localize(st.note)
+	}
+	if _, ok := CapabilityNameByNumber[capno]; ok {
+		return StatusRegular, ""
+	}
+	return StatusUnknown, ""
+}
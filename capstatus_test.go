@@ -0,0 +1,55 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("capability status", func() {
+
+	It("reports overloaded, unused and regular capabilities", func() {
+		status, note := StatusOf(CAP_SYS_ADMIN)
+		Expect(status).To(Equal(StatusOverloaded))
+		Expect(note).To(ContainSubstring("catch-all"))
+
+		status, note = StatusOf(CAP_NET_BROADCAST)
+		Expect(status).To(Equal(StatusUnused))
+		Expect(note).NotTo(BeEmpty())
+
+		status, note = StatusOf(CAP_CHOWN)
+		Expect(status).To(Equal(StatusRegular))
+		Expect(note).To(BeEmpty())
+
+		status, _ = StatusOf(MaxCapabilityNumber + 1)
+		Expect(status).To(Equal(StatusUnknown))
+	})
+
+	It("only lists known capabilities", func() {
+		for capno := range capabilityStatus {
+			Expect(CapabilityNameByNumber).To(HaveKey(capno))
+		}
+	})
+
+	It("marshals statuses as text", func() {
+		Expect(StatusOverloaded.MarshalText()).To(Equal([]byte("overloaded")))
+		Expect(StatusUnused.String()).To(Equal("unused"))
+		Expect(CapabilityStatus(42).String()).To(Equal("CapabilityStatus(42)"))
+		_, err := CapabilityStatus(-1).MarshalText()
+		Expect(err).To(HaveOccurred())
+	})
+
+})
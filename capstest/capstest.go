@@ -0,0 +1,120 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package capstest
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// ChildEnv is the environment variable marking a test binary re-executed by
+// [RunInUserNamespace].
+const ChildEnv = "CAPSTEST_USERNS_CHILD"
+
+// TB is the subset of [testing.TB] used by this package; it is also satisfied
+// by Ginkgo's GinkgoT().
+type TB interface {
+	Helper()
+	Skipf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+}
+
+// RequireEffective skips the test unless all specified capabilities are in
+// the effective set of the calling task. As capabilities are per task
+// (thread), callers should lock their Go routine to its OS-level thread.
+func RequireEffective(t TB, capnos ...int) {
+	t.Helper()
+	effective, _ := sets(t)
+	require(t, "effective", effective, capnos)
+}
+
+// RequirePermitted skips the test unless all specified capabilities are in
+// the permitted set of the calling task, so that the test can raise them.
+func RequirePermitted(t TB, capnos ...int) {
+	t.Helper()
+	_, permitted := sets(t)
+	require(t, "permitted", permitted, capnos)
+}
+
+// sets returns the effective and permitted capability sets of the calling
+// task, failing the test if they cannot be queried.
+func sets(t TB) (effective, permitted []uint32) {
+	t.Helper()
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capget(&hdr, &data[0]); err != nil {
+		t.Fatalf("cannot query capabilities: %s", err.Error())
+		return nil, nil
+	}
+	return []uint32{data[0].Effective, data[1].Effective},
+		[]uint32{data[0].Permitted, data[1].Permitted}
+}
+
+// require skips the test unless all capabilities are in the specified set.
+func require(t TB, setname string, set []uint32, capnos []int) {
+	t.Helper()
+	var missing []string
+	for _, capno := range capnos {
+		if capno < 0 || capno/32 >= len(set) || set[capno/32]&(1<<(capno%32)) == 0 {
+			missing = append(missing, strconv.Itoa(capno))
+		}
+	}
+	if len(missing) > 0 {
+		t.Skipf("needs %s capabilities #%s", setname, strings.Join(missing, ", #"))
+	}
+}
+
+// InChild returns true if the calling process is a test binary re-executed
+// by [RunInUserNamespace].
+func InChild() bool {
+	return os.Getenv(ChildEnv) != ""
+}
+
+// RunInUserNamespace re-executes the test binary with the specified arguments
+// in a new user namespace, mapping the caller's user and group IDs to root, so
+// that the re-executed test binary has a full set of capabilities inside the
+// user namespace. Typically, args select the test to run, such as
+// "-test.run=^TestFoo$". RunInUserNamespace returns the combined output of the
+// re-executed test binary, failing the test if the test binary fails. If the
+// kernel or system configuration doesn't allow creating user namespaces, the
+// test gets skipped instead.
+func RunInUserNamespace(t TB, args ...string) []byte {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), ChildEnv+"=1")
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:  syscall.CLONE_NEWUSER,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			t.Skipf("cannot create user namespace: %s", err.Error())
+			return nil
+		}
+		t.Fatalf("test binary in user namespace failed: %s\n%s", err.Error(), out)
+	}
+	return out
+}
@@ -0,0 +1,73 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package capstest
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// recordingTB records skips and failures instead of acting on them.
+type recordingTB struct {
+	skipped string
+	failed  string
+}
+
+func (t *recordingTB) Helper() {}
+
+func (t *recordingTB) Skipf(format string, args ...interface{}) {
+	t.skipped = fmt.Sprintf(format, args...)
+}
+
+func (t *recordingTB) Fatalf(format string, args ...interface{}) {
+	t.failed = fmt.Sprintf(format, args...)
+}
+
+var _ = Describe("capability test helpers", func() {
+
+	It("skips when capabilities are missing", func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		t := &recordingTB{}
+		RequirePermitted(t, 63, 42)
+		Expect(t.skipped).To(Equal("needs permitted capabilities #63, #42"))
+		Expect(t.failed).To(BeEmpty())
+
+		if os.Getuid() == 0 && !InChild() {
+			t = &recordingTB{}
+			RequireEffective(t, unix.CAP_CHOWN)
+			Expect(t.skipped).To(BeEmpty())
+		}
+	})
+
+	It("runs tests in a user namespace", func() {
+		if InChild() {
+			Expect(os.Getuid()).To(BeZero())
+			RequireEffective(GinkgoT(), unix.CAP_SYS_ADMIN, unix.CAP_SETPCAP)
+			fmt.Println("in user namespace")
+			return
+		}
+		out := RunInUserNamespace(GinkgoT(),
+			"-ginkgo.focus=runs tests in a user namespace", "-ginkgo.v")
+		Expect(string(out)).To(ContainSubstring("in user namespace"))
+	})
+
+})
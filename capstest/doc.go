@@ -0,0 +1,49 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+//go:build linux
+
+/*
+Package capstest helps writing capability-related tests that run not only as
+root, but also in unprivileged CI environments.
+
+[RequireEffective] and [RequirePermitted] skip tests when the capabilities they
+need are missing, instead of bluntly skipping whenever not running as root.
+
+[RunInUserNamespace] re-executes the test binary in a new user namespace where
+the test runs with a full set of capabilities, albeit only with respect to
+resources owned by that user namespace:
+
+	func TestFoo(t *testing.T) {
+		if !capstest.InChild() {
+			capstest.RunInUserNamespace(t, "-test.run=^TestFoo$")
+			return
+		}
+		capstest.RequireEffective(t, unix.CAP_SETPCAP)
+		...
+	}
+
+[RandomSet] and [CheckRoundTrip] support property-based testing of set
+algebra, parsing and serialization, with sets in the representation of
+caps.CapabilitiesSet:
+
+	r := rand.New(rand.NewSource(seed))
+	capstest.CheckRoundTrip(t, r, 1000, 63, func(set []uint32) ([]uint32, error) {
+		return caps.CapabilitiesFromHex(caps.CapabilitiesSet(set).Hex())
+	})
+
+This package deliberately doesn't depend on package caps, so that caps' own
+tests can use it.
+*/
+package capstest
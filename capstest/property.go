@@ -0,0 +1,78 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package capstest
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// RandomSet returns a random set of capabilities numbered from 0 up to and
+// including maxCap, with each capability being present with a probability of
+// one half. The set uses the representation of caps.CapabilitiesSet, so it
+// can be directly assigned to variables of that type.
+func RandomSet(r *rand.Rand, maxCap int) []uint32 {
+	set := make([]uint32, maxCap/32+1)
+	for idx := range set {
+		set[idx] = r.Uint32()
+	}
+	set[len(set)-1] &= ^uint32(0) >> (31 - maxCap%32)
+	return set
+}
+
+// EqualSets returns true if both sets contain the same capabilities, ignoring
+// trailing all-zero words.
+func EqualSets(a, b []uint32) bool {
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	for idx, word := range a {
+		if idx < len(b) {
+			if word != b[idx] {
+				return false
+			}
+		} else if word != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckRoundTrip checks that n random sets of capabilities numbered from 0 up
+// to and including maxCap survive the specified round trip unchanged, such as
+// marshalling and then unmarshalling them again. CheckRoundTrip fails the test
+// with the first set not surviving the round trip.
+func CheckRoundTrip(t TB, r *rand.Rand, n int, maxCap int, roundtrip func(set []uint32) ([]uint32, error)) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		set := RandomSet(r, maxCap)
+		result, err := roundtrip(append([]uint32(nil), set...))
+		if err != nil {
+			t.Fatalf("round trip of set %s failed: %s", words(set), err.Error())
+			return
+		}
+		if !EqualSets(set, result) {
+			t.Fatalf("round trip of set %s returned different set %s", words(set), words(result))
+			return
+		}
+	}
+}
+
+// words returns the words of a set in textual hex representation.
+func words(set []uint32) string {
+	return fmt.Sprintf("%08x", set)
+}
@@ -0,0 +1,59 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package capstest
+
+import (
+	"errors"
+	"math/rand"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("property test helpers", func() {
+
+	It("generates random sets within bounds", func() {
+		r := rand.New(rand.NewSource(GinkgoRandomSeed()))
+		for i := 0; i < 100; i++ {
+			set := RandomSet(r, 40)
+			Expect(set).To(HaveLen(2))
+			Expect(set[1] >> 9).To(BeZero())
+		}
+		Expect(RandomSet(r, 31)).To(HaveLen(1))
+	})
+
+	It("compares sets ignoring trailing zero words", func() {
+		Expect(EqualSets([]uint32{1}, []uint32{1, 0})).To(BeTrue())
+		Expect(EqualSets([]uint32{1, 0, 0}, []uint32{1})).To(BeTrue())
+		Expect(EqualSets(nil, []uint32{0})).To(BeTrue())
+		Expect(EqualSets([]uint32{1}, []uint32{1, 2})).To(BeFalse())
+		Expect(EqualSets([]uint32{1}, []uint32{2})).To(BeFalse())
+	})
+
+	It("checks round trips", func() {
+		r := rand.New(rand.NewSource(GinkgoRandomSeed()))
+		t := &recordingTB{}
+		CheckRoundTrip(t, r, 10, 40, func(set []uint32) ([]uint32, error) { return set, nil })
+		Expect(t.failed).To(BeEmpty())
+
+		CheckRoundTrip(t, r, 10, 40, func(set []uint32) ([]uint32, error) { return nil, errors.New("D'OH!") })
+		Expect(t.failed).To(MatchRegexp(`^round trip of set \[[0-9a-f]{8} [0-9a-f]{8}\] failed: D'OH!$`))
+
+		t = &recordingTB{}
+		CheckRoundTrip(t, r, 10, 40, func(set []uint32) ([]uint32, error) { return []uint32{^uint32(0)}, nil })
+		Expect(t.failed).To(ContainSubstring("returned different set [ffffffff]"))
+	})
+
+})
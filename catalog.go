@@ -0,0 +1,74 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import "fmt"
+
+// Catalog looks up localized versions of user-facing messages, such as
+// findings, remediation hints, version warnings, and policy reasons. Messages
+// are looked up by their built-in English text; messages with parameters are
+// looked up by their English fmt format string instead, and their localized
+// versions must use the same verbs in the same order (explicit argument
+// indexes such as "%[2]s" can be used to reorder parameters).
+//
+// Error messages are not localized, following Go's conventions.
+type Catalog interface {
+	// Lookup returns the localized version of the specified message and true,
+	// or false if there is no localized version.
+	Lookup(message string) (string, bool)
+}
+
+// CatalogMap is a simple Catalog mapping built-in English messages to their
+// localized versions.
+type CatalogMap map[string]string
+
+// Lookup returns the localized version of the specified message and true, or
+// false if there is no localized version.
+func (m CatalogMap) Lookup(message string) (string, bool) {
+	localized, ok := m[message]
+	return localized, ok
+}
+
+// SetCatalog sets the package-wide message catalog; nil restores the built-in
+// English messages. SetCatalog is a convenience shim for setting the Catalog
+// field of the package-wide [Config].
+func SetCatalog(catalog Catalog) {
+	updateConfig(func(cfg *Config) { cfg.Catalog = catalog })
+}
+
+// Localize returns the localized version of the specified message using the
+// package-wide message catalog, or the message unchanged if the catalog
+// doesn't know it.
+func Localize(message string) string {
+	if catalog := config.Load().Catalog; catalog != nil {
+		if localized, ok := catalog.Lookup(message); ok {
+			return localized
+		}
+	}
+	return message
+}
+
+// localizef localizes the specified format string and then formats it with
+// the specified arguments. If there are no arguments, the localized format is
+// returned unchanged.
+func localizef(format string, args ...interface{}) string {
+	format = Localize(format)
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,62 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("message catalog", func() {
+
+	BeforeEach(func() {
+		cfg := CurrentConfig()
+		DeferCleanup(func() { Configure(cfg) })
+	})
+
+	It("renders built-in messages without a catalog", func() {
+		Expect(Localize("foo")).To(Equal("foo"))
+		Expect(localizef("foo %d", 42)).To(Equal("foo 42"))
+		Expect(localizef("100%")).To(Equal("100%"))
+	})
+
+	It("localizes messages", func() {
+		SetCatalog(CatalogMap{
+			"CAP_SYS_ADMIN is not effective": "CAP_SYS_ADMIN ist nicht effektiv",
+			"grant %s":                       "%s gewähren",
+			"%s: %s is denied":               "%[2]s ist in %[1]s verboten",
+			"could not detect the kernel's native capabilities version": "Kernel-Version unbekannt",
+		})
+		Expect(CurrentConfig().Catalog).NotTo(BeNil())
+		Expect(Localize("foo")).To(Equal("foo"))
+
+		f := infoFinding("CAP_SYS_ADMIN", "CAP_SYS_ADMIN is not effective").
+			withRemediation("grant %s", "CAP_SYS_ADMIN")
+		Expect(f.Message).To(Equal("CAP_SYS_ADMIN ist nicht effektiv"))
+		Expect(f.Remediation).To(Equal("CAP_SYS_ADMIN gewähren"))
+
+		denied := NewCapabilitiesSet()
+		denied.Add(CAP_SYS_ADMIN)
+		decision := EvaluatePolicy(Policy{Denied: denied},
+			OCICapabilities{Bounding: []string{"CAP_SYS_ADMIN"}})
+		Expect(decision.Reasons).To(ConsistOf("CAP_SYS_ADMIN ist in bounding verboten"))
+
+		Expect(VersionInfo{}.Warnings()).To(ConsistOf("Kernel-Version unbekannt"))
+
+		SetCatalog(nil)
+		Expect(Localize("CAP_SYS_ADMIN is not effective")).To(Equal("CAP_SYS_ADMIN is not effective"))
+	})
+
+})
@@ -0,0 +1,251 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// CBOR major types used for representing capabilities, see RFC 8949, section
+// 3.1.
+const (
+	cborUnsigned   = 0
+	cborByteString = 2
+	cborArray      = 4
+	cborSimple     = 7
+)
+
+// CBOR simple values used for representing task states, see RFC 8949, section
+// 3.3.
+const (
+	cborFalse = 20
+	cborTrue  = 21
+	cborNull  = 22
+)
+
+var errInvalidCBOR = errors.New("invalid CBOR capabilities representation")
+
+// MarshalCBOR returns the CBOR representation of this capabilities set as a
+// byte string, consisting of the set's words in little endian byte order and
+// independent of the number of trailing zero words in the set.
+//
+// MarshalCBOR implements the Marshaler interface of github.com/fxamacker/cbor
+// without depending on it.
+func (c CapabilitiesSet) MarshalCBOR() ([]byte, error) {
+	return c.appendCBOR(nil), nil
+}
+
+// UnmarshalCBOR sets this capabilities set from the CBOR representation
+// produced by [CapabilitiesSet.MarshalCBOR].
+//
+// UnmarshalCBOR implements the Unmarshaler interface of
+// github.com/fxamacker/cbor without depending on it.
+func (c *CapabilitiesSet) UnmarshalCBOR(b []byte) error {
+	caps, rest, err := capabilitiesFromCBOR(b)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return errInvalidCBOR
+	}
+	*c = caps
+	return nil
+}
+
+// MarshalCBOR returns the CBOR representation of the task capabilities as an
+// array of three byte strings for the effective, permitted, and inheritable
+// sets, in this order. See also [CapabilitiesSet.MarshalCBOR].
+func (t TaskCapabilities) MarshalCBOR() ([]byte, error) {
+	b := appendCBORHead(nil, cborArray, 3)
+	for _, c := range []CapabilitiesSet{t.Effective, t.Permitted, t.Inheritable} {
+		b = c.appendCBOR(b)
+	}
+	return b, nil
+}
+
+// UnmarshalCBOR sets the task capabilities from the CBOR representation
+// produced by [TaskCapabilities.MarshalCBOR].
+func (t *TaskCapabilities) UnmarshalCBOR(b []byte) error {
+	major, length, b, err := cborHead(b)
+	if err != nil {
+		return err
+	}
+	if major != cborArray || length != 3 {
+		return errInvalidCBOR
+	}
+	var taskcaps TaskCapabilities
+	for _, c := range []*CapabilitiesSet{&taskcaps.Effective, &taskcaps.Permitted, &taskcaps.Inheritable} {
+		if *c, b, err = capabilitiesFromCBOR(b); err != nil {
+			return err
+		}
+	}
+	if len(b) != 0 {
+		return errInvalidCBOR
+	}
+	*t = taskcaps
+	return nil
+}
+
+// MarshalCBOR returns the CBOR representation of the task state as an array of
+// the effective, permitted, inheritable, bounding, and ambient sets as byte
+// strings (see [CapabilitiesSet.MarshalCBOR]), the securebits as an unsigned
+// integer, the no_new_privs flag as a boolean, and NSpid and NStgid as arrays
+// of unsigned integers, or null if nil. Embedding [TaskCapabilities] would
+// otherwise promote its MarshalCBOR, losing all other state.
+func (s State) MarshalCBOR() ([]byte, error) {
+	b := appendCBORHead(nil, cborArray, 9)
+	for _, c := range []CapabilitiesSet{s.Effective, s.Permitted, s.Inheritable, s.Bounding, s.Ambient} {
+		b = c.appendCBOR(b)
+	}
+	b = appendCBORHead(b, cborUnsigned, uint64(s.Securebits))
+	if s.NoNewPrivs {
+		b = appendCBORHead(b, cborSimple, cborTrue)
+	} else {
+		b = appendCBORHead(b, cborSimple, cborFalse)
+	}
+	for _, ids := range [][]int{s.NSpid, s.NStgid} {
+		if ids == nil {
+			b = appendCBORHead(b, cborSimple, cborNull)
+			continue
+		}
+		b = appendCBORHead(b, cborArray, uint64(len(ids)))
+		for _, id := range ids {
+			if id < 0 {
+				return nil, errInvalidCBOR
+			}
+			b = appendCBORHead(b, cborUnsigned, uint64(id))
+		}
+	}
+	return b, nil
+}
+
+// UnmarshalCBOR sets the task state from the CBOR representation produced by
+// [State.MarshalCBOR].
+func (s *State) UnmarshalCBOR(b []byte) error {
+	major, length, b, err := cborHead(b)
+	if err != nil {
+		return err
+	}
+	if major != cborArray || length != 9 {
+		return errInvalidCBOR
+	}
+	var state State
+	for _, c := range []*CapabilitiesSet{&state.Effective, &state.Permitted, &state.Inheritable,
+		&state.Bounding, &state.Ambient} {
+		if *c, b, err = capabilitiesFromCBOR(b); err != nil {
+			return err
+		}
+	}
+	var securebits, nnp uint64
+	if major, securebits, b, err = cborHead(b); err != nil || major != cborUnsigned {
+		return errInvalidCBOR
+	}
+	state.Securebits = uint(securebits)
+	if major, nnp, b, err = cborHead(b); err != nil || major != cborSimple || (nnp != cborFalse && nnp != cborTrue) {
+		return errInvalidCBOR
+	}
+	state.NoNewPrivs = nnp == cborTrue
+	for _, ids := range []*[]int{&state.NSpid, &state.NStgid} {
+		if major, length, b, err = cborHead(b); err != nil {
+			return err
+		}
+		if major == cborSimple && length == cborNull {
+			continue
+		}
+		if major != cborArray || length > uint64(len(b)) {
+			return errInvalidCBOR
+		}
+		*ids = make([]int, 0, length)
+		for ; length > 0; length-- {
+			var id uint64
+			if major, id, b, err = cborHead(b); err != nil || major != cborUnsigned || id > uint64(^uint(0)>>1) {
+				return errInvalidCBOR
+			}
+			*ids = append(*ids, int(id))
+		}
+	}
+	if len(b) != 0 {
+		return errInvalidCBOR
+	}
+	*s = state
+	return nil
+}
+
+// appendCBOR appends the CBOR byte string representation of this capabilities
+// set to b and returns the extended buffer.
+func (c CapabilitiesSet) appendCBOR(b []byte) []byte {
+	c = c.normalized()
+	b = appendCBORHead(b, cborByteString, uint64(4*len(c)))
+	return c.appendBinary(b)
+}
+
+// capabilitiesFromCBOR decodes a CBOR byte string with a capabilities set from
+// the beginning of b, returning the capabilities set and the remaining
+// undecoded CBOR data.
+func capabilitiesFromCBOR(b []byte) (CapabilitiesSet, []byte, error) {
+	major, length, b, err := cborHead(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if major != cborByteString || length%4 != 0 || length > uint64(len(b)) {
+		return nil, nil, errInvalidCBOR
+	}
+	return capabilitiesFromBinary(b[:length]), b[length:], nil
+}
+
+// appendCBORHead appends a CBOR data item head with the specified major type
+// and argument to b, using the shortest possible encoding.
+func appendCBORHead(b []byte, major byte, arg uint64) []byte {
+	major <<= 5
+	switch {
+	case arg < 24:
+		return append(b, major|byte(arg))
+	case arg <= 0xff:
+		return append(b, major|24, byte(arg))
+	case arg <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(b, major|25), uint16(arg))
+	case arg <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(b, major|26), uint32(arg))
+	}
+	return binary.BigEndian.AppendUint64(append(b, major|27), arg)
+}
+
+// cborHead decodes a CBOR data item head from the beginning of b, returning
+// its major type, argument, and the remaining data following the head.
+// Indefinite lengths are not supported.
+func cborHead(b []byte) (major byte, arg uint64, rest []byte, err error) {
+	if len(b) == 0 {
+		return 0, 0, nil, errInvalidCBOR
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+	b = b[1:]
+	switch {
+	case info < 24:
+		return major, uint64(info), b, nil
+	case info <= 27:
+		size := 1 << (info - 24)
+		if len(b) < size {
+			return 0, 0, nil, errInvalidCBOR
+		}
+		for _, v := range b[:size] {
+			arg = arg<<8 | uint64(v)
+		}
+		return major, arg, b[size:], nil
+	}
+	return 0, 0, nil, errInvalidCBOR
+}
@@ -0,0 +1,103 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("CBOR", func() {
+
+	It("marshals sets as byte strings", func() {
+		caps := CapabilitiesSet{0x00200001, 0, 0}
+		Expect(Successful(caps.MarshalCBOR())).To(Equal(
+			[]byte{0x44, 0x01, 0x00, 0x20, 0x00}))
+		Expect(Successful(CapabilitiesSet{}.MarshalCBOR())).To(Equal([]byte{0x40}))
+		caps = make(CapabilitiesSet, 8)
+		caps[7] = 1
+		Expect(Successful(caps.MarshalCBOR())[:2]).To(Equal([]byte{0x58, 32}))
+	})
+
+	It("round-trips complete task states", func() {
+		var decoded State
+		Expect(decoded.UnmarshalCBOR(Successful(completeState.MarshalCBOR()))).To(Succeed())
+		Expect(decoded).To(Equal(completeState))
+
+		Expect(Successful(State{}.MarshalCBOR())).To(Equal(
+			[]byte{0x89, 0x40, 0x40, 0x40, 0x40, 0x40, 0x00, 0xf4, 0xf6, 0xf6}))
+		decoded = completeState
+		Expect(decoded.UnmarshalCBOR([]byte{0x89, 0x40, 0x40, 0x40, 0x40, 0x40, 0x00, 0xf4, 0xf6, 0x80})).To(Succeed())
+		Expect(decoded.NSpid).To(BeNil())
+		Expect(decoded.NStgid).To(BeEmpty())
+		Expect(decoded.NStgid).NotTo(BeNil())
+
+		for _, b := range [][]byte{
+			{0x83, 0x40, 0x40, 0x40},
+			{0x89, 0x40, 0x40, 0x40, 0x40, 0x40, 0xf4, 0xf4, 0xf6, 0xf6},
+			{0x89, 0x40, 0x40, 0x40, 0x40, 0x40, 0x00, 0x01, 0xf6, 0xf6},
+			{0x89, 0x40, 0x40, 0x40, 0x40, 0x40, 0x00, 0xf4, 0x81, 0x40, 0xf6},
+			{0x89, 0x40, 0x40, 0x40, 0x40, 0x40, 0x00, 0xf4, 0xf6, 0xf6, 0x00},
+		} {
+			Expect(decoded.UnmarshalCBOR(b)).NotTo(Succeed(), "% x", b)
+		}
+	})
+
+	It("round-trips sets", func() {
+		for _, caps := range []CapabilitiesSet{{}, {0x00200001}, AllCapabilities(), make(CapabilitiesSet, 100)} {
+			var decoded CapabilitiesSet
+			Expect(decoded.UnmarshalCBOR(Successful(caps.MarshalCBOR()))).To(Succeed())
+			Expect(decoded.normalized()).To(Equal(caps.normalized()))
+		}
+	})
+
+	It("round-trips task capabilities", func() {
+		taskcaps := Successful(OfThisTask())
+		b := Successful(taskcaps.MarshalCBOR())
+		Expect(b[0]).To(Equal(byte(0x83)))
+		var decoded TaskCapabilities
+		Expect(decoded.UnmarshalCBOR(b)).To(Succeed())
+		Expect(decoded.Effective.normalized()).To(Equal(taskcaps.Effective.normalized()))
+		Expect(decoded.Permitted.normalized()).To(Equal(taskcaps.Permitted.normalized()))
+		Expect(decoded.Inheritable.normalized()).To(Equal(taskcaps.Inheritable.normalized()))
+	})
+
+	DescribeTable("rejects invalid CBOR for sets",
+		func(b []byte) {
+			var caps CapabilitiesSet
+			Expect(caps.UnmarshalCBOR(b)).NotTo(Succeed())
+		},
+		Entry("empty", []byte{}),
+		Entry("wrong major type", []byte{0x60}),
+		Entry("not a multiple of words", []byte{0x41, 0x00}),
+		Entry("truncated", []byte{0x44, 0x00}),
+		Entry("truncated head", []byte{0x59, 0x00}),
+		Entry("indefinite length", []byte{0x5f}),
+		Entry("trailing data", []byte{0x40, 0x00}),
+	)
+
+	DescribeTable("rejects invalid CBOR for task capabilities",
+		func(b []byte) {
+			var taskcaps TaskCapabilities
+			Expect(taskcaps.UnmarshalCBOR(b)).NotTo(Succeed())
+		},
+		Entry("empty", []byte{}),
+		Entry("wrong array length", []byte{0x82, 0x40, 0x40}),
+		Entry("missing set", []byte{0x83, 0x40, 0x40}),
+		Entry("trailing data", []byte{0x83, 0x40, 0x40, 0x40, 0x40}),
+	)
+
+})
@@ -0,0 +1,71 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+// CanCheckpointRestore checks whether the current task has the effective
+// capabilities needed for common [CRIU] checkpoint and restore operations,
+// returning true if so. Additionally, CanCheckpointRestore returns a list of
+// findings explaining the verdict.
+//
+// The current task needs CAP_SYS_PTRACE in order to seize the processes to be
+// checkpointed, as well as either CAP_SYS_ADMIN, or CAP_CHECKPOINT_RESTORE in
+// CRIU's so-called “non-root mode”. CAP_CHECKPOINT_RESTORE has been introduced
+// only with Linux kernel 5.9, so on older kernels there is no alternative to
+// CAP_SYS_ADMIN.
+//
+// Please note that the capabilities are checked only for the current task
+// (thread), so callers should lock their Go routine to its OS-level thread.
+//
+// [CRIU]: https://criu.org
+func CanCheckpointRestore() (bool, Findings) {
+	taskcaps, err := OfThisTask()
+	if err != nil {
+		return false, Findings{taskQueryFinding(err)}
+	}
+	eff := taskcaps.Effective
+	var findings Findings
+	ok := true
+	if eff.Has(CAP_SYS_PTRACE) {
+		findings = append(findings, infoFinding("CAP_SYS_PTRACE",
+			"CAP_SYS_PTRACE is effective, allowing to seize processes to be checkpointed"))
+	} else {
+		ok = false
+		findings = append(findings, errorFinding("CAP_SYS_PTRACE",
+			"CAP_SYS_PTRACE is not effective, but is required for seizing processes to be checkpointed").
+			withRemediation("grant CAP_SYS_PTRACE"))
+	}
+	if eff.Has(CAP_SYS_ADMIN) {
+		return ok, append(findings, infoFinding("CAP_SYS_ADMIN",
+			"CAP_SYS_ADMIN is effective, allowing all other checkpoint/restore operations"))
+	}
+	findings = append(findings, infoFinding("CAP_SYS_ADMIN", "CAP_SYS_ADMIN is not effective"))
+	if LastCapability() < CAP_CHECKPOINT_RESTORE {
+		return false, append(findings, errorFinding("kernel",
+			"kernel does not support CAP_CHECKPOINT_RESTORE (needs Linux 5.9 or later), so CAP_SYS_ADMIN is required").
+			withRemediation("grant CAP_SYS_ADMIN"))
+	}
+	if eff.Has(CAP_CHECKPOINT_RESTORE) {
+		findings = append(findings, infoFinding("CAP_CHECKPOINT_RESTORE",
+			"CAP_CHECKPOINT_RESTORE is effective, allowing to set PIDs of restored processes and to read map_files"))
+	} else {
+		ok = false
+		findings = append(findings, errorFinding("CAP_CHECKPOINT_RESTORE",
+			"CAP_CHECKPOINT_RESTORE is not effective, but is required for setting PIDs of restored processes and reading map_files").
+			withRemediation("grant CAP_CHECKPOINT_RESTORE or CAP_SYS_ADMIN"))
+	}
+	return ok, findings
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"runtime"
+
+	"github.com/thediveo/caps/capstest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("checkpoint/restore", func() {
+
+	It("checks for the required capabilities", func() {
+		if inUserNamespaceUnlessRoot() {
+			return
+		}
+		capstest.RequireEffective(GinkgoT(), CAP_SYS_ADMIN, CAP_SYS_PTRACE)
+		if LastCapability() >= CAP_CHECKPOINT_RESTORE {
+			capstest.RequirePermitted(GinkgoT(), CAP_CHECKPOINT_RESTORE)
+		}
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			runtime.LockOSThread() // throw away this thread when done.
+
+			before := Successful(OfThisTask())
+			ok, findings := CanCheckpointRestore()
+			Expect(ok).To(BeTrue(), "%v", findings)
+
+			taskcaps := before.Clone()
+			taskcaps.Effective.Clear()
+			taskcaps.Effective.Add(CAP_SYS_ADMIN)
+			Expect(SetForThisTask(taskcaps)).To(Succeed())
+			ok, findings = CanCheckpointRestore()
+			Expect(ok).To(BeFalse())
+			Expect(findings.Messages()).To(ContainElement(HavePrefix("CAP_SYS_PTRACE is not effective")))
+
+			taskcaps.Effective.Clear()
+			Expect(SetForThisTask(taskcaps)).To(Succeed())
+			ok, findings = CanCheckpointRestore()
+			Expect(ok).To(BeFalse())
+			Expect(findings.Messages()).To(ContainElement("CAP_SYS_ADMIN is not effective"))
+
+			if LastCapability() < CAP_CHECKPOINT_RESTORE {
+				return
+			}
+			taskcaps.Effective.Add(CAP_CHECKPOINT_RESTORE)
+			Expect(SetForThisTask(taskcaps)).To(Succeed())
+			ok, findings = CanCheckpointRestore()
+			Expect(ok).To(BeFalse())
+			Expect(findings.Messages()).To(ContainElement(HavePrefix("CAP_SYS_PTRACE is not effective")))
+
+			taskcaps.Effective.Add(CAP_SYS_PTRACE)
+			Expect(SetForThisTask(taskcaps)).To(Succeed())
+			ok, findings = CanCheckpointRestore()
+			Expect(ok).To(BeTrue(), "%v", findings)
+		}()
+		Eventually(done).Should(BeClosed())
+	})
+
+})
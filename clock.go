@@ -0,0 +1,75 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"os"
+	"strings"
+)
+
+// CanSetSystemClock checks whether the current task is allowed to set the
+// system clock (CLOCK_REALTIME), returning true if so. Additionally,
+// CanSetSystemClock returns a list of findings explaining the verdict.
+//
+// Setting the system clock, as well as adjusting it using adjtimex(2) and
+// clock_adjtime(2), requires CAP_SYS_TIME in the initial user namespace; the
+// capability is useless inside any other user namespace. Time namespaces
+// (since Linux 5.6) only virtualize the monotonic and boot-time clocks, so
+// setting the system clock from inside a time namespace still changes the time
+// of the whole host.
+//
+// Please note that the capabilities are checked only for the current task
+// (thread), so callers should lock their Go routine to its OS-level thread.
+func CanSetSystemClock() (bool, Findings) {
+	taskcaps, err := OfThisTask()
+	if err != nil {
+		return false, Findings{taskQueryFinding(err)}
+	}
+	var findings Findings
+	if offsets, err := os.ReadFile(ownProcPath("self/timens_offsets")); err == nil && hasTimeOffsets(offsets) {
+		findings = append(findings, warningFinding("time namespace",
+			"running in a time namespace with clock offsets, but setting the system clock nevertheless affects the whole host").
+			withRemediation("set the clock from the initial time namespace only"))
+	}
+	if !taskcaps.Effective.Has(CAP_SYS_TIME) {
+		return false, append(findings, errorFinding("CAP_SYS_TIME",
+			"CAP_SYS_TIME is not effective, but is required for setting the system clock").
+			withRemediation("grant CAP_SYS_TIME"))
+	}
+	if !Environment().InitialUserNamespace {
+		return false, append(findings, errorFinding("user namespace",
+			"CAP_SYS_TIME is effective, but only in a child user namespace, while setting the system clock requires it in the initial user namespace").
+			withRemediation("run in the initial user namespace"))
+	}
+	return true, append(findings, infoFinding("CAP_SYS_TIME",
+		"CAP_SYS_TIME is effective in the initial user namespace, allowing to set and adjust the system clock"))
+}
+
+// hasTimeOffsets returns true if the contents of a /proc/[pid]/timens_offsets
+// file contain any non-zero clock offsets.
+func hasTimeOffsets(offsets []byte) bool {
+	for _, line := range strings.Split(string(offsets), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		if fields[1] != "0" || fields[2] != "0" {
+			return true
+		}
+	}
+	return false
+}
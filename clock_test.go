@@ -0,0 +1,62 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("system clock", func() {
+
+	BeforeEach(func() {
+		cfg := CurrentConfig()
+		DeferCleanup(func() { Configure(cfg) })
+	})
+
+	It("detects time namespace offsets", func() {
+		Expect(hasTimeOffsets([]byte("monotonic 0 0\nboottime 0 0\n"))).To(BeFalse())
+		Expect(hasTimeOffsets([]byte("monotonic 42 0\nboottime 0 0\n"))).To(BeTrue())
+		Expect(hasTimeOffsets([]byte("boottime 0 1\n"))).To(BeTrue())
+		Expect(hasTimeOffsets(nil)).To(BeFalse())
+	})
+
+	DescribeTable("checks for CAP_SYS_TIME",
+		func(uidmap, offsets string, capnos []int, expected bool, finding string) {
+			root := GinkgoT().TempDir()
+			Expect(os.MkdirAll(filepath.Join(root, "self"), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(root, "self/uid_map"), []byte(uidmap), 0644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(root, "self/timens_offsets"), []byte(offsets), 0644)).To(Succeed())
+			useProc(root)
+			withEffective(func() {
+				ok, findings := CanSetSystemClock()
+				Expect(ok).To(Equal(expected), "%v", findings)
+				Expect(findings.Messages()).To(ContainElement(ContainSubstring(finding)))
+			}, capnos...)
+		},
+		Entry("initial user namespace", "0 0 4294967295\n", "monotonic 0 0\n",
+			[]int{CAP_SYS_TIME}, true, "CAP_SYS_TIME is effective in the initial user namespace"),
+		Entry("child user namespace", "0 1000 1\n", "",
+			[]int{CAP_SYS_TIME}, false, "only in a child user namespace"),
+		Entry("time namespace", "0 0 4294967295\n", "monotonic 42 0\n",
+			[]int{CAP_SYS_TIME}, true, "affects the whole host"),
+		Entry("no CAP_SYS_TIME", "0 0 4294967295\n", "",
+			nil, false, "CAP_SYS_TIME is not effective"),
+	)
+
+})
@@ -0,0 +1,141 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package compat
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/thediveo/caps"
+)
+
+// ErrToolsUnavailable is returned by checks if the required libcap tools
+// cannot be found.
+var ErrToolsUnavailable = errors.New("libcap tools unavailable")
+
+// Mismatch describes a difference between the results of the caps package and
+// the results of libcap's tools.
+type Mismatch struct {
+	Check  string // name of the check, such as "effective"
+	Ours   string // result of the caps package
+	Theirs string // result of the libcap tool
+}
+
+// String returns a textual description of the mismatch.
+func (m Mismatch) String() string {
+	return fmt.Sprintf("%s: caps has %q, but libcap has %q", m.Check, m.Ours, m.Theirs)
+}
+
+// Available returns true if the libcap tools needed by the checks are
+// available.
+func Available() bool {
+	for _, tool := range []string{"getpcaps", "capsh"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckSelf cross-checks the capabilities of the current process (that is, of
+// its main thread) as seen by the caps package and by getpcaps.
+func CheckSelf() ([]Mismatch, error) {
+	return CheckProcess(os.Getpid())
+}
+
+// CheckProcess cross-checks the capabilities of the specified process as seen
+// by the caps package and by getpcaps.
+func CheckProcess(pid int) ([]Mismatch, error) {
+	getpcaps, err := exec.LookPath("getpcaps")
+	if err != nil {
+		return nil, ErrToolsUnavailable
+	}
+	out, err := exec.Command(getpcaps, strconv.Itoa(pid)).Output()
+	if err != nil {
+		return nil, fmt.Errorf("getpcaps failed: %w", err)
+	}
+	pcaps, err := caps.ParseGetpcaps(string(out))
+	if err != nil {
+		return nil, err
+	}
+	theirs, ok := pcaps[pid]
+	if !ok {
+		return nil, fmt.Errorf("getpcaps did not report PID %d", pid)
+	}
+	ours, err := caps.OfTask(pid)
+	if err != nil {
+		return nil, err
+	}
+	mismatches := []Mismatch{}
+	for _, set := range []struct {
+		name         string
+		ours, theirs caps.CapabilitiesSet
+	}{
+		{name: "effective", ours: ours.Effective, theirs: theirs.Effective},
+		{name: "permitted", ours: ours.Permitted, theirs: theirs.Permitted},
+		{name: "inheritable", ours: ours.Inheritable, theirs: theirs.Inheritable},
+	} {
+		if o, t := set.ours.String(), set.theirs.String(); o != t {
+			mismatches = append(mismatches, Mismatch{Check: set.name, Ours: o, Theirs: t})
+		}
+	}
+	return mismatches, nil
+}
+
+// CheckDecode cross-checks the capability names of the specified set as
+// returned by the caps package and by “capsh --decode”. The set must not
+// contain capabilities beyond 63, as capsh cannot decode them.
+func CheckDecode(set caps.CapabilitiesSet) ([]Mismatch, error) {
+	capsh, err := exec.LookPath("capsh")
+	if err != nil {
+		return nil, ErrToolsUnavailable
+	}
+	var mask uint64
+	for idx, w := range set {
+		if w == 0 {
+			continue
+		}
+		if idx >= 2 {
+			return nil, errors.New("capsh cannot decode capabilities beyond 63")
+		}
+		mask |= uint64(w) << (32 * idx)
+	}
+	out, err := exec.Command(capsh, fmt.Sprintf("--decode=0x%016x", mask)).Output()
+	if err != nil {
+		return nil, fmt.Errorf("capsh failed: %w", err)
+	}
+	// "0x0000000000000003=cap_chown,cap_dac_override"
+	_, decoded, ok := strings.Cut(strings.TrimSpace(string(out)), "=")
+	if !ok {
+		return nil, fmt.Errorf("invalid capsh output %q", string(out))
+	}
+	ours := make([]string, 0, len(set.Names()))
+	for _, name := range set.Names() {
+		if capno, err := caps.CapabilityByName(name); err == nil && caps.CapabilityNameByNumber[capno] == "" {
+			name = strconv.Itoa(capno) // capsh shows anonymous capabilities only by number
+		}
+		ours = append(ours, strings.ToLower(name))
+	}
+	if o := strings.Join(ours, ","); o != decoded {
+		return []Mismatch{{Check: "decode", Ours: o, Theirs: decoded}}, nil
+	}
+	return []Mismatch{}, nil
+}
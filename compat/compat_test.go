@@ -0,0 +1,59 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package compat
+
+import (
+	"github.com/thediveo/caps"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("libcap compatibility", func() {
+
+	BeforeEach(func() {
+		if !Available() {
+			Skip("libcap tools not available")
+		}
+	})
+
+	It("agrees on the capabilities of this process", func() {
+		Expect(CheckSelf()).To(BeEmpty())
+	})
+
+	It("agrees on capability names", func() {
+		Expect(CheckDecode(caps.CapabilitiesSet{})).To(BeEmpty())
+		Expect(CheckDecode(caps.AllCapabilities())).To(BeEmpty())
+		set := caps.NewCapabilitiesSet()
+		set.Add(caps.CAP_CHOWN, caps.CAP_SYS_ADMIN, 63)
+		Expect(CheckDecode(set)).To(BeEmpty())
+	})
+
+	It("rejects capabilities beyond 63", func() {
+		set := caps.NewCapabilitiesSet()
+		set.Add(64)
+		Expect(CheckDecode(set)).Error().To(HaveOccurred())
+	})
+
+	It("reports mismatches", func() {
+		Expect(Mismatch{Check: "foo", Ours: "bar", Theirs: "baz"}.String()).To(
+			Equal(`foo: caps has "bar", but libcap has "baz"`))
+	})
+
+	It("returns errors for non-existing processes", func() {
+		Expect(CheckProcess(-1)).Error().To(HaveOccurred())
+	})
+
+})
@@ -0,0 +1,11 @@
+/*
+Package compat cross-checks the results of the caps package against libcap's
+reference tools getpcaps and capsh, if available. Downstream projects can use
+compat in their own CI in order to verify that the caps package sees the same
+capabilities as libcap does.
+
+Checks return the list of mismatches found, with an empty list meaning that
+the results agree. If the libcap tools aren't installed, checks return
+[ErrToolsUnavailable].
+*/
+package compat
@@ -0,0 +1,116 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// Config is the package-wide configuration honored by all APIs of this
+// package. Set it once at program startup using [Configure], before using
+// other APIs of this package.
+type Config struct {
+	// ProcRoot is the mount point of the proc filesystem to read task
+	// information from, such as "/host/proc" when running in a container
+	// with the host's proc filesystem bind-mounted. Defaults to "/proc".
+	// For the calling process and task itself, and where PIDs and TIDs get
+	// passed to or come from syscalls, such as when checking for reused task
+	// IDs, determining the PID of a PID file descriptor, or writing the ID
+	// maps of child processes, the caller's own "/proc" is always used
+	// instead, as other proc filesystems might number tasks in a different
+	// PID namespace.
+	ProcRoot string
+	// CompactJSON makes capabilities sets marshal into JSON in compact form,
+	// see also [UseCompactJSON].
+	CompactJSON bool
+	// Strict makes capability names parsing reject capabilities not supported
+	// by the kernel we're currently running on, that is, capabilities beyond
+	// [LastCapability].
+	Strict bool
+	// Catalog localizes user-facing messages, such as findings and their
+	// remediation hints; nil renders the built-in English messages. See also
+	// [SetCatalog].
+	Catalog Catalog
+	// PanicOnCapabilityLeak makes [MustLowered] panic after lowering
+	// capabilities that a privileged section left raised, instead of only
+	// logging them.
+	PanicOnCapabilityLeak bool
+	// LastCapability overrides the highest capability supported by the
+	// kernel, as returned by [LastCapability], in order to simulate other
+	// kernels, such as in tests. Zero uses the probed value.
+	LastCapability int
+	// KernelCapabilityVersion overrides the capabilities version natively
+	// used by the kernel, as returned by [KernelCapabilityVersion], in order
+	// to simulate other kernels. Zero uses the probed value.
+	KernelCapabilityVersion uint32
+}
+
+// config is the current package-wide configuration; it is never nil.
+var config atomic.Pointer[Config]
+
+func init() {
+	config.Store(&Config{ProcRoot: "/proc"})
+}
+
+// Configure sets the package-wide configuration, filling in defaults for unset
+// fields. Configure is safe for concurrent use, but should preferably be
+// called only once at program startup.
+func Configure(cfg Config) {
+	cfg.ProcRoot = strings.TrimSuffix(cfg.ProcRoot, "/")
+	if cfg.ProcRoot == "" {
+		cfg.ProcRoot = "/proc"
+	}
+	config.Store(&cfg)
+}
+
+// CurrentConfig returns (a copy of) the current package-wide configuration.
+func CurrentConfig() Config {
+	return *config.Load()
+}
+
+// updateConfig atomically applies the specified update to the current
+// package-wide configuration.
+func updateConfig(update func(cfg *Config)) {
+	for {
+		current := config.Load()
+		cfg := *current
+		update(&cfg)
+		if config.CompareAndSwap(current, &cfg) {
+			return
+		}
+	}
+}
+
+// procPath returns the path of the specified file in the proc filesystem,
+// given relative to the proc filesystem's root, such as "self/status".
+func procPath(name string) string {
+	return config.Load().ProcRoot + "/" + name
+}
+
+// ownProcPath returns the path of the specified file in the proc filesystem of
+// the caller's own PID namespace, independent of [Config.ProcRoot]. Reading
+// PIDs that get passed to syscalls, or reading files of PIDs coming from
+// syscalls, must use the own proc filesystem, as other proc filesystems might
+// number their tasks in a different PID namespace.
+func ownProcPath(name string) string {
+	return ownProcRoot + "/" + name
+}
+
+// ownProcRoot is the mount point of the proc filesystem of the caller's own PID
+// namespace; tests might want to replace it.
+var ownProcRoot = "/proc"
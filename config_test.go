@@ -0,0 +1,141 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/thediveo/caps/capstest"
+	"golang.org/x/sys/unix"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("configuration", func() {
+
+	BeforeEach(func() {
+		cfg := CurrentConfig()
+		DeferCleanup(func() { Configure(cfg) })
+	})
+
+	It("defaults", func() {
+		Configure(Config{})
+		Expect(CurrentConfig()).To(Equal(Config{ProcRoot: "/proc"}))
+		Configure(Config{ProcRoot: "/host/proc/"})
+		Expect(CurrentConfig().ProcRoot).To(Equal("/host/proc"))
+	})
+
+	It("reads from an alternative proc root", func() {
+		root := GinkgoT().TempDir()
+		Expect(os.Mkdir(filepath.Join(root, "42"), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(root, "42", "status"), []byte(taskStatus), 0644)).To(Succeed())
+		Configure(Config{ProcRoot: root})
+		state := Successful(StateOf(42))
+		Expect(state.Ambient.Names()).To(ConsistOf("CAP_NET_BIND_SERVICE"))
+	})
+
+	It("uses the own proc filesystem for task IDs passed to syscalls", func() {
+		Configure(Config{ProcRoot: GinkgoT().TempDir()})
+
+		h := Successful(OpenProcess(os.Getpid()))
+		defer h.Close()
+		Expect(h.Capabilities()).Error().NotTo(HaveOccurred())
+
+		Expect(NewCache(time.Minute).OfTask(0)).Error().NotTo(HaveOccurred())
+
+		_, findings := CanPtrace(os.Getpid())
+		Expect(findings).NotTo(ContainElement(HaveField("Subject", "target")))
+	})
+
+	It("uses the own proc filesystem for the calling task", func() {
+		Configure(Config{ProcRoot: GinkgoT().TempDir()})
+		Expect(StateOf(0)).Error().NotTo(HaveOccurred())
+		Expect(initialUserNamespace()).Error().NotTo(HaveOccurred())
+	})
+
+	It("writes ID maps of child processes to the own proc filesystem", func() {
+		own := GinkgoT().TempDir()
+		Expect(os.Mkdir(filepath.Join(own, "42"), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(own, "42", "uid_map"), nil, 0644)).To(Succeed())
+		DeferCleanup(func(old string) { ownProcRoot = old }, ownProcRoot)
+		ownProcRoot = own
+		Configure(Config{ProcRoot: GinkgoT().TempDir()})
+		Expect(RunChildSteps(42, WriteUIDMap(IDMapping{ContainerID: 0, HostID: 1000, Size: 1}))).To(Succeed())
+		Expect(os.ReadFile(filepath.Join(own, "42", "uid_map"))).To(Equal([]byte("0 1000 1\n")))
+	})
+
+	It("doesn't attribute the own securebits to tasks of other proc filesystems", func() {
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			runtime.LockOSThread() // throw away this thread when done.
+			discardThisTask()
+			capstest.RequireEffective(GinkgoT(), CAP_SETPCAP)
+			Expect(unix.Prctl(unix.PR_SET_SECUREBITS, 1<<4 /* SECBIT_KEEP_CAPS */, 0, 0, 0)).To(Succeed())
+			Expect(Successful(StateOf(0)).Securebits).To(Equal(uint(1 << 4)))
+
+			tid := unix.Gettid()
+			root := GinkgoT().TempDir()
+			Expect(os.Mkdir(filepath.Join(root, strconv.Itoa(tid)), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(root, strconv.Itoa(tid), "status"), []byte(taskStatus), 0644)).To(Succeed())
+			Configure(Config{ProcRoot: root})
+			Expect(Successful(StateOf(tid)).Securebits).To(BeZero())
+		}()
+		Eventually(done).Should(BeClosed())
+	})
+
+	It("marshals compact JSON", func() {
+		caps := CapabilitiesSet{1}
+		Configure(Config{CompactJSON: true})
+		Expect(string(Successful(json.Marshal(caps)))).To(Equal(`"0000000000000001"`))
+		UseCompactJSON(false)
+		Expect(CurrentConfig().CompactJSON).To(BeFalse())
+		Expect(string(Successful(json.Marshal(caps)))).To(Equal(`["CAP_CHOWN"]`))
+	})
+
+	It("parses strictly", func() {
+		Configure(Config{LastCapability: CAP_AUDIT_READ})
+		Expect(CapabilityByName("CAP_BPF")).To(Equal(CAP_BPF))
+		Expect(CapabilityByName("CAP_63")).To(Equal(63))
+		Configure(Config{Strict: true, LastCapability: CAP_AUDIT_READ})
+		Expect(CapabilityByName("CAP_AUDIT_READ")).To(Equal(CAP_AUDIT_READ))
+		Expect(CapabilityByName("CAP_BPF")).Error().To(MatchError(ContainSubstring("not supported")))
+		Expect(CapabilityByName("CAP_63")).Error().To(HaveOccurred())
+	})
+
+	It("simulates other kernels", func() {
+		Configure(Config{LastCapability: CAP_AUDIT_READ, KernelCapabilityVersion: LINUX_CAPABILITY_VERSION_2})
+		Expect(LastCapability()).To(Equal(CAP_AUDIT_READ))
+		Expect(AllCapabilities().Numbers()).To(HaveLen(CAP_AUDIT_READ + 1))
+		Expect(FileCapabilities{Permitted: capset(CAP_BPF)}.ValidateFor(LastCapability())).To(
+			MatchError("file capabilities CAP_BPF unknown to kernels with last capability 37"))
+		Expect(KernelCapabilityVersion()).To(Equal(uint32(LINUX_CAPABILITY_VERSION_2)))
+		Expect(KernelCapabilityVersionInfo().Warnings()).To(ConsistOf(
+			ContainSubstring("older than version")))
+
+		Configure(Config{})
+		Expect(LastCapability()).To(Equal(int(lastCapability.Load())))
+		Expect(KernelCapabilityVersion()).To(Equal(linuxCapabilityVersion.Load()))
+	})
+
+})
@@ -0,0 +1,68 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// requiredKey is the context key for the required capabilities.
+type requiredKey struct{}
+
+// WithRequired returns a copy of the parent context annotated with the
+// specified required capabilities, in addition to any required capabilities
+// the parent context is already annotated with. Frameworks can annotate request
+// contexts this way, so that middleware can then verify the requirements once
+// per request using [CheckContext].
+func WithRequired(ctx context.Context, capno int, morecapnos ...int) context.Context {
+	required := Required(ctx)
+	required.Add(capno, morecapnos...)
+	return context.WithValue(ctx, requiredKey{}, required)
+}
+
+// Required returns the required capabilities the specified context has been
+// annotated with using [WithRequired]; the returned set is independent of the
+// context and can be freely modified.
+func Required(ctx context.Context) CapabilitiesSet {
+	required, _ := ctx.Value(requiredKey{}).(CapabilitiesSet)
+	return required.Clone()
+}
+
+// CheckContext checks that the effective capabilities of the current task
+// include all the capabilities the specified context has been annotated with
+// using [WithRequired], returning an error listing the missing capabilities
+// otherwise. As capabilities are per task (thread), CheckContext must be called
+// on the OS-level thread that will handle the request, that is, after locking
+// the Go routine to its thread.
+func CheckContext(ctx context.Context) error {
+	required := Required(ctx)
+	if len(required.normalized()) == 0 {
+		return nil
+	}
+	taskcaps, err := OfThisTask()
+	if err != nil {
+		return err
+	}
+	required.dropSet(taskcaps.Effective)
+	if missing := required.Names(); len(missing) != 0 {
+		return fmt.Errorf("required capabilities not effective: %s",
+			strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/thediveo/caps/capstest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("context requirements", func() {
+
+	It("annotates contexts", func() {
+		ctx := context.Background()
+		Expect(Required(ctx)).To(BeEmpty())
+		Expect(CheckContext(ctx)).To(Succeed())
+		ctx1 := WithRequired(ctx, CAP_NET_RAW)
+		ctx2 := WithRequired(ctx1, CAP_NET_ADMIN, CAP_NET_RAW)
+		Expect(Required(ctx1).Names()).To(ConsistOf("CAP_NET_RAW"))
+		Expect(Required(ctx2).Names()).To(ConsistOf("CAP_NET_RAW", "CAP_NET_ADMIN"))
+		r := Required(ctx1)
+		r.Add(CAP_SYS_ADMIN)
+		Expect(Required(ctx1).Has(CAP_SYS_ADMIN)).To(BeFalse())
+	})
+
+	It("checks the requirements on the current thread", func() {
+		if inUserNamespaceUnlessRoot() {
+			return
+		}
+		capstest.RequireEffective(GinkgoT(), CAP_NET_RAW, CAP_NET_ADMIN)
+		ctx := WithRequired(context.Background(), CAP_NET_RAW, CAP_NET_ADMIN)
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			// Never unlock this thread, so that it gets thrown away when this
+			// Go routine finishes.
+			runtime.LockOSThread()
+			Expect(CheckContext(ctx)).To(Succeed())
+			taskcaps := Successful(OfThisTask())
+			taskcaps.Effective.Drop(CAP_NET_RAW)
+			Expect(SetForThisTask(taskcaps)).To(Succeed())
+			Expect(CheckContext(ctx)).To(MatchError(
+				"required capabilities not effective: CAP_NET_RAW"))
+		}()
+		<-done
+	})
+
+})
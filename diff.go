@@ -0,0 +1,164 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import "strings"
+
+// SetDiff describes the differences between two capabilities sets.
+type SetDiff struct {
+	Added   CapabilitiesSet `yaml:"added"`   // capabilities only in the after set
+	Removed CapabilitiesSet `yaml:"removed"` // capabilities only in the before set
+}
+
+// DiffSets returns the differences between the before and after capabilities
+// sets.
+func DiffSets(before, after CapabilitiesSet) SetDiff {
+	d := SetDiff{Added: after.Clone(), Removed: before.Clone()}
+	d.Added.dropSet(before)
+	d.Removed.dropSet(after)
+	return d
+}
+
+// Empty returns true if there are no differences.
+func (d SetDiff) Empty() bool {
+	return isEmptySet(d.Added) && isEmptySet(d.Removed)
+}
+
+// String returns the differences as the names of the added capabilities
+// prefixed with “+”, followed by the names of the removed capabilities
+// prefixed with “-”, both sorted by increasing capability number and separated
+// by spaces, such as "+CAP_NET_ADMIN -CAP_SYS_ADMIN".
+func (d SetDiff) String() string {
+	return diffString(d.Added.Names(), d.Removed.Names())
+}
+
+// StateDiff describes the differences between two task capabilities-related
+// states, such as before and after an upgrade.
+type StateDiff struct {
+	Effective         SetDiff `yaml:"effective"`
+	Permitted         SetDiff `yaml:"permitted"`
+	Inheritable       SetDiff `yaml:"inheritable"`
+	Bounding          SetDiff `yaml:"bounding"`
+	Ambient           SetDiff `yaml:"ambient"`
+	SecurebitsSet     uint    `yaml:"securebitsset"`     // securebits only set after
+	SecurebitsCleared uint    `yaml:"securebitscleared"` // securebits only set before
+	NoNewPrivsChanged bool    `yaml:"nonewprivschanged"` // no_new_privs flag changed
+	NoNewPrivs        bool    `yaml:"nonewprivs"`        // no_new_privs flag after
+}
+
+// DiffStates returns the differences between the before and after task
+// capabilities-related states, covering not only the effective, permitted,
+// and inheritable capabilities, but also the bounding and ambient
+// capabilities, the securebits, and the no_new_privs flag.
+func DiffStates(before, after State) StateDiff {
+	return StateDiff{
+		Effective:         DiffSets(before.Effective, after.Effective),
+		Permitted:         DiffSets(before.Permitted, after.Permitted),
+		Inheritable:       DiffSets(before.Inheritable, after.Inheritable),
+		Bounding:          DiffSets(before.Bounding, after.Bounding),
+		Ambient:           DiffSets(before.Ambient, after.Ambient),
+		SecurebitsSet:     after.Securebits &^ before.Securebits,
+		SecurebitsCleared: before.Securebits &^ after.Securebits,
+		NoNewPrivsChanged: before.NoNewPrivs != after.NoNewPrivs,
+		NoNewPrivs:        after.NoNewPrivs,
+	}
+}
+
+// Empty returns true if there are no differences.
+func (d StateDiff) Empty() bool {
+	return d.Effective.Empty() && d.Permitted.Empty() && d.Inheritable.Empty() &&
+		d.Bounding.Empty() && d.Ambient.Empty() &&
+		d.SecurebitsSet == 0 && d.SecurebitsCleared == 0 &&
+		!d.NoNewPrivsChanged
+}
+
+// String returns a stable textual rendering of the differences, with one line
+// per changed aspect in the fixed order of effective, permitted, inheritable,
+// bounding, and ambient capabilities, securebits, and no_new_privs flag.
+// Unchanged aspects are left out, so no differences render as an empty string.
+// For example:
+//
+//	effective: +CAP_NET_ADMIN -CAP_SYS_ADMIN
+//	bounding: -CAP_SYS_ADMIN
+//	securebits: +SECBIT_NOROOT
+//	nonewprivs: 0 -> 1
+func (d StateDiff) String() string {
+	var b strings.Builder
+	for _, set := range []struct {
+		name string
+		diff SetDiff
+	}{
+		{"effective", d.Effective},
+		{"permitted", d.Permitted},
+		{"inheritable", d.Inheritable},
+		{"bounding", d.Bounding},
+		{"ambient", d.Ambient},
+	} {
+		if set.diff.Empty() {
+			continue
+		}
+		b.WriteString(set.name)
+		b.WriteString(": ")
+		b.WriteString(set.diff.String())
+		b.WriteByte('\n')
+	}
+	if d.SecurebitsSet != 0 || d.SecurebitsCleared != 0 {
+		b.WriteString("securebits: ")
+		b.WriteString(diffString(SecurebitsNames(d.SecurebitsSet), SecurebitsNames(d.SecurebitsCleared)))
+		b.WriteByte('\n')
+	}
+	if d.NoNewPrivsChanged {
+		if d.NoNewPrivs {
+			b.WriteString("nonewprivs: 0 -> 1\n")
+		} else {
+			b.WriteString("nonewprivs: 1 -> 0\n")
+		}
+	}
+	return b.String()
+}
+
+// diffString returns the added names prefixed with “+” followed by the removed
+// names prefixed with “-”, separated by spaces.
+func diffString(added, removed []string) string {
+	var b strings.Builder
+	for _, name := range added {
+		if b.Len() != 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteByte('+')
+		b.WriteString(name)
+	}
+	for _, name := range removed {
+		if b.Len() != 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteByte('-')
+		b.WriteString(name)
+	}
+	return b.String()
+}
+
+// isEmptySet returns true if the specified capabilities set contains no
+// capabilities.
+func isEmptySet(c CapabilitiesSet) bool {
+	for _, w := range c {
+		if w != 0 {
+			return false
+		}
+	}
+	return true
+}
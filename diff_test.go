@@ -0,0 +1,76 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("state differences", func() {
+
+	It("diffs capabilities sets", func() {
+		before := NewCapabilitiesSet()
+		before.Add(CAP_CHOWN, CAP_SYS_ADMIN)
+		after := NewCapabilitiesSet()
+		after.Add(CAP_CHOWN, CAP_NET_ADMIN, 63)
+
+		d := DiffSets(before, after)
+		Expect(d.Empty()).To(BeFalse())
+		Expect(d.Added.Numbers()).To(ConsistOf(CAP_NET_ADMIN, 63))
+		Expect(d.Removed.Numbers()).To(ConsistOf(CAP_SYS_ADMIN))
+		Expect(d.String()).To(Equal("+CAP_NET_ADMIN +CAP_63 -CAP_SYS_ADMIN"))
+
+		Expect(DiffSets(before, before.Clone()).Empty()).To(BeTrue())
+		Expect(DiffSets(nil, NewCapabilitiesSet()).Empty()).To(BeTrue())
+		Expect(before.Numbers()).To(ConsistOf(CAP_CHOWN, CAP_SYS_ADMIN))
+	})
+
+	It("diffs states", func() {
+		before := Successful(parseStatus([]byte(taskStatus)))
+		Expect(DiffStates(before, before).Empty()).To(BeTrue())
+		Expect(DiffStates(before, before).String()).To(BeEmpty())
+
+		after := Successful(parseStatus([]byte(taskStatus)))
+		after.Effective.Drop(CAP_SYS_ADMIN)
+		after.Inheritable.Add(CAP_NET_ADMIN)
+		after.Bounding.Drop(CAP_SYS_ADMIN)
+		after.Ambient.Add(CAP_NET_RAW)
+		after.Ambient.Drop(CAP_NET_BIND_SERVICE)
+		after.Securebits = SECBIT_NOROOT
+		before.Securebits = SECBIT_KEEP_CAPS
+		after.NoNewPrivs = !before.NoNewPrivs
+
+		d := DiffStates(before, after)
+		Expect(d.Empty()).To(BeFalse())
+		Expect(d.Permitted.Empty()).To(BeTrue())
+		Expect(d.SecurebitsSet).To(Equal(uint(SECBIT_NOROOT)))
+		Expect(d.SecurebitsCleared).To(Equal(uint(SECBIT_KEEP_CAPS)))
+		Expect(d.NoNewPrivsChanged).To(BeTrue())
+		nnp := "nonewprivs: 0 -> 1\n"
+		if before.NoNewPrivs {
+			nnp = "nonewprivs: 1 -> 0\n"
+		}
+		Expect(d.String()).To(Equal(
+			"effective: -CAP_SYS_ADMIN\n" +
+				"inheritable: +CAP_NET_ADMIN\n" +
+				"bounding: -CAP_SYS_ADMIN\n" +
+				"ambient: +CAP_NET_RAW -CAP_NET_BIND_SERVICE\n" +
+				"securebits: +SECBIT_NOROOT -SECBIT_KEEP_CAPS\n" +
+				nnp))
+	})
+
+})
@@ -30,6 +30,26 @@ And finally to regain all originally effective capabilities:
 
 	caps.SetForThisTask(origcaps)
 
+# Zero Values
+
+The zero values of this package's types are ready to use and never cause
+panics:
+
+  - a nil [CapabilitiesSet] is an empty set. All methods accept nil sets;
+    [CapabilitiesSet.Clone] and [CapabilitiesSet.Compact] return non-nil sets
+    even then. [CapabilitiesSet.Add] grows nil sets held in variables or
+    fields, but obviously cannot work on nil pointers to sets. Nil and empty
+    sets marshal identically, so compare sets using [DiffSets] instead of
+    reflect.DeepEqual.
+  - a zero [TaskCapabilities] has no capabilities in any of its sets; setting
+    it drops all capabilities of the calling task.
+  - a zero [State] has no capabilities at all, no securebits, and no_new_privs
+    unset.
+  - a zero [Policy] allows no capabilities at all, so only requests without any
+    capabilities pass.
+
+Negative capability numbers are programming errors, so they cause panics.
+
 # Notes
 
 This package assumes at least a kernel version 2.65 or later and does not
@@ -0,0 +1,113 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Seccomp modes as reported in the "Seccomp:" field of /proc/[pid]/status.
+const (
+	SeccompDisabled = 0 // no seccomp restrictions
+	SeccompStrict   = 1 // strict mode, allowing only read, write, _exit, and sigreturn
+	SeccompFilter   = 2 // filter mode, allowing syscalls as decided by BPF filters
+)
+
+// EnvironmentInfo describes detected quirks of the environment we're running
+// in that affect how capabilities behave.
+type EnvironmentInfo struct {
+	GVisor                     bool   // running inside the gVisor sandbox
+	UML                        bool   // running on a User Mode Linux kernel
+	WSL1                       bool   // running on Windows Subsystem for Linux version 1
+	Seccomp                    int    // seccomp mode, see SeccompDisabled, et cetera.
+	AppArmorProfile            string // confining AppArmor profile, if any
+	SELinuxEnforcing           bool   // SELinux is enforcing its policy
+	InitialUserNamespace       bool   // running in the initial user namespace
+	UnprivilegedUserNamespaces bool   // unprivileged users can create user namespaces
+}
+
+// Environment returns the detected quirks of the environment we're currently
+// running in, such as running in a sandbox that fakes or restricts
+// capabilities, seccomp filtering, LSM confinement, and restrictions on user
+// namespaces. The detection is heuristic and based on information in /proc and
+// /sys; if information is unavailable, the corresponding quirk is considered
+// to be absent.
+func Environment() EnvironmentInfo {
+	return detectEnvironment(func(name string) ([]byte, error) {
+		switch {
+		case strings.HasPrefix(name, "/proc/self/"):
+			name = ownProcPath(name[len("/proc/"):])
+		case strings.HasPrefix(name, "/proc/"):
+			name = procPath(name[len("/proc/"):])
+		}
+		return os.ReadFile(name)
+	})
+}
+
+// detectEnvironment returns the detected environment quirks, reading the
+// necessary information using the specified readFile function.
+func detectEnvironment(readFile func(name string) ([]byte, error)) EnvironmentInfo {
+	env := EnvironmentInfo{
+		InitialUserNamespace:       true,
+		UnprivilegedUserNamespaces: true,
+	}
+	readString := func(name string) string {
+		contents, _ := readFile(name)
+		return strings.TrimSpace(string(contents))
+	}
+	// gVisor presents a fixed fake kernel version.
+	env.GVisor = strings.Contains(readString("/proc/version"),
+		"4.4.0 #1 SMP Sun Jan 10 15:06:54 PST 2016")
+	env.UML = strings.Contains(readString("/proc/cpuinfo"), "User Mode Linux")
+	// WSL1 uses a capitalized "Microsoft" in its kernel release, while WSL2
+	// uses "microsoft".
+	env.WSL1 = strings.Contains(readString("/proc/sys/kernel/osrelease"), "Microsoft")
+
+	status, _ := readFile("/proc/self/status")
+	scanner := bufio.NewScanner(bytes.NewReader(status))
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "Seccomp:") {
+			env.Seccomp, _ = strconv.Atoi(strings.TrimSpace(line[len("Seccomp:"):]))
+			break
+		}
+	}
+
+	// The AppArmor confinement is shown in the form of "profile (mode)".
+	if profile := readString("/proc/self/attr/apparmor/current"); profile != "" {
+		env.AppArmorProfile = profile
+	} else {
+		env.AppArmorProfile = readString("/proc/self/attr/current")
+	}
+	if env.AppArmorProfile == "unconfined" || strings.Contains(env.AppArmorProfile, ":") {
+		// SELinux contexts look like "user:role:type:level" instead.
+		env.AppArmorProfile = ""
+	}
+	env.SELinuxEnforcing = readString("/sys/fs/selinux/enforce") == "1"
+
+	if uidmap := strings.Fields(readString("/proc/self/uid_map")); len(uidmap) == 3 {
+		env.InitialUserNamespace = uidmap[0] == "0" && uidmap[1] == "0" && uidmap[2] == "4294967295"
+	}
+	if readString("/proc/sys/kernel/unprivileged_userns_clone") == "0" ||
+		readString("/proc/sys/user/max_user_namespaces") == "0" {
+		env.UnprivilegedUserNamespaces = false
+	}
+	return env
+}
@@ -0,0 +1,84 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeFiles returns a readFile function serving the specified fake file
+// contents.
+func fakeFiles(files map[string]string) func(string) ([]byte, error) {
+	return func(name string) ([]byte, error) {
+		contents, ok := files[name]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return []byte(contents), nil
+	}
+}
+
+var _ = Describe("environment", func() {
+
+	It("detects the current environment", func() {
+		env := Environment()
+		Expect(env.GVisor).To(BeFalse())
+		Expect(env.WSL1).To(BeFalse())
+	})
+
+	It("assumes no quirks without information", func() {
+		Expect(detectEnvironment(fakeFiles(nil))).To(Equal(EnvironmentInfo{
+			InitialUserNamespace:       true,
+			UnprivilegedUserNamespaces: true,
+		}))
+	})
+
+	It("detects quirks", func() {
+		Expect(detectEnvironment(fakeFiles(map[string]string{
+			"/proc/version":                              "Linux version 4.4.0 #1 SMP Sun Jan 10 15:06:54 PST 2016\n",
+			"/proc/cpuinfo":                              "vendor_id\t: User Mode Linux\n",
+			"/proc/sys/kernel/osrelease":                 "4.4.0-19041-Microsoft\n",
+			"/proc/self/status":                          "Name:\tfoo\nSeccomp:\t2\nSeccomp_filters:\t1\n",
+			"/proc/self/attr/apparmor/current":           "docker-default (enforce)\n",
+			"/sys/fs/selinux/enforce":                    "1",
+			"/proc/self/uid_map":                         "         0     100000      65536\n",
+			"/proc/sys/kernel/unprivileged_userns_clone": "0\n",
+		}))).To(Equal(EnvironmentInfo{
+			GVisor:           true,
+			UML:              true,
+			WSL1:             true,
+			Seccomp:          SeccompFilter,
+			AppArmorProfile:  "docker-default (enforce)",
+			SELinuxEnforcing: true,
+		}))
+	})
+
+	It("tells AppArmor from SELinux", func() {
+		Expect(detectEnvironment(fakeFiles(map[string]string{
+			"/proc/self/attr/current": "system_u:system_r:container_t:s0\n",
+			"/proc/self/uid_map":      "0 0 4294967295\n",
+		})).AppArmorProfile).To(BeEmpty())
+		Expect(detectEnvironment(fakeFiles(map[string]string{
+			"/proc/self/attr/current": "unconfined\n",
+		})).AppArmorProfile).To(BeEmpty())
+		Expect(detectEnvironment(fakeFiles(map[string]string{
+			"/proc/self/attr/current": "foo (complain)\n",
+		})).AppArmorProfile).To(Equal("foo (complain)"))
+	})
+
+})
@@ -1,38 +1,141 @@
+//go:build linux
+
 package errno
 
 import (
+	"errors"
 	"syscall"
 )
 
-// Do the interface allocations only once for common
-// Errno values.
+// boxed contains the error-type values of all known error numbers, indexed
+// by error number, so that the interface allocations happen only once.
+var boxed = boxAll()
+
+// Some common boxed Errno values.
 var (
-	errEAGAIN   error = syscall.EAGAIN
-	errEBADF    error = syscall.EBADF
-	errEINVAL   error = syscall.EINVAL
-	errENOENT   error = syscall.ENOENT
-	errENOTSOCK error = syscall.ENOTSOCK
+	errEAGAIN   = boxed[syscall.EAGAIN]
+	errEBADF    = boxed[syscall.EBADF]
+	errEINVAL   = boxed[syscall.EINVAL]
+	errENOENT   = boxed[syscall.ENOENT]
+	errENOTSOCK = boxed[syscall.ENOTSOCK]
 )
 
+// boxAll returns the boxed error-type values for all error numbers in the
+// generated errnos table.
+func boxAll() []error {
+	highest := syscall.Errno(0)
+	for _, e := range errnos {
+		if e > highest {
+			highest = e
+		}
+	}
+	boxed := make([]error, highest+1)
+	for _, e := range errnos {
+		boxed[e] = e
+	}
+	return boxed
+}
+
 // Error turns a syscall.Errno in an ordinary error-type value -- this mimics
 // the behavior of golang.org/x/sys/unix for returning boxed [syscall.EAGAIN],
 // [syscall.EINVAL] and [syscall.ENOENT] instead of their unix package
 // counterparts (The Source tells us that this prevents allocations at runtime).
-// This function returns nil if the error number is zero.
+// In contrast to golang.org/x/sys/unix, Error returns boxed values for all
+// error numbers known to golang.org/x/sys/unix. This function returns nil if
+// the error number is zero.
 func Error(e syscall.Errno) error {
-	switch e {
-	case 0:
+	if e == 0 {
 		return nil
-	case syscall.EBADF: // Brian says: Be Different!
-		return errEBADF
-	case syscall.ENOTSOCK:
-		return errENOTSOCK
-	case syscall.EAGAIN:
-		return errEAGAIN
-	case syscall.EINVAL:
-		return errEINVAL
-	case syscall.ENOENT:
-		return errENOENT
+	}
+	if e < syscall.Errno(len(boxed)) {
+		if err := boxed[e]; err != nil {
+			return err
+		}
 	}
 	return e
 }
+
+// AllErrnos returns all error numbers known to this package, in increasing
+// order, such as for tooling.
+func AllErrnos() []syscall.Errno {
+	all := make([]syscall.Errno, len(errnos))
+	copy(all, errnos[:])
+	return all
+}
+
+// Wrap turns a syscall.Errno into an [*OpError] error-type value that is
+// prefixed with the specified operation, such as "capget", while still
+// matching the (boxed) error number using [errors.Is], as well as the error
+// categories [ErrPermission] and [ErrNotFound]. Wrap returns nil if the error
+// number is zero.
+func Wrap(op string, e syscall.Errno) error {
+	if e == 0 {
+		return nil
+	}
+	return &OpError{Op: op, Errno: e}
+}
+
+// OpError is an error number that occurred in a particular operation.
+type OpError struct {
+	Op    string        // operation, such as "capget"
+	Errno syscall.Errno // error number
+}
+
+// Error returns the operation followed by the error number's description.
+func (e *OpError) Error() string {
+	return e.Op + ": " + e.Errno.Error()
+}
+
+// Unwrap returns the boxed error number.
+func (e *OpError) Unwrap() error {
+	return Error(e.Errno)
+}
+
+// Is returns true if the target is an error [Category] the error number
+// belongs to.
+func (e *OpError) Is(target error) bool {
+	c, ok := target.(*Category)
+	return ok && c.has(e.Errno)
+}
+
+// Category is a semantic category of error numbers, such as
+// [ErrPermission], allowing callers to branch on the category of an error
+// instead of enumerating raw error numbers. Errors returned by [Wrap] match
+// their categories using [errors.Is]; use [Category.Matches] for arbitrary
+// errors wrapping a syscall.Errno.
+type Category struct {
+	name   string
+	errnos []syscall.Errno
+}
+
+// Error categories.
+var (
+	// ErrPermission is the category of errors due to missing privileges,
+	// matching EPERM and EACCES.
+	ErrPermission = &Category{name: "permission denied", errnos: []syscall.Errno{
+		syscall.EPERM, syscall.EACCES}}
+	// ErrNotFound is the category of errors due to a process, task, or file
+	// not being found (anymore), matching ESRCH and ENOENT.
+	ErrNotFound = &Category{name: "not found", errnos: []syscall.Errno{
+		syscall.ESRCH, syscall.ENOENT}}
+)
+
+// Error returns the name of the error category.
+func (c *Category) Error() string { return c.name }
+
+// Matches returns true if the specified error is or wraps a syscall.Errno
+// belonging to this category.
+func (c *Category) Matches(err error) bool {
+	var e syscall.Errno
+	return errors.As(err, &e) && c.has(e)
+}
+
+// has returns true if the error number belongs to this category.
+func (c *Category) has(e syscall.Errno) bool {
+	for _, errno := range c.errnos {
+		if e == errno {
+			return true
+		}
+	}
+	return false
+}
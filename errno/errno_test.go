@@ -15,8 +15,12 @@
 package errno
 
 import (
+	"errors"
+	"fmt"
 	"syscall"
 
+	"golang.org/x/sys/unix"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -34,6 +38,17 @@ var _ = Describe("boxed errno error values", func() {
 		Entry("ENOENT", syscall.ENOENT, errENOENT),
 	)
 
+	It("returns boxed error values for all errno values", func() {
+		all := AllErrnos()
+		Expect(all).To(ContainElements(syscall.EPERM, unix.EHWPOISON))
+		for _, e := range all {
+			Expect(Error(e)).To(BeIdenticalTo(Error(e)))
+			Expect(Error(e)).To(MatchError(e))
+		}
+		all[0] = 0
+		Expect(AllErrnos()[0]).To(Equal(syscall.EPERM))
+	})
+
 	It("returns nil for errno 0", func() {
 		Expect(Error(0)).To(BeNil())
 	})
@@ -42,4 +57,25 @@ var _ = Describe("boxed errno error values", func() {
 		Expect(Error(42000)).To(MatchError("errno 42000"))
 	})
 
+	It("wraps errno values with operations", func() {
+		Expect(Wrap("capget", 0)).To(BeNil())
+		err := Wrap("capget", syscall.EINVAL)
+		Expect(err).To(MatchError("capget: invalid argument"))
+		Expect(errors.Unwrap(err)).To(BeIdenticalTo(errEINVAL))
+		Expect(errors.Is(err, syscall.EINVAL)).To(BeTrue())
+	})
+
+	It("matches error categories", func() {
+		Expect(errors.Is(Wrap("capget", syscall.EPERM), ErrPermission)).To(BeTrue())
+		Expect(errors.Is(Wrap("capget", syscall.EACCES), ErrPermission)).To(BeTrue())
+		Expect(errors.Is(Wrap("capget", syscall.ESRCH), ErrNotFound)).To(BeTrue())
+		Expect(errors.Is(Wrap("capget", syscall.ESRCH), ErrPermission)).To(BeFalse())
+		Expect(errors.Is(Wrap("capget", syscall.EINVAL), ErrNotFound)).To(BeFalse())
+
+		Expect(ErrNotFound.Matches(syscall.ENOENT)).To(BeTrue())
+		Expect(ErrNotFound.Matches(fmt.Errorf("foo: %w", syscall.ESRCH))).To(BeTrue())
+		Expect(ErrNotFound.Matches(errors.New("D'OH!"))).To(BeFalse())
+		Expect(ErrPermission.Error()).To(Equal("permission denied"))
+	})
+
 })
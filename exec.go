@@ -0,0 +1,237 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// WithEffective returns a copy of these file capabilities with the effective
+// flag set as specified. If set, all capabilities a task gets permitted when
+// executing the binary also become effective, so that “capability-dumb”
+// binaries work without raising their capabilities themselves. Otherwise,
+// the binary gets its new permitted capabilities not effective (except for
+// ambient capabilities, which don't exist for binaries with file
+// capabilities) and has to raise them itself. See [ExecTransition] for
+// details.
+func (fc FileCapabilities) WithEffective(effective bool) FileCapabilities {
+	fc.Permitted = fc.Permitted.Clone()
+	fc.Inheritable = fc.Inheritable.Clone()
+	fc.Effective = effective
+	return fc
+}
+
+// ExecTransition returns the capabilities state of a task after executing a
+// binary using execve(2), starting from the specified parent state and with
+// the specified file capabilities of the binary, or nil if the binary has no
+// file capabilities. ExecTransition follows the transformation rules of
+// capabilities(7), with P the parent's and F the file's capabilities:
+//
+//	P'(ambient)     = (F exists) ? 0 : P(ambient)
+//	P'(permitted)   = (P(inheritable) & F(inheritable)) |
+//	                  (F(permitted) & P(bounding)) | P'(ambient)
+//	P'(effective)   = F(effective) ? P'(permitted) : P'(ambient)
+//	P'(inheritable) = P(inheritable)
+//	P'(bounding)    = P(bounding)
+//
+// If no_new_privs is set, the new permitted capabilities are limited to the
+// parent's permitted capabilities. If the file's effective flag is set but not
+// all of the file's permitted capabilities can be granted, the kernel refuses
+// to execute such a “capability-dumb” binary, and ExecTransition returns an
+// error wrapping [unix.EPERM].
+//
+// ExecTransition doesn't model the special treatment of the root user (unless
+// SECBIT_NOROOT is set), set-user-ID and set-group-ID binaries, and of
+// filesystems mounted nosuid, which make the kernel ignore file capabilities.
+// The resulting state has no capabilities sets in common with the parent
+// state.
+func ExecTransition(parent State, file *FileCapabilities) (State, error) {
+	child := State{
+		TaskCapabilities: TaskCapabilities{
+			Inheritable: parent.Inheritable.Clone(),
+		},
+		Bounding:   parent.Bounding.Clone(),
+		Securebits: parent.Securebits &^ SECBIT_KEEP_CAPS,
+		NoNewPrivs: parent.NoNewPrivs,
+	}
+	if file == nil {
+		child.Ambient = parent.Ambient.Clone()
+		child.Permitted = parent.Ambient.Clone()
+		child.Effective = parent.Ambient.Clone()
+		return child, nil
+	}
+	child.Ambient = NewCapabilitiesSet()
+	child.Permitted = AggregateUnion([]CapabilitiesSet{
+		AggregateIntersection([]CapabilitiesSet{parent.Inheritable, file.Inheritable}),
+		AggregateIntersection([]CapabilitiesSet{file.Permitted, parent.Bounding}),
+	})
+	if file.Effective {
+		missing := file.Permitted.Clone()
+		missing.dropSet(child.Permitted)
+		if names := missing.Names(); len(names) != 0 {
+			return State{}, fmt.Errorf("capability-dumb binary cannot get %s: %w",
+				strings.Join(names, ", "), unix.EPERM)
+		}
+	}
+	if parent.NoNewPrivs {
+		child.Permitted = AggregateIntersection([]CapabilitiesSet{child.Permitted, parent.Permitted})
+	}
+	if file.Effective {
+		child.Effective = child.Permitted.Clone()
+	} else {
+		child.Effective = NewCapabilitiesSet()
+	}
+	return child, nil
+}
+
+// ExplainExec returns findings explaining how executing a binary with the
+// specified file capabilities, or nil for none, transforms the specified
+// parent state, as calculated by [ExecTransition]. In particular, the findings
+// explain the effect of the file's effective flag.
+func ExplainExec(parent State, file *FileCapabilities) Findings {
+	if file == nil {
+		if isEmptySet(parent.Ambient) {
+			return Findings{infoFinding("ambient",
+				"binary has no file capabilities and there are no ambient capabilities, so the new program gets no capabilities")}
+		}
+		return Findings{infoFinding("ambient",
+			"binary has no file capabilities, so the ambient capabilities %s become permitted and effective",
+			parent.Ambient.String())}
+	}
+	var findings Findings
+	if !isEmptySet(parent.Ambient) {
+		findings = append(findings, warningFinding("ambient",
+			"ambient capabilities %s are cleared, as the binary has file capabilities",
+			parent.Ambient.String()))
+	}
+	if bounded := without(file.Permitted, parent.Bounding); !isEmptySet(bounded) {
+		findings = append(findings, warningFinding("bounding",
+			"file permitted capabilities %s are not granted, as they are not in the bounding set",
+			bounded.String()).
+			withRemediation("keep %s in the bounding set of the invoking task", bounded.String()))
+	}
+	if notInherited := without(file.Inheritable, parent.Inheritable); !isEmptySet(notInherited) {
+		findings = append(findings, infoFinding("inheritable",
+			"file inheritable capabilities %s are not granted, as they are not in the inheritable set of the invoking task",
+			notInherited.String()))
+	}
+	child, err := ExecTransition(parent, file)
+	if err != nil {
+		return append(findings, errorFinding("effective flag",
+			"file effective flag is set, but not all file permitted capabilities can be granted, so execution fails with EPERM").
+			withRemediation("keep the file permitted capabilities in the bounding set of the invoking task"))
+	}
+	if parent.NoNewPrivs {
+		if lost := without(AggregateUnion([]CapabilitiesSet{
+			AggregateIntersection([]CapabilitiesSet{parent.Inheritable, file.Inheritable}),
+			AggregateIntersection([]CapabilitiesSet{file.Permitted, parent.Bounding}),
+		}), child.Permitted); !isEmptySet(lost) {
+			findings = append(findings, warningFinding("no_new_privs",
+				"no_new_privs limits the new permitted capabilities to the permitted capabilities of the invoking task, dropping %s",
+				lost.String()))
+		}
+	}
+	switch {
+	case isEmptySet(child.Permitted):
+		findings = append(findings, infoFinding("effective flag",
+			"the new program gets no permitted capabilities"))
+	case file.Effective:
+		findings = append(findings, infoFinding("effective flag",
+			"file effective flag is set, so the new permitted capabilities %s also become effective",
+			child.Permitted.String()))
+	default:
+		findings = append(findings, infoFinding("effective flag",
+			"file effective flag is not set, so the new permitted capabilities %s are not effective until the program raises them",
+			child.Permitted.String()).
+			withRemediation("set the file effective flag for programs not raising their capabilities themselves"))
+	}
+	return findings
+}
+
+// PredictChildCaps predicts the effective, permitted and inheritable
+// capabilities a task gets when a task in the specified parent state executes
+// the binary at the specified path, together with findings explaining the
+// prediction. This answers questions such as “will my helper binary actually
+// have CAP_NET_ADMIN when my service executes it?” in a single call.
+//
+// PredictChildCaps reads the file capabilities of the binary, takes into
+// account whether the kernel will ignore them, and then applies
+// [ExecTransition]. The kernel ignores file capabilities on filesystems
+// mounted nosuid, as well as namespaced file capabilities for another root
+// user than the root user of the calling task's user namespace.
+//
+// If the binary cannot be executed because the file's effective flag is set
+// but not all file permitted capabilities can be granted, PredictChildCaps
+// returns an error wrapping [unix.EPERM], together with findings explaining
+// why. Other errors are returned when the binary or its file capabilities
+// cannot be read.
+func PredictChildCaps(binaryPath string, parent State) (TaskCapabilities, Findings, error) {
+	info, err := os.Stat(binaryPath)
+	if err != nil {
+		return TaskCapabilities{}, nil, err
+	}
+	var findings Findings
+	if info.Mode()&os.ModeSetuid != 0 {
+		findings = append(findings, warningFinding("set-user-ID",
+			"binary is set-user-ID, which might change its capabilities beyond this prediction"))
+	}
+	var file *FileCapabilities
+	fc, err := FileCapabilitiesOf(binaryPath)
+	switch {
+	case err == nil:
+		file = &fc
+	case !errors.Is(err, unix.ENODATA):
+		return TaskCapabilities{}, nil, err
+	}
+	if file != nil {
+		var fs unix.Statfs_t
+		if err := unix.Statfs(binaryPath, &fs); err != nil {
+			return TaskCapabilities{}, nil, &os.PathError{Op: "statfs", Path: binaryPath, Err: err}
+		}
+		switch {
+		case fs.Flags&unix.ST_NOSUID != 0:
+			findings = append(findings, warningFinding("mount",
+				"binary resides on a filesystem mounted nosuid, so its file capabilities are ignored").
+				withRemediation("move the binary to a filesystem mounted without nosuid"))
+			file = nil
+		case file.Namespaced:
+			findings = append(findings, warningFinding("file capabilities",
+				"file capabilities are for root user ID %d of another user namespace, so they are ignored",
+				file.RootID))
+			file = nil
+		}
+	}
+	findings = append(findings, ExplainExec(parent, file)...)
+	child, err := ExecTransition(parent, file)
+	if err != nil {
+		return TaskCapabilities{}, findings, err
+	}
+	return child.TaskCapabilities, findings, nil
+}
+
+// without returns a new set with the capabilities of set c that are not in
+// the other set.
+func without(c, other CapabilitiesSet) CapabilitiesSet {
+	c = c.Clone()
+	c.dropSet(other)
+	return c
+}
@@ -0,0 +1,185 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/thediveo/caps/capstest"
+	"golang.org/x/sys/unix"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+// capset returns a new capabilities set with the specified capabilities.
+func capset(capnos ...int) CapabilitiesSet {
+	c := NewCapabilitiesSet()
+	for _, capno := range capnos {
+		c.Add(capno)
+	}
+	return c
+}
+
+var _ = Describe("exec transitions", func() {
+
+	var parent State
+
+	BeforeEach(func() {
+		parent = State{
+			TaskCapabilities: TaskCapabilities{
+				Effective:   capset(CAP_NET_RAW),
+				Permitted:   capset(CAP_NET_RAW),
+				Inheritable: capset(CAP_NET_ADMIN),
+			},
+			Bounding:   capset(CAP_NET_RAW, CAP_NET_ADMIN, CAP_NET_BIND_SERVICE),
+			Ambient:    capset(CAP_NET_RAW),
+			Securebits: SECBIT_KEEP_CAPS | SECBIT_NOROOT,
+		}
+	})
+
+	It("sets the effective flag on a copy", func() {
+		fc := FileCapabilities{Permitted: capset(CAP_NET_RAW)}
+		eff := fc.WithEffective(true)
+		Expect(eff.Effective).To(BeTrue())
+		Expect(fc.Effective).To(BeFalse())
+		eff.Permitted.Add(CAP_CHOWN)
+		Expect(fc.Permitted.Has(CAP_CHOWN)).To(BeFalse())
+	})
+
+	It("passes ambient capabilities to binaries without file capabilities", func() {
+		child := Successful(ExecTransition(parent, nil))
+		Expect(child.Permitted.Names()).To(ConsistOf("CAP_NET_RAW"))
+		Expect(child.Effective.Names()).To(ConsistOf("CAP_NET_RAW"))
+		Expect(child.Ambient.Names()).To(ConsistOf("CAP_NET_RAW"))
+		Expect(child.Inheritable.Names()).To(ConsistOf("CAP_NET_ADMIN"))
+		Expect(child.Securebits).To(Equal(uint(SECBIT_NOROOT)))
+		Expect(ExplainExec(parent, nil).Messages()).To(ConsistOf(
+			ContainSubstring("ambient capabilities CAP_NET_RAW become permitted and effective")))
+
+		parent.Ambient = nil
+		child = Successful(ExecTransition(parent, nil))
+		Expect(child.Permitted.Names()).To(BeEmpty())
+		Expect(ExplainExec(parent, nil).Messages()).To(ConsistOf(
+			ContainSubstring("new program gets no capabilities")))
+	})
+
+	It("grants file capabilities", func() {
+		fc := FileCapabilities{
+			Permitted:   capset(CAP_NET_BIND_SERVICE),
+			Inheritable: capset(CAP_NET_ADMIN, CAP_SYS_ADMIN),
+		}
+		child := Successful(ExecTransition(parent, &fc))
+		Expect(child.Permitted.Names()).To(ConsistOf("CAP_NET_ADMIN", "CAP_NET_BIND_SERVICE"))
+		Expect(child.Effective.Names()).To(BeEmpty())
+		Expect(child.Ambient.Names()).To(BeEmpty())
+		findings := ExplainExec(parent, &fc)
+		Expect(findings.Messages()).To(ConsistOf(
+			ContainSubstring("ambient capabilities CAP_NET_RAW are cleared"),
+			ContainSubstring("file inheritable capabilities CAP_SYS_ADMIN are not granted"),
+			ContainSubstring("file effective flag is not set"),
+		))
+
+		fc = fc.WithEffective(true)
+		child = Successful(ExecTransition(parent, &fc))
+		Expect(child.Effective.Names()).To(ConsistOf("CAP_NET_ADMIN", "CAP_NET_BIND_SERVICE"))
+		Expect(ExplainExec(parent, &fc).Messages()).To(ContainElement(
+			ContainSubstring("file effective flag is set, so the new permitted capabilities CAP_NET_ADMIN, CAP_NET_BIND_SERVICE also become effective")))
+	})
+
+	It("refuses capability-dumb binaries not getting all capabilities", func() {
+		fc := FileCapabilities{Permitted: capset(CAP_SYS_ADMIN), Effective: true}
+		Expect(ExecTransition(parent, &fc)).Error().To(MatchError(unix.EPERM))
+		findings := ExplainExec(parent, &fc)
+		Expect(findings.Severity()).To(Equal(SeverityError))
+		Expect(findings.Messages()).To(ContainElement(
+			ContainSubstring("file permitted capabilities CAP_SYS_ADMIN are not granted")))
+
+		fc.Effective = false
+		Expect(Successful(ExecTransition(parent, &fc)).Permitted.Names()).To(BeEmpty())
+	})
+
+	It("limits permitted capabilities with no_new_privs", func() {
+		parent.NoNewPrivs = true
+		fc := FileCapabilities{Permitted: capset(CAP_NET_RAW, CAP_NET_BIND_SERVICE)}
+		child := Successful(ExecTransition(parent, &fc))
+		Expect(child.Permitted.Names()).To(ConsistOf("CAP_NET_RAW"))
+		Expect(child.NoNewPrivs).To(BeTrue())
+		Expect(ExplainExec(parent, &fc).Messages()).To(ContainElement(
+			ContainSubstring("no_new_privs limits the new permitted capabilities to the permitted capabilities of the invoking task, dropping CAP_NET_BIND_SERVICE")))
+	})
+
+	When("predicting child capabilities", func() {
+
+		var path string
+
+		BeforeEach(func() {
+			path = filepath.Join(GinkgoT().TempDir(), "binary")
+			Expect(os.WriteFile(path, nil, 0755)).To(Succeed())
+		})
+
+		It("fails for non-existing binaries", func() {
+			Expect(PredictChildCaps(path+"-nope", parent)).Error().To(HaveOccurred())
+		})
+
+		It("predicts for binaries without file capabilities", func() {
+			taskcaps, findings, err := PredictChildCaps(path, parent)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(taskcaps.Effective.Names()).To(ConsistOf("CAP_NET_RAW"))
+			Expect(findings.Severity()).To(Equal(SeverityInfo))
+		})
+
+		It("predicts for binaries with file capabilities", func() {
+			capstest.RequireEffective(GinkgoT(), CAP_SETFCAP)
+			Expect(SetFileCapabilities(path, FileCapabilities{
+				Permitted: capset(CAP_NET_BIND_SERVICE),
+				Effective: true,
+			})).To(Succeed())
+			taskcaps, findings, err := PredictChildCaps(path, parent)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(taskcaps.Effective.Names()).To(ConsistOf("CAP_NET_BIND_SERVICE"))
+			Expect(taskcaps.Permitted.Names()).To(ConsistOf("CAP_NET_BIND_SERVICE"))
+			Expect(findings.Messages()).To(ContainElement(
+				ContainSubstring("ambient capabilities CAP_NET_RAW are cleared")))
+
+			Expect(SetFileCapabilities(path, FileCapabilities{
+				Permitted: capset(CAP_SYS_ADMIN),
+				Effective: true,
+			})).To(Succeed())
+			_, findings, err = PredictChildCaps(path, parent)
+			Expect(err).To(MatchError(unix.EPERM))
+			Expect(findings.Severity()).To(Equal(SeverityError))
+		})
+
+		It("ignores file capabilities of other user namespaces", func() {
+			capstest.RequireEffective(GinkgoT(), CAP_SETFCAP)
+			Expect(SetFileCapabilities(path, FileCapabilities{
+				Permitted:  capset(CAP_NET_BIND_SERVICE),
+				Effective:  true,
+				Namespaced: true,
+				RootID:     100000,
+			})).To(Succeed())
+			taskcaps, findings, err := PredictChildCaps(path, parent)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(taskcaps.Effective.Names()).To(ConsistOf("CAP_NET_RAW"))
+			Expect(findings.Messages()).To(ContainElement(
+				ContainSubstring("root user ID 100000 of another user namespace")))
+		})
+
+	})
+
+})
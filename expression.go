@@ -0,0 +1,165 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxAnonymousCapability is the highest capability number accepted in
+// "anonymous" capability names. It is well beyond the capabilities of current
+// Linux kernels, so that the names of all capabilities in sets produced by
+// this package parse back, yet keeps names from allocating huge sets.
+const maxAnonymousCapability = 1<<16 - 1
+
+// capabilityNumberByName maps (uppercase) capability names to their
+// capability bit numbers.
+var capabilityNumberByName = func() map[string]int {
+	m := make(map[string]int, len(CapabilityNameByNumber))
+	for capno, name := range CapabilityNameByNumber {
+		m[name] = capno
+	}
+	return m
+}()
+
+// CapabilityByName returns the number of the capability with the specified
+// name. Names are matched case-insensitively and are either the well-known
+// names, such as "CAP_SYS_ADMIN", or "anonymous" names in the form of "CAP_"
+// followed by the capability number, such as "CAP_42", up to CAP_65535. If the
+// name is unknown, or if strict parsing has been configured (see
+// [Config.Strict]) and the kernel doesn't support the capability, an error is
+// returned instead.
+func CapabilityByName(name string) (int, error) {
+	uname := strings.ToUpper(name)
+	if capno, ok := capabilityNumberByName[uname]; ok {
+		return strictCapability(name, capno)
+	}
+	if isAnonymousCapability(uname) && len(uname) > len("CAP_") {
+		capno, err := strconv.Atoi(uname[len("CAP_"):])
+		if err == nil && capno <= maxAnonymousCapability {
+			return strictCapability(name, capno)
+		}
+	}
+	return 0, fmt.Errorf("unknown capability name %q", name)
+}
+
+// AllCapabilityNames returns the names of all capabilities known to this
+// package, in uppercase and sorted by increasing capability number, for
+// instance for CLI completion generators. The names are always complete, that
+// is, they are derived from the same generated data as the capability
+// constants, independent of the kernel this package is running on. As
+// [CapabilityByName] matches names case-insensitively, the lowercase variants
+// of these names are accepted as well; see also [CompleteCapabilityName].
+func AllCapabilityNames() []string {
+	names := make([]string, 0, len(CapabilityNameByNumber))
+	for capno := 0; len(names) < len(CapabilityNameByNumber); capno++ {
+		if name, ok := CapabilityNameByNumber[capno]; ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// CompleteCapabilityName returns the names of all capabilities known to this
+// package that start with the specified prefix, matched case-insensitively,
+// sorted by increasing capability number. If the prefix is in lowercase, the
+// names are returned in lowercase, otherwise in uppercase.
+func CompleteCapabilityName(prefix string) []string {
+	lower := prefix != "" && prefix == strings.ToLower(prefix)
+	uprefix := strings.ToUpper(prefix)
+	var names []string
+	for _, name := range AllCapabilityNames() {
+		if !strings.HasPrefix(name, uprefix) {
+			continue
+		}
+		if lower {
+			name = strings.ToLower(name)
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// strictCapability returns the specified capability number, unless strict
+// parsing is configured (see [Config.Strict]) and the kernel we're running on
+// doesn't support the capability.
+func strictCapability(name string, capno int) (int, error) {
+	if config.Load().Strict && capno > LastCapability() {
+		return 0, fmt.Errorf("capability %q not supported by kernel", name)
+	}
+	return capno, nil
+}
+
+// EvalExpression evaluates the capabilities expression expr against the
+// specified base set, returning the resulting set. The base set itself is left
+// unmodified.
+//
+// An expression consists of comma-separated terms, where each term is either a
+// capability name (see [CapabilityByName]), the name of a registered bundle
+// (see [RegisterBundle]), or the keyword “all”, representing
+// [AllCapabilities]. A term prefixed with “-” drops its capabilities from the
+// set, while a term prefixed with “+” or without any prefix adds its
+// capabilities to the set. Terms are evaluated from left to right, for
+// instance:
+//
+//	all,-CAP_SYS_ADMIN
+//	CAP_NET_ADMIN,+CAP_NET_RAW
+//
+// Please note that unprefixed terms never replace the base set; pass an empty
+// base set when the expression should be evaluated from scratch.
+func EvalExpression(base CapabilitiesSet, expr string) (CapabilitiesSet, error) {
+	result := base.Clone()
+	if strings.TrimSpace(expr) == "" {
+		return result, nil
+	}
+	for _, term := range strings.Split(expr, ",") {
+		term = strings.TrimSpace(term)
+		drop := false
+		switch {
+		case strings.HasPrefix(term, "-"):
+			drop = true
+			term = term[1:]
+		case strings.HasPrefix(term, "+"):
+			term = term[1:]
+		}
+		if term == "" {
+			return nil, errors.New("empty capabilities expression term")
+		}
+		var capset CapabilitiesSet
+		if strings.EqualFold(term, "all") {
+			capset = AllCapabilities()
+		} else if bundle, ok := Bundle(term); ok {
+			capset = bundle
+		} else {
+			capno, err := CapabilityByName(term)
+			if err != nil {
+				return nil, err
+			}
+			capset = NewCapabilitiesSet()
+			capset.Add(capno)
+		}
+		if drop {
+			result.dropSet(capset)
+		} else {
+			result.addSet(capset)
+		}
+	}
+	return result, nil
+}
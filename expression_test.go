@@ -0,0 +1,111 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("capabilities expressions", func() {
+
+	It("lists all capability names in sync with the constants", func() {
+		names := AllCapabilityNames()
+		Expect(names).To(HaveLen(len(CapabilityNameByNumber)))
+		Expect(names[CAP_CHOWN]).To(Equal("CAP_CHOWN"))
+		Expect(names[CAP_CHECKPOINT_RESTORE]).To(Equal("CAP_CHECKPOINT_RESTORE"))
+		for capno, name := range names {
+			Expect(CapabilityByName(name)).To(Equal(capno))
+			Expect(CapabilityByName(strings.ToLower(name))).To(Equal(capno))
+		}
+	})
+
+	It("completes capability names", func() {
+		Expect(CompleteCapabilityName("CAP_NET_")).To(Equal([]string{
+			"CAP_NET_BIND_SERVICE", "CAP_NET_BROADCAST", "CAP_NET_ADMIN", "CAP_NET_RAW"}))
+		Expect(CompleteCapabilityName("cap_sys_a")).To(Equal([]string{"cap_sys_admin"}))
+		Expect(CompleteCapabilityName("Cap_Sys_A")).To(Equal([]string{"CAP_SYS_ADMIN"}))
+		Expect(CompleteCapabilityName("")).To(Equal(AllCapabilityNames()))
+		Expect(CompleteCapabilityName("CAP_FOO")).To(BeEmpty())
+	})
+
+	DescribeTable("looks up capabilities by name",
+		func(name string, capno int) {
+			Expect(CapabilityByName(name)).To(Equal(capno))
+		},
+		Entry(nil, "CAP_SYS_ADMIN", CAP_SYS_ADMIN),
+		Entry(nil, "cap_net_raw", CAP_NET_RAW),
+		Entry(nil, "CAP_42", 42),
+		Entry(nil, "cap_63", 63),
+		Entry(nil, "CAP_64", 64),
+		Entry(nil, "CAP_65535", 65535),
+	)
+
+	DescribeTable("rejects unknown capability names",
+		func(name string) {
+			Expect(CapabilityByName(name)).Error().To(HaveOccurred())
+		},
+		Entry(nil, ""),
+		Entry(nil, "CAP_"),
+		Entry(nil, "CAP_FOOBAR"),
+		Entry(nil, "SYS_ADMIN"),
+		Entry(nil, "CAP_65536"),
+	)
+
+	It("evaluates an empty expression", func() {
+		base := NewCapabilitiesSet()
+		base.Add(CAP_CHOWN)
+		caps := Successful(EvalExpression(base, " "))
+		Expect(caps).To(Equal(base))
+		caps.Add(CAP_KILL)
+		Expect(base.Has(CAP_KILL)).To(BeFalse())
+	})
+
+	It("evaluates all and drops", func() {
+		caps := Successful(EvalExpression(nil, "all,-CAP_SYS_ADMIN"))
+		Expect(caps.Has(CAP_SYS_ADMIN)).To(BeFalse())
+		Expect(caps.Has(CAP_NET_RAW)).To(BeTrue())
+		Expect(caps.Has(LastCapability())).To(BeTrue())
+		Expect(caps.Has(LastCapability() + 1)).To(BeFalse())
+	})
+
+	It("adds to a base set", func() {
+		base := NewCapabilitiesSet()
+		base.Add(CAP_CHOWN)
+		caps := Successful(EvalExpression(base, "CAP_NET_ADMIN, +cap_net_raw, -CAP_CHOWN, -CAP_BPF"))
+		Expect(caps.Names()).To(ConsistOf("CAP_NET_ADMIN", "CAP_NET_RAW"))
+		Expect(base.Names()).To(ConsistOf("CAP_CHOWN"))
+	})
+
+	It("drops everything", func() {
+		base := NewCapabilitiesSet()
+		base.Add(CAP_CHOWN, CAP_BPF)
+		Expect(Successful(EvalExpression(base, "-all")).Names()).To(BeEmpty())
+	})
+
+	DescribeTable("rejects invalid expressions",
+		func(expr string) {
+			Expect(EvalExpression(nil, expr)).Error().To(HaveOccurred())
+		},
+		Entry(nil, "CAP_CHOWN,,CAP_KILL"),
+		Entry(nil, "-"),
+		Entry(nil, "+CAP_FOOBAR"),
+		Entry(nil, "all,-"),
+	)
+
+})
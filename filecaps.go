@@ -0,0 +1,214 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Revisions of the file capabilities extended attribute "security.capability"
+// (struct vfs_cap_data and struct vfs_ns_cap_data), and its flags.
+const (
+	vfsCapRevisionMask   = 0xff000000
+	vfsCapRevision1      = 0x01000000 // 32-bit capabilities
+	vfsCapRevision2      = 0x02000000 // 64-bit capabilities
+	vfsCapRevision3      = 0x03000000 // 64-bit capabilities with rootid
+	vfsCapFlagsMask      = ^uint32(vfsCapRevisionMask)
+	vfsCapFlagsEffective = 0x000001
+
+	xattrNameCaps = "security.capability"
+)
+
+// FileCapabilities represents the file capabilities of a binary, as stored in
+// its "security.capability" extended attribute.
+type FileCapabilities struct {
+	Permitted   CapabilitiesSet
+	Inheritable CapabilitiesSet
+	Effective   bool   // raise the new permitted capabilities as effective on exec
+	Namespaced  bool   // revision 3 with a rootid
+	RootID      uint32 // user ID of the root user the file capabilities apply to
+}
+
+// FileCapabilitiesFromXattr decodes file capabilities from the binary value of
+// a "security.capability" extended attribute in any of the revisions 1, 2, or
+// 3, returning an error if the value is malformed.
+func FileCapabilitiesFromXattr(b []byte) (FileCapabilities, error) {
+	if len(b) < 4 {
+		return FileCapabilities{}, errors.New("file capabilities too short")
+	}
+	magic := binary.LittleEndian.Uint32(b)
+	var words, size int
+	switch magic & vfsCapRevisionMask {
+	case vfsCapRevision1:
+		words, size = 1, 12
+	case vfsCapRevision2:
+		words, size = 2, 20
+	case vfsCapRevision3:
+		words, size = 2, 24
+	default:
+		return FileCapabilities{}, fmt.Errorf("unsupported file capabilities revision 0x%08x",
+			magic&vfsCapRevisionMask)
+	}
+	if len(b) != size {
+		return FileCapabilities{}, fmt.Errorf("invalid file capabilities size %d, expected %d",
+			len(b), size)
+	}
+	if magic&vfsCapFlagsMask&^vfsCapFlagsEffective != 0 {
+		return FileCapabilities{}, fmt.Errorf("invalid file capabilities flags 0x%06x",
+			magic&vfsCapFlagsMask)
+	}
+	fc := FileCapabilities{
+		Permitted:   make(CapabilitiesSet, words),
+		Inheritable: make(CapabilitiesSet, words),
+		Effective:   magic&vfsCapFlagsEffective != 0,
+	}
+	for idx := 0; idx < words; idx++ {
+		fc.Permitted[idx] = binary.LittleEndian.Uint32(b[4+8*idx:])
+		fc.Inheritable[idx] = binary.LittleEndian.Uint32(b[8+8*idx:])
+	}
+	if size == 24 {
+		fc.Namespaced = true
+		fc.RootID = binary.LittleEndian.Uint32(b[20:])
+	}
+	return fc, nil
+}
+
+// Xattr returns the binary value of the "security.capability" extended
+// attribute for these file capabilities, in revision 3 if namespaced, and
+// revision 2 otherwise.
+func (fc FileCapabilities) Xattr() []byte {
+	magic, size := uint32(vfsCapRevision2), 20
+	if fc.Namespaced {
+		magic, size = vfsCapRevision3, 24
+	}
+	if fc.Effective {
+		magic |= vfsCapFlagsEffective
+	}
+	b := make([]byte, size)
+	binary.LittleEndian.PutUint32(b, magic)
+	for idx := 0; idx < 2; idx++ {
+		if idx < len(fc.Permitted) {
+			binary.LittleEndian.PutUint32(b[4+8*idx:], fc.Permitted[idx])
+		}
+		if idx < len(fc.Inheritable) {
+			binary.LittleEndian.PutUint32(b[8+8*idx:], fc.Inheritable[idx])
+		}
+	}
+	if fc.Namespaced {
+		binary.LittleEndian.PutUint32(b[20:], fc.RootID)
+	}
+	return b
+}
+
+// ValidateFor returns an error if these file capabilities contain
+// capabilities beyond the specified last capability of a target kernel, that
+// is, capabilities the target kernel doesn't know about. For instance, file
+// capabilities including CAP_BPF are invalid for Linux 4.19, where the last
+// capability is CAP_AUDIT_READ.
+func (fc FileCapabilities) ValidateFor(lastCap int) error {
+	unknown := NewCapabilitiesSet()
+	for _, set := range []CapabilitiesSet{fc.Permitted, fc.Inheritable} {
+		for _, capno := range set.Numbers() {
+			if capno > lastCap {
+				unknown.Add(capno)
+			}
+		}
+	}
+	if names := unknown.Names(); len(names) != 0 {
+		return fmt.Errorf("file capabilities %s unknown to kernels with last capability %d",
+			strings.Join(names, ", "), lastCap)
+	}
+	return nil
+}
+
+// FileCapabilitiesOf returns the file capabilities of the file at the
+// specified path. If the file has no file capabilities, an error wrapping
+// [unix.ENODATA] is returned.
+func FileCapabilitiesOf(path string) (FileCapabilities, error) {
+	b := make([]byte, 24)
+	sz, err := unix.Getxattr(path, xattrNameCaps, b)
+	if err != nil {
+		return FileCapabilities{}, &os.PathError{Op: "getxattr", Path: path, Err: err}
+	}
+	return FileCapabilitiesFromXattr(b[:sz])
+}
+
+// SetFileCapabilities sets the file capabilities of the file at the specified
+// path, which requires CAP_SETFCAP to be effective.
+//
+// When the calling task runs inside a (non-initial) user namespace and the
+// file capabilities aren't already namespaced, SetFileCapabilities
+// automatically switches to revision 3 with the rootid set to the root user of
+// the user namespace, that is, user ID 0 inside the namespace, which the
+// kernel translates into the corresponding host user ID using the namespace's
+// user ID mapping. The file capabilities then only apply in user namespaces
+// having the same root user. Set Namespaced and RootID explicitly in order to
+// use a different rootid, such as when preparing container images from the
+// initial user namespace, where RootID must be the host user ID of the
+// container's root user.
+func SetFileCapabilities(path string, fc FileCapabilities) error {
+	taskcaps, err := OfThisTask()
+	if err != nil {
+		return err
+	}
+	if !taskcaps.Effective.Has(CAP_SETFCAP) {
+		return errors.New("setting file capabilities requires CAP_SETFCAP")
+	}
+	if !fc.Namespaced {
+		initial, err := initialUserNamespace()
+		if err != nil {
+			return err
+		}
+		if !initial {
+			fc.Namespaced, fc.RootID = true, 0
+		}
+	}
+	if err := unix.Setxattr(path, xattrNameCaps, fc.Xattr(), 0); err != nil {
+		return &os.PathError{Op: "setxattr", Path: path, Err: err}
+	}
+	return nil
+}
+
+// initialUserNamespace returns true if the calling task is in the initial user
+// namespace, as indicated by its user ID mapping covering the full ID range.
+// It returns an error if the calling task is in a user namespace without a
+// root user mapping, as then file capabilities cannot be namespaced.
+func initialUserNamespace() (bool, error) {
+	uidmap, err := os.ReadFile(ownProcPath("self/uid_map"))
+	if err != nil {
+		return false, err
+	}
+	mappings, err := parseIDMappings(string(uidmap))
+	if err != nil {
+		return false, err
+	}
+	if len(mappings) == 1 && mappings[0] == (IDMapping{Size: 4294967295}) {
+		return true, nil
+	}
+	for _, m := range mappings {
+		if m.ContainerID == 0 {
+			return false, nil
+		}
+	}
+	return false, errors.New("user namespace without root user mapping")
+}
@@ -0,0 +1,145 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/thediveo/caps/capstest"
+	"golang.org/x/sys/unix"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+// fakeUIDMapProc returns the root of a fake /proc with the specified
+// self/uid_map contents.
+func fakeUIDMapProc(uidmap string) string {
+	root := GinkgoT().TempDir()
+	Expect(os.MkdirAll(filepath.Join(root, "self"), 0755)).To(Succeed())
+	Expect(os.WriteFile(filepath.Join(root, "self/uid_map"), []byte(uidmap), 0644)).To(Succeed())
+	return root
+}
+
+var _ = Describe("file capabilities", func() {
+
+	BeforeEach(func() {
+		cfg := CurrentConfig()
+		DeferCleanup(func() { Configure(cfg) })
+	})
+
+	It("encodes and decodes file capabilities", func() {
+		fc := FileCapabilities{
+			Permitted:   CapabilitiesSet{1 << CAP_NET_RAW, 1 << (CAP_BPF - 32)},
+			Inheritable: CapabilitiesSet{1 << CAP_CHOWN, 0},
+			Effective:   true,
+		}
+		b := fc.Xattr()
+		Expect(b).To(HaveLen(20))
+		Expect(b[:4]).To(Equal([]byte{0x01, 0, 0, 0x02}))
+		Expect(FileCapabilitiesFromXattr(b)).To(Equal(fc))
+
+		fc.Namespaced, fc.RootID = true, 100000
+		b = fc.Xattr()
+		Expect(b).To(HaveLen(24))
+		Expect(b[:4]).To(Equal([]byte{0x01, 0, 0, 0x03}))
+		Expect(FileCapabilitiesFromXattr(b)).To(Equal(fc))
+	})
+
+	It("decodes revision 1 file capabilities", func() {
+		fc := Successful(FileCapabilitiesFromXattr([]byte{
+			0, 0, 0, 0x01,
+			1 << CAP_KILL, 0, 0, 0,
+			0, 0, 0, 0,
+		}))
+		Expect(fc.Permitted.Names()).To(ConsistOf("CAP_KILL"))
+		Expect(fc.Effective).To(BeFalse())
+		Expect(fc.Namespaced).To(BeFalse())
+	})
+
+	DescribeTable("rejects malformed file capabilities",
+		func(b []byte) {
+			Expect(FileCapabilitiesFromXattr(b)).Error().To(HaveOccurred())
+		},
+		Entry("too short", []byte{0, 0, 0}),
+		Entry("unknown revision", []byte{0, 0, 0, 0x04}),
+		Entry("wrong size", append([]byte{0, 0, 0, 0x02}, make([]byte, 20)...)),
+		Entry("unknown flags", append([]byte{0x02, 0, 0, 0x02}, make([]byte, 16)...)),
+	)
+
+	It("validates file capabilities for target kernels", func() {
+		fc := FileCapabilities{
+			Permitted:   CapabilitiesSet{1 << CAP_NET_RAW, 1 << (CAP_BPF - 32)},
+			Inheritable: CapabilitiesSet{0, 1 << (CAP_PERFMON - 32)},
+		}
+		Expect(fc.ValidateFor(CAP_CHECKPOINT_RESTORE)).To(Succeed())
+		Expect(fc.ValidateFor(CAP_AUDIT_READ)).To(MatchError(
+			"file capabilities CAP_PERFMON, CAP_BPF unknown to kernels with last capability 37"))
+		Expect(FileCapabilities{}.ValidateFor(CAP_AUDIT_READ)).To(Succeed())
+	})
+
+	It("detects the user namespace for rootids", func() {
+		useProc(fakeUIDMapProc("         0          0 4294967295\n"))
+		Expect(initialUserNamespace()).To(BeTrue())
+		useProc(fakeUIDMapProc("0 100000 65536\n"))
+		Expect(initialUserNamespace()).To(BeFalse())
+		useProc(fakeUIDMapProc("1000 1000 1\n"))
+		Expect(initialUserNamespace()).Error().To(HaveOccurred())
+		useProc(fakeUIDMapProc("0 0\n"))
+		Expect(initialUserNamespace()).Error().To(HaveOccurred())
+	})
+
+	When("setting file capabilities", func() {
+
+		var path string
+
+		BeforeEach(func() {
+			path = filepath.Join(GinkgoT().TempDir(), "binary")
+			Expect(os.WriteFile(path, nil, 0755)).To(Succeed())
+		})
+
+		It("sets and reads back file capabilities", func() {
+			capstest.RequireEffective(GinkgoT(), CAP_SETFCAP)
+			Expect(FileCapabilitiesOf(path)).Error().To(MatchError(unix.ENODATA))
+			fc := FileCapabilities{
+				Permitted:   CapabilitiesSet{1 << CAP_NET_RAW, 0},
+				Inheritable: CapabilitiesSet{0, 0},
+				Effective:   true,
+			}
+			err := SetFileCapabilities(path, fc)
+			if errors.Is(err, unix.ENOTSUP) {
+				Skip("filesystem doesn't support file capabilities")
+			}
+			Expect(err).To(Succeed())
+			Expect(FileCapabilitiesOf(path)).To(Equal(fc))
+
+			fc.Namespaced, fc.RootID = true, 100000
+			Expect(SetFileCapabilities(path, fc)).To(Succeed())
+			Expect(FileCapabilitiesOf(path)).To(Equal(fc))
+		})
+
+		It("requires CAP_SETFCAP", func() {
+			withEffective(func() {
+				Expect(SetFileCapabilities(path, FileCapabilities{})).To(
+					MatchError(ContainSubstring("CAP_SETFCAP")))
+			}, CAP_DAC_OVERRIDE)
+		})
+
+	})
+
+})
@@ -0,0 +1,80 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// WillFileCapsApply checks whether file capabilities of the binary at the
+// specified path would actually take effect when the calling task executes
+// it, returning false together with findings explaining why not. This answers
+// the all-too-common question of “I've set file capabilities but they don't
+// work”. WillFileCapsApply checks that:
+//   - the binary has file capabilities in the first place,
+//   - the filesystem of the binary isn't mounted with “nosuid”, which makes
+//     the kernel ignore file capabilities,
+//   - the no_new_privs flag of the calling task isn't set, as the kernel then
+//     doesn't grant any capabilities beyond the task's current permitted
+//     capabilities.
+//
+// Please note that none of the securebits keeps file capabilities from taking
+// effect; SECBIT_NOROOT only concerns set-user-ID-root binaries and the root
+// user, but not file capabilities.
+func WillFileCapsApply(path string) (bool, Findings) {
+	sz, err := unix.Getxattr(path, "security.capability", nil)
+	if err != nil {
+		if errors.Is(err, unix.ENODATA) {
+			return false, Findings{noFileCapsFinding()}
+		}
+		return false, Findings{errorFinding("file capabilities",
+			"cannot read file capabilities: %s", err.Error())}
+	}
+	if sz == 0 {
+		return false, Findings{noFileCapsFinding()}
+	}
+	var fs unix.Statfs_t
+	if err := unix.Statfs(path, &fs); err != nil {
+		return false, Findings{errorFinding("mount",
+			"cannot determine mount options: %s", err.Error())}
+	}
+	if fs.Flags&unix.ST_NOSUID != 0 {
+		return false, Findings{errorFinding("mount",
+			"binary resides on a filesystem mounted nosuid").
+			withRemediation("move the binary to a filesystem mounted without nosuid")}
+	}
+	nnp, err := unix.PrctlRetInt(unix.PR_GET_NO_NEW_PRIVS, 0, 0, 0, 0)
+	if err != nil {
+		return false, Findings{errorFinding("no_new_privs",
+			"cannot determine no_new_privs: %s", err.Error())}
+	}
+	if nnp != 0 {
+		return false, Findings{errorFinding("no_new_privs",
+			"no_new_privs is set for the calling task").
+			withRemediation("execute the binary from a task without no_new_privs")}
+	}
+	return true, Findings{infoFinding("file capabilities", "file capabilities will apply")}
+}
+
+// noFileCapsFinding returns the finding for a binary without file
+// capabilities.
+func noFileCapsFinding() Finding {
+	return errorFinding("file capabilities", "binary has no file capabilities").
+		withRemediation("set file capabilities using setcap(8)")
+}
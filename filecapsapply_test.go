@@ -0,0 +1,83 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/thediveo/caps/capstest"
+	"golang.org/x/sys/unix"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("file capabilities applicability", func() {
+
+	// v2 file capabilities with CAP_NET_RAW permitted and effective.
+	var fcaps = []byte{
+		0x01, 0x00, 0x00, 0x02, // VFS_CAP_REVISION_2 | VFS_CAP_FLAGS_EFFECTIVE
+		0x00, 0x20, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // permitted, inheritable [0]
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // permitted, inheritable [1]
+	}
+
+	It("reports binaries without file capabilities", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "binary")
+		Expect(os.WriteFile(path, nil, 0755)).To(Succeed())
+		ok, findings := WillFileCapsApply(path)
+		Expect(ok).To(BeFalse())
+		Expect(findings.Messages()).To(ConsistOf("binary has no file capabilities"))
+
+		ok, findings = WillFileCapsApply(filepath.Join(path, "nonexisting"))
+		Expect(ok).To(BeFalse())
+		Expect(findings.Messages()).To(ConsistOf(HavePrefix("cannot read file capabilities")))
+	})
+
+	It("checks file capabilities and no_new_privs", func() {
+		capstest.RequireEffective(GinkgoT(), CAP_SETFCAP)
+		path := filepath.Join(GinkgoT().TempDir(), "binary")
+		Expect(os.WriteFile(path, nil, 0755)).To(Succeed())
+		if err := unix.Setxattr(path, "security.capability", fcaps, 0); err != nil {
+			Skip("cannot set file capabilities: " + err.Error())
+		}
+		var fs unix.Statfs_t
+		Expect(unix.Statfs(path, &fs)).To(Succeed())
+		if fs.Flags&unix.ST_NOSUID != 0 {
+			ok, findings := WillFileCapsApply(path)
+			Expect(ok).To(BeFalse())
+			Expect(findings.Messages()).To(ContainElement(ContainSubstring("nosuid")))
+			return
+		}
+		ok, findings := WillFileCapsApply(path)
+		Expect(ok).To(BeTrue(), findings.Text())
+
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			// Never unlock this thread, so that it gets thrown away when this
+			// Go routine finishes.
+			runtime.LockOSThread()
+			Expect(unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0)).To(Succeed())
+			ok, findings := WillFileCapsApply(path)
+			Expect(ok).To(BeFalse())
+			Expect(findings.Messages()).To(ContainElement(ContainSubstring("no_new_privs")))
+		}()
+		<-done
+	})
+
+})
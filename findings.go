@@ -0,0 +1,190 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Severity of a Finding.
+type Severity int
+
+// Severities of findings, in increasing order.
+const (
+	SeverityInfo    Severity = iota // informational, not affecting the verdict
+	SeverityWarning                 // noteworthy, but not affecting the verdict
+	SeverityError                   // causing a negative verdict
+)
+
+var severityNames = [...]string{
+	SeverityInfo:    "info",
+	SeverityWarning: "warning",
+	SeverityError:   "error",
+}
+
+// String returns the name of the severity, such as "warning".
+func (s Severity) String() string {
+	if s >= 0 && int(s) < len(severityNames) {
+		return severityNames[s]
+	}
+	return "Severity(" + strconv.Itoa(int(s)) + ")"
+}
+
+// MarshalText returns the name of the severity.
+func (s Severity) MarshalText() ([]byte, error) {
+	if s < 0 || int(s) >= len(severityNames) {
+		return nil, fmt.Errorf("invalid severity %d", int(s))
+	}
+	return []byte(severityNames[s]), nil
+}
+
+// UnmarshalText sets the severity from its name.
+func (s *Severity) UnmarshalText(text []byte) error {
+	for sev, name := range severityNames {
+		if string(text) == name {
+			*s = Severity(sev)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid severity %q", string(text))
+}
+
+// ParseSeverity returns the severity with the specified name, such as
+// "warning", or an error if there is no such severity.
+func ParseSeverity(name string) (Severity, error) {
+	var sev Severity
+	err := sev.UnmarshalText([]byte(name))
+	return sev, err
+}
+
+// Set sets the severity from its name, so that *Severity implements
+// [flag.Value] for command line flags such as "--fail-on warning".
+func (s *Severity) Set(name string) error {
+	return s.UnmarshalText([]byte(name))
+}
+
+// Finding explains a particular aspect of the verdict of a predicate, such as
+// CanPtrace.
+type Finding struct {
+	Severity    Severity `json:"severity"`
+	Subject     string   `json:"subject"`               // such as "CAP_SYS_PTRACE" or "kernel.perf_event_paranoid"
+	Message     string   `json:"message"`               // human-readable explanation
+	Remediation string   `json:"remediation,omitempty"` // optional hint on how to fix the cause
+}
+
+// String returns the severity and message of the finding, such as "error:
+// CAP_SYS_PTRACE is not effective".
+func (f Finding) String() string {
+	return f.Severity.String() + ": " + f.Message
+}
+
+// Findings is a list of findings explaining the verdict of a predicate, such as
+// CanPtrace.
+type Findings []Finding
+
+// Messages returns the messages of the findings.
+func (f Findings) Messages() []string {
+	msgs := make([]string, 0, len(f))
+	for _, finding := range f {
+		msgs = append(msgs, finding.Message)
+	}
+	return msgs
+}
+
+// Severity returns the highest severity of the findings, or SeverityInfo if
+// there are no findings.
+func (f Findings) Severity() Severity {
+	sev := SeverityInfo
+	for _, finding := range f {
+		if finding.Severity > sev {
+			sev = finding.Severity
+		}
+	}
+	return sev
+}
+
+// FailOn returns true if any of the findings has the specified threshold
+// severity or a higher severity, such as for CI pipelines failing on
+// warnings. FailOn returns false if there are no findings.
+func (f Findings) FailOn(threshold Severity) bool {
+	for _, finding := range f {
+		if finding.Severity >= threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// Text renders the findings as text, one finding per line, with any
+// remediation hints on indented lines of their own.
+func (f Findings) Text() string {
+	var b strings.Builder
+	for _, finding := range f {
+		b.WriteString(finding.String())
+		b.WriteByte('\n')
+		if finding.Remediation != "" {
+			b.WriteString("  hint: ")
+			b.WriteString(finding.Remediation)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// MarshalJSON returns the findings as a JSON array, which is empty instead of
+// null for no findings.
+func (f Findings) MarshalJSON() ([]byte, error) {
+	if f == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]Finding(f))
+}
+
+// infoFinding returns an informational finding about the specified subject,
+// with its message localized and formatted according to the format specifier.
+func infoFinding(subject, format string, args ...interface{}) Finding {
+	return Finding{Severity: SeverityInfo, Subject: subject, Message: localizef(format, args...)}
+}
+
+// warningFinding returns a warning finding about the specified subject, with
+// its message localized and formatted according to the format specifier.
+func warningFinding(subject, format string, args ...interface{}) Finding {
+	return Finding{Severity: SeverityWarning, Subject: subject, Message: localizef(format, args...)}
+}
+
+// errorFinding returns a finding about the specified subject that causes a
+// negative verdict, with its message localized and formatted according to the
+// format specifier.
+func errorFinding(subject, format string, args ...interface{}) Finding {
+	return Finding{Severity: SeverityError, Subject: subject, Message: localizef(format, args...)}
+}
+
+// withRemediation returns the finding with a remediation hint, localized and
+// formatted according to the format specifier.
+func (f Finding) withRemediation(format string, args ...interface{}) Finding {
+	f.Remediation = localizef(format, args...)
+	return f
+}
+
+// taskQueryFinding returns the finding for failing to query the capabilities
+// of the current task.
+func taskQueryFinding(err error) Finding {
+	return errorFinding("task", "cannot query capabilities of current task: %s", err.Error())
+}
@@ -0,0 +1,98 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("findings", func() {
+
+	It("names severities", func() {
+		Expect(SeverityInfo.String()).To(Equal("info"))
+		Expect(SeverityError.String()).To(Equal("error"))
+		Expect(Severity(42).String()).To(Equal("Severity(42)"))
+		Expect(Severity(-1).MarshalText()).Error().To(HaveOccurred())
+
+		var sev Severity
+		Expect(sev.UnmarshalText([]byte("warning"))).To(Succeed())
+		Expect(sev).To(Equal(SeverityWarning))
+		Expect(sev.UnmarshalText([]byte("fatal"))).NotTo(Succeed())
+	})
+
+	It("parses severities from command line flags", func() {
+		Expect(ParseSeverity("error")).To(Equal(SeverityError))
+		Expect(ParseSeverity("fatal")).Error().To(HaveOccurred())
+
+		sev := SeverityError
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		fs.Var(&sev, "fail-on", "fail on findings with this severity or higher")
+		Expect(fs.Parse([]string{"--fail-on", "warning"})).To(Succeed())
+		Expect(sev).To(Equal(SeverityWarning))
+		Expect(fs.Parse([]string{"--fail-on", "fatal"})).NotTo(Succeed())
+	})
+
+	It("fails on findings with a threshold severity", func() {
+		Expect(Findings(nil).FailOn(SeverityInfo)).To(BeFalse())
+		findings := Findings{
+			infoFinding("foo", "foo"),
+			warningFinding("bar", "bar"),
+		}
+		Expect(findings.FailOn(SeverityInfo)).To(BeTrue())
+		Expect(findings.FailOn(SeverityWarning)).To(BeTrue())
+		Expect(findings.FailOn(SeverityError)).To(BeFalse())
+	})
+
+	It("renders findings as text", func() {
+		findings := Findings{
+			infoFinding("CAP_SYS_ADMIN", "CAP_SYS_ADMIN is not effective"),
+			errorFinding("CAP_SYS_PTRACE", "CAP_SYS_PTRACE is not effective").
+				withRemediation("grant %s", "CAP_SYS_PTRACE"),
+		}
+		Expect(findings.Text()).To(Equal(
+			"info: CAP_SYS_ADMIN is not effective\n" +
+				"error: CAP_SYS_PTRACE is not effective\n" +
+				"  hint: grant CAP_SYS_PTRACE\n"))
+		Expect(findings.Messages()).To(Equal([]string{
+			"CAP_SYS_ADMIN is not effective", "CAP_SYS_PTRACE is not effective"}))
+		Expect(findings.Severity()).To(Equal(SeverityError))
+		Expect(Findings(nil).Severity()).To(Equal(SeverityInfo))
+		Expect(Findings(nil).Text()).To(BeEmpty())
+	})
+
+	It("renders findings as JSON", func() {
+		Expect(string(Successful(json.Marshal(Findings(nil))))).To(Equal(`[]`))
+		findings := Findings{
+			warningFinding("time namespace", "foo").withRemediation("bar"),
+			taskQueryFinding(errors.New("baz")),
+		}
+		j := Successful(json.Marshal(findings))
+		Expect(string(j)).To(Equal(
+			`[{"severity":"warning","subject":"time namespace","message":"foo","remediation":"bar"},` +
+				`{"severity":"error","subject":"task","message":"cannot query capabilities of current task: baz"}]`))
+		var f Findings
+		Expect(json.Unmarshal(j, &f)).To(Succeed())
+		Expect(f).To(Equal(findings))
+	})
+
+})
@@ -0,0 +1,194 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Format versions of the serialized representations of this package's types.
+// A format version is only incremented with incompatible format changes.
+// Serialized representations carry their format version in their “version”
+// field, starting with format version 1. As policies are often written by hand,
+// policies without a version field are considered to be of the current format
+// version; all other representations must carry their format version.
+// Unmarshalling representations with newer format versions than supported by
+// this package fails, while older format versions are migrated (see also
+// [UpgradePolicy]).
+//
+// See also [CanonicalFormatVersion] for the version of the textual snapshots
+// of task states.
+const (
+	StateFormatVersion  = 1 // JSON and YAML representation of State
+	PolicyFormatVersion = 1 // JSON and YAML representation of Policy
+)
+
+// checkFormatVersion returns an error if the specified format version is
+// invalid or newer than the current format version of the named format.
+func checkFormatVersion(format string, version, current int) error {
+	if version < 1 || version > current {
+		return fmt.Errorf("unsupported %s format version %d, supporting versions 1 to %d",
+			format, version, current)
+	}
+	return nil
+}
+
+// stateAlias has the same fields as State, but none of its methods, so it can
+// be (un)marshalled without recursing.
+type stateAlias State
+
+// versionedState is the serialized representation of State, tagged with its
+// format version.
+type versionedState struct {
+	Version    int `json:"version" yaml:"version"`
+	stateAlias `yaml:",inline"`
+}
+
+// MarshalJSON returns the JSON representation of this task state, tagged with
+// its format version [StateFormatVersion].
+func (s State) MarshalJSON() ([]byte, error) {
+	return json.Marshal(versionedState{Version: StateFormatVersion, stateAlias: stateAlias(s)})
+}
+
+// UnmarshalJSON sets this task state from its JSON representation, rejecting
+// newer format versions than [StateFormatVersion].
+func (s *State) UnmarshalJSON(b []byte) error {
+	var vs versionedState
+	if err := json.Unmarshal(b, &vs); err != nil {
+		return err
+	}
+	if err := checkFormatVersion("state", vs.Version, StateFormatVersion); err != nil {
+		return err
+	}
+	*s = State(vs.stateAlias)
+	return nil
+}
+
+// MarshalYAML returns the YAML representation of this task state, tagged with
+// its format version [StateFormatVersion].
+func (s State) MarshalYAML() (interface{}, error) {
+	return versionedState{Version: StateFormatVersion, stateAlias: stateAlias(s)}, nil
+}
+
+// UnmarshalYAML sets this task state from its YAML representation, rejecting
+// newer format versions than [StateFormatVersion].
+func (s *State) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var vs versionedState
+	if err := unmarshal(&vs); err != nil {
+		return err
+	}
+	if err := checkFormatVersion("state", vs.Version, StateFormatVersion); err != nil {
+		return err
+	}
+	*s = State(vs.stateAlias)
+	return nil
+}
+
+// policyAlias has the same fields as Policy, but none of its methods, so it
+// can be (un)marshalled without recursing.
+type policyAlias Policy
+
+// versionedPolicy is the serialized representation of Policy, tagged with its
+// format version.
+type versionedPolicy struct {
+	Version     int `json:"version" yaml:"version"`
+	policyAlias `yaml:",inline"`
+}
+
+// policyUpgrades migrate the generic JSON representation of a policy from the
+// format version given by their index plus one to the next format version.
+var policyUpgrades = [PolicyFormatVersion - 1]func(policy map[string]interface{}) error{}
+
+// UpgradePolicy returns the policy from its JSON representation in the
+// specified format version, migrating the policy from older format versions to
+// the current [PolicyFormatVersion]. Unversioned policies are considered to be
+// of the current format version.
+// UpgradePolicy returns an error if the policy is invalid or of a newer format
+// version than supported by this package.
+func UpgradePolicy(old []byte) (Policy, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(old, &raw); err != nil {
+		return Policy{}, err
+	}
+	version := PolicyFormatVersion
+	if v, ok := raw["version"]; ok {
+		f, ok := v.(float64)
+		if !ok || f != float64(int(f)) {
+			return Policy{}, fmt.Errorf("invalid policy format version %v", v)
+		}
+		version = int(f)
+	}
+	if err := checkFormatVersion("policy", version, PolicyFormatVersion); err != nil {
+		return Policy{}, err
+	}
+	for ; version < PolicyFormatVersion; version++ {
+		if err := policyUpgrades[version-1](raw); err != nil {
+			return Policy{}, fmt.Errorf("cannot upgrade policy from format version %d: %w",
+				version, err)
+		}
+	}
+	raw["version"] = PolicyFormatVersion
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return Policy{}, err
+	}
+	var vp versionedPolicy
+	if err := json.Unmarshal(b, &vp); err != nil {
+		return Policy{}, err
+	}
+	return Policy(vp.policyAlias), nil
+}
+
+// MarshalJSON returns the JSON representation of this policy, tagged with its
+// format version [PolicyFormatVersion].
+func (p Policy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(versionedPolicy{Version: PolicyFormatVersion, policyAlias: policyAlias(p)})
+}
+
+// UnmarshalJSON sets this policy from its JSON representation, migrating older
+// format versions using [UpgradePolicy] and rejecting newer format versions
+// than [PolicyFormatVersion].
+func (p *Policy) UnmarshalJSON(b []byte) error {
+	policy, err := UpgradePolicy(b)
+	if err != nil {
+		return err
+	}
+	*p = policy
+	return nil
+}
+
+// MarshalYAML returns the YAML representation of this policy, tagged with its
+// format version [PolicyFormatVersion].
+func (p Policy) MarshalYAML() (interface{}, error) {
+	return versionedPolicy{Version: PolicyFormatVersion, policyAlias: policyAlias(p)}, nil
+}
+
+// UnmarshalYAML sets this policy from its YAML representation, rejecting newer
+// format versions than [PolicyFormatVersion]. As there are no older format
+// versions yet, there is nothing to migrate.
+func (p *Policy) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	vp := versionedPolicy{Version: PolicyFormatVersion}
+	if err := unmarshal(&vp); err != nil {
+		return err
+	}
+	if err := checkFormatVersion("policy", vp.Version, PolicyFormatVersion); err != nil {
+		return err
+	}
+	*p = Policy(vp.policyAlias)
+	return nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"encoding/json"
+
+	"gopkg.in/yaml.v3"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("format versions", func() {
+
+	It("tags task states", func() {
+		state := Successful(parseStatus([]byte(taskStatus)))
+		state.Securebits = SECBIT_NOROOT
+
+		j := Successful(json.Marshal(state))
+		Expect(string(j)).To(HavePrefix(`{"version":1,"Effective":`))
+		var s State
+		Expect(json.Unmarshal(j, &s)).To(Succeed())
+		Expect(s.Canonical()).To(Equal(state.Canonical()))
+		Expect(s.Securebits).To(Equal(uint(SECBIT_NOROOT)))
+
+		Expect(json.Unmarshal([]byte(`{"version":1,"Ambient":["CAP_CHOWN"]}`), &s)).To(Succeed())
+		Expect(s.Ambient.Names()).To(ConsistOf("CAP_CHOWN"))
+		Expect(json.Unmarshal([]byte(`{"Ambient":["CAP_CHOWN"]}`), &s)).To(
+			MatchError("unsupported state format version 0, supporting versions 1 to 1"))
+		Expect(json.Unmarshal([]byte(`{"version":2}`), &s)).To(
+			MatchError("unsupported state format version 2, supporting versions 1 to 1"))
+		Expect(json.Unmarshal([]byte(`{"version":"1"}`), &s)).NotTo(Succeed())
+
+		y := Successful(yaml.Marshal(state))
+		Expect(string(y)).To(HavePrefix("version: 1\n"))
+		s = State{}
+		Expect(yaml.Unmarshal(y, &s)).To(Succeed())
+		Expect(s.Canonical()).To(Equal(state.Canonical()))
+		Expect(yaml.Unmarshal([]byte("version: 42\n"), &s)).To(MatchError(ContainSubstring("version 42")))
+	})
+
+	It("tags policies", func() {
+		policy := Policy{Allowed: NewCapabilitiesSet(), Denied: NewCapabilitiesSet(), UserNamespace: true}
+		policy.Allowed.Add(CAP_NET_RAW)
+		policy.Denied.Add(CAP_SYS_ADMIN)
+
+		j := Successful(json.Marshal(policy))
+		Expect(string(j)).To(Equal(
+			`{"version":1,"allowed":["CAP_NET_RAW"],"denied":["CAP_SYS_ADMIN"],"allowAnonymous":false,"userNamespace":true}`))
+		var p Policy
+		Expect(json.Unmarshal(j, &p)).To(Succeed())
+		Expect(p).To(Equal(policy))
+
+		y := Successful(yaml.Marshal(policy))
+		Expect(string(y)).To(HavePrefix("version: 1\n"))
+		p = Policy{}
+		Expect(yaml.Unmarshal(y, &p)).To(Succeed())
+		Expect(p.Allowed.Names()).To(ConsistOf("CAP_NET_RAW"))
+		Expect(p.UserNamespace).To(BeTrue())
+		Expect(yaml.Unmarshal([]byte("version: 2\n"), &p)).NotTo(Succeed())
+		p = Policy{}
+		Expect(yaml.Unmarshal([]byte("userNamespace: true\n"), &p)).To(Succeed())
+		Expect(p.UserNamespace).To(BeTrue())
+	})
+
+	It("upgrades policies", func() {
+		p := Successful(UpgradePolicy([]byte(`{"allowed":["CAP_NET_RAW"],"allowAnonymous":true}`)))
+		Expect(p.Allowed.Names()).To(ConsistOf("CAP_NET_RAW"))
+		Expect(p.AllowAnonymous).To(BeTrue())
+
+		p = Successful(UpgradePolicy([]byte(`{"version":1,"denied":"0000000000200000"}`)))
+		Expect(p.Denied.Names()).To(ConsistOf("CAP_SYS_ADMIN"))
+
+		Expect(UpgradePolicy([]byte(`{"version":2}`))).Error().To(MatchError(ContainSubstring("version 2")))
+		Expect(UpgradePolicy([]byte(`{"version":0}`))).Error().To(HaveOccurred())
+		Expect(UpgradePolicy([]byte(`{"version":-1}`))).Error().To(HaveOccurred())
+		Expect(UpgradePolicy([]byte(`{"version":1.5}`))).Error().To(MatchError(ContainSubstring("invalid policy format version")))
+		Expect(UpgradePolicy([]byte(`{"version":"1"}`))).Error().To(HaveOccurred())
+		Expect(UpgradePolicy([]byte(`[]`))).Error().To(HaveOccurred())
+		Expect(UpgradePolicy([]byte(`{"allowed":["CAP_FOO"]}`))).Error().To(HaveOccurred())
+	})
+
+})
@@ -0,0 +1,88 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"reflect"
+	"testing"
+)
+
+// The seed corpora of the following fuzz targets live in testdata/fuzz/.
+
+func FuzzParseHex(f *testing.F) {
+	f.Fuzz(func(t *testing.T, b []byte) {
+		caps, err := ParseHex(b)
+		if err != nil {
+			return
+		}
+		again, err := ParseHex([]byte(caps.Hex()))
+		if err != nil {
+			t.Fatalf("cannot parse rendered set %q: %s", caps.Hex(), err)
+		}
+		if !reflect.DeepEqual(again.Numbers(), caps.Numbers()) {
+			t.Fatalf("round trip of %q changed set %v into %v", b, caps.Numbers(), again.Numbers())
+		}
+	})
+}
+
+func FuzzParseText(f *testing.F) {
+	f.Fuzz(func(t *testing.T, b []byte) {
+		taskcaps, err := ParseText(b)
+		if err != nil {
+			return
+		}
+		again, err := ParseText([]byte(taskcaps.Text()))
+		if err != nil {
+			t.Fatalf("cannot parse rendered text %q: %s", taskcaps.Text(), err)
+		}
+		if again.Text() != taskcaps.Text() {
+			t.Fatalf("round trip of %q changed %q into %q", b, taskcaps.Text(), again.Text())
+		}
+	})
+}
+
+func FuzzParseCapsh(f *testing.F) {
+	f.Fuzz(func(t *testing.T, b []byte) {
+		_, _ = ParseCapsh(b)
+	})
+}
+
+func FuzzParseStatus(f *testing.F) {
+	f.Fuzz(func(t *testing.T, b []byte) {
+		_, _ = ParseStatus(b)
+	})
+}
+
+func FuzzParseAuditRecord(f *testing.F) {
+	f.Fuzz(func(t *testing.T, b []byte) {
+		_, _ = ParseAuditRecord(b)
+	})
+}
+
+func FuzzParseSystemdUnit(f *testing.F) {
+	f.Fuzz(func(t *testing.T, b []byte) {
+		s, err := ParseSystemdUnit(b)
+		if err != nil {
+			return
+		}
+		again, err := ParseSystemdUnit([]byte(s.Directives()))
+		if err != nil {
+			t.Fatalf("cannot parse rendered directives %q: %s", s.Directives(), err)
+		}
+		if again.Directives() != s.Directives() {
+			t.Fatalf("round trip of %q changed %q into %q", b, s.Directives(), again.Directives())
+		}
+	})
+}
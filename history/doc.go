@@ -0,0 +1,58 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package history keeps historical snapshots of the capabilities-related states
+of processes, so that questions such as “when did this process gain
+CAP_SYS_PTRACE?” can be answered after the fact.
+
+Snapshots are identified by the host, the PID, and the time they were taken
+(see [Key]), and are kept in a pluggable [Store]; [FileStore] stores snapshots
+as JSON files in a directory tree:
+
+	store, err := history.NewFileStore("/var/lib/caps/history")
+	...
+	snapshot, err := history.Capture(hostname, pid, time.Now())
+	...
+	err = store.Put(snapshot)
+
+In order to keep stores bounded on busy hosts, [Compact] applies a [Retention]
+policy, removing snapshots exceeding a maximum age or number of versions, as
+well as identical consecutive snapshots:
+
+	removed, err := history.Compact(store, history.Retention{
+		MaxAge:      30 * 24 * time.Hour,
+		MaxVersions: 100,
+		Dedup:       true,
+	}, time.Now())
+
+[Query] answers historical questions, returning the [ChangeEvent]s between
+consecutive snapshots matching a [Filter]:
+
+	ptrace := caps.NewCapabilitiesSet()
+	ptrace.Add(caps.CAP_SYS_PTRACE)
+	events, err := history.Query(store, history.Filter{
+		PID:          pid,
+		Capabilities: ptrace,
+		Direction:    history.Gained,
+	})
+
+Change events can be exported to observability platforms as log records with
+OpenTelemetry semantic attributes, see [LogRecordOf] and [Emit]; in order to
+avoid a hard dependency on the OpenTelemetry API, records are emitted to a
+[LogEmitter] bridging them to the OpenTelemetry Logs API.
+*/
+package history
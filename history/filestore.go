@@ -0,0 +1,210 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileStore is a [Store] keeping snapshots as JSON files in a directory tree,
+// with a file per snapshot in a directory per host and PID:
+//
+//	<dir>/<host>/<pid>/<unix time in nanoseconds>.json
+//
+// Snapshots are written atomically, so concurrent readers never see partially
+// written snapshots.
+type FileStore struct {
+	dir string
+}
+
+var _ Store = (*FileStore)(nil)
+
+// NewFileStore returns a new store keeping its snapshots in the specified
+// directory, creating the directory if necessary.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// path returns the path of the file with the snapshot of the specified key,
+// or an error if the key's host name cannot be used as a directory name.
+func (s *FileStore) path(key Key) (string, error) {
+	if key.Host == "" || key.Host == "." || key.Host == ".." || strings.ContainsRune(key.Host, '/') {
+		return "", fmt.Errorf("invalid snapshot host %q", key.Host)
+	}
+	if key.PID <= 0 {
+		return "", fmt.Errorf("invalid snapshot PID %d", key.PID)
+	}
+	return filepath.Join(s.dir, key.Host, strconv.Itoa(key.PID),
+		strconv.FormatInt(key.Time.UnixNano(), 10)+".json"), nil
+}
+
+// Put stores the snapshot, replacing any existing snapshot with the same key.
+// Snapshots with a zero time are rejected.
+func (s *FileStore) Put(snapshot Snapshot) error {
+	if snapshot.Time.IsZero() {
+		return errors.New("invalid zero snapshot time")
+	}
+	path, err := s.path(snapshot.Key)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".snapshot-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // no-op after successful rename
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Get returns the snapshot with the specified key, or [ErrNotFound] if there
+// is no such snapshot.
+func (s *FileStore) Get(key Key) (Snapshot, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return Snapshot{}, ErrNotFound
+		}
+		return Snapshot{}, err
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(b, &snapshot); err != nil {
+		return Snapshot{}, fmt.Errorf("invalid snapshot %s: %w", path, err)
+	}
+	return snapshot, nil
+}
+
+// Delete removes the snapshot with the specified key, as well as the
+// directory of the snapshot's process when it becomes empty. Deleting
+// non-existing snapshots is not an error.
+func (s *FileStore) Delete(key Key) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	_ = os.Remove(filepath.Dir(path)) // fails unless empty
+	return nil
+}
+
+// List returns the keys of the stored snapshots for the specified host and
+// PID, sorted by host, PID, and time. An empty host matches all hosts and a
+// zero PID matches all PIDs. Files not following the store's naming scheme
+// are ignored.
+func (s *FileStore) List(host string, pid int) ([]Key, error) {
+	hosts := []string{host}
+	if host == "" {
+		var err error
+		if hosts, err = subdirs(s.dir); err != nil {
+			return nil, err
+		}
+	}
+	keys := []Key{}
+	for _, host := range hosts {
+		pids := []string{strconv.Itoa(pid)}
+		if pid == 0 {
+			var err error
+			if pids, err = subdirs(filepath.Join(s.dir, host)); err != nil {
+				return nil, err
+			}
+		}
+		for _, pidname := range pids {
+			pid, err := strconv.Atoi(pidname)
+			if err != nil || pid <= 0 {
+				continue
+			}
+			entries, err := os.ReadDir(filepath.Join(s.dir, host, pidname))
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					continue
+				}
+				return nil, err
+			}
+			for _, entry := range entries {
+				if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+					continue
+				}
+				ns, err := strconv.ParseInt(strings.TrimSuffix(entry.Name(), ".json"), 10, 64)
+				if err != nil {
+					continue
+				}
+				keys = append(keys, Key{Host: host, PID: pid, Time: time.Unix(0, ns)})
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.PID != b.PID {
+			return a.PID < b.PID
+		}
+		return a.Time.Before(b.Time)
+	})
+	return keys, nil
+}
+
+// subdirs returns the names of the subdirectories of the specified directory,
+// or none if the directory doesn't exist.
+func subdirs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package history
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thediveo/caps"
+)
+
+// ErrNotFound is returned by [Store.Get] if there is no snapshot with the
+// specified key.
+var ErrNotFound = errors.New("snapshot not found")
+
+// Key identifies a snapshot by the host, the PID of the process, and the time
+// the snapshot was taken.
+type Key struct {
+	Host string    `json:"host" yaml:"host"`
+	PID  int       `json:"pid" yaml:"pid"`
+	Time time.Time `json:"time" yaml:"time"`
+}
+
+// Snapshot is the capabilities-related state of a process at a particular
+// time.
+type Snapshot struct {
+	Key   `yaml:",inline"`
+	Name  string     `json:"name,omitempty" yaml:"name,omitempty"` // process name, if known
+	State caps.State `json:"state" yaml:"state"`
+}
+
+// Store stores snapshots, keyed by host, PID, and time.
+type Store interface {
+	// Put stores the snapshot, replacing any existing snapshot with the same
+	// key. Put rejects snapshots with a zero time.
+	Put(snapshot Snapshot) error
+	// Get returns the snapshot with the specified key, or [ErrNotFound] if
+	// there is no such snapshot.
+	Get(key Key) (Snapshot, error)
+	// List returns the keys of the stored snapshots for the specified host
+	// and PID, sorted by host, PID, and time. An empty host matches all
+	// hosts and a zero PID matches all PIDs.
+	List(host string, pid int) ([]Key, error)
+	// Delete removes the snapshot with the specified key; deleting
+	// non-existing snapshots is not an error.
+	Delete(key Key) error
+}
+
+// Capture returns a snapshot of the process with the specified PID on the
+// local host, identified by the specified host name, taken at the specified
+// time. The process name is taken from the proc filesystem (see
+// [caps.Config.ProcRoot]) and left empty if it cannot be read.
+func Capture(host string, pid int, at time.Time) (Snapshot, error) {
+	state, err := caps.StateOf(pid)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snapshot := Snapshot{
+		Key:   Key{Host: host, PID: pid, Time: at},
+		State: state,
+	}
+	comm, err := os.ReadFile(caps.CurrentConfig().ProcRoot + "/" + strconv.Itoa(pid) + "/comm")
+	if err == nil {
+		snapshot.Name = strings.TrimSuffix(string(comm), "\n")
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package history
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/thediveo/caps"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var epoch = time.Date(2023, 1, 2, 3, 4, 5, 6, time.UTC)
+
+func capsOf(capnos ...int) caps.CapabilitiesSet {
+	set := caps.NewCapabilitiesSet()
+	for _, capno := range capnos {
+		set.Add(capno)
+	}
+	return set
+}
+
+func snapshotAt(host string, pid int, offset time.Duration, capnos ...int) Snapshot {
+	return Snapshot{
+		Key:   Key{Host: host, PID: pid, Time: epoch.Add(offset)},
+		Name:  "foo",
+		State: caps.State{TaskCapabilities: caps.TaskCapabilities{Effective: capsOf(capnos...)}},
+	}
+}
+
+var _ = Describe("file store", func() {
+
+	var store *FileStore
+
+	BeforeEach(func() {
+		store = Successful(NewFileStore(GinkgoT().TempDir() + "/history"))
+	})
+
+	It("puts and gets snapshots", func() {
+		snapshot := snapshotAt("alpha", 42, 0, caps.CAP_SYS_PTRACE)
+		Expect(store.Put(snapshot)).To(Succeed())
+		got := Successful(store.Get(snapshot.Key))
+		Expect(got.Time.Equal(snapshot.Time)).To(BeTrue())
+		Expect(got.Name).To(Equal("foo"))
+		Expect(got.State.Effective.Has(caps.CAP_SYS_PTRACE)).To(BeTrue())
+
+		snapshot.Name = "bar"
+		Expect(store.Put(snapshot)).To(Succeed())
+		Expect(store.Get(snapshot.Key)).To(HaveField("Name", "bar"))
+
+		_, err := store.Get(Key{Host: "alpha", PID: 42, Time: epoch.Add(time.Second)})
+		Expect(err).To(MatchError(ErrNotFound))
+	})
+
+	It("deletes snapshots", func() {
+		snapshot := snapshotAt("alpha", 42, 0)
+		Expect(store.Put(snapshot)).To(Succeed())
+		Expect(store.Delete(snapshot.Key)).To(Succeed())
+		Expect(store.Get(snapshot.Key)).Error().To(MatchError(ErrNotFound))
+		Expect(store.dir + "/alpha/42").NotTo(BeADirectory())
+		Expect(store.Delete(snapshot.Key)).To(Succeed())
+		Expect(store.Delete(Key{Host: "..", PID: 42})).NotTo(Succeed())
+	})
+
+	It("rejects invalid keys", func() {
+		for _, key := range []Key{
+			{Host: "", PID: 1},
+			{Host: "..", PID: 1},
+			{Host: "a/b", PID: 1},
+			{Host: "alpha", PID: 0},
+		} {
+			Expect(store.Put(Snapshot{Key: key})).NotTo(Succeed(), "key %v", key)
+			Expect(store.Get(key)).Error().To(HaveOccurred(), "key %v", key)
+		}
+	})
+
+	It("rejects zero snapshot times", func() {
+		Expect(store.Put(Snapshot{Key: Key{Host: "alpha", PID: 42}})).To(
+			MatchError(ContainSubstring("zero snapshot time")))
+		Expect(store.List("", 0)).To(BeEmpty())
+	})
+
+	It("lists snapshot keys", func() {
+		Expect(store.List("", 0)).To(BeEmpty())
+		for _, snapshot := range []Snapshot{
+			snapshotAt("beta", 1, time.Minute),
+			snapshotAt("alpha", 666, 0),
+			snapshotAt("alpha", 42, time.Hour),
+			snapshotAt("alpha", 42, 0),
+		} {
+			Expect(store.Put(snapshot)).To(Succeed())
+		}
+		Expect(os.WriteFile(store.dir+"/alpha/42/README", nil, 0o600)).To(Succeed())
+		Expect(os.Mkdir(store.dir+"/alpha/init", 0o700)).To(Succeed())
+
+		keyOf := func(k Key) string { return k.Host + "/" + strconv.Itoa(k.PID) + "@" + k.Time.Sub(epoch).String() }
+		Expect(Successful(store.List("", 0))).To(WithTransform(func(keys []Key) []string {
+			s := []string{}
+			for _, k := range keys {
+				s = append(s, keyOf(k))
+			}
+			return s
+		}, Equal([]string{"alpha/42@0s", "alpha/42@1h0m0s", "alpha/666@0s", "beta/1@1m0s"})))
+		Expect(store.List("alpha", 0)).To(HaveLen(3))
+		Expect(store.List("", 42)).To(HaveLen(2))
+		Expect(store.List("gamma", 1)).To(BeEmpty())
+	})
+
+})
+
+var _ = Describe("capturing snapshots", func() {
+
+	It("captures the current process", func() {
+		at := time.Now()
+		snapshot := Successful(Capture("localhost", os.Getpid(), at))
+		Expect(snapshot.Key).To(Equal(Key{Host: "localhost", PID: os.Getpid(), Time: at}))
+		Expect(snapshot.Name).NotTo(BeEmpty())
+		Expect(snapshot.State.Bounding).NotTo(BeEmpty())
+	})
+
+	It("fails for non-existing processes", func() {
+		Expect(Capture("localhost", -1, time.Now())).Error().To(HaveOccurred())
+	})
+
+})
@@ -0,0 +1,149 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package history
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/thediveo/caps"
+)
+
+// OpenTelemetry severity numbers of the log records of change events.
+const (
+	SeverityInfo = 9  // capabilities lost, or other changes
+	SeverityWarn = 13 // capabilities gained
+)
+
+// ChangeEventName is the event name of the log records of change events.
+const ChangeEventName = "caps.change"
+
+// LogRecord is a change event in the shape of an OpenTelemetry log record,
+// without depending on the OpenTelemetry API, so that [LogEmitter]
+// implementations can bridge it to the OpenTelemetry Logs API (or any other
+// logging or event exporting API) with a few lines of code.
+type LogRecord struct {
+	Timestamp      time.Time
+	EventName      string
+	SeverityNumber int
+	SeverityText   string
+	Body           string
+	// Attributes have string, int64, bool, or []string values.
+	Attributes []Attribute
+}
+
+// Attribute is a key-value pair attribute of a [LogRecord].
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// LogEmitter emits log records, such as by bridging them to an OpenTelemetry
+// logger:
+//
+//	func (b bridge) Emit(ctx context.Context, r history.LogRecord) {
+//		var rec log.Record
+//		rec.SetTimestamp(r.Timestamp)
+//		rec.SetEventName(r.EventName)
+//		rec.SetSeverity(log.Severity(r.SeverityNumber))
+//		rec.SetSeverityText(r.SeverityText)
+//		rec.SetBody(log.StringValue(r.Body))
+//		// ...convert and add the attributes...
+//		b.logger.Emit(ctx, rec)
+//	}
+type LogEmitter interface {
+	Emit(ctx context.Context, record LogRecord)
+}
+
+// LogRecordOf returns the change event as a log record. The host, PID, and
+// process name are passed in the OpenTelemetry semantic convention attributes
+// “host.name”, “process.pid”, and “process.executable.name”. The names of the
+// capabilities added to and removed from the capabilities sets are passed in
+// the “caps.<set>.added” and “caps.<set>.removed” attributes, such as
+// “caps.effective.added”, the names of the securebits set and cleared in
+// “caps.securebits.set” and “caps.securebits.cleared”, and a changed
+// no_new_privs flag in “caps.no_new_privs”; only changed aspects are passed.
+// Change events gaining capabilities have severity WARN, all others INFO.
+func LogRecordOf(event ChangeEvent) LogRecord {
+	record := LogRecord{
+		Timestamp:      event.Time,
+		EventName:      ChangeEventName,
+		SeverityNumber: SeverityInfo,
+		SeverityText:   "INFO",
+		Attributes: []Attribute{
+			{Key: "host.name", Value: event.Host},
+			{Key: "process.pid", Value: int64(event.PID)},
+		},
+	}
+	if event.Name != "" {
+		record.Attributes = append(record.Attributes, Attribute{Key: "process.executable.name", Value: event.Name})
+	}
+	changes := []string{}
+	for _, set := range []struct {
+		name string
+		diff caps.SetDiff
+	}{
+		{"effective", event.Diff.Effective},
+		{"permitted", event.Diff.Permitted},
+		{"inheritable", event.Diff.Inheritable},
+		{"bounding", event.Diff.Bounding},
+		{"ambient", event.Diff.Ambient},
+	} {
+		if added := set.diff.Added.Names(); len(added) != 0 {
+			record.Attributes = append(record.Attributes, Attribute{Key: "caps." + set.name + ".added", Value: added})
+			record.SeverityNumber, record.SeverityText = SeverityWarn, "WARN"
+		}
+		if removed := set.diff.Removed.Names(); len(removed) != 0 {
+			record.Attributes = append(record.Attributes, Attribute{Key: "caps." + set.name + ".removed", Value: removed})
+		}
+		if !set.diff.Empty() {
+			changes = append(changes, set.name+" "+set.diff.String())
+		}
+	}
+	if event.Diff.SecurebitsSet != 0 {
+		record.Attributes = append(record.Attributes,
+			Attribute{Key: "caps.securebits.set", Value: caps.SecurebitsNames(event.Diff.SecurebitsSet)})
+	}
+	if event.Diff.SecurebitsCleared != 0 {
+		record.Attributes = append(record.Attributes,
+			Attribute{Key: "caps.securebits.cleared", Value: caps.SecurebitsNames(event.Diff.SecurebitsCleared)})
+	}
+	if event.Diff.SecurebitsSet != 0 || event.Diff.SecurebitsCleared != 0 {
+		changes = append(changes, "securebits")
+	}
+	if event.Diff.NoNewPrivsChanged {
+		record.Attributes = append(record.Attributes, Attribute{Key: "caps.no_new_privs", Value: event.Diff.NoNewPrivs})
+		changes = append(changes, "no_new_privs")
+	}
+	process := fmt.Sprintf("process %d", event.PID)
+	if event.Name != "" {
+		process += " (" + event.Name + ")"
+	}
+	record.Body = fmt.Sprintf("capabilities of %s on %s changed: %s",
+		process, event.Host, strings.Join(changes, "; "))
+	return record
+}
+
+// Emit emits the change events as log records to the emitter, see
+// [LogRecordOf].
+func Emit(ctx context.Context, emitter LogEmitter, events []ChangeEvent) {
+	for _, event := range events {
+		emitter.Emit(ctx, LogRecordOf(event))
+	}
+}
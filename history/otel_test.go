@@ -0,0 +1,96 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package history
+
+import (
+	"context"
+	"time"
+
+	"github.com/thediveo/caps"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+// recordingEmitter records the emitted log records.
+type recordingEmitter struct{ records []LogRecord }
+
+func (e *recordingEmitter) Emit(ctx context.Context, record LogRecord) {
+	e.records = append(e.records, record)
+}
+
+var _ = Describe("log records", func() {
+
+	It("converts capability gains", func() {
+		before := snapshotAt("alpha", 42, 0, caps.CAP_NET_RAW)
+		after := snapshotAt("alpha", 42, time.Minute, caps.CAP_SYS_PTRACE)
+		record := LogRecordOf(ChangeEvent{
+			Key:      after.Key,
+			Name:     "foo",
+			Previous: before.Time,
+			Diff:     caps.DiffStates(before.State, after.State),
+		})
+		Expect(record.Timestamp).To(Equal(after.Time))
+		Expect(record.EventName).To(Equal(ChangeEventName))
+		Expect(record.SeverityNumber).To(Equal(SeverityWarn))
+		Expect(record.SeverityText).To(Equal("WARN"))
+		Expect(record.Body).To(Equal(
+			"capabilities of process 42 (foo) on alpha changed: effective +CAP_SYS_PTRACE -CAP_NET_RAW"))
+		Expect(record.Attributes).To(Equal([]Attribute{
+			{Key: "host.name", Value: "alpha"},
+			{Key: "process.pid", Value: int64(42)},
+			{Key: "process.executable.name", Value: "foo"},
+			{Key: "caps.effective.added", Value: []string{"CAP_SYS_PTRACE"}},
+			{Key: "caps.effective.removed", Value: []string{"CAP_NET_RAW"}},
+		}))
+	})
+
+	It("converts other changes", func() {
+		after := caps.State{Securebits: caps.SECBIT_KEEP_CAPS, NoNewPrivs: true}
+		before := caps.State{TaskCapabilities: caps.TaskCapabilities{Permitted: capsOf(caps.CAP_CHOWN)}}
+		record := LogRecordOf(ChangeEvent{
+			Key:  Key{Host: "alpha", PID: 42, Time: epoch},
+			Diff: caps.DiffStates(before, after),
+		})
+		Expect(record.SeverityNumber).To(Equal(SeverityInfo))
+		Expect(record.Body).To(Equal(
+			"capabilities of process 42 on alpha changed: permitted -CAP_CHOWN; securebits; no_new_privs"))
+		Expect(record.Attributes).To(Equal([]Attribute{
+			{Key: "host.name", Value: "alpha"},
+			{Key: "process.pid", Value: int64(42)},
+			{Key: "caps.permitted.removed", Value: []string{"CAP_CHOWN"}},
+			{Key: "caps.securebits.set", Value: []string{"SECBIT_KEEP_CAPS"}},
+			{Key: "caps.no_new_privs", Value: true},
+		}))
+	})
+
+	It("emits queried change events", func() {
+		store := Successful(NewFileStore(GinkgoT().TempDir()))
+		for _, snapshot := range []Snapshot{
+			snapshotAt("alpha", 42, 0),
+			snapshotAt("alpha", 42, time.Minute, caps.CAP_SYS_PTRACE),
+			snapshotAt("alpha", 42, 2*time.Minute),
+		} {
+			Expect(store.Put(snapshot)).To(Succeed())
+		}
+		emitter := &recordingEmitter{}
+		Emit(context.Background(), emitter, Successful(Query(store, Filter{})))
+		Expect(emitter.records).To(HaveLen(2))
+		Expect(emitter.records[0].SeverityText).To(Equal("WARN"))
+		Expect(emitter.records[1].SeverityText).To(Equal("INFO"))
+	})
+
+})
@@ -0,0 +1,145 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package history
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/thediveo/caps"
+)
+
+// Direction is the direction of capability changes.
+type Direction string
+
+// Directions of capability changes.
+const (
+	Gained Direction = "gained" // capabilities added to any set
+	Lost   Direction = "lost"   // capabilities removed from any set
+)
+
+// ChangeEvent is a change in the capabilities-related state of a process
+// between two consecutive snapshots of the process.
+type ChangeEvent struct {
+	// Key of the snapshot after the change.
+	Key      `yaml:",inline"`
+	Name     string         `json:"name,omitempty" yaml:"name,omitempty"` // process name after the change, if known
+	Previous time.Time      `json:"previous" yaml:"previous"`             // time of the snapshot before the change
+	Diff     caps.StateDiff `json:"diff" yaml:"diff"`
+}
+
+// Filter selects change events. Zero-valued fields match all events.
+type Filter struct {
+	Host string // host name
+	PID  int    // process ID
+	Name string // process name after the change
+	// Capabilities selects changes of any of these capabilities in any of the
+	// capabilities sets; if empty, changes of any capabilities, as well as of
+	// securebits and the no_new_privs flag, are selected.
+	Capabilities caps.CapabilitiesSet
+	Since        time.Time // earliest time of changes, inclusive
+	Until        time.Time // latest time of changes, exclusive
+	Direction    Direction // gained or lost capabilities
+}
+
+// Query returns the change events in the store matching the filter, sorted by
+// host, PID, and time. Changes are detected between consecutive snapshots of
+// the same process, so the first snapshot of a process never results in a
+// change event.
+func Query(store Store, filter Filter) ([]ChangeEvent, error) {
+	switch filter.Direction {
+	case "", Gained, Lost:
+	default:
+		return nil, fmt.Errorf("invalid change direction %q", filter.Direction)
+	}
+	keys, err := store.List(filter.Host, filter.PID)
+	if err != nil {
+		return nil, err
+	}
+	events := []ChangeEvent{}
+	var prev *Snapshot
+	for idx, key := range keys {
+		if idx == 0 || key.Host != keys[idx-1].Host || key.PID != keys[idx-1].PID {
+			prev = nil
+		}
+		if !filter.Until.IsZero() && !key.Time.Before(filter.Until) {
+			continue
+		}
+		// The snapshot preceding the queried time range is still needed in
+		// order to detect the first change in the time range.
+		if !filter.Since.IsZero() && key.Time.Before(filter.Since) &&
+			idx+1 < len(keys) && keys[idx+1].Host == key.Host && keys[idx+1].PID == key.PID &&
+			keys[idx+1].Time.Before(filter.Since) {
+			continue
+		}
+		snapshot, err := store.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		if prev != nil && !key.Time.Before(filter.Since) {
+			event := ChangeEvent{
+				Key:      snapshot.Key,
+				Name:     snapshot.Name,
+				Previous: prev.Time,
+				Diff:     caps.DiffStates(prev.State, snapshot.State),
+			}
+			if filter.matches(event) {
+				events = append(events, event)
+			}
+		}
+		prev = &snapshot
+	}
+	return events, nil
+}
+
+// matches returns true if the change event matches the filter's name,
+// capabilities, and direction.
+func (f Filter) matches(event ChangeEvent) bool {
+	if event.Diff.Empty() || (f.Name != "" && event.Name != f.Name) {
+		return false
+	}
+	sets := []caps.SetDiff{event.Diff.Effective, event.Diff.Permitted, event.Diff.Inheritable,
+		event.Diff.Bounding, event.Diff.Ambient}
+	if len(f.Capabilities.Numbers()) == 0 {
+		switch f.Direction {
+		case Gained:
+			for _, set := range sets {
+				if len(set.Added.Numbers()) != 0 {
+					return true
+				}
+			}
+			return false
+		case Lost:
+			for _, set := range sets {
+				if len(set.Removed.Numbers()) != 0 {
+					return true
+				}
+			}
+			return false
+		}
+		return true
+	}
+	for _, capno := range f.Capabilities.Numbers() {
+		for _, set := range sets {
+			if (f.Direction != Lost && set.Added.Has(capno)) ||
+				(f.Direction != Gained && set.Removed.Has(capno)) {
+				return true
+			}
+		}
+	}
+	return false
+}
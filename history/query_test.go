@@ -0,0 +1,104 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package history
+
+import (
+	"strings"
+	"time"
+
+	"github.com/thediveo/caps"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("querying history", func() {
+
+	var store *FileStore
+
+	query := func(filter Filter) []string {
+		events := []string{}
+		for _, event := range Successful(Query(store, filter)) {
+			events = append(events, event.Host+"@"+event.Time.Sub(epoch).String()+" "+strings.TrimSuffix(event.Diff.String(), "\n"))
+		}
+		return events
+	}
+
+	BeforeEach(func() {
+		store = Successful(NewFileStore(GinkgoT().TempDir()))
+		bar := snapshotAt("beta", 1, 2*time.Minute, caps.CAP_SYS_PTRACE)
+		bar.Name = "bar"
+		for _, snapshot := range []Snapshot{
+			snapshotAt("alpha", 42, 0),
+			snapshotAt("alpha", 42, 1*time.Minute),
+			snapshotAt("alpha", 42, 2*time.Minute, caps.CAP_SYS_PTRACE),
+			snapshotAt("alpha", 42, 3*time.Minute, caps.CAP_NET_RAW),
+			snapshotAt("beta", 1, 0, caps.CAP_NET_RAW),
+			bar,
+		} {
+			Expect(store.Put(snapshot)).To(Succeed())
+		}
+	})
+
+	It("returns all changes", func() {
+		events := Successful(Query(store, Filter{}))
+		Expect(events).To(HaveLen(3))
+		Expect(events[0].Previous.Equal(epoch.Add(time.Minute))).To(BeTrue())
+		Expect(events[0].Name).To(Equal("foo"))
+		Expect(query(Filter{})).To(Equal([]string{
+			"alpha@2m0s effective: +CAP_SYS_PTRACE",
+			"alpha@3m0s effective: +CAP_NET_RAW -CAP_SYS_PTRACE",
+			"beta@2m0s effective: +CAP_SYS_PTRACE -CAP_NET_RAW",
+		}))
+	})
+
+	It("filters by host, PID, and process name", func() {
+		Expect(query(Filter{Host: "beta"})).To(HaveLen(1))
+		Expect(query(Filter{PID: 42})).To(HaveLen(2))
+		Expect(query(Filter{Name: "bar"})).To(ConsistOf(HavePrefix("beta@")))
+		Expect(query(Filter{Host: "gamma"})).To(BeEmpty())
+	})
+
+	It("filters by capability and direction", func() {
+		ptrace := caps.NewCapabilitiesSet()
+		ptrace.Add(caps.CAP_SYS_PTRACE)
+		Expect(query(Filter{Capabilities: ptrace, Direction: Gained})).To(Equal([]string{
+			"alpha@2m0s effective: +CAP_SYS_PTRACE",
+			"beta@2m0s effective: +CAP_SYS_PTRACE -CAP_NET_RAW",
+		}))
+		Expect(query(Filter{Capabilities: ptrace, Direction: Lost})).To(Equal([]string{
+			"alpha@3m0s effective: +CAP_NET_RAW -CAP_SYS_PTRACE",
+		}))
+		Expect(query(Filter{Capabilities: ptrace})).To(HaveLen(3))
+		Expect(query(Filter{Direction: Lost})).To(HaveLen(2))
+		Expect(query(Filter{Host: "alpha", Direction: Gained})).To(HaveLen(2))
+
+		Expect(Query(store, Filter{Direction: "sideways"})).Error().To(
+			MatchError(ContainSubstring("invalid change direction")))
+	})
+
+	It("filters by time range", func() {
+		Expect(query(Filter{Since: epoch.Add(3 * time.Minute)})).To(Equal([]string{
+			"alpha@3m0s effective: +CAP_NET_RAW -CAP_SYS_PTRACE",
+		}))
+		Expect(query(Filter{Since: epoch.Add(2 * time.Minute), Until: epoch.Add(3 * time.Minute)})).To(Equal([]string{
+			"alpha@2m0s effective: +CAP_SYS_PTRACE",
+			"beta@2m0s effective: +CAP_SYS_PTRACE -CAP_NET_RAW",
+		}))
+		Expect(query(Filter{Until: epoch.Add(2 * time.Minute)})).To(BeEmpty())
+	})
+
+})
@@ -0,0 +1,100 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package history
+
+import (
+	"time"
+
+	"github.com/thediveo/caps"
+)
+
+// Retention is a retention policy for snapshot histories, keeping stores
+// bounded on busy hosts. Zero limits mean unlimited, so the zero Retention
+// keeps all snapshots.
+type Retention struct {
+	// MaxAge is the maximum age of snapshots to keep.
+	MaxAge time.Duration
+	// MaxVersions is the maximum number of snapshots to keep per host and
+	// PID; the newest snapshots are kept.
+	MaxVersions int
+	// Dedup removes snapshots identical to their preceding snapshot of the
+	// same host and PID, keeping only the earliest snapshot of each run of
+	// identical snapshots. Snapshots are identical if their process names
+	// are the same and there are no differences between their states (see
+	// [caps.DiffStates]).
+	Dedup bool
+}
+
+// Compact applies the retention policy to the snapshots in the store at the
+// specified time, returning the number of snapshots removed. Identical
+// snapshots are removed first, so that they don't count towards the maximum
+// number of versions.
+func Compact(store Store, retention Retention, now time.Time) (int, error) {
+	keys, err := store.List("", 0)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for first := 0; first < len(keys); {
+		last := first + 1
+		for last < len(keys) && keys[last].Host == keys[first].Host && keys[last].PID == keys[first].PID {
+			last++
+		}
+		n, err := compactProcess(store, retention, keys[first:last], now)
+		removed += n
+		if err != nil {
+			return removed, err
+		}
+		first = last
+	}
+	return removed, nil
+}
+
+// compactProcess applies the retention policy to the snapshot keys of a
+// single process, sorted by time, returning the number of snapshots removed.
+func compactProcess(store Store, retention Retention, keys []Key, now time.Time) (int, error) {
+	keep := make([]Key, 0, len(keys))
+	drop := []Key{}
+	var prev *Snapshot
+	for _, key := range keys {
+		if retention.MaxAge > 0 && now.Sub(key.Time) > retention.MaxAge {
+			drop = append(drop, key)
+			continue
+		}
+		if retention.Dedup {
+			snapshot, err := store.Get(key)
+			if err != nil {
+				return 0, err
+			}
+			if prev != nil && prev.Name == snapshot.Name && caps.DiffStates(prev.State, snapshot.State).Empty() {
+				drop = append(drop, key)
+				continue
+			}
+			prev = &snapshot
+		}
+		keep = append(keep, key)
+	}
+	if retention.MaxVersions > 0 && len(keep) > retention.MaxVersions {
+		drop = append(drop, keep[:len(keep)-retention.MaxVersions]...)
+	}
+	for idx, key := range drop {
+		if err := store.Delete(key); err != nil {
+			return idx, err
+		}
+	}
+	return len(drop), nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package history
+
+import (
+	"errors"
+	"time"
+
+	"github.com/thediveo/caps"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+// failingStore fails deleting snapshots.
+type failingStore struct{ Store }
+
+func (failingStore) Delete(Key) error { return errors.New("read-only") }
+
+var _ = Describe("retention", func() {
+
+	var store *FileStore
+
+	offsets := func(host string, pid int) []time.Duration {
+		offsets := []time.Duration{}
+		for _, key := range Successful(store.List(host, pid)) {
+			offsets = append(offsets, key.Time.Sub(epoch))
+		}
+		return offsets
+	}
+
+	BeforeEach(func() {
+		store = Successful(NewFileStore(GinkgoT().TempDir()))
+		for _, snapshot := range []Snapshot{
+			snapshotAt("alpha", 42, 0),
+			snapshotAt("alpha", 42, 1*time.Minute),
+			snapshotAt("alpha", 42, 2*time.Minute, caps.CAP_SYS_PTRACE),
+			snapshotAt("alpha", 42, 3*time.Minute, caps.CAP_SYS_PTRACE),
+			snapshotAt("alpha", 42, 4*time.Minute),
+			snapshotAt("beta", 1, 0),
+		} {
+			Expect(store.Put(snapshot)).To(Succeed())
+		}
+	})
+
+	It("keeps everything by default", func() {
+		Expect(Compact(store, Retention{}, epoch.Add(time.Hour))).To(BeZero())
+		Expect(offsets("", 0)).To(HaveLen(6))
+	})
+
+	It("removes identical consecutive snapshots", func() {
+		Expect(Compact(store, Retention{Dedup: true}, epoch)).To(Equal(2))
+		Expect(offsets("alpha", 42)).To(Equal([]time.Duration{0, 2 * time.Minute, 4 * time.Minute}))
+		Expect(offsets("beta", 1)).To(HaveLen(1))
+	})
+
+	It("removes snapshots exceeding the maximum age", func() {
+		Expect(Compact(store, Retention{MaxAge: 2 * time.Minute}, epoch.Add(4*time.Minute))).To(Equal(3))
+		Expect(offsets("alpha", 42)).To(Equal([]time.Duration{2 * time.Minute, 3 * time.Minute, 4 * time.Minute}))
+		Expect(offsets("beta", 1)).To(BeEmpty())
+	})
+
+	It("keeps the newest versions", func() {
+		Expect(Compact(store, Retention{MaxVersions: 2, Dedup: true}, epoch)).To(Equal(3))
+		Expect(offsets("alpha", 42)).To(Equal([]time.Duration{2 * time.Minute, 4 * time.Minute}))
+		Expect(offsets("beta", 1)).To(HaveLen(1))
+	})
+
+	It("reports failing deletions", func() {
+		n, err := Compact(failingStore{store}, Retention{MaxVersions: 1}, epoch)
+		Expect(err).To(MatchError("read-only"))
+		Expect(n).To(BeZero())
+	})
+
+})
@@ -0,0 +1,171 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+
+	"github.com/thediveo/caps/internal/diag"
+)
+
+// InspectionAspect identifies an aspect of inspecting a task that might need
+// privileges and thus might get skipped when inspecting without them.
+type InspectionAspect string
+
+// The aspects of inspecting tasks that might get skipped, as well as the
+// capabilities unlocking them:
+//
+//   - InspectPidfd: querying the capabilities via a PID file descriptor, safe
+//     against PID recycling. Needs Linux 5.3+ and a thread group leader;
+//     otherwise, capabilities are queried by task ID instead.
+//   - InspectState: reading the full capabilities state from /proc/[tid]/status,
+//     which might be hidden for tasks of other users ("hidepid" mount option);
+//     unlocked by CAP_SYS_PTRACE.
+//   - InspectExecutable: reading the executable path from /proc/[tid]/exe,
+//     which for tasks of other users is unlocked by CAP_SYS_PTRACE.
+const (
+	InspectPidfd      InspectionAspect = "pidfd"
+	InspectState      InspectionAspect = "state"
+	InspectExecutable InspectionAspect = "executable"
+)
+
+// Degradation reports an aspect of a task inspection that has been skipped,
+// as well as the capability that would unlock it, if any.
+type Degradation struct {
+	Aspect     InspectionAspect `json:"aspect"`
+	Capability string           `json:"capability,omitempty"`
+	Reason     string           `json:"reason"`
+	err        error            // original error, if known.
+}
+
+// TaskInspection is the best-effort result of inspecting a task, listing the
+// aspects that had to be skipped.
+type TaskInspection struct {
+	TID          int              `json:"tid"`
+	Capabilities TaskCapabilities `json:"capabilities"`
+	State        *State           `json:"state,omitempty"`
+	Executable   string           `json:"executable,omitempty"`
+	Skipped      []Degradation    `json:"skipped,omitempty"`
+}
+
+// InspectTask inspects the task with the specified tid as far as the
+// privileges of the calling task allow, degrading gracefully instead of
+// failing: aspects that cannot be inspected are skipped and reported in
+// Skipped, together with the capability that would unlock them (see
+// [InspectionAspect]). InspectTask only returns an error if it cannot even
+// query the effective, permitted and inheritable capabilities of the task,
+// such as when the task doesn't exist.
+//
+// The tid is in the caller's own PID namespace, as it gets passed to
+// syscalls. Thus, InspectTask reads the state and executable of the task from
+// the caller's own proc filesystem, independent of [Config.ProcRoot], so that
+// all aspects of the inspection always refer to the same task.
+func InspectTask(tid int) (TaskInspection, error) {
+	insp := TaskInspection{TID: tid}
+	var err error
+	if insp.Capabilities, err = inspectCapabilities(tid); err != nil {
+		if errors.Is(err, syscall.ESRCH) {
+			return TaskInspection{}, err
+		}
+		insp.skip(InspectPidfd, err)
+		if insp.Capabilities, err = OfTask(tid); err != nil {
+			return TaskInspection{}, err
+		}
+	}
+	state, err := ownStateOf(tid)
+	if err != nil {
+		// With capget(2) having succeeded, the task exists, so a missing
+		// status means it has been hidden from us.
+		insp.skip(InspectState, err)
+	} else {
+		insp.State = &state
+	}
+	exe, err := os.Readlink(ownProcPath(strconv.Itoa(tid) + "/exe"))
+	switch {
+	case err == nil:
+		insp.Executable = exe
+	case errors.Is(err, os.ErrNotExist) && insp.State != nil:
+		// kernel threads don't have executables.
+	default:
+		insp.skip(InspectExecutable, err)
+	}
+	if len(insp.Skipped) != 0 {
+		diag.Log("degraded task inspection", "tid", tid, "skipped", len(insp.Skipped))
+	}
+	return insp, nil
+}
+
+// inspectCapabilities queries the capabilities of the specified task via a
+// PID file descriptor.
+func inspectCapabilities(tid int) (TaskCapabilities, error) {
+	h, err := OpenProcess(tid)
+	if err != nil {
+		return TaskCapabilities{}, err
+	}
+	defer h.Close()
+	return h.Capabilities()
+}
+
+// skip records the specified aspect as skipped due to the specified error,
+// together with the capability that would unlock it.
+func (i *TaskInspection) skip(aspect InspectionAspect, err error) {
+	d := Degradation{Aspect: aspect, Reason: err.Error(), err: err}
+	if aspect != InspectPidfd &&
+		(errors.Is(err, os.ErrPermission) || errors.Is(err, os.ErrNotExist)) {
+		d.Capability = "CAP_SYS_PTRACE"
+	}
+	i.Skipped = append(i.Skipped, d)
+}
+
+// Degraded returns true if any aspects of the inspection have been skipped.
+func (i TaskInspection) Degraded() bool {
+	return len(i.Skipped) != 0
+}
+
+// Err returns the errors that caused skipping aspects of this inspection as a
+// [MultiError], or nil if no aspects have been skipped. Inspections that have
+// been unmarshalled or redacted only retain the reasons of their errors.
+func (i TaskInspection) Err() error {
+	var errs MultiError
+	for _, d := range i.Skipped {
+		err := d.err
+		if err == nil {
+			err = errors.New(d.Reason)
+		}
+		errs = append(errs, fmt.Errorf("skipped inspecting %s: %w", d.Aspect, err))
+	}
+	return errs.errOrNil()
+}
+
+// Findings returns the skipped aspects of this inspection as warning findings,
+// with remediation hints naming the capabilities that would unlock them.
+func (i TaskInspection) Findings() Findings {
+	var findings Findings
+	for _, d := range i.Skipped {
+		f := warningFinding(string(d.Aspect),
+			"skipped inspecting %s of task %d: %s", string(d.Aspect), i.TID, d.Reason)
+		if d.Capability != "" {
+			f = f.withRemediation("grant %s", d.Capability)
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
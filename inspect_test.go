@@ -0,0 +1,102 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"os"
+	"runtime"
+
+	"github.com/thediveo/caps/capstest"
+	"golang.org/x/sys/unix"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("task inspection", func() {
+
+	BeforeEach(func() {
+		cfg := CurrentConfig()
+		DeferCleanup(func() { Configure(cfg) })
+	})
+
+	It("fully inspects a task when privileged", func() {
+		capstest.RequireEffective(GinkgoT(), CAP_SYS_ADMIN)
+		insp := Successful(InspectTask(os.Getpid()))
+		Expect(insp.Degraded()).To(BeFalse())
+		Expect(insp.Err()).To(Succeed())
+		Expect(insp.Findings()).To(BeEmpty())
+		Expect(insp.Capabilities.Effective.Has(CAP_SYS_ADMIN)).To(BeTrue())
+		Expect(insp.State).NotTo(BeNil())
+		Expect(insp.Executable).NotTo(BeEmpty())
+	})
+
+	It("inspects all aspects in the own PID namespace", func() {
+		capstest.RequireEffective(GinkgoT(), CAP_SYS_ADMIN)
+		Configure(Config{ProcRoot: GinkgoT().TempDir()})
+		insp := Successful(InspectTask(os.Getpid()))
+		Expect(insp.Degraded()).To(BeFalse())
+		Expect(insp.State).NotTo(BeNil())
+		Expect(insp.Executable).NotTo(BeEmpty())
+	})
+
+	It("inspects the capabilities of processes owned by other users via pidfd", func() {
+		capstest.RequireEffective(GinkgoT(), CAP_SETUID)
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			runtime.LockOSThread() // throw away this thread when done.
+			discardThisTask()
+			_, _, e := unix.RawSyscall(unix.SYS_SETRESUID, 65534, 65534, 65534)
+			Expect(e).To(BeZero())
+
+			insp := Successful(InspectTask(1))
+			Expect(insp.Skipped).NotTo(ContainElement(HaveField("Aspect", InspectPidfd)))
+			Expect(insp.Capabilities).To(Equal(Successful(OfTask(1))))
+		}()
+		Eventually(done).Should(BeClosed())
+	})
+
+	It("fails for non-existing tasks", func() {
+		Expect(InspectTask(1 << 30)).Error().To(HaveOccurred())
+	})
+
+	It("degrades when /proc entries are inaccessible", func() {
+		capstest.RequireEffective(GinkgoT(), CAP_SYS_ADMIN)
+		useProc(GinkgoT().TempDir())
+		insp := Successful(InspectTask(os.Getpid()))
+		Expect(insp.Degraded()).To(BeTrue())
+		Expect(insp.Capabilities.Effective.Has(CAP_SYS_ADMIN)).To(BeTrue())
+		Expect(insp.State).To(BeNil())
+		Expect(insp.Executable).To(BeEmpty())
+		Expect(insp.Skipped).To(ContainElements(
+			HaveField("Aspect", InspectState),
+			HaveField("Aspect", InspectExecutable)))
+		Expect(insp.Err()).To(MatchError(os.ErrNotExist))
+		Expect(insp.Err()).To(MatchError(ContainSubstring("skipped inspecting state: ")))
+		for _, d := range insp.Skipped {
+			if d.Aspect != InspectPidfd {
+				Expect(d.Capability).To(Equal("CAP_SYS_PTRACE"))
+			}
+		}
+		findings := insp.Findings()
+		Expect(findings).To(HaveLen(len(insp.Skipped)))
+		Expect(findings.Severity()).To(Equal(SeverityWarning))
+		Expect(findings.Text()).To(ContainSubstring("hint: grant CAP_SYS_PTRACE"))
+	})
+
+})
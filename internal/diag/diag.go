@@ -0,0 +1,139 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+// Package diag implements the rate-limited diagnostics logging shared by the
+// caps package and its subpackages. Logging is silent unless a logger has been
+// set.
+package diag
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Logger receives diagnostic messages, together with optional context in form
+// of alternating keys and values.
+type Logger interface {
+	Log(msg string, keysAndValues ...interface{})
+}
+
+// Interval is the minimum time between logging the same message twice;
+// repetitions within this interval get suppressed and only counted.
+var Interval = time.Second
+
+// now returns the current time; tests might want to replace it.
+var now = time.Now
+
+// logger is the currently set logger, if any.
+var logger atomic.Pointer[loggerBox]
+
+// loggerBox wraps a Logger, as atomic pointers need a concrete type.
+type loggerBox struct{ Logger }
+
+// limiter tracks when each distinct message has been logged last and how many
+// repetitions have been suppressed since then.
+var limiter = struct {
+	sync.Mutex
+	seen map[string]*occurrence
+}{seen: map[string]*occurrence{}}
+
+type occurrence struct {
+	last       time.Time
+	suppressed int
+}
+
+// Set sets the logger to use for diagnostics; nil silences diagnostics. Set
+// also resets the rate limiting.
+func Set(l Logger) {
+	limiter.Lock()
+	limiter.seen = map[string]*occurrence{}
+	limiter.Unlock()
+	if l == nil {
+		logger.Store(nil)
+		return
+	}
+	logger.Store(&loggerBox{l})
+}
+
+// Enabled returns true if a logger has been set.
+func Enabled() bool {
+	return logger.Load() != nil
+}
+
+// Log logs the specified message with its context, unless the same message has
+// already been logged within the rate limiting interval. The number of
+// suppressed repetitions is added to the context of the next logged occurrence
+// as "suppressed".
+func Log(msg string, keysAndValues ...interface{}) {
+	box := logger.Load()
+	if box == nil {
+		return
+	}
+	limiter.Lock()
+	t := now()
+	occ, ok := limiter.seen[msg]
+	if ok && t.Sub(occ.last) < Interval {
+		occ.suppressed++
+		limiter.Unlock()
+		return
+	}
+	if !ok {
+		occ = &occurrence{}
+		limiter.seen[msg] = occ
+	}
+	suppressed := occ.suppressed
+	occ.last = t
+	occ.suppressed = 0
+	limiter.Unlock()
+	if suppressed > 0 {
+		keysAndValues = append(keysAndValues[:len(keysAndValues):len(keysAndValues)],
+			"suppressed", suppressed)
+	}
+	box.Log(msg, keysAndValues...)
+}
+
+// Printfer is implemented by Printf-style loggers, such as [log.Logger].
+type Printfer interface {
+	Printf(format string, v ...interface{})
+}
+
+// PrintfLogger adapts a Printf-style logger, rendering the context as
+// "key=value" pairs following the message.
+type PrintfLogger struct {
+	Printfer Printfer
+}
+
+// Log logs the message with its context using the Printf-style logger.
+func (l PrintfLogger) Log(msg string, keysAndValues ...interface{}) {
+	l.Printfer.Printf("%s", Format(msg, keysAndValues...))
+}
+
+// Format renders a message with its context as "msg key=value key=value". A
+// trailing key without a value gets rendered with an empty value.
+func Format(msg string, keysAndValues ...interface{}) string {
+	var b strings.Builder
+	b.WriteString(msg)
+	for idx := 0; idx < len(keysAndValues); idx += 2 {
+		fmt.Fprintf(&b, " %v=", keysAndValues[idx])
+		if idx+1 < len(keysAndValues) {
+			fmt.Fprintf(&b, "%v", keysAndValues[idx+1])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,85 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"syscall"
+	"text/template"
+
+	"golang.org/x/sys/unix"
+)
+
+// Name of the Go source file to generate.
+const errnosGoFile = "errnos.go"
+
+// Highest error number to check for a name; Linux error numbers are
+// restricted to below 4096 (MAX_ERRNO).
+const maxErrno = 4095
+
+var errnosTemplate = template.Must(template.New("").Parse(`// Code generated by go generate. DO NOT EDIT.
+//
+//go:generate go run ../internal/generrno
+
+//go:build linux
+
+package errno
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// errnos lists all error numbers known to golang.org/x/sys/unix.
+var errnos = [...]syscall.Errno{
+	{{ range . -}}
+		unix.{{ . }},
+	{{ end }}
+}
+`))
+
+func main() {
+	names := []string{}
+	seen := map[string]bool{}
+	for e := syscall.Errno(1); e <= maxErrno; e++ {
+		name := unix.ErrnoName(e)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	var source bytes.Buffer
+	if err := errnosTemplate.Execute(&source, names); err != nil {
+		fmt.Printf("cannot generate %s, reason: %s\n", errnosGoFile, err)
+		os.Exit(1)
+	}
+	formatted, err := format.Source(source.Bytes())
+	if err != nil {
+		fmt.Printf("cannot format %s, reason: %s\n", errnosGoFile, err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(errnosGoFile, formatted, 0664); err != nil {
+		fmt.Printf("cannot write %s, reason: %s\n", errnosGoFile, err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s generated with %d error numbers\n", errnosGoFile, len(names))
+}
@@ -62,6 +62,10 @@ var removeIndentation = regexp.MustCompile(`(?m)^ +`)
 // Grabs the definition for the versioned capability user data array sizes.
 var capabilityUserDataLen = regexp.MustCompile(`(?m)^#define _(LINUX_CAPABILITY_U32S_\d+)\s+(\d+)$`)
 
+// Grabs the definition for the capability user data versions, optionally
+// followed by a comment, such as "deprecated - use v3".
+var capabilityVersion = regexp.MustCompile(`(?m)^#define _(LINUX_CAPABILITY_VERSION_\d+)\s+(0x[0-9a-fA-F]+)(?:\s+/\*.*\*/)?$`)
+
 var capabilitiesTemplate = template.Must(template.New("").Parse(`// Code generated by go generate. DO NOT EDIT.
 // Generated from libcap {{ .SemVer }}
 // {{ .URL }}
@@ -91,6 +95,12 @@ var CapabilityNameByNumber = map[int]string{
 	{{ end }}
 }
 
+const (
+	{{ range .Versions -}}
+		{{ .Name }} = {{ .Version }}
+	{{ end }}
+)
+
 const (
 	{{ range .Sizes -}}
 		{{ .Name }} = {{ .Size }}
@@ -133,14 +143,14 @@ func getCaps(contents string) []capability {
 }
 
 // capusersize describes the versioned sizes as used in the capabilities
-// user-space API getcap(2) and setcap(2).
+// user-space API capget(2) and capset(2).
 type capusersize struct {
 	Name string // versioned symbol name
 	Size int    // number of uint32 elements
 }
 
 // getSizes scans the given contents for definition of the data sizes for
-// different versions of the user-facing getcap/setcap API.
+// different versions of the user-facing capget/capset API.
 func getSizes(contents string) []capusersize {
 	allmatches := capabilityUserDataLen.FindAllStringSubmatch(contents, -1)
 	sizes := []capusersize{}
@@ -159,18 +169,42 @@ func getSizes(contents string) []capusersize {
 	return sizes
 }
 
+// capuserversion describes a version of the capabilities user-space API
+// capget(2) and capset(2).
+type capuserversion struct {
+	Name    string // versioned symbol name
+	Version string // version value in hexadecimal notation
+}
+
+// getVersions scans the given contents for definitions of the versions of the
+// user-facing capget/capset API.
+func getVersions(contents string) []capuserversion {
+	allmatches := capabilityVersion.FindAllStringSubmatch(contents, -1)
+	versions := []capuserversion{}
+	for _, match := range allmatches {
+		versions = append(versions, capuserversion{
+			Name:    match[1],
+			Version: match[2],
+		})
+	}
+	slices.SortFunc(versions, func(a, b capuserversion) int { return strings.Compare(a.Name, b.Name) })
+	return versions
+}
+
 // generates the source code for cap/capabilities.go
-func generateCapsSource(semver string, remoteURL string, caps []capability, sizes []capusersize) []byte {
+func generateCapsSource(semver string, remoteURL string, caps []capability, versions []capuserversion, sizes []capusersize) []byte {
 	var source bytes.Buffer
 	if err := capabilitiesTemplate.Execute(&source, struct {
 		SemVer       string
 		URL          string
 		Capabilities []capability
+		Versions     []capuserversion
 		Sizes        []capusersize
 	}{
 		SemVer:       semver,
 		URL:          remoteURL,
 		Capabilities: caps,
+		Versions:     versions,
 		Sizes:        sizes,
 	}); err != nil {
 		fmt.Printf("cannot generate source code, reason: %s\n", err)
@@ -254,8 +288,9 @@ func main() {
 	}
 
 	caps := getCaps(string(capsDefinitionsCSource))
+	versions := getVersions(string(capsDefinitionsCSource))
 	sizes := getSizes(string(capsDefinitionsCSource))
-	source := generateCapsSource(latestSemVer, libcapGitURL, caps, sizes)
+	source := generateCapsSource(latestSemVer, libcapGitURL, caps, versions, sizes)
 	err = os.WriteFile(capabilitiesGoFile, source, 0664)
 	if err != nil {
 		fmt.Printf("cannot write %s, reason: %s\n", capabilitiesGoFile, err)
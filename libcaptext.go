@@ -0,0 +1,387 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TaskCapabilitiesFromText parses the textual representation of task
+// capabilities as used by libcap's [cap_from_text(3)] and [cap_to_text(3)],
+// such as "=ep cap_sys_resource-ep" or "cap_chown,cap_net_raw+ep". Besides
+// capability names and numbers, the names of registered bundles (see
+// [RegisterBundle]) can be used. If the textual representation is invalid, an
+// error is returned instead, together with zero task capabilities.
+//
+// [cap_from_text(3)]: https://man7.org/linux/man-pages/man3/cap_from_text.3.html
+// [cap_to_text(3)]: https://man7.org/linux/man-pages/man3/cap_to_text.3.html
+func TaskCapabilitiesFromText(text string) (TaskCapabilities, error) {
+	taskcaps := TaskCapabilities{
+		Effective:   NewCapabilitiesSet(),
+		Permitted:   NewCapabilitiesSet(),
+		Inheritable: NewCapabilitiesSet(),
+	}
+	for _, clause := range strings.Fields(text) {
+		opidx := strings.IndexAny(clause, "=+-")
+		if opidx < 0 {
+			return TaskCapabilities{}, fmt.Errorf("missing operator in clause %q", clause)
+		}
+		var capset CapabilitiesSet
+		if names := clause[:opidx]; names == "" || strings.EqualFold(names, "all") {
+			if names == "" && clause[0] != '=' {
+				return TaskCapabilities{}, fmt.Errorf("missing capability names in clause %q", clause)
+			}
+			capset = AllCapabilities()
+		} else {
+			capset = NewCapabilitiesSet()
+			for _, name := range strings.Split(names, ",") {
+				caps, err := namedCapabilities(name, libcapCapabilityByName)
+				if err != nil {
+					return TaskCapabilities{}, err
+				}
+				capset.addSet(caps)
+			}
+		}
+		for actions := clause[opidx:]; actions != ""; {
+			op := actions[0]
+			flagsEnd := strings.IndexAny(actions[1:], "=+-") + 1
+			if flagsEnd == 0 {
+				flagsEnd = len(actions)
+			}
+			flags := actions[1:flagsEnd]
+			actions = actions[flagsEnd:]
+			if op != '=' && flags == "" {
+				return TaskCapabilities{}, fmt.Errorf("missing flags in clause %q", clause)
+			}
+			if op == '=' {
+				taskcaps.Effective.dropSet(capset)
+				taskcaps.Permitted.dropSet(capset)
+				taskcaps.Inheritable.dropSet(capset)
+			}
+			for _, flag := range flags {
+				var target *CapabilitiesSet
+				switch flag {
+				case 'e':
+					target = &taskcaps.Effective
+				case 'p':
+					target = &taskcaps.Permitted
+				case 'i':
+					target = &taskcaps.Inheritable
+				default:
+					return TaskCapabilities{}, fmt.Errorf("invalid flag %q in clause %q", flag, clause)
+				}
+				if op == '-' {
+					target.dropSet(capset)
+				} else {
+					target.addSet(capset)
+				}
+			}
+		}
+	}
+	return taskcaps, nil
+}
+
+// maxLibcapCapability is the highest capability number libcap accepts in its
+// textual notation, matching its 64 bit wide capability sets.
+const maxLibcapCapability = 63
+
+// libcapCapabilityByName returns the number of the capability with the
+// specified name in libcap's textual notation, that is, either a capability
+// name, such as "cap_chown", or a capability number.
+func libcapCapabilityByName(name string) (int, error) {
+	if capno, err := strconv.Atoi(name); err == nil {
+		if capno < 0 || capno > maxLibcapCapability {
+			return 0, fmt.Errorf("invalid capability number %d", capno)
+		}
+		return capno, nil
+	}
+	return CapabilityByName(name)
+}
+
+// ParseGetpcaps parses the output of libcap's getpcaps tool for one or more
+// processes, returning the task capabilities indexed by PID. ParseGetpcaps
+// understands both the current output format “PID: TEXT” as well as the
+// legacy format “Capabilities for `PID': TEXT”.
+func ParseGetpcaps(output string) (map[int]TaskCapabilities, error) {
+	result := map[int]TaskCapabilities{}
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		sep := strings.Index(line, ":")
+		if sep < 0 {
+			return nil, fmt.Errorf("invalid getpcaps line %q", line)
+		}
+		pidtext := line[:sep]
+		if strings.HasPrefix(pidtext, "Capabilities for `") && strings.HasSuffix(pidtext, "'") {
+			pidtext = pidtext[len("Capabilities for `") : len(pidtext)-1]
+		}
+		pid, err := strconv.Atoi(pidtext)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PID in getpcaps line %q", line)
+		}
+		taskcaps, err := TaskCapabilitiesFromText(line[sep+1:])
+		if err != nil {
+			return nil, err
+		}
+		result[pid] = taskcaps
+	}
+	return result, nil
+}
+
+// CapshPrint represents the information shown by libcap's “capsh --print”.
+type CapshPrint struct {
+	Current    TaskCapabilities // "Current:" task capabilities
+	Bounding   CapabilitiesSet  // "Bounding set"
+	Ambient    CapabilitiesSet  // "Ambient set"
+	IAB        string           // unparsed "Current IAB:" tuple
+	Securebits uint             // "Securebits:" value
+	NoNewPrivs bool             // no-new-privs flag shown with the securebits
+	UID        int              // real user ID
+	EUID       int              // effective user ID
+	GID        int              // real group ID
+	Groups     []int            // supplementary group IDs
+}
+
+// ParseCapshPrint parses the output of “capsh --print”. Lines not carrying
+// capabilities-related information, such as the “Guessed mode” and the
+// individual securebits explanations, are ignored.
+func ParseCapshPrint(output string) (CapshPrint, error) {
+	var info CapshPrint
+	var err error
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "Current IAB:"):
+			info.IAB = strings.TrimSpace(line[len("Current IAB:"):])
+		case strings.HasPrefix(line, "Current:"):
+			info.Current, err = TaskCapabilitiesFromText(line[len("Current:"):])
+		case strings.HasPrefix(line, "Bounding set ="):
+			info.Bounding, err = capshCapabilitiesList(line[len("Bounding set ="):])
+		case strings.HasPrefix(line, "Ambient set ="):
+			info.Ambient, err = capshCapabilitiesList(line[len("Ambient set ="):])
+		case strings.HasPrefix(line, "Securebits:"):
+			// "Securebits: 00/0x0/1'b0 (no-new-privs=0)", where the value is
+			// given in octal, hex, and binary.
+			fields := strings.Fields(line[len("Securebits:"):])
+			if len(fields) == 0 {
+				return CapshPrint{}, fmt.Errorf("invalid securebits line %q", line)
+			}
+			values := strings.Split(fields[0], "/")
+			if len(values) < 2 || !strings.HasPrefix(values[1], "0x") {
+				return CapshPrint{}, fmt.Errorf("invalid securebits line %q", line)
+			}
+			var bits uint64
+			bits, err = strconv.ParseUint(values[1][2:], 16, 32)
+			info.Securebits = uint(bits)
+			info.NoNewPrivs = strings.Contains(line, "(no-new-privs=1)")
+		case strings.HasPrefix(line, "uid="):
+			// "uid=0(root) euid=0(root)"
+			for _, field := range strings.Fields(line) {
+				if strings.HasPrefix(field, "uid=") {
+					info.UID, err = capshID(field[len("uid="):])
+				} else if strings.HasPrefix(field, "euid=") {
+					info.EUID, err = capshID(field[len("euid="):])
+				}
+				if err != nil {
+					break
+				}
+			}
+		case strings.HasPrefix(line, "gid="):
+			info.GID, err = capshID(line[len("gid="):])
+		case strings.HasPrefix(line, "groups="):
+			info.Groups = []int{}
+			for _, group := range strings.Split(line[len("groups="):], ",") {
+				if group == "" {
+					continue
+				}
+				var gid int
+				if gid, err = capshID(group); err != nil {
+					break
+				}
+				info.Groups = append(info.Groups, gid)
+			}
+		}
+		if err != nil {
+			return CapshPrint{}, err
+		}
+	}
+	return info, nil
+}
+
+// capshCapabilitiesList parses a comma-separated list of capability names in
+// libcap notation.
+func capshCapabilitiesList(list string) (CapabilitiesSet, error) {
+	capset := NewCapabilitiesSet()
+	for _, name := range strings.Split(strings.TrimSpace(list), ",") {
+		if name == "" {
+			continue
+		}
+		capno, err := libcapCapabilityByName(name)
+		if err != nil {
+			return nil, err
+		}
+		capset.Add(capno)
+	}
+	return capset, nil
+}
+
+// capshID parses a user or group ID in the form of "ID(name)".
+func capshID(s string) (int, error) {
+	if idx := strings.IndexByte(s, '('); idx >= 0 {
+		s = s[:idx]
+	}
+	return strconv.Atoi(s)
+}
+
+// libcap's flag bits for the individual capabilities sets.
+const (
+	libcapEff = 1 << iota
+	libcapPer
+	libcapInh
+)
+
+// libcapMaxBits is the maximum number of capabilities supported by libcap's
+// textual representation.
+const libcapMaxBits = 64
+
+// Text returns the textual representation of the task capabilities as
+// rendered by libcap's [cap_to_text(3)], such as "=ep cap_sys_resource-ep".
+// The representation is character-exact, as it uses the same algorithm as
+// libcap: the most common combination of flags becomes the prevailing state
+// that the other capabilities are then expressed relative to, where
+// capabilities not supported by the kernel we're running on are only listed
+// in the form of their numbers, if set at all.
+//
+// [cap_to_text(3)]: https://man7.org/linux/man-pages/man3/cap_to_text.3.html
+func (t TaskCapabilities) Text() string {
+	stateflags := func(capno int) int {
+		flags := 0
+		if t.Effective.Has(capno) {
+			flags |= libcapEff
+		}
+		if t.Inheritable.Has(capno) {
+			flags |= libcapInh
+		}
+		if t.Permitted.Has(capno) {
+			flags |= libcapPer
+		}
+		return flags
+	}
+	flagstext := func(flags int) string {
+		s := ""
+		if flags&libcapEff != 0 {
+			s += "e"
+		}
+		if flags&libcapInh != 0 {
+			s += "i"
+		}
+		if flags&libcapPer != 0 {
+			s += "p"
+		}
+		return s
+	}
+
+	// The prevailing state is the most popular combination of flags among the
+	// capabilities supported by the kernel, preferring "lower" combinations
+	// in case of a tie.
+	maxbits := LastCapability() + 1
+	var histo [8]int
+	for capno := 0; capno < maxbits; capno++ {
+		histo[stateflags(capno)]++
+	}
+	m := 7
+	for t := 6; t >= 0; t-- {
+		if histo[t] >= histo[m] {
+			m = t
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("=" + flagstext(m))
+	for t := 7; t >= 0; t-- {
+		if t == m || histo[t] == 0 {
+			continue
+		}
+		names := []string{}
+		for capno := 0; capno < maxbits; capno++ {
+			if stateflags(capno) == t {
+				names = append(names, libcapCapabilityName(capno))
+			}
+		}
+		clause := strings.Join(names, ",")
+		if raise := t &^ m; raise != 0 {
+			if b.Len() == 1 {
+				// Special case of "= foo+eip" as "foo=eip", which is
+				// equivalent, but shorter.
+				b.Reset()
+				clause += "=" + flagstext(raise)
+			} else {
+				clause += "+" + flagstext(raise)
+			}
+		}
+		if lower := m &^ t; lower != 0 {
+			clause += "-" + flagstext(lower)
+		}
+		if b.Len() != 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(clause)
+	}
+
+	// Capabilities unsupported by the kernel are listed by number only when
+	// set, and always relative to the prevailing state being empty.
+	histo = [8]int{}
+	for capno := maxbits; capno < libcapMaxBits; capno++ {
+		histo[stateflags(capno)]++
+	}
+	for t := 7; t >= 1; t-- {
+		if histo[t] == 0 {
+			continue
+		}
+		numbers := []string{}
+		for capno := maxbits; capno < libcapMaxBits; capno++ {
+			if stateflags(capno) == t {
+				numbers = append(numbers, strconv.Itoa(capno))
+			}
+		}
+		b.WriteString(" " + strings.Join(numbers, ",") + "+" + flagstext(t))
+	}
+	return b.String()
+}
+
+// Getpcaps returns the task capabilities of the process with the specified
+// PID in the same format as libcap's getpcaps tool, such as "42: =ep\n".
+func (t TaskCapabilities) Getpcaps(pid int) string {
+	return strconv.Itoa(pid) + ": " + t.Text() + "\n"
+}
+
+// libcapCapabilityName returns the name of the capability with the specified
+// number in libcap's notation, that is, in lower case, or just the number in
+// case of capabilities unknown to this package.
+func libcapCapabilityName(capno int) string {
+	if name, ok := CapabilityNameByNumber[capno]; ok {
+		return strings.ToLower(name)
+	}
+	return strconv.Itoa(capno)
+}
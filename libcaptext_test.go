@@ -0,0 +1,184 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+const capshPrintOutput = `Current: =ep cap_sys_resource-ep
+Bounding set =cap_chown,cap_dac_override,cap_net_raw,cap_sys_admin,40
+Ambient set =
+Current IAB: !cap_sys_resource
+Securebits: 024/0x14/5'b10100 (no-new-privs=1)
+ secure-noroot: no (unlocked)
+ secure-no-suid-fixup: yes (unlocked)
+ secure-keep-caps: no (unlocked)
+ secure-no-ambient-raise: no (unlocked)
+uid=1000(alice) euid=0(root)
+gid=1000(alice)
+groups=4(adm),27(sudo)
+Guessed mode: HYBRID (4)
+`
+
+var _ = Describe("libcap textual representations", func() {
+
+	DescribeTable("parses capabilities text",
+		func(text string, eff, prm, inh []string) {
+			taskcaps := Successful(TaskCapabilitiesFromText(text))
+			Expect(taskcaps.Effective.Names()).To(ConsistOf(eff))
+			Expect(taskcaps.Permitted.Names()).To(ConsistOf(prm))
+			Expect(taskcaps.Inheritable.Names()).To(ConsistOf(inh))
+		},
+		Entry(nil, "", []string{}, []string{}, []string{}),
+		Entry(nil, "=", []string{}, []string{}, []string{}),
+		Entry(nil, "cap_chown,cap_net_raw+ep", []string{"CAP_CHOWN", "CAP_NET_RAW"},
+			[]string{"CAP_CHOWN", "CAP_NET_RAW"}, []string{}),
+		Entry(nil, "cap_chown=ep cap_chown-e cap_sys_admin+i", []string{},
+			[]string{"CAP_CHOWN"}, []string{"CAP_SYS_ADMIN"}),
+		Entry(nil, "cap_kill=ep-p+i", []string{"CAP_KILL"}, []string{}, []string{"CAP_KILL"}),
+		Entry(nil, "CAP_KILL,5,6=i", []string{}, []string{}, []string{"CAP_KILL", "CAP_SETGID"}),
+	)
+
+	It("parses all capabilities", func() {
+		taskcaps := Successful(TaskCapabilitiesFromText("=ep cap_sys_resource-ep all+i"))
+		all := AllCapabilities()
+		Expect(taskcaps.Inheritable).To(Equal(all))
+		all.Drop(CAP_SYS_RESOURCE)
+		Expect(taskcaps.Effective).To(Equal(all))
+		Expect(taskcaps.Permitted).To(Equal(all))
+	})
+
+	DescribeTable("rejects invalid capabilities text",
+		func(text string) {
+			Expect(TaskCapabilitiesFromText(text)).Error().To(HaveOccurred())
+		},
+		Entry(nil, "cap_chown"),
+		Entry(nil, "+ep"),
+		Entry(nil, "cap_chown+"),
+		Entry(nil, "cap_chown+x"),
+		Entry(nil, "cap_foobar=ep"),
+		Entry(nil, "64=ep"),
+	)
+
+	It("parses getpcaps output", func() {
+		pcaps := Successful(ParseGetpcaps("1: =ep\n\n42: cap_chown+i\nCapabilities for `666': = cap_kill+ep\n"))
+		Expect(pcaps).To(HaveLen(3))
+		Expect(pcaps[1].Effective).To(Equal(AllCapabilities()))
+		Expect(pcaps[42].Inheritable.Names()).To(ConsistOf("CAP_CHOWN"))
+		Expect(pcaps[666].Permitted.Names()).To(ConsistOf("CAP_KILL"))
+
+		Expect(ParseGetpcaps("1 =ep")).Error().To(HaveOccurred())
+		Expect(ParseGetpcaps("foo: =ep")).Error().To(HaveOccurred())
+		Expect(ParseGetpcaps("1: cap_foo=ep")).Error().To(HaveOccurred())
+	})
+
+	It("parses capsh --print output", func() {
+		info := Successful(ParseCapshPrint(capshPrintOutput))
+		Expect(info.Current.Effective.Has(CAP_SYS_RESOURCE)).To(BeFalse())
+		Expect(info.Current.Effective.Has(CAP_SYS_ADMIN)).To(BeTrue())
+		Expect(info.Bounding.Names()).To(ConsistOf(
+			"CAP_CHOWN", "CAP_DAC_OVERRIDE", "CAP_NET_RAW", "CAP_SYS_ADMIN", "CAP_CHECKPOINT_RESTORE"))
+		Expect(info.Ambient).To(BeEmpty())
+		Expect(info.IAB).To(Equal("!cap_sys_resource"))
+		Expect(info.Securebits).To(Equal(uint(0x14)))
+		Expect(info.NoNewPrivs).To(BeTrue())
+		Expect(info.UID).To(Equal(1000))
+		Expect(info.EUID).To(Equal(0))
+		Expect(info.GID).To(Equal(1000))
+		Expect(info.Groups).To(Equal([]int{4, 27}))
+	})
+
+	DescribeTable("rejects invalid capsh --print output",
+		func(output string) {
+			Expect(ParseCapshPrint(output)).Error().To(HaveOccurred())
+		},
+		Entry(nil, "Current: cap_foo=ep"),
+		Entry(nil, "Bounding set =cap_foo"),
+		Entry(nil, "Securebits:"),
+		Entry(nil, "Securebits: 00"),
+		Entry(nil, "Securebits: 00/0xzz/1'b0"),
+		Entry(nil, "uid=foo(root) euid=0(root)"),
+		Entry(nil, "gid=foo"),
+		Entry(nil, "groups=0(root),foo"),
+	)
+
+	DescribeTable("renders capabilities text",
+		func(text string) {
+			Expect(Successful(TaskCapabilitiesFromText(text)).Text()).To(Equal(text))
+		},
+		Entry(nil, "="),
+		Entry(nil, "=ep"),
+		Entry(nil, "=ep cap_sys_resource-ep"),
+		Entry(nil, "cap_chown,cap_kill=eip cap_net_raw+p"),
+		Entry(nil, "=p cap_setuid+ei cap_audit_control+i cap_audit_read+i-p cap_kill,cap_lease+e cap_setgid,cap_sys_module-p"),
+		Entry(nil, "=eip cap_syslog-e cap_perfmon-ep cap_sys_admin,cap_sys_boot-i cap_setuid-ei"),
+	)
+
+	It("renders unsupported capabilities by number", func() {
+		taskcaps := TaskCapabilities{Permitted: NewCapabilitiesSet()}
+		taskcaps.Permitted.Add(CAP_CHOWN, 62, 63)
+		Expect(taskcaps.Text()).To(Equal("cap_chown=p 62,63+p"))
+	})
+
+	It("renders getpcaps output", func() {
+		taskcaps := Successful(TaskCapabilitiesFromText("=ep cap_sys_resource-ep"))
+		Expect(taskcaps.Getpcaps(42)).To(Equal("42: =ep cap_sys_resource-ep\n"))
+	})
+
+	When("libcap tools are available", func() {
+
+		It("renders getpcaps output identically", func() {
+			getpcaps, err := exec.LookPath("getpcaps")
+			if err != nil {
+				Skip("getpcaps not available")
+			}
+			pid := os.Getpid()
+			out := Successful(exec.Command(getpcaps, strconv.Itoa(pid)).Output())
+			Expect(Successful(OfTask(pid)).Getpcaps(pid)).To(Equal(string(out)))
+		})
+
+		It("parses getpcaps of ourselves", func() {
+			getpcaps, err := exec.LookPath("getpcaps")
+			if err != nil {
+				Skip("getpcaps not available")
+			}
+			pid := os.Getpid()
+			out := Successful(exec.Command(getpcaps, strconv.Itoa(pid)).Output())
+			pcaps := Successful(ParseGetpcaps(string(out)))
+			taskcaps := Successful(OfTask(pid))
+			Expect(pcaps[pid].Effective.normalized()).To(Equal(taskcaps.Effective.normalized()))
+			Expect(pcaps[pid].Permitted.normalized()).To(Equal(taskcaps.Permitted.normalized()))
+			Expect(pcaps[pid].Inheritable.normalized()).To(Equal(taskcaps.Inheritable.normalized()))
+		})
+
+		It("parses capsh --print", func() {
+			capsh, err := exec.LookPath("capsh")
+			if err != nil {
+				Skip("capsh not available")
+			}
+			info := Successful(ParseCapshPrint(string(Successful(exec.Command(capsh, "--print").Output()))))
+			Expect(info.UID).To(Equal(os.Getuid()))
+		})
+
+	})
+
+})
@@ -0,0 +1,45 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import "github.com/thediveo/caps/internal/diag"
+
+// Logger receives diagnostic messages from the long-running parts of this
+// package and its subpackages, such as skipped tasks when querying
+// capabilities through a [Cache], or failing to raise capabilities for HTTP
+// handlers. The context of a message is passed as alternating keys and values,
+// similar to slog and logr.
+type Logger = diag.Logger
+
+// SetLogger sets the logger for diagnostic messages; nil silences diagnostics,
+// which is also the default.
+//
+// Diagnostics are rate-limited: the same message is logged at most once per
+// second, and the number of repetitions suppressed in the meantime is then
+// passed as "suppressed" in the context of the next logged occurrence.
+func SetLogger(logger Logger) {
+	diag.Set(logger)
+}
+
+// PrintfLogger returns a Logger adapting a Printf-style logger, such as
+// [log.Logger]. The context of a message is rendered as "key=value" pairs
+// following the message.
+func PrintfLogger(logger interface {
+	Printf(format string, v ...interface{})
+}) Logger {
+	return diag.PrintfLogger{Printfer: logger}
+}
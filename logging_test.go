@@ -0,0 +1,87 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"bytes"
+	"log"
+	"time"
+
+	"github.com/thediveo/caps/internal/diag"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type logEntry struct {
+	msg string
+	kv  []interface{}
+}
+
+type recordingLogger struct{ entries []logEntry }
+
+func (l *recordingLogger) Log(msg string, keysAndValues ...interface{}) {
+	l.entries = append(l.entries, logEntry{msg: msg, kv: keysAndValues})
+}
+
+var _ = Describe("diagnostics logging", func() {
+
+	var rec *recordingLogger
+
+	BeforeEach(func() {
+		rec = &recordingLogger{}
+		SetLogger(rec)
+		DeferCleanup(func() { SetLogger(nil) })
+	})
+
+	It("is silent by default", func() {
+		SetLogger(nil)
+		diag.Log("foo")
+		Expect(rec.entries).To(BeEmpty())
+	})
+
+	It("rate-limits repeated messages", func() {
+		defer func(old time.Duration) { diag.Interval = old }(diag.Interval)
+		diag.Interval = time.Hour
+		diag.Log("foo", "tid", 42)
+		diag.Log("foo", "tid", 43)
+		diag.Log("bar")
+		diag.Log("foo", "tid", 44)
+		Expect(rec.entries).To(ConsistOf(
+			logEntry{msg: "foo", kv: []interface{}{"tid", 42}},
+			logEntry{msg: "bar", kv: nil},
+		))
+
+		diag.Interval = 0
+		diag.Log("foo", "tid", 45)
+		Expect(rec.entries).To(HaveLen(3))
+		Expect(rec.entries[2]).To(Equal(
+			logEntry{msg: "foo", kv: []interface{}{"tid", 45, "suppressed", 2}}))
+	})
+
+	It("adapts Printf-style loggers", func() {
+		var buff bytes.Buffer
+		SetLogger(PrintfLogger(log.New(&buff, "", 0)))
+		diag.Log("foo", "tid", 42, "dangling")
+		Expect(buff.String()).To(Equal("foo tid=42 dangling=\n"))
+	})
+
+	It("logs skipped tasks when querying via the cache", func() {
+		Expect(NewCache(0).OfTask(1 << 30)).Error().To(HaveOccurred())
+		Expect(rec.entries).To(HaveLen(1))
+		Expect(rec.entries[0].msg).To(Equal("skipping task without start time"))
+	})
+
+})
@@ -0,0 +1,91 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/thediveo/caps/internal/diag"
+	"golang.org/x/sys/unix"
+)
+
+// ErrCapabilitiesLeaked is the panic value (wrapped) of [MustLowered] when a
+// privileged section leaves capabilities raised and
+// [Config.PanicOnCapabilityLeak] is set.
+var ErrCapabilitiesLeaked = errors.New("privileged section leaked raised effective capabilities")
+
+// MustLowered runs fn on the calling Go routine, locked to its current OS-level
+// thread, and afterwards ensures that fn didn't leave any capabilities raised
+// in the effective set of the thread, even when fn panics or calls
+// [runtime.Goexit]. Leaked capabilities get lowered again to the effective
+// capabilities before fn ran and are logged (see [SetLogger]); if
+// [Config.PanicOnCapabilityLeak] is set, MustLowered additionally panics with
+// an error wrapping [ErrCapabilitiesLeaked].
+//
+// If the capabilities cannot be lowered or checked, MustLowered keeps the
+// calling Go routine locked to its thread, so that the thread gets thrown away
+// when the Go routine finishes instead of returning to general scheduling with
+// raised capabilities.
+func MustLowered(fn func()) {
+	runtime.LockOSThread()
+	before, err := OfThisTask()
+	if err != nil {
+		// Leave the thread locked, as we cannot check it afterwards.
+		diag.Log("cannot check capabilities before privileged section", "error", err)
+		fn()
+		return
+	}
+	defer func() {
+		leaked, err := lower(before.Effective)
+		if err != nil {
+			diag.Log("cannot lower capabilities after privileged section",
+				"tid", unix.Gettid(), "error", err)
+			return
+		}
+		runtime.UnlockOSThread()
+		if len(leaked) == 0 {
+			return
+		}
+		diag.Log("lowered leaked capabilities after privileged section",
+			"tid", unix.Gettid(), "capabilities", strings.Join(leaked, ","))
+		if config.Load().PanicOnCapabilityLeak {
+			panic(fmt.Errorf("%w: %s", ErrCapabilitiesLeaked, strings.Join(leaked, ", ")))
+		}
+	}()
+	fn()
+}
+
+// lower drops those effective capabilities of the calling task that are not in
+// the specified effective capabilities, returning the names of the dropped
+// capabilities.
+func lower(effective CapabilitiesSet) ([]string, error) {
+	current, err := OfThisTask()
+	if err != nil {
+		return nil, err
+	}
+	raised := current.Effective.Clone()
+	raised.dropSet(effective)
+	leaked := raised.Names()
+	if len(leaked) == 0 {
+		return nil, nil
+	}
+	current.Effective.dropSet(raised)
+	return leaked, SetForThisTask(current)
+}
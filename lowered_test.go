@@ -0,0 +1,105 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/thediveo/caps/capstest"
+	"github.com/thediveo/caps/internal/diag"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("lowering capabilities after privileged sections", func() {
+
+	var rec *recordingLogger
+
+	BeforeEach(func() {
+		capstest.RequirePermitted(GinkgoT(), CAP_NET_RAW, CAP_CHOWN)
+		cfg := CurrentConfig()
+		DeferCleanup(func() { Configure(cfg) })
+		rec = &recordingLogger{}
+		SetLogger(rec)
+		DeferCleanup(func() { SetLogger(nil) })
+		DeferCleanup(func(old time.Duration) { diag.Interval = old }, diag.Interval)
+		diag.Interval = 0
+	})
+
+	// onThrowAwayThread runs fn on a Go routine locked to a thread that gets
+	// thrown away afterwards, with only CAP_CHOWN effective.
+	onThrowAwayThread := func(fn func()) {
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			runtime.LockOSThread()
+			Expect(SetEffectiveCaps(CAP_CHOWN)).Error().NotTo(HaveOccurred())
+			fn()
+		}()
+		<-done
+	}
+
+	It("leaves properly lowered sections alone", func() {
+		onThrowAwayThread(func() {
+			MustLowered(func() {
+				before := Successful(AddEffectiveCaps(CAP_NET_RAW))
+				Expect(SetForThisTask(before)).To(Succeed())
+			})
+			Expect(effectiveNames()).To(ConsistOf("CAP_CHOWN"))
+		})
+		Expect(rec.entries).To(BeEmpty())
+	})
+
+	It("lowers and logs leaked capabilities", func() {
+		onThrowAwayThread(func() {
+			MustLowered(func() {
+				Expect(SetEffectiveCaps(CAP_NET_RAW)).Error().NotTo(HaveOccurred())
+			})
+			Expect(effectiveNames()).To(BeEmpty())
+		})
+		Expect(rec.entries).To(HaveLen(1))
+		Expect(rec.entries[0].msg).To(Equal("lowered leaked capabilities after privileged section"))
+		Expect(rec.entries[0].kv).To(ContainElements("capabilities", "CAP_NET_RAW"))
+	})
+
+	It("lowers leaked capabilities when panicking", func() {
+		onThrowAwayThread(func() {
+			Expect(func() {
+				MustLowered(func() {
+					Expect(AddEffectiveCaps(CAP_NET_RAW)).Error().NotTo(HaveOccurred())
+					panic("D'OH!")
+				})
+			}).To(PanicWith("D'OH!"))
+			Expect(effectiveNames()).To(ConsistOf("CAP_CHOWN"))
+		})
+	})
+
+	It("panics on leaked capabilities when configured", func() {
+		Configure(Config{PanicOnCapabilityLeak: true})
+		onThrowAwayThread(func() {
+			Expect(func() {
+				MustLowered(func() {
+					Expect(AddEffectiveCaps(CAP_NET_RAW)).Error().NotTo(HaveOccurred())
+				})
+			}).To(PanicWith(MatchError(ErrCapabilitiesLeaked)))
+			Expect(effectiveNames()).To(ConsistOf("CAP_CHOWN"))
+		})
+	})
+
+})
@@ -0,0 +1,311 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// UseCompactJSON globally switches JSON marshalling of capabilities sets
+// between arrays of capability names (the default) and the compact form of a
+// single hexadecimal string, such as "000001ff00000000". The compact form is
+// useful for high-volume pipelines where arrays of names bloat payloads.
+// Unmarshalling always accepts both forms. UseCompactJSON is a shortcut for
+// setting [Config.CompactJSON].
+func UseCompactJSON(enable bool) {
+	updateConfig(func(cfg *Config) { cfg.CompactJSON = enable })
+}
+
+// MarshalJSON returns the JSON representation of this capabilities set as an
+// array of capability names, sorted by increasing bit number. If compact JSON
+// has been enabled using [UseCompactJSON], the capabilities set is instead
+// represented by a string with its hexadecimal representation.
+func (c CapabilitiesSet) MarshalJSON() ([]byte, error) {
+	if config.Load().CompactJSON {
+		return c.MarshalCompactJSON()
+	}
+	return json.Marshal(c.Names())
+}
+
+// MarshalCompactJSON returns the compact JSON representation of this
+// capabilities set in form of a string with its hexadecimal representation,
+// regardless of the setting of [UseCompactJSON].
+func (c CapabilitiesSet) MarshalCompactJSON() ([]byte, error) {
+	return json.Marshal(c.Hex())
+}
+
+// UnmarshalJSON sets this capabilities set from either a JSON array of
+// capability names or a JSON string with the hexadecimal representation of a
+// capabilities set. For backwards compatibility, UnmarshalJSON additionally
+// accepts a JSON array of the set's (numeric) words, as produced when
+// capabilities sets were marshalled as plain uint32 slices.
+func (c *CapabilitiesSet) UnmarshalJSON(b []byte) error {
+	var h string
+	if err := json.Unmarshal(b, &h); err == nil {
+		caps, err := CapabilitiesFromHex(h)
+		if err != nil {
+			return err
+		}
+		*c = caps
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(b, &names); err != nil {
+		var words []uint32
+		if json.Unmarshal(b, &words) != nil {
+			return err
+		}
+		*c = CapabilitiesSet(words)
+		return nil
+	}
+	caps, err := CapabilitiesFromNames(names)
+	if err != nil {
+		return err
+	}
+	*c = caps
+	return nil
+}
+
+// MarshalYAML returns the names of the capabilities in this set, sorted by
+// increasing bit number, for serialization as a YAML sequence.
+//
+// MarshalYAML implements the Marshaler interface of both gopkg.in/yaml.v2 and
+// gopkg.in/yaml.v3 without depending on any of them.
+func (c CapabilitiesSet) MarshalYAML() (interface{}, error) {
+	return c.Names(), nil
+}
+
+// UnmarshalYAML sets this capabilities set from either a YAML sequence of
+// capability names, or from a YAML scalar with a capabilities expression (see
+// [EvalExpression]), such as "all,-CAP_SYS_ADMIN".
+//
+// UnmarshalYAML implements the (obsolete) Unmarshaler interface of
+// gopkg.in/yaml.v2 that gopkg.in/yaml.v3 still supports.
+func (c *CapabilitiesSet) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var names []string
+	if err := unmarshal(&names); err != nil {
+		var expr string
+		if unmarshal(&expr) != nil {
+			return err
+		}
+		caps, err := EvalExpression(nil, expr)
+		if err != nil {
+			return err
+		}
+		*c = caps
+		return nil
+	}
+	caps, err := CapabilitiesFromNames(names)
+	if err != nil {
+		return err
+	}
+	*c = caps
+	return nil
+}
+
+// GobEncode returns a deterministic binary representation of this capabilities
+// set, independent of the number of trailing zero words in the set. The
+// representation consists of the set's words in little endian byte order.
+func (c CapabilitiesSet) GobEncode() ([]byte, error) {
+	return c.appendBinary(nil), nil
+}
+
+// GobDecode sets this capabilities set from the binary representation
+// produced by [CapabilitiesSet.GobEncode].
+func (c *CapabilitiesSet) GobDecode(b []byte) error {
+	if len(b)%4 != 0 {
+		return errInvalidBinary
+	}
+	*c = capabilitiesFromBinary(b)
+	return nil
+}
+
+// GobEncode returns a deterministic binary representation of the task
+// capabilities, independent of the number of trailing zero words in the
+// individual sets. For each set in the order of effective, permitted, and
+// inheritable, the representation consists of the number of words in the set
+// as an unsigned varint, followed by the set's words in little endian byte
+// order.
+func (t TaskCapabilities) GobEncode() ([]byte, error) {
+	b := []byte{}
+	for _, c := range []CapabilitiesSet{t.Effective, t.Permitted, t.Inheritable} {
+		c = c.normalized()
+		b = binary.AppendUvarint(b, uint64(len(c)))
+		b = c.appendBinary(b)
+	}
+	return b, nil
+}
+
+// GobDecode sets the task capabilities from the binary representation produced
+// by [TaskCapabilities.GobEncode].
+func (t *TaskCapabilities) GobDecode(b []byte) error {
+	var taskcaps TaskCapabilities
+	for _, c := range []*CapabilitiesSet{&taskcaps.Effective, &taskcaps.Permitted, &taskcaps.Inheritable} {
+		words, n := binary.Uvarint(b)
+		if n <= 0 || words > uint64(len(b)-n)/4 {
+			return errInvalidBinary
+		}
+		b = b[n:]
+		*c = capabilitiesFromBinary(b[:4*words])
+		b = b[4*words:]
+	}
+	if len(b) != 0 {
+		return errInvalidBinary
+	}
+	*t = taskcaps
+	return nil
+}
+
+// GobEncode returns a deterministic binary representation of the task state.
+// Embedding [TaskCapabilities] would otherwise promote its GobEncode, losing
+// all other state. The representation starts with the sets in the order of
+// effective, permitted, inheritable, bounding, and ambient, each set
+// represented the same as in [TaskCapabilities.GobEncode], followed by the
+// securebits as an unsigned varint, the no_new_privs flag as a single byte, and
+// finally NSpid and NStgid, each as an unsigned varint with the number of IDs
+// plus one (zero for nil), followed by the IDs as unsigned varints.
+func (s State) GobEncode() ([]byte, error) {
+	b := []byte{}
+	for _, c := range []CapabilitiesSet{s.Effective, s.Permitted, s.Inheritable, s.Bounding, s.Ambient} {
+		c = c.normalized()
+		b = binary.AppendUvarint(b, uint64(len(c)))
+		b = c.appendBinary(b)
+	}
+	b = binary.AppendUvarint(b, uint64(s.Securebits))
+	if s.NoNewPrivs {
+		b = append(b, 1)
+	} else {
+		b = append(b, 0)
+	}
+	for _, ids := range [][]int{s.NSpid, s.NStgid} {
+		if ids == nil {
+			b = binary.AppendUvarint(b, 0)
+			continue
+		}
+		b = binary.AppendUvarint(b, uint64(len(ids))+1)
+		for _, id := range ids {
+			if id < 0 {
+				return nil, fmt.Errorf("invalid namespaced ID %d", id)
+			}
+			b = binary.AppendUvarint(b, uint64(id))
+		}
+	}
+	return b, nil
+}
+
+// GobDecode sets the task state from the binary representation produced by
+// [State.GobEncode].
+func (s *State) GobDecode(b []byte) error {
+	var state State
+	for _, c := range []*CapabilitiesSet{&state.Effective, &state.Permitted, &state.Inheritable,
+		&state.Bounding, &state.Ambient} {
+		words, n := binary.Uvarint(b)
+		if n <= 0 || words > uint64(len(b)-n)/4 {
+			return errInvalidBinary
+		}
+		b = b[n:]
+		*c = capabilitiesFromBinary(b[:4*words])
+		b = b[4*words:]
+	}
+	securebits, n := binary.Uvarint(b)
+	if n <= 0 || len(b) == n || b[n] > 1 {
+		return errInvalidBinary
+	}
+	state.Securebits = uint(securebits)
+	state.NoNewPrivs = b[n] == 1
+	b = b[n+1:]
+	for _, ids := range []*[]int{&state.NSpid, &state.NStgid} {
+		count, n := binary.Uvarint(b)
+		if n <= 0 || (count > 0 && count-1 > uint64(len(b)-n)) {
+			return errInvalidBinary
+		}
+		b = b[n:]
+		if count == 0 {
+			continue
+		}
+		*ids = make([]int, 0, count-1)
+		for ; count > 1; count-- {
+			id, n := binary.Uvarint(b)
+			if n <= 0 || id > uint64(^uint(0)>>1) {
+				return errInvalidBinary
+			}
+			b = b[n:]
+			*ids = append(*ids, int(id))
+		}
+	}
+	if len(b) != 0 {
+		return errInvalidBinary
+	}
+	*s = state
+	return nil
+}
+
+var errInvalidBinary = errors.New("invalid binary capabilities representation")
+
+// appendBinary appends the normalized words of this capabilities set in little
+// endian byte order to b and returns the extended buffer.
+func (c CapabilitiesSet) appendBinary(b []byte) []byte {
+	for _, w := range c.normalized() {
+		b = binary.LittleEndian.AppendUint32(b, w)
+	}
+	return b
+}
+
+// capabilitiesFromBinary returns the capabilities set for the specified words
+// in little endian byte order; len(b) must be a multiple of 4.
+func capabilitiesFromBinary(b []byte) CapabilitiesSet {
+	caps := make(CapabilitiesSet, len(b)/4)
+	for idx := range caps {
+		caps[idx] = binary.LittleEndian.Uint32(b[4*idx:])
+	}
+	return caps
+}
+
+// Value returns the normalized hexadecimal representation of this capabilities
+// set for storing it in an SQL database, implementing the [driver.Valuer]
+// interface.
+func (c CapabilitiesSet) Value() (driver.Value, error) {
+	return c.normalized().Hex(), nil
+}
+
+// Scan sets this capabilities set from the hexadecimal representation of a
+// capabilities set as read from an SQL database, implementing the
+// [database/sql.Scanner] interface. A NULL value results in an empty set.
+func (c *CapabilitiesSet) Scan(src interface{}) error {
+	var h string
+	switch src := src.(type) {
+	case nil:
+		*c = NewCapabilitiesSet()
+		return nil
+	case string:
+		h = src
+	case []byte:
+		h = string(src)
+	default:
+		return fmt.Errorf("cannot scan %T into capabilities set", src)
+	}
+	caps, err := CapabilitiesFromHex(h)
+	if err != nil {
+		return err
+	}
+	*c = caps
+	return nil
+}
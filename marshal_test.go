@@ -0,0 +1,304 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+// completeState has all its fields set, in order to check that round trips
+// don't lose any state.
+var completeState = State{
+	TaskCapabilities: TaskCapabilities{
+		Effective:   CapabilitiesSet{0x00200000},
+		Permitted:   CapabilitiesSet{0x00200001, 0x80},
+		Inheritable: CapabilitiesSet{0x2},
+	},
+	Bounding:   CapabilitiesSet{0xffffffff, 0x1ff},
+	Ambient:    CapabilitiesSet{0x1},
+	Securebits: SECBIT_KEEP_CAPS | SECBIT_NOROOT_LOCKED,
+	NoNewPrivs: true,
+	NSpid:      []int{4242, 42, 1},
+	NStgid:     []int{4242, 42, 1},
+}
+
+var _ = Describe("marshalling capabilities", func() {
+
+	Context("YAML", func() {
+
+		It("marshals states flat, in the same shape as JSON", func() {
+			state := State{
+				TaskCapabilities: TaskCapabilities{
+					Effective: CapabilitiesSet{0x00200000},
+					Permitted: CapabilitiesSet{0x00200001},
+				},
+				Bounding:   CapabilitiesSet{0x1},
+				Securebits: SECBIT_KEEP_CAPS,
+				NoNewPrivs: true,
+				NSpid:      []int{42},
+			}
+			y := Successful(yaml.Marshal(state))
+			Expect(string(y)).To(Equal(`version: 1
+effective:
+    - CAP_SYS_ADMIN
+permitted:
+    - CAP_CHOWN
+    - CAP_SYS_ADMIN
+inheritable: []
+bounding:
+    - CAP_CHOWN
+ambient: []
+securebits: 16
+nonewprivs: true
+nspid:
+    - 42
+`))
+
+			var fromYAML, fromJSON map[string]interface{}
+			Expect(yaml.Unmarshal(y, &fromYAML)).To(Succeed())
+			Expect(json.Unmarshal(Successful(json.Marshal(state)), &fromJSON)).To(Succeed())
+			keys := func(m map[string]interface{}) []string {
+				var keys []string
+				for key := range m {
+					keys = append(keys, strings.ToLower(key))
+				}
+				return keys
+			}
+			Expect(keys(fromYAML)).To(ConsistOf(keys(fromJSON)))
+
+			var roundtripped State
+			Expect(yaml.Unmarshal(y, &roundtripped)).To(Succeed())
+			Expect(roundtripped.Permitted.Names()).To(ConsistOf("CAP_CHOWN", "CAP_SYS_ADMIN"))
+		})
+
+		It("marshals sets as name sequences", func() {
+			caps := NewCapabilitiesSet()
+			caps.Add(CAP_SYS_ADMIN, CAP_CHOWN)
+			Expect(string(Successful(yaml.Marshal(caps)))).To(Equal(
+				"- CAP_CHOWN\n- CAP_SYS_ADMIN\n"))
+			Expect(string(Successful(yaml.Marshal(CapabilitiesSet{})))).To(Equal("[]\n"))
+		})
+
+		It("marshals task capabilities", func() {
+			taskcaps := TaskCapabilities{
+				Effective: NewCapabilitiesSet(),
+				Permitted: NewCapabilitiesSet(),
+			}
+			taskcaps.Effective.Add(CAP_NET_RAW)
+			taskcaps.Permitted.Add(CAP_NET_RAW, CAP_NET_ADMIN)
+			y := Successful(yaml.Marshal(taskcaps))
+			Expect(string(y)).To(Equal(`effective:
+    - CAP_NET_RAW
+permitted:
+    - CAP_NET_ADMIN
+    - CAP_NET_RAW
+inheritable: []
+`))
+			var roundtripped TaskCapabilities
+			Expect(yaml.Unmarshal(y, &roundtripped)).To(Succeed())
+			Expect(roundtripped.Effective.Names()).To(ConsistOf("CAP_NET_RAW"))
+			Expect(roundtripped.Permitted.Names()).To(ConsistOf("CAP_NET_RAW", "CAP_NET_ADMIN"))
+			Expect(roundtripped.Inheritable.Names()).To(BeEmpty())
+		})
+
+		It("unmarshals name sequences and expressions", func() {
+			var caps CapabilitiesSet
+			Expect(yaml.Unmarshal([]byte("[cap_chown, CAP_42]"), &caps)).To(Succeed())
+			Expect(caps.Names()).To(ConsistOf("CAP_CHOWN", "CAP_42"))
+
+			Expect(yaml.Unmarshal([]byte(`"all,-CAP_SYS_ADMIN"`), &caps)).To(Succeed())
+			Expect(caps.Has(CAP_SYS_ADMIN)).To(BeFalse())
+			Expect(caps.Has(CAP_CHOWN)).To(BeTrue())
+		})
+
+		It("rejects invalid YAML capabilities", func() {
+			var caps CapabilitiesSet
+			Expect(yaml.Unmarshal([]byte("[CAP_FOOBAR]"), &caps)).NotTo(Succeed())
+			Expect(yaml.Unmarshal([]byte("CAP_FOOBAR"), &caps)).NotTo(Succeed())
+			Expect(yaml.Unmarshal([]byte("{foo: bar}"), &caps)).NotTo(Succeed())
+		})
+
+	})
+
+	Context("JSON", func() {
+
+		AfterEach(func() {
+			UseCompactJSON(false)
+		})
+
+		It("marshals sets as name arrays", func() {
+			caps := NewCapabilitiesSet()
+			caps.Add(CAP_SYS_ADMIN, CAP_CHOWN)
+			Expect(string(Successful(json.Marshal(caps)))).To(Equal(
+				`["CAP_CHOWN","CAP_SYS_ADMIN"]`))
+			Expect(string(Successful(json.Marshal(CapabilitiesSet{})))).To(Equal("[]"))
+		})
+
+		It("marshals sets in compact form", func() {
+			caps := NewCapabilitiesSet()
+			caps.Add(CAP_SYS_ADMIN, CAP_CHOWN)
+			Expect(string(Successful(caps.MarshalCompactJSON()))).To(Equal(
+				`"0000000000200001"`))
+			UseCompactJSON(true)
+			Expect(string(Successful(json.Marshal(caps)))).To(Equal(
+				`"0000000000200001"`))
+		})
+
+		It("unmarshals both forms", func() {
+			var caps CapabilitiesSet
+			Expect(json.Unmarshal([]byte(`["cap_chown","CAP_SYS_ADMIN"]`), &caps)).To(Succeed())
+			Expect(caps.Names()).To(ConsistOf("CAP_CHOWN", "CAP_SYS_ADMIN"))
+			caps = nil
+			Expect(json.Unmarshal([]byte(`"0000000000200001"`), &caps)).To(Succeed())
+			Expect(caps.Names()).To(ConsistOf("CAP_CHOWN", "CAP_SYS_ADMIN"))
+			caps = nil
+			Expect(json.Unmarshal([]byte(`[2097153]`), &caps)).To(Succeed())
+			Expect(caps.Names()).To(ConsistOf("CAP_CHOWN", "CAP_SYS_ADMIN"))
+		})
+
+		It("unmarshals the legacy wire format of task capabilities", func() {
+			var taskcaps TaskCapabilities
+			Expect(json.Unmarshal(Successful(os.ReadFile("testdata/json/legacy-taskcaps.json")), &taskcaps)).
+				To(Succeed())
+			Expect(taskcaps.Effective.Names()).To(ConsistOf("CAP_CHOWN", "CAP_SYS_ADMIN"))
+			Expect(taskcaps.Permitted.Names()).To(ConsistOf("CAP_CHOWN", "CAP_SYS_ADMIN", "CAP_MAC_OVERRIDE"))
+			Expect(taskcaps.Inheritable.Names()).To(BeEmpty())
+		})
+
+		It("round-trips task capabilities", func() {
+			taskcaps := Successful(OfThisTask())
+			for _, compact := range []bool{false, true} {
+				UseCompactJSON(compact)
+				var decoded TaskCapabilities
+				Expect(json.Unmarshal(Successful(json.Marshal(taskcaps)), &decoded)).To(Succeed())
+				Expect(decoded.Effective.normalized()).To(Equal(taskcaps.Effective.normalized()))
+				Expect(decoded.Permitted.normalized()).To(Equal(taskcaps.Permitted.normalized()))
+				Expect(decoded.Inheritable.normalized()).To(Equal(taskcaps.Inheritable.normalized()))
+			}
+		})
+
+		It("rejects invalid JSON", func() {
+			var caps CapabilitiesSet
+			Expect(json.Unmarshal([]byte(`["CAP_FOOBAR"]`), &caps)).NotTo(Succeed())
+			Expect(json.Unmarshal([]byte(`"xyz"`), &caps)).NotTo(Succeed())
+			Expect(json.Unmarshal([]byte(`{}`), &caps)).NotTo(Succeed())
+		})
+
+	})
+
+	Context("gob", func() {
+
+		It("encodes deterministically", func() {
+			encode := func(v interface{}) []byte {
+				var buff bytes.Buffer
+				Expect(gob.NewEncoder(&buff).Encode(v)).To(Succeed())
+				return buff.Bytes()
+			}
+			Expect(encode(CapabilitiesSet{0x80000001, 0, 0})).To(
+				Equal(encode(CapabilitiesSet{0x80000001})))
+			Expect(encode(TaskCapabilities{Effective: CapabilitiesSet{0, 0}})).To(
+				Equal(encode(TaskCapabilities{})))
+		})
+
+		It("roundtrips", func() {
+			taskcaps := TaskCapabilities{
+				Effective: CapabilitiesSet{0x00200000, 0x80, 0},
+				Permitted: CapabilitiesSet{0x00200001, 0x80},
+			}
+			var buff bytes.Buffer
+			Expect(gob.NewEncoder(&buff).Encode(taskcaps)).To(Succeed())
+			var roundtripped TaskCapabilities
+			Expect(gob.NewDecoder(&buff).Decode(&roundtripped)).To(Succeed())
+			Expect(roundtripped.Effective).To(Equal(CapabilitiesSet{0x00200000, 0x80}))
+			Expect(roundtripped.Permitted).To(Equal(CapabilitiesSet{0x00200001, 0x80}))
+			Expect(roundtripped.Inheritable).To(BeEmpty())
+		})
+
+		It("roundtrips complete task states", func() {
+			var buff bytes.Buffer
+			Expect(gob.NewEncoder(&buff).Encode(completeState)).To(Succeed())
+			var roundtripped State
+			Expect(gob.NewDecoder(&buff).Decode(&roundtripped)).To(Succeed())
+			Expect(roundtripped).To(Equal(completeState))
+
+			buff.Reset()
+			Expect(gob.NewEncoder(&buff).Encode(State{})).To(Succeed())
+			roundtripped = completeState
+			Expect(gob.NewDecoder(&buff).Decode(&roundtripped)).To(Succeed())
+			Expect(roundtripped.NSpid).To(BeNil())
+			Expect(roundtripped.Bounding).To(BeEmpty())
+			Expect(roundtripped.NoNewPrivs).To(BeFalse())
+		})
+
+		It("rejects invalid binary representations", func() {
+			var state State
+			Expect(state.GobDecode([]byte{0, 0, 0, 0, 0, 0})).NotTo(Succeed())
+			Expect(state.GobDecode([]byte{0, 0, 0, 0, 0, 0, 2, 0, 0})).NotTo(Succeed())
+			Expect(state.GobDecode([]byte{0, 0, 0, 0, 0, 0, 0, 3, 1, 0})).NotTo(Succeed())
+			Expect(state.GobDecode([]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0})).NotTo(Succeed())
+			Expect(state.GobDecode([]byte{0, 0, 0, 0, 0, 0, 0, 0, 0})).To(Succeed())
+			Expect(State{NSpid: []int{-1}}.GobEncode()).Error().To(HaveOccurred())
+
+			var caps CapabilitiesSet
+			Expect(caps.GobDecode([]byte{1, 2, 3})).NotTo(Succeed())
+
+			var taskcaps TaskCapabilities
+			Expect(taskcaps.GobDecode([]byte{})).NotTo(Succeed())
+			Expect(taskcaps.GobDecode([]byte{1, 0, 0, 0, 0, 0})).NotTo(Succeed())
+			Expect(taskcaps.GobDecode([]byte{2, 0, 0, 0, 0, 0, 0})).NotTo(Succeed())
+			Expect(taskcaps.GobDecode([]byte{0, 0, 0, 0})).NotTo(Succeed())
+			Expect(taskcaps.GobDecode([]byte{0, 0, 0})).To(Succeed())
+		})
+
+	})
+
+	Context("SQL", func() {
+
+		It("returns normalized hex values", func() {
+			Expect(CapabilitiesSet{0x00200000, 0, 0}.Value()).To(Equal("0000000000200000"))
+			Expect(CapabilitiesSet{0x1, 0x2, 0x3}.Value()).To(Equal("000000030000000200000001"))
+			Expect(CapabilitiesSet(nil).Value()).To(Equal("0000000000000000"))
+		})
+
+		DescribeTable("scans values",
+			func(src interface{}, expected CapabilitiesSet) {
+				caps := CapabilitiesSet{0x42}
+				Expect(caps.Scan(src)).To(Succeed())
+				Expect(caps).To(Equal(expected))
+			},
+			Entry("NULL", nil, CapabilitiesSet{}),
+			Entry("string", "0000000100200000", CapabilitiesSet{0x00200000, 0x1}),
+			Entry("bytes", []byte("00200000"), CapabilitiesSet{0x00200000}),
+		)
+
+		It("rejects invalid values", func() {
+			var caps CapabilitiesSet
+			Expect(caps.Scan(42)).To(MatchError(ContainSubstring("cannot scan int")))
+			Expect(caps.Scan("0")).NotTo(Succeed())
+		})
+
+	})
+
+})
@@ -0,0 +1,149 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"sync"
+	"unsafe"
+
+	"golang.org/x/exp/slices"
+)
+
+// EstimateSetMemory returns the estimated number of bytes needed to keep n
+// compacted capabilities sets in memory (see [CapabilitiesSet.Compact]), such
+// as when keeping capabilities snapshots of a whole fleet. Each set consists
+// of a slice header plus its words, where sets of up to two words – which
+// covers all capabilities currently defined – fit into the smallest possible
+// heap allocation of 8 bytes. Interning identical sets using an [Interner]
+// reduces the memory needed to just the slice headers for all duplicates.
+// Alternatively, [InlineSet] stores sets without any heap allocations.
+func EstimateSetMemory(n int) int {
+	var c CapabilitiesSet
+	const words = capDataElements
+	return n * (int(unsafe.Sizeof(c)) + (4*words+7)&^7)
+}
+
+// Compact returns a new and independent clone of this capabilities set without
+// trailing zero words and without any excess capacity, so that it uses the
+// least amount of memory. For sets with capabilities only up to the kernel's
+// current capabilities, this requires a single heap allocation of 8 bytes.
+func (c CapabilitiesSet) Compact() CapabilitiesSet {
+	c = c.normalized()
+	compact := make(CapabilitiesSet, len(c))
+	copy(compact, c)
+	return compact
+}
+
+// InlineSet is a capabilities set stored inline as a value, for keeping large
+// numbers of capabilities sets in memory, such as fleet snapshots. Sets with
+// capabilities only up to CAP_63, which covers all capabilities currently
+// defined, are stored in 16 bytes without any heap allocation; only the words
+// of larger sets are stored separately on the heap. The zero value is an empty
+// set.
+//
+// InlineSet values can be compared using “==” only as long as they don't
+// contain capabilities beyond CAP_63; use [InlineSet.Equal] otherwise.
+type InlineSet struct {
+	low  uint64           // capabilities 0 to 63
+	high *CapabilitiesSet // words beyond the low 64 capabilities, if any
+}
+
+// Inline returns the inline representation of this capabilities set.
+func (c CapabilitiesSet) Inline() InlineSet {
+	c = c.normalized()
+	var s InlineSet
+	if len(c) > 0 {
+		s.low = uint64(c[0])
+	}
+	if len(c) > 1 {
+		s.low |= uint64(c[1]) << 32
+	}
+	if len(c) > 2 {
+		high := c[2:].Compact()
+		s.high = &high
+	}
+	return s
+}
+
+// Set returns a new and independent capabilities set with the capabilities
+// of this inline set.
+func (s InlineSet) Set() CapabilitiesSet {
+	c := CapabilitiesSet{uint32(s.low), uint32(s.low >> 32)}
+	if s.high != nil {
+		c = append(c, *s.high...)
+	}
+	return c
+}
+
+// Has returns true if the specified capability is in this inline set.
+func (s InlineSet) Has(capno int) bool {
+	if capno < 64 {
+		return capno >= 0 && s.low&(uint64(1)<<capno) != 0
+	}
+	return s.high != nil && s.high.Has(capno-64)
+}
+
+// Equal returns true if this inline set contains the same capabilities as the
+// other inline set.
+func (s InlineSet) Equal(other InlineSet) bool {
+	if s.low != other.low {
+		return false
+	}
+	if s.high == nil || other.high == nil {
+		return s.high == other.high
+	}
+	return slices.Equal(*s.high, *other.high)
+}
+
+// Interner interns capabilities sets so that identical sets share the same
+// words in memory, which considerably reduces memory consumption when keeping
+// large numbers of capabilities sets, where most sets are identical. An
+// Interner is safe for concurrent use.
+//
+// Interned sets must not be modified; use [CapabilitiesSet.Clone] to get an
+// independent and modifiable copy.
+type Interner struct {
+	mu   sync.Mutex
+	sets map[string]CapabilitiesSet
+}
+
+// NewInterner returns a new and empty [Interner].
+func NewInterner() *Interner {
+	return &Interner{sets: map[string]CapabilitiesSet{}}
+}
+
+// Intern returns the interned, compacted capabilities set equal to the
+// specified capabilities set, where trailing zero words don't matter.
+func (i *Interner) Intern(c CapabilitiesSet) CapabilitiesSet {
+	var buf [4 * capDataElements]byte
+	key := c.appendBinary(buf[:0])
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if interned, ok := i.sets[string(key)]; ok {
+		return interned
+	}
+	interned := c.Compact()
+	i.sets[string(key)] = interned
+	return interned
+}
+
+// Len returns the number of distinct capabilities sets interned.
+func (i *Interner) Len() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return len(i.sets)
+}
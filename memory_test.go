@@ -0,0 +1,85 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"testing"
+	"unsafe"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("memory use", func() {
+
+	It("estimates memory", func() {
+		Expect(EstimateSetMemory(0)).To(BeZero())
+		Expect(EstimateSetMemory(1000)).To(Equal(1000 * (24 + 8)))
+	})
+
+	It("compacts sets", func() {
+		c := make(CapabilitiesSet, 4, 10)
+		c[0] = 1
+		compact := c.Compact()
+		Expect(compact).To(Equal(CapabilitiesSet{1}))
+		Expect(cap(compact)).To(Equal(1))
+		compact[0] = 42
+		Expect(c[0]).To(Equal(uint32(1)))
+		Expect(CapabilitiesSet{0, 0}.Compact()).To(BeEmpty())
+	})
+
+	It("stores small sets inline", func() {
+		Expect(unsafe.Sizeof(InlineSet{})).To(Equal(uintptr(16)))
+		c := NewCapabilitiesSet()
+		c.Add(CAP_CHOWN, CAP_SYS_ADMIN, 63)
+		Expect(testing.AllocsPerRun(10, func() { _ = c.Inline() })).To(BeZero())
+		s := c.Inline()
+		Expect(s.Has(CAP_SYS_ADMIN)).To(BeTrue())
+		Expect(s.Has(63)).To(BeTrue())
+		Expect(s.Has(CAP_NET_RAW)).To(BeFalse())
+		Expect(s.Has(64)).To(BeFalse())
+		Expect(s.Has(-1)).To(BeFalse())
+		Expect(s.Set().normalized()).To(Equal(c.normalized()))
+		Expect(s).To(Equal(CapabilitiesSet{0x00200001, 0x80000000, 0}.Inline()))
+		Expect(InlineSet{}.Set().normalized()).To(BeEmpty())
+	})
+
+	It("stores large sets partly on the heap", func() {
+		c := NewCapabilitiesSet()
+		c.Add(CAP_CHOWN, 64, 100)
+		s := c.Inline()
+		Expect(s.Has(64)).To(BeTrue())
+		Expect(s.Has(100)).To(BeTrue())
+		Expect(s.Has(101)).To(BeFalse())
+		Expect(s.Set().normalized()).To(Equal(c.normalized()))
+		Expect(s.Equal(c.Clone().Inline())).To(BeTrue())
+		Expect(s.Equal(CapabilitiesSet{1}.Inline())).To(BeFalse())
+		c.Drop(100)
+		Expect(s.Equal(c.Inline())).To(BeFalse())
+		Expect(s.Has(100)).To(BeTrue())
+	})
+
+	It("interns sets", func() {
+		i := NewInterner()
+		a := i.Intern(CapabilitiesSet{1, 0})
+		b := i.Intern(CapabilitiesSet{1})
+		Expect(a).To(Equal(CapabilitiesSet{1}))
+		Expect(&a[0]).To(BeIdenticalTo(&b[0]))
+		Expect(i.Intern(CapabilitiesSet{2})).To(Equal(CapabilitiesSet{2}))
+		Expect(i.Intern(nil)).To(BeEmpty())
+		Expect(i.Len()).To(Equal(3))
+	})
+
+})
@@ -0,0 +1,80 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// MultiError collects the errors of individual items, such as tasks, that
+// failed in an operation which nevertheless carried on with the remaining
+// items, returning a partial result instead of failing as a whole.
+//
+// A MultiError matches [errors.Is] and [errors.As] if any of its errors
+// matches, also on Go versions before 1.20 that don't know about unwrapping
+// multiple errors.
+type MultiError []error
+
+// Error returns the messages of the collected errors, separated by
+// semicolons.
+func (m MultiError) Error() string {
+	if len(m) == 1 {
+		return m[0].Error()
+	}
+	msgs := make([]string, len(m))
+	for idx, err := range m {
+		msgs[idx] = err.Error()
+	}
+	return strconv.Itoa(len(m)) + " errors: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the collected errors.
+func (m MultiError) Unwrap() []error {
+	return m
+}
+
+// Is returns true if any of the collected errors matches target.
+func (m MultiError) Is(target error) bool {
+	for _, err := range m {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the collected errors that matches target, and if one
+// is found, sets target to that error value and returns true.
+func (m MultiError) As(target interface{}) bool {
+	for _, err := range m {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// errOrNil returns this MultiError if it has collected any errors, and nil
+// otherwise.
+func (m MultiError) errOrNil() error {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
@@ -0,0 +1,62 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("multiple errors", func() {
+
+	It("returns the messages of its errors", func() {
+		Expect(MultiError{errors.New("foo")}.Error()).To(Equal("foo"))
+		Expect(MultiError{errors.New("foo"), errors.New("bar")}.Error()).To(
+			Equal("2 errors: foo; bar"))
+	})
+
+	It("matches any of its errors", func() {
+		err := error(MultiError{
+			errors.New("foo"),
+			fmt.Errorf("task 42: %w", syscall.ESRCH),
+			&fs.PathError{Op: "open", Path: "/proc/42/exe", Err: os.ErrPermission},
+		})
+		Expect(errors.Is(err, syscall.ESRCH)).To(BeTrue())
+		Expect(errors.Is(err, os.ErrPermission)).To(BeTrue())
+		Expect(errors.Is(err, os.ErrNotExist)).To(BeFalse())
+
+		var perr *fs.PathError
+		Expect(errors.As(err, &perr)).To(BeTrue())
+		Expect(perr.Path).To(Equal("/proc/42/exe"))
+		var errno syscall.Errno
+		Expect(errors.As(fmt.Errorf("scan: %w", err), &errno)).To(BeTrue())
+		Expect(errno).To(Equal(syscall.ESRCH))
+		var lerr *os.LinkError
+		Expect(errors.As(err, &lerr)).To(BeFalse())
+	})
+
+	It("is nil only without errors", func() {
+		Expect(MultiError(nil).errOrNil()).To(BeNil())
+		Expect(MultiError{}.errOrNil()).To(BeNil())
+		Expect(MultiError{syscall.ESRCH}.errOrNil()).To(MatchError(syscall.ESRCH))
+	})
+
+})
@@ -0,0 +1,178 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// CanLoadBPF checks whether the current task is allowed to load BPF programs
+// and to create BPF maps, returning true if so. Additionally, CanLoadBPF
+// returns a list of findings explaining the verdict.
+//
+// The current task either needs CAP_BPF (on Linux 5.8 and later) or
+// CAP_SYS_ADMIN. Without these capabilities, the kernel allows loading only
+// socket filter programs and only if the “kernel.unprivileged_bpf_disabled”
+// sysctl is 0. Please note that loading tracing programs additionally needs
+// CAP_PERFMON, and loading networking programs additionally needs
+// CAP_NET_ADMIN.
+//
+// Please note that the capabilities are checked only for the current task
+// (thread), so callers should lock their Go routine to its OS-level thread.
+func CanLoadBPF() (bool, Findings) {
+	taskcaps, err := OfThisTask()
+	if err != nil {
+		return false, Findings{taskQueryFinding(err)}
+	}
+	eff := taskcaps.Effective
+	var findings Findings
+	if LastCapability() < CAP_BPF {
+		findings = append(findings, infoFinding("kernel",
+			"kernel does not support CAP_BPF (needs Linux 5.8 or later), so CAP_SYS_ADMIN is required"))
+	} else if eff.Has(CAP_BPF) {
+		findings = append(findings, infoFinding("CAP_BPF",
+			"CAP_BPF is effective, allowing to load BPF programs and to create maps"))
+		if !eff.Has(CAP_PERFMON) {
+			findings = append(findings, warningFinding("CAP_PERFMON",
+				"CAP_PERFMON is not effective, but is required for loading tracing programs").
+				withRemediation("grant CAP_PERFMON for tracing programs"))
+		}
+		if !eff.Has(CAP_NET_ADMIN) {
+			findings = append(findings, warningFinding("CAP_NET_ADMIN",
+				"CAP_NET_ADMIN is not effective, but is required for loading networking programs").
+				withRemediation("grant CAP_NET_ADMIN for networking programs"))
+		}
+		return true, findings
+	} else {
+		findings = append(findings, infoFinding("CAP_BPF", "CAP_BPF is not effective"))
+	}
+	if eff.Has(CAP_SYS_ADMIN) {
+		return true, append(findings, infoFinding("CAP_SYS_ADMIN",
+			"CAP_SYS_ADMIN is effective, allowing all BPF operations"))
+	}
+	findings = append(findings, infoFinding("CAP_SYS_ADMIN", "CAP_SYS_ADMIN is not effective"))
+	disabled, err := readSysctl("kernel/unprivileged_bpf_disabled")
+	if err != nil {
+		return false, append(findings, errorFinding("kernel.unprivileged_bpf_disabled",
+			"cannot query kernel.unprivileged_bpf_disabled: %s", err.Error()).
+			withRemediation("grant %s", bpfCapability()))
+	}
+	if disabled != 0 {
+		return false, append(findings, errorFinding("kernel.unprivileged_bpf_disabled",
+			"kernel.unprivileged_bpf_disabled is %d, disallowing unprivileged BPF", disabled).
+			withRemediation("grant %s", bpfCapability()))
+	}
+	return true, append(findings, warningFinding("kernel.unprivileged_bpf_disabled",
+		"kernel.unprivileged_bpf_disabled is 0, allowing to load socket filter programs only").
+		withRemediation("grant %s for other program types", bpfCapability()))
+}
+
+// CanPerf checks whether the current task is allowed to use performance
+// monitoring via perf_event_open(2), returning true if so. Additionally, CanPerf
+// returns a list of findings explaining the verdict.
+//
+// The current task either needs CAP_PERFMON (on Linux 5.8 and later) or
+// CAP_SYS_ADMIN for unrestricted performance monitoring. Without these
+// capabilities, the “kernel.perf_event_paranoid” sysctl decides about what is
+// allowed: -1 allows all events, 0 disallows raw tracepoint access, 1
+// additionally disallows CPU events, and 2 allows only user-space
+// measurements. Values above 2, as introduced by some distributions, disallow
+// unprivileged performance monitoring completely.
+//
+// Please note that the capabilities are checked only for the current task
+// (thread), so callers should lock their Go routine to its OS-level thread.
+func CanPerf() (bool, Findings) {
+	taskcaps, err := OfThisTask()
+	if err != nil {
+		return false, Findings{taskQueryFinding(err)}
+	}
+	eff := taskcaps.Effective
+	var findings Findings
+	if LastCapability() < CAP_PERFMON {
+		findings = append(findings, infoFinding("kernel",
+			"kernel does not support CAP_PERFMON (needs Linux 5.8 or later), so CAP_SYS_ADMIN is required"))
+	} else if eff.Has(CAP_PERFMON) {
+		return true, append(findings, infoFinding("CAP_PERFMON",
+			"CAP_PERFMON is effective, allowing all performance monitoring operations"))
+	} else {
+		findings = append(findings, infoFinding("CAP_PERFMON", "CAP_PERFMON is not effective"))
+	}
+	if eff.Has(CAP_SYS_ADMIN) {
+		return true, append(findings, infoFinding("CAP_SYS_ADMIN",
+			"CAP_SYS_ADMIN is effective, allowing all performance monitoring operations"))
+	}
+	findings = append(findings, infoFinding("CAP_SYS_ADMIN", "CAP_SYS_ADMIN is not effective"))
+	paranoid, err := readSysctl("kernel/perf_event_paranoid")
+	if err != nil {
+		return false, append(findings, errorFinding("kernel.perf_event_paranoid",
+			"cannot query kernel.perf_event_paranoid: %s", err.Error()).
+			withRemediation("grant %s", perfCapability()))
+	}
+	const sysctl = "kernel.perf_event_paranoid"
+	switch {
+	case paranoid <= -1:
+		findings = append(findings, infoFinding(sysctl,
+			"kernel.perf_event_paranoid is -1, allowing all events"))
+	case paranoid == 0:
+		findings = append(findings, warningFinding(sysctl,
+			"kernel.perf_event_paranoid is 0, allowing all events except raw tracepoints").
+			withRemediation("grant %s for raw tracepoints", perfCapability()))
+	case paranoid == 1:
+		findings = append(findings, warningFinding(sysctl,
+			"kernel.perf_event_paranoid is 1, allowing user-space and kernel measurements, but no CPU events").
+			withRemediation("grant %s for CPU events", perfCapability()))
+	case paranoid == 2:
+		findings = append(findings, warningFinding(sysctl,
+			"kernel.perf_event_paranoid is 2, allowing user-space measurements only").
+			withRemediation("grant %s for kernel measurements", perfCapability()))
+	default:
+		return false, append(findings, errorFinding(sysctl,
+			"kernel.perf_event_paranoid is %d, disallowing unprivileged performance monitoring", paranoid).
+			withRemediation("grant %s", perfCapability()))
+	}
+	return true, findings
+}
+
+// bpfCapability returns the name of the capability to grant for BPF
+// operations, depending on whether the kernel supports CAP_BPF.
+func bpfCapability() string {
+	if LastCapability() < CAP_BPF {
+		return "CAP_SYS_ADMIN"
+	}
+	return "CAP_BPF"
+}
+
+// perfCapability returns the name of the capability to grant for performance
+// monitoring, depending on whether the kernel supports CAP_PERFMON.
+func perfCapability() string {
+	if LastCapability() < CAP_PERFMON {
+		return "CAP_SYS_ADMIN"
+	}
+	return "CAP_PERFMON"
+}
+
+// readSysctl returns the integer value of the specified sysctl, such as
+// "kernel/perf_event_paranoid", relative to /proc/sys.
+func readSysctl(name string) (int, error) {
+	contents, err := os.ReadFile(procPath("sys/" + name))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(contents)))
+}
@@ -0,0 +1,126 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/thediveo/caps/capstest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+// fakeSysctlProc creates a fake proc root with the specified kernel sysctls.
+func fakeSysctlProc(sysctls map[string]string) string {
+	root := GinkgoT().TempDir()
+	Expect(os.MkdirAll(filepath.Join(root, "sys/kernel"), 0755)).To(Succeed())
+	for name, value := range sysctls {
+		Expect(os.WriteFile(filepath.Join(root, "sys/kernel", name),
+			[]byte(value+"\n"), 0644)).To(Succeed())
+	}
+	return root
+}
+
+// withEffective runs fn on a throw-away OS-level thread with only the
+// specified effective capabilities.
+func withEffective(fn func(), capnos ...int) {
+	capstest.RequirePermitted(GinkgoT(), capnos...)
+	done := make(chan struct{})
+	go func() {
+		defer GinkgoRecover()
+		defer close(done)
+		runtime.LockOSThread() // throw away this thread when done.
+		taskcaps := Successful(OfThisTask())
+		taskcaps.Effective.Clear()
+		for _, capno := range capnos {
+			taskcaps.Effective.Add(capno)
+		}
+		Expect(SetForThisTask(taskcaps)).To(Succeed())
+		fn()
+	}()
+	Eventually(done).Should(BeClosed())
+}
+
+var _ = Describe("observability", func() {
+
+	BeforeEach(func() {
+		cfg := CurrentConfig()
+		DeferCleanup(func() { Configure(cfg) })
+	})
+
+	It("reads sysctls", func() {
+		Configure(Config{ProcRoot: fakeSysctlProc(map[string]string{
+			"perf_event_paranoid": "-1",
+			"foo":                 "bar",
+		})})
+		Expect(readSysctl("kernel/perf_event_paranoid")).To(Equal(-1))
+		Expect(readSysctl("kernel/foo")).Error().To(HaveOccurred())
+		Expect(readSysctl("kernel/bar")).Error().To(HaveOccurred())
+	})
+
+	DescribeTable("checks BPF",
+		func(sysctl string, capnos []int, expected bool, finding string) {
+			if LastCapability() < CAP_BPF {
+				Skip("needs CAP_BPF support")
+			}
+			sysctls := map[string]string{}
+			if sysctl != "" {
+				sysctls["unprivileged_bpf_disabled"] = sysctl
+			}
+			Configure(Config{ProcRoot: fakeSysctlProc(sysctls)})
+			withEffective(func() {
+				ok, findings := CanLoadBPF()
+				Expect(ok).To(Equal(expected), "%v", findings)
+				Expect(findings.Messages()).To(ContainElement(ContainSubstring(finding)))
+			}, capnos...)
+		},
+		Entry("CAP_BPF", "2", []int{CAP_BPF}, true, "CAP_NET_ADMIN is not effective"),
+		Entry("CAP_SYS_ADMIN", "2", []int{CAP_SYS_ADMIN}, true, "CAP_SYS_ADMIN is effective"),
+		Entry("unprivileged enabled", "0", nil, true, "socket filter programs only"),
+		Entry("unprivileged disabled", "1", nil, false, "disallowing unprivileged BPF"),
+		Entry("unknown", "", nil, false, "cannot query"),
+	)
+
+	DescribeTable("checks perf",
+		func(sysctl string, capnos []int, expected bool, finding string) {
+			if LastCapability() < CAP_PERFMON {
+				Skip("needs CAP_PERFMON support")
+			}
+			sysctls := map[string]string{}
+			if sysctl != "" {
+				sysctls["perf_event_paranoid"] = sysctl
+			}
+			Configure(Config{ProcRoot: fakeSysctlProc(sysctls)})
+			withEffective(func() {
+				ok, findings := CanPerf()
+				Expect(ok).To(Equal(expected), "%v", findings)
+				Expect(findings.Messages()).To(ContainElement(ContainSubstring(finding)))
+			}, capnos...)
+		},
+		Entry("CAP_PERFMON", "4", []int{CAP_PERFMON}, true, "CAP_PERFMON is effective"),
+		Entry("CAP_SYS_ADMIN", "4", []int{CAP_SYS_ADMIN}, true, "CAP_SYS_ADMIN is effective"),
+		Entry("paranoid -1", "-1", nil, true, "allowing all events"),
+		Entry("paranoid 0", "0", nil, true, "except raw tracepoints"),
+		Entry("paranoid 1", "1", nil, true, "no CPU events"),
+		Entry("paranoid 2", "2", nil, true, "user-space measurements only"),
+		Entry("paranoid 3", "3", nil, false, "disallowing unprivileged"),
+		Entry("unknown", "", nil, false, "cannot query"),
+	)
+
+})
@@ -0,0 +1,26 @@
+/*
+Package ocihook helps with writing OCI runtime hooks that inspect the
+capabilities of a container's init process, such as checking them against a
+policy and logging findings. Hooks are typically run as “createRuntime”,
+“createContainer”, “startContainer”, or “poststart” hooks.
+
+A hook binary reads the container state JSON from stdin, as specified by the
+[OCI runtime specification], looks up the capabilities-related state of the
+container's init process, and finally exits with a proper status:
+
+	func main() {
+		ocihook.Main(func(s ocihook.State, capstate caps.State) ([]string, error) {
+			if capstate.Effective.Has(caps.CAP_SYS_ADMIN) {
+				return nil, errors.New("CAP_SYS_ADMIN not allowed")
+			}
+			return nil, nil
+		})
+	}
+
+Please note that Linux doesn't allow changing the capabilities of other
+processes, so hooks can only inspect, but not adjust, the capabilities of a
+container's init process.
+
+[OCI runtime specification]: https://github.com/opencontainers/runtime-spec/blob/main/runtime.md#state
+*/
+package ocihook
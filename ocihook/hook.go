@@ -0,0 +1,94 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package ocihook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/thediveo/caps"
+)
+
+// State is the state of a container as passed to OCI hooks on stdin.
+type State struct {
+	OCIVersion  string            `json:"ociVersion"`
+	ID          string            `json:"id"`
+	Status      string            `json:"status"`
+	Pid         int               `json:"pid,omitempty"`
+	Bundle      string            `json:"bundle"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Check inspects the capabilities-related state of a container's init process,
+// returning findings to be logged. Returning a non-nil error makes the hook
+// fail, which usually aborts the container's lifecycle operation.
+type Check func(state State, capstate caps.State) (findings []string, err error)
+
+// Exit statuses of hooks.
+const (
+	ExitOK      = 0 // the check passed
+	ExitFailure = 1 // the check failed or the hook could not run the check
+)
+
+// ReadState reads the container state JSON from the specified reader.
+func ReadState(r io.Reader) (State, error) {
+	var state State
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return State{}, fmt.Errorf("invalid OCI container state: %w", err)
+	}
+	if state.Pid <= 0 {
+		return State{}, errors.New("OCI container state lacks the init process PID")
+	}
+	return state, nil
+}
+
+// Run reads the container state JSON from stdin, runs the check on the
+// capabilities-related state of the container's init process and logs any
+// findings to stderr, one finding per line. Run returns [ExitOK] if the check
+// passed, otherwise [ExitFailure].
+func Run(stdin io.Reader, stderr io.Writer, check Check) int {
+	state, err := ReadState(stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return ExitFailure
+	}
+	capstate, err := caps.StateOf(state.Pid)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: cannot read capabilities of container %s init process %d: %s\n",
+			state.ID, state.Pid, err)
+		return ExitFailure
+	}
+	findings, err := check(state, capstate)
+	for _, finding := range findings {
+		fmt.Fprintf(stderr, "container %s: %s\n", state.ID, finding)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "error: container %s: %s\n", state.ID, err)
+		return ExitFailure
+	}
+	return ExitOK
+}
+
+// Main runs the check as an OCI hook binary, reading the container state from
+// os.Stdin and logging findings to os.Stderr, and then exits the process with
+// the proper exit status. Main never returns.
+func Main(check Check) {
+	os.Exit(Run(os.Stdin, os.Stderr, check))
+}
@@ -0,0 +1,87 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package ocihook
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/thediveo/caps"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+func stateJSON(pid int) string {
+	return `{"ociVersion":"1.0.2","id":"foobar","status":"created","pid":` +
+		strconv.Itoa(pid) + `,"bundle":"/run/bundle","annotations":{"foo":"bar"}}`
+}
+
+var _ = Describe("OCI hooks", func() {
+
+	It("reads the container state", func() {
+		state := Successful(ReadState(strings.NewReader(stateJSON(42))))
+		Expect(state.OCIVersion).To(Equal("1.0.2"))
+		Expect(state.ID).To(Equal("foobar"))
+		Expect(state.Status).To(Equal("created"))
+		Expect(state.Pid).To(Equal(42))
+		Expect(state.Bundle).To(Equal("/run/bundle"))
+		Expect(state.Annotations).To(HaveKeyWithValue("foo", "bar"))
+	})
+
+	DescribeTable("rejects invalid container state",
+		func(state string) {
+			Expect(ReadState(strings.NewReader(state))).Error().To(HaveOccurred())
+		},
+		Entry("invalid JSON", `{`),
+		Entry("missing PID", `{"id":"foobar"}`),
+	)
+
+	It("runs a passing check", func() {
+		var stderr bytes.Buffer
+		var capstate caps.State
+		Expect(Run(strings.NewReader(stateJSON(os.Getpid())), &stderr,
+			func(s State, cs caps.State) ([]string, error) {
+				capstate = cs
+				return []string{"all fine"}, nil
+			})).To(Equal(ExitOK))
+		Expect(capstate.Bounding).NotTo(BeEmpty())
+		Expect(stderr.String()).To(Equal("container foobar: all fine\n"))
+	})
+
+	It("runs a failing check", func() {
+		var stderr bytes.Buffer
+		Expect(Run(strings.NewReader(stateJSON(os.Getpid())), &stderr,
+			func(State, caps.State) ([]string, error) {
+				return nil, errors.New("D'OH!")
+			})).To(Equal(ExitFailure))
+		Expect(stderr.String()).To(Equal("error: container foobar: D'OH!\n"))
+	})
+
+	It("fails for invalid state and vanished processes", func() {
+		check := func(State, caps.State) ([]string, error) { return nil, nil }
+		var stderr bytes.Buffer
+		Expect(Run(strings.NewReader("{"), &stderr, check)).To(Equal(ExitFailure))
+		Expect(stderr.String()).To(HavePrefix("error: invalid OCI container state"))
+		stderr.Reset()
+		Expect(Run(strings.NewReader(stateJSON(1<<30)), &stderr, check)).To(Equal(ExitFailure))
+		Expect(stderr.String()).To(HavePrefix("error: cannot read capabilities"))
+	})
+
+})
@@ -0,0 +1,281 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/thediveo/caps/errno"
+	"github.com/thediveo/caps/internal/diag"
+	"golang.org/x/sys/unix"
+)
+
+// Operation identifies a common privileged operation, such as binding to a
+// privileged port or opening a raw socket, for which [RequiredFor] returns the
+// capabilities needed.
+type Operation int
+
+// Privileged operations known to [RequiredFor].
+const (
+	OpBindPrivilegedPort            Operation = iota // bind to ports below 1024
+	OpOpenRawSocket                                  // open raw and packet sockets
+	OpConfigureNetwork                               // configure interfaces, routes, firewalls, ...
+	OpPtraceOtherUser                                // ptrace processes of other users
+	OpKillOtherUser                                  // send signals to processes of other users
+	OpMount                                          // mount and unmount filesystems
+	OpChroot                                         // change root directory
+	OpSetSystemClock                                 // set the system clock
+	OpLoadBPF                                        // load BPF programs and create maps
+	OpPerfMonitoring                                 // system-wide performance monitoring
+	OpCheckpointRestore                              // checkpoint and restore processes
+	OpCreateRestrictedUserNamespace                  // create user namespaces when restricted by the system
+	OpChangeOwnership                                // change file ownership
+	OpChangeUserID                                   // switch user IDs
+	OpChangeGroupID                                  // switch group IDs
+	OpMakeDeviceNode                                 // create device nodes
+	OpLockMemory                                     // lock memory
+	OpRaisePriority                                  // raise scheduling priorities
+	OpOverrideResourceLimits                         // override resource limits
+	OpLoadKernelModule                               // load and unload kernel modules
+	OpReboot                                         // reboot the system
+	OpReadKernelLog                                  // read the kernel log
+	OpCreateNamespace                                // create non-user namespaces
+	OpJoinNamespace                                  // join namespaces using setns
+	OpSetHostname                                    // set the host and domain names
+	OpInjectTerminalInput                            // fake terminal input using the TIOCSTI ioctl
+)
+
+var operationNames = map[Operation]string{
+	OpBindPrivilegedPort:            "bind-privileged-port",
+	OpOpenRawSocket:                 "open-raw-socket",
+	OpConfigureNetwork:              "configure-network",
+	OpPtraceOtherUser:               "ptrace-other-user",
+	OpKillOtherUser:                 "kill-other-user",
+	OpMount:                         "mount",
+	OpChroot:                        "chroot",
+	OpSetSystemClock:                "set-system-clock",
+	OpLoadBPF:                       "load-bpf",
+	OpPerfMonitoring:                "perf-monitoring",
+	OpCheckpointRestore:             "checkpoint-restore",
+	OpCreateRestrictedUserNamespace: "create-restricted-user-namespace",
+	OpChangeOwnership:               "change-ownership",
+	OpChangeUserID:                  "change-user-id",
+	OpChangeGroupID:                 "change-group-id",
+	OpMakeDeviceNode:                "make-device-node",
+	OpLockMemory:                    "lock-memory",
+	OpRaisePriority:                 "raise-priority",
+	OpOverrideResourceLimits:        "override-resource-limits",
+	OpLoadKernelModule:              "load-kernel-module",
+	OpReboot:                        "reboot",
+	OpReadKernelLog:                 "read-kernel-log",
+	OpCreateNamespace:               "create-namespace",
+	OpJoinNamespace:                 "join-namespace",
+	OpSetHostname:                   "set-hostname",
+	OpInjectTerminalInput:           "inject-terminal-input",
+}
+
+// String returns the name of the operation, such as "open-raw-socket".
+func (op Operation) String() string {
+	if name, ok := operationNames[op]; ok {
+		return name
+	}
+	return "Operation(" + strconv.Itoa(int(op)) + ")"
+}
+
+// operationCaps maps operations to their required capabilities, where an
+// operation's capabilities can be unsupported by older kernels.
+var operationCaps = map[Operation][]int{
+	OpBindPrivilegedPort:            {CAP_NET_BIND_SERVICE},
+	OpOpenRawSocket:                 {CAP_NET_RAW},
+	OpConfigureNetwork:              {CAP_NET_ADMIN},
+	OpPtraceOtherUser:               {CAP_SYS_PTRACE},
+	OpKillOtherUser:                 {CAP_KILL},
+	OpMount:                         {CAP_SYS_ADMIN},
+	OpChroot:                        {CAP_SYS_CHROOT},
+	OpSetSystemClock:                {CAP_SYS_TIME},
+	OpLoadBPF:                       {CAP_BPF},
+	OpPerfMonitoring:                {CAP_PERFMON},
+	OpCheckpointRestore:             {CAP_CHECKPOINT_RESTORE, CAP_SYS_PTRACE},
+	OpCreateRestrictedUserNamespace: {CAP_SYS_ADMIN},
+	OpChangeOwnership:               {CAP_CHOWN},
+	OpChangeUserID:                  {CAP_SETUID},
+	OpChangeGroupID:                 {CAP_SETGID},
+	OpMakeDeviceNode:                {CAP_MKNOD},
+	OpLockMemory:                    {CAP_IPC_LOCK},
+	OpRaisePriority:                 {CAP_SYS_NICE},
+	OpOverrideResourceLimits:        {CAP_SYS_RESOURCE},
+	OpLoadKernelModule:              {CAP_SYS_MODULE},
+	OpReboot:                        {CAP_SYS_BOOT},
+	OpReadKernelLog:                 {CAP_SYSLOG},
+	OpCreateNamespace:               {CAP_SYS_ADMIN},
+	OpJoinNamespace:                 {CAP_SYS_ADMIN},
+	OpSetHostname:                   {CAP_SYS_ADMIN},
+	OpInjectTerminalInput:           {CAP_SYS_ADMIN},
+}
+
+// RequiredFor returns the capabilities required for the specified operation.
+// In case the kernel we're currently running on doesn't support a fine-grained
+// capability yet, such as CAP_BPF, CAP_PERFMON, or CAP_CHECKPOINT_RESTORE,
+// RequiredFor replaces only this unsupported capability with CAP_SYS_ADMIN,
+// keeping any other required capabilities. For unknown operations, an empty
+// set is returned.
+func RequiredFor(op Operation) CapabilitiesSet {
+	c := NewCapabilitiesSet()
+	for _, capno := range operationCaps[op] {
+		if capno > LastCapability() {
+			capno = CAP_SYS_ADMIN
+		}
+		c.Add(capno)
+	}
+	return c
+}
+
+// SetupFor sets up the capabilities of all tasks (threads) of this process for
+// only the specified operations, dropping all other capabilities. More
+// precisely, SetupFor sets the effective and permitted capabilities to exactly
+// the capabilities required for the operations (see [RequiredFor]), and clears
+// the inheritable capabilities. As the permitted capabilities get reduced, the
+// dropped capabilities cannot be regained later.
+//
+// SetupFor returns an error without changing any capabilities if the required
+// capabilities aren't all in the permitted sets of all tasks of this process,
+// as the Go runtime would otherwise crash this process when changing the
+// capabilities fails for only some tasks. The derivation of the capabilities
+// from the operations gets logged (see [SetLogger]).
+//
+// SetupFor relies on [syscall.AllThreadsSyscall] and thus is not supported when
+// cgo is enabled; it then returns [syscall.ENOTSUP]. While the test guard is
+// active, SetupFor fails with [ErrTestGuard] unless explicitly allowed, see
+// [SetTestGuard].
+func SetupFor(ops ...Operation) error {
+	if err := guard(GuardSetupFor); err != nil {
+		return err
+	}
+	current, err := OfThisTask()
+	if err != nil {
+		return err
+	}
+	taskcaps, err := setupCaps(current, ops...)
+	if err != nil {
+		return err
+	}
+	diag.Log("setting up capabilities for operations",
+		"derivation", derivation(ops), "capabilities", taskcaps.Permitted.String())
+	if err := checkAllTasksPermit(ownProcPath("self/task"), taskcaps.Permitted); err != nil {
+		return err
+	}
+	ensureBaseline()
+	return setForAllTasks(taskcaps)
+}
+
+// derivation returns which operations require which capabilities, such as
+// "open-raw-socket: CAP_NET_RAW; mount: CAP_SYS_ADMIN".
+func derivation(ops []Operation) string {
+	derivations := make([]string, 0, len(ops))
+	for _, op := range ops {
+		derivations = append(derivations, op.String()+": "+RequiredFor(op).String())
+	}
+	return strings.Join(derivations, "; ")
+}
+
+// checkAllTasksPermit returns an error if any of the tasks in the specified
+// task directory, such as /proc/self/task, doesn't have all the specified
+// capabilities in its permitted set. Tasks terminating while being checked are
+// ignored. The task directory must belong to the caller's own PID namespace,
+// so it is never taken from [Config.ProcRoot].
+func checkAllTasksPermit(taskdir string, required CapabilitiesSet) error {
+	entries, err := os.ReadDir(taskdir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		tid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		status, err := os.ReadFile(taskdir + "/" + entry.Name() + "/status")
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ESRCH) {
+				continue
+			}
+			return err
+		}
+		state, err := parseStatus(status)
+		if err != nil {
+			return fmt.Errorf("task %d: %w", tid, err)
+		}
+		missing := required.Clone()
+		missing.dropSet(state.Permitted)
+		if names := missing.Names(); len(names) != 0 {
+			return fmt.Errorf("required capabilities not permitted in task %d: %s",
+				tid, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+// setupCaps returns the task capabilities for the specified operations, based
+// on the current task capabilities. It returns an error if any of the required
+// capabilities isn't permitted.
+func setupCaps(current TaskCapabilities, ops ...Operation) (TaskCapabilities, error) {
+	required := NewCapabilitiesSet()
+	for _, op := range ops {
+		required.addSet(RequiredFor(op))
+	}
+	missing := NewCapabilitiesSet()
+	for idx, w := range required {
+		if idx < len(current.Permitted) {
+			w &^= current.Permitted[idx]
+		}
+		missing.ensure(idx)
+		missing[idx] = w
+	}
+	if names := missing.Names(); len(names) != 0 {
+		return TaskCapabilities{}, fmt.Errorf("required capabilities not permitted: %s",
+			strings.Join(names, ", "))
+	}
+	return TaskCapabilities{
+		Effective:   required,
+		Permitted:   required.Clone(),
+		Inheritable: NewCapabilitiesSet(),
+	}, nil
+}
+
+// setForAllTasks sets the capability sets (effective, permitted and
+// inheritable) for all tasks of this process.
+func setForAllTasks(taskcaps TaskCapabilities) error {
+	var capHeader = unix.CapUserHeader{
+		Version: LINUX_CAPABILITY_VERSION_3,
+	}
+	capData := taskcaps.capUserData()
+	_, _, e := syscall.AllThreadsSyscall(
+		unix.SYS_CAPSET,
+		uintptr(unsafe.Pointer(&capHeader)),
+		uintptr(unsafe.Pointer(&capData[0])),
+		0)
+	if e != 0 {
+		return errno.Wrap("capset", e)
+	}
+	untaintAll()
+	return nil
+}
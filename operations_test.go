@@ -0,0 +1,133 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"os"
+	"runtime"
+
+	"github.com/thediveo/caps/capstest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("operations", func() {
+
+	BeforeEach(func() {
+		cfg := CurrentConfig()
+		DeferCleanup(func() { Configure(cfg) })
+	})
+
+	It("knows all operations", func() {
+		for op := OpBindPrivilegedPort; op <= OpInjectTerminalInput; op++ {
+			Expect(operationNames).To(HaveKey(op))
+			Expect(operationCaps).To(HaveKey(op))
+		}
+		Expect(operationNames).To(HaveLen(len(operationCaps)))
+	})
+
+	It("names operations", func() {
+		Expect(OpOpenRawSocket.String()).To(Equal("open-raw-socket"))
+		Expect(Operation(-1).String()).To(Equal("Operation(-1)"))
+	})
+
+	It("returns the required capabilities", func() {
+		Expect(RequiredFor(OpBindPrivilegedPort).Names()).To(ConsistOf("CAP_NET_BIND_SERVICE"))
+		Expect(RequiredFor(Operation(-1))).To(BeEmpty())
+		if LastCapability() >= CAP_CHECKPOINT_RESTORE {
+			Expect(RequiredFor(OpCheckpointRestore).Names()).To(
+				ConsistOf("CAP_CHECKPOINT_RESTORE", "CAP_SYS_PTRACE"))
+		}
+	})
+
+	It("falls back to CAP_SYS_ADMIN only for unsupported capabilities", func() {
+		Configure(Config{LastCapability: CAP_AUDIT_READ})
+		Expect(RequiredFor(OpCheckpointRestore).Names()).To(ConsistOf("CAP_SYS_ADMIN", "CAP_SYS_PTRACE"))
+		Expect(RequiredFor(OpLoadBPF).Names()).To(ConsistOf("CAP_SYS_ADMIN"))
+		Expect(RequiredFor(OpOpenRawSocket).Names()).To(ConsistOf("CAP_NET_RAW"))
+	})
+
+	It("sets up only the required capabilities", func() {
+		current := TaskCapabilities{
+			Effective: NewCapabilitiesSet(),
+			Permitted: NewCapabilitiesSet(),
+		}
+		current.Permitted.Add(CAP_NET_RAW, CAP_NET_BIND_SERVICE, CAP_SYS_ADMIN)
+		current.Inheritable.Add(CAP_NET_RAW)
+		taskcaps := Successful(setupCaps(current, OpOpenRawSocket, OpBindPrivilegedPort))
+		Expect(taskcaps.Effective.Names()).To(ConsistOf("CAP_NET_RAW", "CAP_NET_BIND_SERVICE"))
+		Expect(taskcaps.Permitted).To(Equal(taskcaps.Effective))
+		Expect(taskcaps.Inheritable).To(BeEmpty())
+	})
+
+	It("rejects setting up non-permitted capabilities", func() {
+		current := TaskCapabilities{Permitted: NewCapabilitiesSet()}
+		current.Permitted.Add(CAP_NET_RAW)
+		Expect(setupCaps(current, OpOpenRawSocket, OpMount, OpChroot)).Error().To(
+			MatchError("required capabilities not permitted: CAP_SYS_CHROOT, CAP_SYS_ADMIN"))
+	})
+
+	It("derives the required capabilities", func() {
+		Expect(derivation([]Operation{OpOpenRawSocket, OpMount})).To(Equal(
+			"open-raw-socket: CAP_NET_RAW; mount: CAP_SYS_ADMIN"))
+		Expect(derivation(nil)).To(BeEmpty())
+	})
+
+	It("checks the permitted capabilities of all tasks", func() {
+		taskdir := GinkgoT().TempDir()
+		for tid, prm := range map[string]string{"1": "0000000000002000", "42": "0000000000000000"} {
+			Expect(os.Mkdir(taskdir+"/"+tid, 0o700)).To(Succeed())
+			Expect(os.WriteFile(taskdir+"/"+tid+"/status", []byte(
+				"CapInh:\t00\nCapPrm:\t"+prm+"\nCapEff:\t00\nCapBnd:\t00\nCapAmb:\t00\n"), 0o600)).To(Succeed())
+		}
+		Expect(os.Mkdir(taskdir+"/gone", 0o700)).To(Succeed())
+		required := NewCapabilitiesSet()
+		Expect(checkAllTasksPermit(taskdir, required)).To(Succeed())
+		required.Add(CAP_NET_RAW)
+		Expect(checkAllTasksPermit(taskdir, required)).To(MatchError(
+			"required capabilities not permitted in task 42: CAP_NET_RAW"))
+		Expect(checkAllTasksPermit(taskdir+"/nada", required)).NotTo(Succeed())
+	})
+
+	It("refuses to set up capabilities not permitted in all tasks", func() {
+		capstest.RequirePermitted(GinkgoT(), CAP_NET_RAW)
+		dropped := make(chan struct{})
+		release := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			runtime.LockOSThread() // throw away
+			taskcaps := Successful(OfThisTask())
+			taskcaps.Effective.Drop(CAP_NET_RAW)
+			taskcaps.Permitted.Drop(CAP_NET_RAW)
+			Expect(SetForThisTask(taskcaps)).To(Succeed())
+			close(dropped)
+			<-release
+		}()
+		defer close(release)
+		<-dropped
+		rec := &recordingLogger{}
+		SetLogger(rec)
+		DeferCleanup(func() { SetLogger(nil) })
+		Expect(SetupFor(OpOpenRawSocket)).To(MatchError(
+			MatchRegexp(`required capabilities not permitted in task \d+: CAP_NET_RAW`)))
+		Expect(rec.entries).To(ConsistOf(logEntry{
+			msg: "setting up capabilities for operations",
+			kv:  []interface{}{"derivation", "open-raw-socket: CAP_NET_RAW", "capabilities", "CAP_NET_RAW"},
+		}))
+	})
+
+})
@@ -15,8 +15,13 @@
 package caps
 
 import (
+	"os"
+	"regexp"
+	"strings"
 	"testing"
 
+	"github.com/thediveo/caps/capstest"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -25,3 +30,23 @@ func TestCaps(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "caps package")
 }
+
+// inUserNamespaceUnlessRoot re-executes the test binary for just the current
+// spec in a new user namespace when not running as root, so that the spec gets
+// the full set of capabilities inside the user namespace. It returns true if
+// the spec has been run in the re-executed test binary, and the caller then
+// must return. Otherwise, when running as root or in the re-executed test
+// binary itself, it returns false and the caller runs the spec itself.
+func inUserNamespaceUnlessRoot() bool {
+	if os.Getuid() == 0 || capstest.InChild() {
+		return false
+	}
+	// Ginkgo matches the focus against the suite description, a space, and
+	// then the full spec text.
+	out := string(capstest.RunInUserNamespace(GinkgoT(),
+		"-ginkgo.focus= "+regexp.QuoteMeta(CurrentSpecReport().FullText())+"$"))
+	if !strings.Contains(out, "1 Passed") {
+		Skip("skipped in user namespace:\n" + out)
+	}
+	return true
+}
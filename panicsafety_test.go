@@ -0,0 +1,92 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"runtime"
+
+	"github.com/thediveo/caps/capstest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+// raiseAndThen returns a function that raises CAP_SYS_ADMIN in the effective
+// set of the current task and then calls abort, such as panicking or calling
+// runtime.Goexit.
+func raiseAndThen(abort func()) func() error {
+	return func() error {
+		if _, err := AddEffectiveCaps(CAP_SYS_ADMIN); err != nil {
+			return err
+		}
+		abort()
+		return nil
+	}
+}
+
+var _ = Describe("panic-safe capabilities restoration", func() {
+
+	aborts := []TableEntry{
+		Entry("panic", func() { panic("D'oh!") }),
+		Entry("runtime.Goexit", runtime.Goexit),
+	}
+
+	DescribeTable("Raised never touches the caller's capabilities",
+		func(abort func()) {
+			capstest.RequirePermitted(GinkgoT(), CAP_SYS_ADMIN, CAP_NET_RAW)
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				runtime.LockOSThread()
+				taskcaps := Successful(OfThisTask())
+				taskcaps.Effective = NewCapabilitiesSet()
+				Expect(SetForThisTask(taskcaps)).To(Succeed())
+
+				func() {
+					defer func() { _ = recover() }()
+					err := Raised(raiseAndThen(abort), CAP_NET_RAW)
+					Expect(err).To(MatchError(ErrGoexit))
+				}()
+				Expect(Successful(OfThisTask()).Effective.Names()).To(BeEmpty())
+			}()
+			<-done
+		},
+		aborts)
+
+	DescribeTable("workers restore their capabilities",
+		func(abort func()) {
+			capstest.RequirePermitted(GinkgoT(), CAP_SYS_ADMIN, CAP_SYS_PTRACE)
+			ptrace := NewCapabilitiesSet()
+			ptrace.Add(CAP_SYS_PTRACE)
+			pool := Successful(NewWorkerPool(WorkerProfile{Capabilities: ptrace, Workers: 1}))
+			defer pool.Close()
+
+			for i := 0; i < 3; i++ {
+				func() {
+					defer func() { _ = recover() }()
+					err := pool.Submit(raiseAndThen(abort), CAP_SYS_PTRACE)
+					Expect(err).To(MatchError(ErrGoexit))
+				}()
+				var names []string
+				Expect(pool.Submit(func() error { names = effectiveNames(); return nil },
+					CAP_SYS_PTRACE)).To(Succeed())
+				Expect(names).To(ConsistOf("CAP_SYS_PTRACE"))
+			}
+		},
+		aborts)
+
+})
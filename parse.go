@@ -0,0 +1,71 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"bytes"
+	"strings"
+)
+
+// This file provides uniform entry points for all parsers of this package,
+// each taking the raw input as a byte slice. As these parsers ingest
+// untrusted data, such as from /proc or configuration files, they are also
+// the targets for fuzzing.
+
+// ParseHex parses a capabilities set in hexadecimal notation, such as found in
+// /proc/[pid]/status; see also [CapabilitiesFromHex]. Leading and trailing
+// white space is ignored.
+func ParseHex(b []byte) (CapabilitiesSet, error) {
+	return CapabilitiesFromHex(string(bytes.TrimSpace(b)))
+}
+
+// ParseText parses task capabilities in libcap's textual representation, such
+// as "cap_net_raw=ep"; see also [TaskCapabilitiesFromText].
+func ParseText(b []byte) (TaskCapabilities, error) {
+	return TaskCapabilitiesFromText(string(b))
+}
+
+// ParseCapsh parses the output of “capsh --print”, including its “Current
+// IAB:” tuple; see also [ParseCapshPrint].
+func ParseCapsh(b []byte) (CapshPrint, error) {
+	return ParseCapshPrint(string(b))
+}
+
+// ParseStatus parses the capabilities-related state from the contents of a
+// /proc/[tid]/status file.
+func ParseStatus(b []byte) (State, error) {
+	return parseStatus(b)
+}
+
+// ParseAuditRecord parses the capabilities-related “cap_*” fields of a single
+// Linux kernel audit record in its textual form, such as “type=CAPSET
+// msg=audit(...): pid=42 cap_pi=0 ...”; see also [FromAuditRecord].
+func ParseAuditRecord(b []byte) (AuditCapabilities, error) {
+	fields := map[string]string{}
+	for _, field := range strings.Fields(string(b)) {
+		if name, value, ok := strings.Cut(field, "="); ok {
+			fields[name] = value
+		}
+	}
+	return FromAuditRecord(fields)
+}
+
+// ParseSystemdUnit parses the capabilities-related directives of a systemd
+// unit; see also [ParseSystemdCapabilities].
+func ParseSystemdUnit(b []byte) (SystemdCapabilities, error) {
+	return ParseSystemdCapabilities(string(b))
+}
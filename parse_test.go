@@ -0,0 +1,39 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("parser entry points", func() {
+
+	It("parses hex with surrounding white space", func() {
+		Expect(Successful(ParseHex([]byte("\t0000000000003000\n"))).Names()).To(
+			ConsistOf("CAP_NET_ADMIN", "CAP_NET_RAW"))
+		Expect(ParseHex([]byte("foo"))).Error().To(HaveOccurred())
+	})
+
+	It("parses audit records", func() {
+		ac := Successful(ParseAuditRecord([]byte(
+			"type=PATH msg=audit(1700000000.000:43): item=0 name=\"/usr/bin/ping\" cap_fp=0000000000002000 cap_fe=1")))
+		Expect(ac.FilePermitted.Names()).To(ConsistOf("CAP_NET_RAW"))
+		Expect(ac.FileEffective).To(BeTrue())
+		Expect(ParseAuditRecord([]byte("cap_fe=2"))).Error().To(HaveOccurred())
+	})
+
+})
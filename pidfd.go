@@ -0,0 +1,134 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// ProcessHandle references a process via a PID file descriptor (pidfd), so
+// that the capabilities of the referenced process can be queried without
+// getting fooled by PID recycling. A ProcessHandle must be closed when not
+// needed anymore in order to release its PID file descriptor.
+type ProcessHandle struct {
+	fd int
+}
+
+// OpenProcess returns a new handle for the process with the specified PID,
+// using pidfd_open(2). This requires a Linux kernel 5.3 or later.
+func OpenProcess(pid int) (*ProcessHandle, error) {
+	fd, err := unix.PidfdOpen(pid, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &ProcessHandle{fd: fd}, nil
+}
+
+// FD returns the PID file descriptor of this process handle.
+func (h *ProcessHandle) FD() int { return h.fd }
+
+// Capabilities returns the effective, permitted and inheritable capability
+// sets of the process referenced by this handle.
+func (h *ProcessHandle) Capabilities() (TaskCapabilities, error) {
+	return OfProcessFD(h.fd)
+}
+
+// Close closes the PID file descriptor of this process handle.
+func (h *ProcessHandle) Close() error {
+	return unix.Close(h.fd)
+}
+
+// OfProcessFD returns the effective, permitted and inheritable capability sets
+// of the process referenced by the specified PID file descriptor. In case the
+// process has terminated, [syscall.ESRCH] is returned, even if its PID has
+// been reused by another process in the meantime.
+//
+// OfProcessFD requires a Linux kernel 5.4 or later in order to be able to
+// determine the PID of the process referenced by the PID file descriptor.
+func OfProcessFD(pidfd int) (TaskCapabilities, error) {
+	pid, err := pidOfPidfd(pidfd)
+	if err != nil {
+		return TaskCapabilities{}, err
+	}
+	taskcaps, err := OfTask(pid)
+	if err != nil {
+		return TaskCapabilities{}, err
+	}
+	// Only after we've successfully queried the capabilities we can check
+	// that the process is still alive and thus its PID couldn't have been
+	// reused in the meantime.
+	if err := pidfdAlive(pidfd); err != nil {
+		return TaskCapabilities{}, err
+	}
+	return taskcaps, nil
+}
+
+// pidfdAlive returns nil if the process referenced by the specified PID file
+// descriptor hasn't terminated yet, and [syscall.ESRCH] otherwise. In contrast
+// to sending the null signal, polling the PID file descriptor doesn't need any
+// permissions for the referenced process, just as capget(2) doesn't.
+func pidfdAlive(pidfd int) error {
+	fds := []unix.PollFd{{Fd: int32(pidfd), Events: unix.POLLIN}}
+	for {
+		n, err := unix.Poll(fds, 0)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if n > 0 && fds[0].Revents&(unix.POLLIN|unix.POLLHUP) != 0 {
+			return syscall.ESRCH
+		}
+		return nil
+	}
+}
+
+// pidOfPidfd returns the PID of the process referenced by the specified PID
+// file descriptor, as shown in the "Pid:" field of the file descriptor's
+// fdinfo.
+func pidOfPidfd(pidfd int) (int, error) {
+	fdinfo, err := os.ReadFile(ownProcPath("self/fdinfo/" + strconv.Itoa(pidfd)))
+	if err != nil {
+		return 0, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(fdinfo))
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !bytes.HasPrefix(line, []byte("Pid:")) {
+			continue
+		}
+		pid, err := strconv.Atoi(string(bytes.TrimSpace(line[len("Pid:"):])))
+		if err != nil {
+			return 0, err
+		}
+		if pid <= 0 {
+			// the process has already terminated (-1), or it's not in our
+			// PID namespace (0).
+			return 0, syscall.ESRCH
+		}
+		return pid, nil
+	}
+	return 0, errors.New("not a PID file descriptor")
+}
@@ -0,0 +1,88 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+
+	"github.com/thediveo/caps/capstest"
+	"golang.org/x/sys/unix"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("process handles", func() {
+
+	It("returns the capabilities of a process", func() {
+		h := Successful(OpenProcess(os.Getpid()))
+		defer h.Close()
+		Expect(h.FD()).NotTo(BeZero())
+		Expect(h.Capabilities()).To(Equal(Successful(OfTask(os.Getpid()))))
+	})
+
+	It("detects terminated processes", func() {
+		cmd := exec.Command("/bin/sh", "-c", "exit 0")
+		Expect(cmd.Start()).To(Succeed())
+		h := Successful(OpenProcess(cmd.Process.Pid))
+		defer h.Close()
+		Expect(cmd.Wait()).To(Succeed())
+		Expect(h.Capabilities()).Error().To(MatchError(syscall.ESRCH))
+	})
+
+	It("queries processes owned by other users without privileges", func() {
+		capstest.RequireEffective(GinkgoT(), CAP_SETUID)
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			runtime.LockOSThread() // throw away this thread when done.
+			discardThisTask()
+			_, _, e := unix.RawSyscall(unix.SYS_SETRESUID, 65534, 65534, 65534)
+			Expect(e).To(BeZero())
+			Expect(unix.Getuid()).To(Equal(65534))
+
+			h := Successful(OpenProcess(1))
+			defer h.Close()
+			Expect(h.Capabilities()).To(Equal(Successful(OfTask(1))))
+		}()
+		Eventually(done).Should(BeClosed())
+	})
+
+	It("detects terminated but not yet reaped processes", func() {
+		cmd := exec.Command("/bin/sh", "-c", "exit 0")
+		Expect(cmd.Start()).To(Succeed())
+		defer func() { _ = cmd.Wait() }()
+		h := Successful(OpenProcess(cmd.Process.Pid))
+		defer h.Close()
+		Eventually(func() error { return pidfdAlive(h.FD()) }).Should(MatchError(syscall.ESRCH))
+	})
+
+	It("rejects non-pidfds", func() {
+		Expect(OfProcessFD(-1)).Error().To(HaveOccurred())
+		fd := Successful(unix.Open("/", unix.O_RDONLY, 0))
+		defer unix.Close(fd)
+		Expect(OfProcessFD(fd)).Error().To(MatchError("not a PID file descriptor"))
+	})
+
+	It("returns an error for non-existing processes", func() {
+		Expect(OpenProcess(-1)).Error().To(HaveOccurred())
+	})
+
+})
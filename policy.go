@@ -0,0 +1,166 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"strings"
+)
+
+// OCICapabilities represents the capabilities of a process in an OCI runtime
+// configuration (“process.capabilities”), with the capabilities given by their
+// names, such as "CAP_NET_RAW".
+type OCICapabilities struct {
+	Bounding    []string `json:"bounding,omitempty" yaml:"bounding,omitempty"`
+	Effective   []string `json:"effective,omitempty" yaml:"effective,omitempty"`
+	Inheritable []string `json:"inheritable,omitempty" yaml:"inheritable,omitempty"`
+	Permitted   []string `json:"permitted,omitempty" yaml:"permitted,omitempty"`
+	Ambient     []string `json:"ambient,omitempty" yaml:"ambient,omitempty"`
+}
+
+// Policy defines which capabilities containers are allowed to request.
+type Policy struct {
+	// Allowed capabilities that may be requested.
+	Allowed CapabilitiesSet `json:"allowed" yaml:"allowed"`
+	// Denied capabilities that must never be requested, taking precedence
+	// over Allowed.
+	Denied CapabilitiesSet `json:"denied" yaml:"denied"`
+	// AllowAnonymous allows requesting capabilities unknown to this package,
+	// such as "CAP_42", as long as they are allowed and not denied.
+	AllowAnonymous bool `json:"allowAnonymous" yaml:"allowAnonymous"`
+	// UserNamespace indicates that the container runs in its own user
+	// namespace, so capabilities only effective in the initial user namespace
+	// are harmless and thus allowed even when not in Allowed, unless denied.
+	UserNamespace bool `json:"userNamespace" yaml:"userNamespace"`
+}
+
+// Decision is the result of evaluating a [Policy].
+type Decision struct {
+	Allowed bool     // the requested capabilities are allowed
+	Reasons []string // human-readable reasons for the decision
+}
+
+// initialUserNamespaceOnly contains the capabilities that the kernel checks
+// only against the initial user namespace, so they have no effect when held in
+// any other user namespace.
+var initialUserNamespaceOnly = []int{
+	CAP_LINUX_IMMUTABLE,
+	CAP_SYS_MODULE,
+	CAP_SYS_RAWIO,
+	CAP_SYS_PACCT,
+	CAP_SYS_BOOT,
+	CAP_SYS_TIME,
+	CAP_SYS_TTY_CONFIG,
+	CAP_MKNOD,
+	CAP_AUDIT_WRITE,
+	CAP_AUDIT_CONTROL,
+	CAP_MAC_OVERRIDE,
+	CAP_MAC_ADMIN,
+	CAP_SYSLOG,
+	CAP_WAKE_ALARM,
+	CAP_BLOCK_SUSPEND,
+	CAP_AUDIT_READ,
+	CAP_PERFMON,
+	CAP_BPF,
+}
+
+// allKnownCapabilities returns a new set with all capabilities known to this
+// package, independent of the kernel we're running on.
+func allKnownCapabilities() CapabilitiesSet {
+	caps := NewCapabilitiesSet()
+	for capno := 0; capno <= MaxCapabilityNumber; capno++ {
+		caps.Add(capno)
+	}
+	return caps
+}
+
+// EvaluatePolicy evaluates the requested OCI capabilities against the specified
+// policy, returning an allow or deny decision together with human-readable
+// reasons. This is intended for use in container admission webhooks and
+// runtime plugins.
+//
+// Capability names are matched case-insensitively and may omit the "CAP_"
+// prefix, as is common in container tooling; the "ALL" keyword requests all
+// capabilities known to this package, up to [MaxCapabilityNumber], as the
+// kernel of the node running the container might support more capabilities
+// than the kernel we're running on. The names of registered bundles (see
+// [RegisterBundle]) request the bundles' capabilities. Unknown capability
+// names are always denied.
+func EvaluatePolicy(policy Policy, requested OCICapabilities) Decision {
+	decision := Decision{Allowed: true, Reasons: []string{}}
+	deny := func(format string, args ...interface{}) {
+		decision.Allowed = false
+		decision.Reasons = append(decision.Reasons, localizef(format, args...))
+	}
+	usernsOnly := NewCapabilitiesSet()
+	if policy.UserNamespace {
+		usernsOnly.Add(initialUserNamespaceOnly[0], initialUserNamespaceOnly[1:]...)
+	}
+	for _, set := range []struct {
+		name  string
+		names []string
+	}{
+		{"bounding", requested.Bounding},
+		{"effective", requested.Effective},
+		{"inheritable", requested.Inheritable},
+		{"permitted", requested.Permitted},
+		{"ambient", requested.Ambient},
+	} {
+		caps := NewCapabilitiesSet()
+		for _, name := range set.names {
+			if strings.EqualFold(name, "ALL") {
+				caps.addSet(allKnownCapabilities())
+				continue
+			}
+			if bundle, ok := Bundle(name); ok {
+				caps.addSet(bundle)
+				continue
+			}
+			capno, err := CapabilityByName(ociCapabilityName(name))
+			if err != nil {
+				deny("%s: unknown capability %q", set.name, name)
+				continue
+			}
+			caps.Add(capno)
+		}
+		for _, capno := range caps.Numbers() {
+			name := CapabilityNameByNumber[capno]
+			switch {
+			case name == "" && !policy.AllowAnonymous:
+				deny("%s: anonymous capability %s not allowed", set.name, capabilityName(capno))
+			case policy.Denied.Has(capno):
+				deny("%s: %s is denied", set.name, capabilityName(capno))
+			case policy.Allowed.Has(capno):
+			case usernsOnly.Has(capno):
+				decision.Reasons = append(decision.Reasons, localizef(
+					"%s: %s allowed as it has no effect outside the initial user namespace",
+					set.name, capabilityName(capno)))
+			default:
+				deny("%s: %s is not allowed", set.name, capabilityName(capno))
+			}
+		}
+	}
+	return decision
+}
+
+// ociCapabilityName returns the capability name with the "CAP_" prefix,
+// adding it if missing.
+func ociCapabilityName(name string) string {
+	if len(name) >= 4 && strings.EqualFold(name[:4], "CAP_") {
+		return name
+	}
+	return "CAP_" + name
+}
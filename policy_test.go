@@ -0,0 +1,100 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("policies", func() {
+
+	var policy Policy
+
+	BeforeEach(func() {
+		policy = Policy{
+			Allowed: Successful(CapabilitiesFromNames([]string{
+				"CAP_CHOWN", "CAP_NET_BIND_SERVICE", "CAP_NET_RAW", "CAP_SYS_ADMIN"})),
+			Denied: Successful(CapabilitiesFromNames([]string{"CAP_SYS_ADMIN"})),
+		}
+	})
+
+	It("allows requests within the policy", func() {
+		d := EvaluatePolicy(policy, OCICapabilities{
+			Bounding:  []string{"CAP_CHOWN", "net_bind_service"},
+			Effective: []string{"CAP_NET_BIND_SERVICE"},
+			Permitted: []string{"cap_net_bind_service"},
+		})
+		Expect(d.Allowed).To(BeTrue())
+		Expect(d.Reasons).To(BeEmpty())
+		Expect(EvaluatePolicy(policy, OCICapabilities{}).Allowed).To(BeTrue())
+	})
+
+	It("denies requests outside the policy", func() {
+		d := EvaluatePolicy(policy, OCICapabilities{
+			Bounding:  []string{"CAP_SYS_ADMIN", "CAP_SYS_MODULE", "CAP_FOOBAR"},
+			Effective: []string{"CAP_CHOWN"},
+		})
+		Expect(d.Allowed).To(BeFalse())
+		Expect(d.Reasons).To(ConsistOf(
+			`bounding: unknown capability "CAP_FOOBAR"`,
+			"bounding: CAP_SYS_ADMIN is denied",
+			"bounding: CAP_SYS_MODULE is not allowed"))
+	})
+
+	It("denies ALL", func() {
+		d := EvaluatePolicy(policy, OCICapabilities{Ambient: []string{"all"}})
+		Expect(d.Allowed).To(BeFalse())
+		Expect(d.Reasons).To(ContainElement("ambient: CAP_SYS_ADMIN is denied"))
+		Expect(d.Reasons).To(ContainElement("ambient: CAP_KILL is not allowed"))
+	})
+
+	It("denies ALL beyond the capabilities of the local kernel", func() {
+		cfg := CurrentConfig()
+		DeferCleanup(func() { Configure(cfg) })
+		local := cfg
+		local.LastCapability = CAP_AUDIT_READ
+		Configure(local)
+		Expect(LastCapability()).To(BeNumerically("<", CAP_BPF))
+
+		policy.Denied.Add(CAP_BPF)
+		d := EvaluatePolicy(policy, OCICapabilities{Effective: []string{"ALL"}})
+		Expect(d.Allowed).To(BeFalse())
+		Expect(d.Reasons).To(ContainElement("effective: CAP_BPF is denied"))
+		Expect(d.Reasons).To(ContainElement("effective: CAP_CHECKPOINT_RESTORE is not allowed"))
+	})
+
+	It("handles anonymous capabilities", func() {
+		policy.Allowed.Add(63)
+		d := EvaluatePolicy(policy, OCICapabilities{Effective: []string{"CAP_63"}})
+		Expect(d.Allowed).To(BeFalse())
+		Expect(d.Reasons).To(ConsistOf("effective: anonymous capability CAP_63 not allowed"))
+		policy.AllowAnonymous = true
+		Expect(EvaluatePolicy(policy, OCICapabilities{Effective: []string{"CAP_63"}}).Allowed).To(BeTrue())
+	})
+
+	It("relaxes for user namespaces", func() {
+		policy.UserNamespace = true
+		policy.Denied.Add(CAP_SYS_BOOT)
+		d := EvaluatePolicy(policy, OCICapabilities{Bounding: []string{"CAP_SYS_MODULE"}})
+		Expect(d.Allowed).To(BeTrue())
+		Expect(d.Reasons).To(ConsistOf(
+			"bounding: CAP_SYS_MODULE allowed as it has no effect outside the initial user namespace"))
+		d = EvaluatePolicy(policy, OCICapabilities{Bounding: []string{"CAP_SYS_BOOT"}})
+		Expect(d.Allowed).To(BeFalse())
+	})
+
+})
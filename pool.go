@@ -0,0 +1,56 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import "sync"
+
+// setPool keeps the words of unused capabilities sets for reuse, reducing the
+// garbage collection pressure when repeatedly scanning the capabilities of
+// large numbers of tasks.
+var setPool = sync.Pool{
+	New: func() interface{} { return new([capDataElements]uint32) },
+}
+
+// GetSet returns an empty capabilities set from a pool of capabilities sets,
+// with sufficient capacity for the capabilities of the kernel. Return the set
+// to the pool using [PutSet] when it isn't needed anymore.
+func GetSet() CapabilitiesSet {
+	return setPool.Get().(*[capDataElements]uint32)[:0]
+}
+
+// PutSet returns a capabilities set to the pool of capabilities sets for later
+// reuse by [GetSet]. The set must not be used anymore after returning it to
+// the pool. Sets with a capacity other than needed for the capabilities of the
+// kernel are silently ignored, so it is safe to pass any set.
+func PutSet(c CapabilitiesSet) {
+	if cap(c) != capDataElements {
+		return
+	}
+	setPool.Put((*[capDataElements]uint32)(c[:capDataElements]))
+}
+
+// Release returns the capabilities sets of this state to the pool of
+// capabilities sets (see [PutSet]), so that they can be reused when reading the
+// states of further tasks. The state must not be used anymore afterwards.
+func (s *State) Release() {
+	for _, c := range []*CapabilitiesSet{
+		&s.Effective, &s.Permitted, &s.Inheritable, &s.Bounding, &s.Ambient,
+	} {
+		PutSet(*c)
+		*c = nil
+	}
+}
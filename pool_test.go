@@ -0,0 +1,48 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("capabilities set pool", func() {
+
+	It("returns empty sets", func() {
+		c := GetSet()
+		Expect(c).To(BeEmpty())
+		Expect(cap(c)).To(Equal(capDataElements))
+		c.Add(CAP_SYS_ADMIN)
+		PutSet(c)
+		Expect(GetSet()).To(BeEmpty())
+	})
+
+	It("ignores unsuitable sets", func() {
+		Expect(func() {
+			PutSet(nil)
+			PutSet(make(CapabilitiesSet, 0, 42))
+		}).NotTo(Panic())
+	})
+
+	It("releases states", func() {
+		state := Successful(StateOf(0))
+		state.Release()
+		Expect(state.Effective).To(BeNil())
+		Expect(state.Ambient).To(BeNil())
+	})
+
+})
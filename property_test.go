@@ -0,0 +1,117 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"math/rand"
+
+	"github.com/thediveo/caps/capstest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("set properties", func() {
+
+	var r *rand.Rand
+
+	BeforeEach(func() {
+		r = rand.New(rand.NewSource(GinkgoRandomSeed()))
+	})
+
+	DescribeTable("round-trips random sets",
+		func(maxCap int, roundtrip func(c CapabilitiesSet) (CapabilitiesSet, error)) {
+			capstest.CheckRoundTrip(GinkgoT(), r, 200, maxCap, func(set []uint32) ([]uint32, error) {
+				return roundtrip(set)
+			})
+		},
+		Entry("JSON", 63, func(c CapabilitiesSet) (CapabilitiesSet, error) {
+			b, err := json.Marshal(c)
+			if err != nil {
+				return nil, err
+			}
+			var result CapabilitiesSet
+			err = json.Unmarshal(b, &result)
+			return result, err
+		}),
+		Entry("compact JSON", 95, func(c CapabilitiesSet) (CapabilitiesSet, error) {
+			b, err := c.MarshalCompactJSON()
+			if err != nil {
+				return nil, err
+			}
+			var result CapabilitiesSet
+			err = json.Unmarshal(b, &result)
+			return result, err
+		}),
+		Entry("gob", 95, func(c CapabilitiesSet) (CapabilitiesSet, error) {
+			var buff bytes.Buffer
+			if err := gob.NewEncoder(&buff).Encode(c); err != nil {
+				return nil, err
+			}
+			var result CapabilitiesSet
+			err := gob.NewDecoder(&buff).Decode(&result)
+			return result, err
+		}),
+		Entry("CBOR", 95, func(c CapabilitiesSet) (CapabilitiesSet, error) {
+			b, err := c.MarshalCBOR()
+			if err != nil {
+				return nil, err
+			}
+			var result CapabilitiesSet
+			err = result.UnmarshalCBOR(b)
+			return result, err
+		}),
+		Entry("hex", 95, func(c CapabilitiesSet) (CapabilitiesSet, error) {
+			return CapabilitiesFromHex(c.Hex())
+		}),
+		Entry("names", 63, func(c CapabilitiesSet) (CapabilitiesSet, error) {
+			return CapabilitiesFromNames(c.Names())
+		}),
+		Entry("uint64", 63, func(c CapabilitiesSet) (CapabilitiesSet, error) {
+			return CapabilitiesFromUint64(c.Uint64()), nil
+		}),
+	)
+
+	It("adds and drops capabilities consistently", func() {
+		for i := 0; i < 200; i++ {
+			a := CapabilitiesSet(capstest.RandomSet(r, 63))
+			b := CapabilitiesSet(capstest.RandomSet(r, 63))
+			union := AggregateUnion([]CapabilitiesSet{a, b})
+			intersection := AggregateIntersection([]CapabilitiesSet{a, b})
+			Expect(capstest.EqualSets(union, AggregateUnion([]CapabilitiesSet{b, a}))).To(BeTrue())
+			Expect(capstest.EqualSets(intersection, AggregateIntersection([]CapabilitiesSet{b, a}))).To(BeTrue())
+			for capno := 0; capno <= 63; capno++ {
+				Expect(union.Has(capno)).To(Equal(a.Has(capno) || b.Has(capno)))
+				Expect(intersection.Has(capno)).To(Equal(a.Has(capno) && b.Has(capno)))
+			}
+
+			c := a.Clone()
+			for _, capno := range b.Numbers() {
+				c.Add(capno)
+			}
+			Expect(capstest.EqualSets(c, union)).To(BeTrue())
+			for _, capno := range b.Numbers() {
+				c.Drop(capno)
+			}
+			for capno := 0; capno <= 63; capno++ {
+				Expect(c.Has(capno)).To(Equal(a.Has(capno) && !b.Has(capno)))
+			}
+		}
+	})
+
+})
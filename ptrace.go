@@ -0,0 +1,151 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// YAMA ptrace scopes, see also
+// https://www.kernel.org/doc/html/latest/admin-guide/LSM/Yama.html.
+const (
+	ptraceScopeClassic    = 0 // classic ptrace permissions
+	ptraceScopeRestricted = 1 // only descendants, unless CAP_SYS_PTRACE
+	ptraceScopeAdminOnly  = 2 // only with CAP_SYS_PTRACE
+	ptraceScopeNoAttach   = 3 // no ptrace attaching at all
+)
+
+// CanPtrace checks whether the current task is allowed to ptrace attach to the
+// process with the specified PID, returning true if so. Additionally,
+// CanPtrace returns a list of findings explaining the verdict. CanPtrace
+// combines the effective CAP_SYS_PTRACE capability of the current task with
+// the kernel's same-user rules and the YAMA ptrace scope
+// (/proc/sys/kernel/yama/ptrace_scope).
+//
+// Please note that CanPtrace cannot take into account whether the target has
+// made itself non-dumpable or has declared a specific ptracer using
+// PR_SET_PTRACER, and that it checks CAP_SYS_PTRACE without considering user
+// namespaces. Also, capabilities are checked only for the current task
+// (thread), so callers should lock their Go routine to its OS-level thread.
+func CanPtrace(targetPid int) (bool, Findings) {
+	scope, err := readSysctl("kernel/yama/ptrace_scope")
+	if err != nil {
+		scope = ptraceScopeClassic
+	}
+	if scope >= ptraceScopeNoAttach {
+		return false, Findings{errorFinding("kernel.yama.ptrace_scope",
+			"YAMA ptrace scope 3 disallows any ptrace attaching").
+			withRemediation("lower kernel.yama.ptrace_scope, which requires a reboot")}
+	}
+	taskcaps, err := OfThisTask()
+	if err != nil {
+		return false, Findings{taskQueryFinding(err)}
+	}
+	target, err := os.ReadFile(ownProcPath(strconv.Itoa(targetPid) + "/status"))
+	if err != nil {
+		return false, Findings{errorFinding("target",
+			"cannot query process %d: %s", targetPid, err.Error())}
+	}
+	if taskcaps.Effective.Has(CAP_SYS_PTRACE) {
+		return true, Findings{infoFinding("CAP_SYS_PTRACE",
+			"CAP_SYS_PTRACE is effective, overriding credential checks and YAMA restrictions")}
+	}
+	findings := Findings{infoFinding("CAP_SYS_PTRACE", "CAP_SYS_PTRACE is not effective")}
+	ok := true
+	uids, gids := statusIDs(target, "Uid:"), statusIDs(target, "Gid:")
+	if sameIDs(uids, os.Getuid()) && sameIDs(gids, os.Getgid()) {
+		findings = append(findings, infoFinding("credentials",
+			"target runs with the same user and group IDs"))
+	} else {
+		ok = false
+		findings = append(findings, errorFinding("credentials",
+			"target runs with different user or group IDs, requiring CAP_SYS_PTRACE").
+			withRemediation("grant CAP_SYS_PTRACE"))
+	}
+	switch scope {
+	case ptraceScopeRestricted:
+		if isDescendant(targetPid, os.Getpid()) {
+			findings = append(findings, infoFinding("kernel.yama.ptrace_scope",
+				"YAMA ptrace scope 1 allows attaching to descendants"))
+		} else {
+			ok = false
+			findings = append(findings, errorFinding("kernel.yama.ptrace_scope",
+				"YAMA ptrace scope 1 allows attaching only to descendants, unless CAP_SYS_PTRACE").
+				withRemediation("grant CAP_SYS_PTRACE, or have the target declare this process as its ptracer using PR_SET_PTRACER"))
+		}
+	case ptraceScopeAdminOnly:
+		ok = false
+		findings = append(findings, errorFinding("kernel.yama.ptrace_scope",
+			"YAMA ptrace scope 2 requires CAP_SYS_PTRACE").withRemediation("grant CAP_SYS_PTRACE"))
+	}
+	return ok, findings
+}
+
+// statusIDs returns the real, effective, saved set, and filesystem IDs from
+// the specified line of a /proc/[pid]/status file, such as "Uid:".
+func statusIDs(status []byte, key string) []int {
+	scanner := bufio.NewScanner(bytes.NewReader(status))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, key) {
+			continue
+		}
+		ids := []int{}
+		for _, field := range strings.Fields(line[len(key):]) {
+			id, err := strconv.Atoi(field)
+			if err != nil {
+				return nil
+			}
+			ids = append(ids, id)
+		}
+		return ids
+	}
+	return nil
+}
+
+// sameIDs returns true if the real, effective, and saved set IDs all match the
+// specified ID.
+func sameIDs(ids []int, id int) bool {
+	if len(ids) < 3 {
+		return false
+	}
+	return ids[0] == id && ids[1] == id && ids[2] == id
+}
+
+// isDescendant returns true if the process with the specified PID is a
+// descendant of the ancestor process.
+func isDescendant(pid, ancestor int) bool {
+	for pid > 1 {
+		status, err := os.ReadFile(ownProcPath(strconv.Itoa(pid) + "/status"))
+		if err != nil {
+			return false
+		}
+		ppids := statusIDs(status, "PPid:")
+		if len(ppids) == 0 {
+			return false
+		}
+		pid = ppids[0]
+		if pid == ancestor {
+			return true
+		}
+	}
+	return false
+}
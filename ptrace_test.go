@@ -0,0 +1,135 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+
+	"github.com/thediveo/caps/capstest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+// useProc makes both the configured and the own proc filesystem the specified
+// proc root for the duration of the current spec.
+func useProc(root string) {
+	Configure(Config{ProcRoot: root})
+	DeferCleanup(func(old string) { ownProcRoot = old }, ownProcRoot)
+	ownProcRoot = root
+}
+
+// fakePtraceProc creates a fake proc root with the YAMA ptrace scope, a
+// target process 42 with the specified user ID and parent, and a parent
+// process 41 which is a child of PID 1.
+func fakePtraceProc(scope string, uid int, ppid int) string {
+	root := GinkgoT().TempDir()
+	if scope != "" {
+		Expect(os.MkdirAll(filepath.Join(root, "sys/kernel/yama"), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(root, "sys/kernel/yama/ptrace_scope"),
+			[]byte(scope+"\n"), 0644)).To(Succeed())
+	}
+	writeStatus := func(pid, ppid, uid, gid int) {
+		dir := filepath.Join(root, strconv.Itoa(pid))
+		Expect(os.MkdirAll(dir, 0755)).To(Succeed())
+		u, g := strconv.Itoa(uid), strconv.Itoa(gid)
+		Expect(os.WriteFile(filepath.Join(dir, "status"), []byte(
+			"Name:\tsleep\nPPid:\t"+strconv.Itoa(ppid)+"\n"+
+				"Uid:\t"+u+"\t"+u+"\t"+u+"\t"+u+"\n"+
+				"Gid:\t"+g+"\t"+g+"\t"+g+"\t"+g+"\n"), 0644)).To(Succeed())
+	}
+	writeStatus(42, ppid, uid, os.Getgid())
+	writeStatus(41, 1, os.Getuid(), os.Getgid())
+	return root
+}
+
+var _ = Describe("ptrace", func() {
+
+	BeforeEach(func() {
+		cfg := CurrentConfig()
+		DeferCleanup(func() { Configure(cfg) })
+	})
+
+	It("parses IDs from status", func() {
+		status := []byte("Name:\tfoo\nUid:\t1\t2\t3\t4\nGid:\tfoo\n")
+		Expect(statusIDs(status, "Uid:")).To(Equal([]int{1, 2, 3, 4}))
+		Expect(statusIDs(status, "Gid:")).To(BeNil())
+		Expect(statusIDs(status, "PPid:")).To(BeNil())
+		Expect(sameIDs([]int{1, 1, 1, 2}, 1)).To(BeTrue())
+		Expect(sameIDs([]int{1, 2, 1, 1}, 1)).To(BeFalse())
+		Expect(sameIDs([]int{1}, 1)).To(BeFalse())
+	})
+
+	It("reports failing to query the target", func() {
+		useProc(fakePtraceProc("", os.Getuid(), 1))
+		ok, findings := CanPtrace(666)
+		Expect(ok).To(BeFalse())
+		Expect(findings.Messages()).To(ConsistOf(ContainSubstring("cannot query process 666")))
+	})
+
+	It("disallows attaching in YAMA scope 3", func() {
+		useProc(fakePtraceProc("3", os.Getuid(), 1))
+		ok, findings := CanPtrace(42)
+		Expect(ok).To(BeFalse())
+		Expect(findings.Messages()).To(ConsistOf(ContainSubstring("scope 3")))
+	})
+
+	It("can ptrace ourselves", func() {
+		capstest.RequireEffective(GinkgoT(), CAP_SYS_PTRACE)
+		ok, findings := CanPtrace(os.Getpid())
+		Expect(ok).To(BeTrue(), "%v", findings)
+	})
+
+	DescribeTable("checks credentials and YAMA scope without CAP_SYS_PTRACE",
+		func(scope string, uid int, ppid int, expected bool, finding string) {
+			if inUserNamespaceUnlessRoot() {
+				return
+			}
+			useProc(fakePtraceProc(scope, uid, ppid))
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				// Never unlock this thread, so that it gets thrown away when
+				// this Go routine finishes.
+				runtime.LockOSThread()
+				taskcaps := Successful(OfThisTask())
+				taskcaps.Effective.Drop(CAP_SYS_PTRACE)
+				Expect(SetForThisTask(taskcaps)).To(Succeed())
+				ok, findings := CanPtrace(42)
+				Expect(ok).To(Equal(expected), "%v", findings)
+				Expect(findings.Messages()).To(ContainElement(ContainSubstring(finding)))
+			}()
+			<-done
+		},
+		Entry("no YAMA, same user", "", 0, 1, true, "same user"),
+		Entry("no YAMA, different user", "", 1000, 1, false, "different user"),
+		Entry("scope 0, same user", "0", 0, 1, true, "same user"),
+		Entry("scope 1, non-descendant", "1", 0, 1, false, "only to descendants"),
+		Entry("scope 2", "2", 0, 1, false, "scope 2"),
+	)
+
+	It("recognizes descendants", func() {
+		useProc(fakePtraceProc("1", 0, 41))
+		Expect(isDescendant(42, 41)).To(BeTrue())
+		Expect(isDescendant(42, 40)).To(BeFalse())
+		Expect(isDescendant(666, 41)).To(BeFalse())
+	})
+
+})
@@ -0,0 +1,89 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// ErrGoexit is returned when a function run with raised capabilities or on a
+// worker calls [runtime.Goexit] instead of returning.
+var ErrGoexit = errors.New("runtime.Goexit called instead of returning")
+
+// Raised runs fn with the specified capabilities raised in the effective set,
+// returning the error returned by fn, or an error if the capabilities cannot be
+// raised, such as when they aren't in the permitted set or aren't supported by
+// the kernel. Raised blocks until fn
+// has finished.
+//
+// Raised runs fn on a separate Go routine locked to its own OS-level thread,
+// which gets thrown away afterwards, so the raised capabilities can never leak
+// to other Go routines. In consequence, fn must not spawn Go routines relying
+// on the raised capabilities. Any panic inside fn is propagated to the caller
+// of Raised, and if fn calls [runtime.Goexit], Raised returns [ErrGoexit]. In
+// both cases, the capabilities of the caller are never affected, as they never
+// get raised on the caller's thread in the first place.
+func Raised(fn func() error, capno int, morecapnos ...int) error {
+	type result struct {
+		err   error
+		panic interface{}
+	}
+	done := make(chan result)
+	go func() {
+		// Never unlock this thread, so that it gets thrown away when this Go
+		// routine finishes.
+		runtime.LockOSThread()
+		var res result
+		goexit := false
+		defer func() {
+			if r := recover(); r != nil {
+				res.panic = r
+			} else if goexit {
+				res.err = ErrGoexit
+			}
+			discardThisTask()
+			done <- res
+		}()
+		if _, res.err = AddEffectiveCaps(capno, morecapnos...); res.err != nil {
+			return
+		}
+		// The kernel silently ignores capabilities it doesn't support, so
+		// make sure that all capabilities have been raised.
+		var taskcaps TaskCapabilities
+		if taskcaps, res.err = OfThisTask(); res.err != nil {
+			return
+		}
+		missing := NewCapabilitiesSet()
+		missing.Add(capno, morecapnos...)
+		missing.dropSet(taskcaps.Effective)
+		if names := missing.Names(); len(names) != 0 {
+			res.err = fmt.Errorf("capabilities not raised: %s", strings.Join(names, ", "))
+			return
+		}
+		goexit = true
+		res.err = fn()
+		goexit = false
+	}()
+	res := <-done
+	if res.panic != nil {
+		panic(res.panic)
+	}
+	return res.err
+}
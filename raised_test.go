@@ -0,0 +1,84 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"errors"
+	"runtime"
+
+	"github.com/thediveo/caps/capstest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("raised capabilities", func() {
+
+	It("runs with raised capabilities on a throw-away thread", func() {
+		capstest.RequirePermitted(GinkgoT(), CAP_NET_RAW)
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			// Never unlock this thread, so that it gets thrown away when this
+			// Go routine finishes.
+			runtime.LockOSThread()
+			taskcaps := Successful(OfThisTask())
+			taskcaps.Effective.Drop(CAP_NET_RAW)
+			Expect(SetForThisTask(taskcaps)).To(Succeed())
+
+			Expect(Raised(func() error {
+				if !Successful(OfThisTask()).Effective.Has(CAP_NET_RAW) {
+					return errors.New("CAP_NET_RAW not raised")
+				}
+				return nil
+			}, CAP_NET_RAW)).To(Succeed())
+			Expect(Successful(OfThisTask()).Effective.Has(CAP_NET_RAW)).To(BeFalse())
+		}()
+		<-done
+	})
+
+	It("returns errors", func() {
+		Expect(Raised(func() error { return nil }, 63)).To(HaveOccurred())
+		capstest.RequirePermitted(GinkgoT(), CAP_CHOWN)
+		Expect(Raised(func() error { return errors.New("D'OH!") }, CAP_CHOWN)).To(
+			MatchError("D'OH!"))
+	})
+
+	It("propagates panics", func() {
+		capstest.RequirePermitted(GinkgoT(), CAP_CHOWN)
+		Expect(func() {
+			_ = Raised(func() error { panic("D'OH!") }, CAP_CHOWN)
+		}).To(PanicWith("D'OH!"))
+	})
+
+	It("raises capabilities in an unprivileged user namespace", func() {
+		if !capstest.InChild() {
+			out := capstest.RunInUserNamespace(GinkgoT(),
+				"-ginkgo.focus=raises capabilities in an unprivileged user namespace")
+			Expect(string(out)).To(ContainSubstring("1 Passed"))
+			return
+		}
+		capstest.RequirePermitted(GinkgoT(), CAP_SYS_ADMIN)
+		Expect(Raised(func() error {
+			if !Successful(OfThisTask()).Effective.Has(CAP_SYS_ADMIN) {
+				return errors.New("CAP_SYS_ADMIN not raised")
+			}
+			return nil
+		}, CAP_SYS_ADMIN)).To(Succeed())
+	})
+
+})
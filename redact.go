@@ -0,0 +1,68 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import "regexp"
+
+// RedactedText replaces sensitive details, such as paths, in redacted
+// reports.
+const RedactedText = "<redacted>"
+
+// pathPattern matches absolute paths in free text, such as in error messages,
+// but not slashes inside words, nor trailing colons and periods.
+var pathPattern = regexp.MustCompile(`(^|[\s"'(=\[])/(?:[^\s"'),\]]*[^\s"'),\]:.])?`)
+
+// redactPaths returns the text with all absolute paths redacted.
+func redactPaths(text string) string {
+	return pathPattern.ReplaceAllString(text, "${1}"+RedactedText)
+}
+
+// Redacted returns a copy of these findings with paths in messages and
+// remediation hints redacted, while preserving the capability data, so that
+// the findings can be shared externally, such as with vendor support.
+func (f Findings) Redacted() Findings {
+	if f == nil {
+		return nil
+	}
+	redacted := make(Findings, len(f))
+	for idx, finding := range f {
+		finding.Message = redactPaths(finding.Message)
+		finding.Remediation = redactPaths(finding.Remediation)
+		redacted[idx] = finding
+	}
+	return redacted
+}
+
+// Redacted returns a copy of this inspection with the executable path and
+// paths in the reasons for skipped aspects redacted, while preserving the
+// capability data, so that the inspection can be shared externally, such as
+// with vendor support.
+func (i TaskInspection) Redacted() TaskInspection {
+	if i.Executable != "" {
+		i.Executable = RedactedText
+	}
+	if i.Skipped != nil {
+		skipped := make([]Degradation, len(i.Skipped))
+		for idx, d := range i.Skipped {
+			d.Reason = redactPaths(d.Reason)
+			d.err = nil
+			skipped[idx] = d
+		}
+		i.Skipped = skipped
+	}
+	return i
+}
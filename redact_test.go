@@ -0,0 +1,75 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("redaction", func() {
+
+	DescribeTable("redacts paths in text",
+		func(text, expected string) {
+			Expect(redactPaths(text)).To(Equal(expected))
+		},
+		Entry("no paths", "CAP_NET_RAW is not effective", "CAP_NET_RAW is not effective"),
+		Entry("error", "open /proc/42/status: permission denied",
+			"open <redacted>: permission denied"),
+		Entry("leading path", "/usr/bin/foo has no file capabilities",
+			"<redacted> has no file capabilities"),
+		Entry("quoted path", `cannot stat "/home/jdoe/secret bar"`, `cannot stat "<redacted> bar"`),
+		Entry("slash inside words", "TCP/IP and read/write", "TCP/IP and read/write"),
+	)
+
+	It("redacts findings", func() {
+		findings := Findings{
+			errorFinding("target", "cannot query process %d: %s", 42, "open /proc/42/status: no such file"),
+			infoFinding("CAP_SYS_PTRACE", "CAP_SYS_PTRACE is effective").
+				withRemediation("move /opt/foo to another filesystem"),
+		}
+		redacted := findings.Redacted()
+		Expect(redacted.Messages()).To(Equal([]string{
+			"cannot query process 42: open <redacted>: no such file",
+			"CAP_SYS_PTRACE is effective",
+		}))
+		Expect(redacted[1].Remediation).To(Equal("move <redacted> to another filesystem"))
+		Expect(findings[0].Message).To(ContainSubstring("/proc/42/status"))
+		Expect(Findings(nil).Redacted()).To(BeNil())
+	})
+
+	It("redacts task inspections", func() {
+		insp := TaskInspection{
+			TID:          42,
+			Capabilities: TaskCapabilities{Effective: capset(CAP_NET_RAW)},
+			Executable:   "/usr/local/bin/secret-agent",
+			Skipped: []Degradation{
+				{Aspect: InspectState, Capability: "CAP_SYS_PTRACE", Reason: "open /proc/42/status: permission denied",
+					err: errors.New("open /proc/42/status: permission denied")},
+			},
+		}
+		redacted := insp.Redacted()
+		Expect(redacted.Executable).To(Equal(RedactedText))
+		Expect(redacted.Capabilities.Effective.Names()).To(ConsistOf("CAP_NET_RAW"))
+		Expect(redacted.Skipped).To(ConsistOf(Degradation{
+			Aspect: InspectState, Capability: "CAP_SYS_PTRACE", Reason: "open <redacted>: permission denied",
+		}))
+		Expect(redacted.Err()).To(MatchError("skipped inspecting state: open <redacted>: permission denied"))
+		Expect(insp.Skipped[0].Reason).To(ContainSubstring("/proc/42"))
+	})
+
+})
@@ -0,0 +1,43 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+/*
+Package render provides a shared output rendering layer for command line tools
+built on the caps package, rendering caps reports as tables (normal and wide),
+JSON, or YAML, so that tools produce consistent and scriptable output:
+
+	var format render.Format = render.Table
+	flag.Var(&format, "output", "output format: table, wide, json, or yaml")
+	...
+	err := render.Renderer{Format: format}.Render(os.Stdout, render.Findings(findings))
+
+Values to be rendered as tables must implement [Tabular]; adapters for
+[caps.Findings], [caps.TaskInspection], and [caps.StateDiff] reports are
+provided, as well as [Matrix] for rendering which tasks hold which
+capabilities. Table columns are aligned taking wide characters into account;
+long tables can be paged using [Renderer.PageSize] and [Renderer.PageBreak].
+
+# Colors
+
+Tables can optionally be styled for terminals by setting a [Styler]; the [ANSI]
+styler highlights error and warning findings, overloaded and unused
+capabilities (see [caps.StatusOf]), as well as added and removed capabilities.
+[TerminalStyler] returns the ANSI styler only when writing to a terminal and the
+NO_COLOR environment variable isn't set:
+
+	r := render.Renderer{Format: format, Styler: render.TerminalStyler(os.Stdout)}
+*/
+package render
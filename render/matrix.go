@@ -0,0 +1,108 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/thediveo/caps"
+)
+
+// Matrix renders which tasks hold which capabilities, with a row per task and
+// a column per capability. Cells are "e" for effective capabilities and "p"
+// for capabilities that are only permitted, but not effective; in wide mode,
+// cells list all of "e", "p", and "i" for effective, permitted, and
+// inheritable capabilities, and the task executables are rendered too.
+// Capability columns are headed by the capability names without their “CAP_”
+// prefix, in order to keep the matrix compact.
+type Matrix struct {
+	Tasks []caps.TaskInspection `json:"tasks" yaml:"tasks"`
+	// Capabilities to render columns for; if nil, columns are rendered for
+	// all capabilities permitted to at least one task.
+	Capabilities caps.CapabilitiesSet `json:"capabilities,omitempty" yaml:"capabilities,omitempty"`
+}
+
+// CapabilitiesMatrix returns the task inspections for rendering as a matrix
+// of the permitted capabilities of the tasks.
+func CapabilitiesMatrix(inspections ...caps.TaskInspection) Matrix {
+	return Matrix{Tasks: inspections}
+}
+
+// columns returns the set of capabilities to render columns for.
+func (m Matrix) columns() caps.CapabilitiesSet {
+	if m.Capabilities != nil {
+		return m.Capabilities
+	}
+	held := caps.NewCapabilitiesSet()
+	for _, task := range m.Tasks {
+		for _, capno := range task.Capabilities.Permitted.Numbers() {
+			held.Add(capno)
+		}
+		for _, capno := range task.Capabilities.Effective.Numbers() {
+			held.Add(capno)
+		}
+	}
+	return held
+}
+
+// Header returns the column headers.
+func (m Matrix) Header(wide bool) []string {
+	header := []string{"TID"}
+	if wide {
+		header = append(header, "EXECUTABLE")
+	}
+	for _, name := range m.columns().Names() {
+		header = append(header, strings.TrimPrefix(name, "CAP_"))
+	}
+	return header
+}
+
+// Rows returns a row per task.
+func (m Matrix) Rows(wide bool) [][]string {
+	columns := m.columns().Numbers()
+	rows := make([][]string, 0, len(m.Tasks))
+	for _, task := range m.Tasks {
+		row := []string{fmt.Sprint(task.TID)}
+		if wide {
+			row = append(row, task.Executable)
+		}
+		tc := task.Capabilities
+		for _, capno := range columns {
+			var cell string
+			switch {
+			case wide:
+				if tc.Effective.Has(capno) {
+					cell += "e"
+				}
+				if tc.Permitted.Has(capno) {
+					cell += "p"
+				}
+				if tc.Inheritable.Has(capno) {
+					cell += "i"
+				}
+			case tc.Effective.Has(capno):
+				cell = "e"
+			case tc.Permitted.Has(capno):
+				cell = "p"
+			}
+			row = append(row, cell)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
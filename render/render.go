@@ -0,0 +1,354 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/thediveo/caps"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is an output format.
+type Format string
+
+// Supported output formats.
+const (
+	Table Format = "table" // human-readable table
+	Wide  Format = "wide"  // human-readable table with additional columns
+	JSON  Format = "json"
+	YAML  Format = "yaml"
+)
+
+var formats = []Format{Table, Wide, JSON, YAML}
+
+// ParseFormat returns the output format with the specified name, or an error
+// if there is no such format.
+func ParseFormat(name string) (Format, error) {
+	for _, format := range formats {
+		if string(format) == name {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("invalid output format %q", name)
+}
+
+// String returns the name of the output format.
+func (f Format) String() string { return string(f) }
+
+// Set sets the output format from its name, so that *Format implements
+// [flag.Value] for command line flags such as "--output json".
+func (f *Format) Set(name string) error {
+	format, err := ParseFormat(name)
+	if err != nil {
+		return err
+	}
+	*f = format
+	return nil
+}
+
+// Tabular is implemented by values that can be rendered as tables.
+type Tabular interface {
+	// Header returns the column headers, including the additional columns
+	// in wide mode.
+	Header(wide bool) []string
+	// Rows returns the table rows, including the additional columns in wide
+	// mode.
+	Rows(wide bool) [][]string
+}
+
+// Renderer renders values in a particular output format.
+type Renderer struct {
+	Format Format
+	Redact bool   // redact paths in reports, see [caps.Findings.Redacted]
+	Styler Styler // optional styling of table cells, such as [ANSI]
+
+	// PageSize optionally limits the number of rows per table page; the
+	// column headers are repeated on each page. Columns are aligned across
+	// all pages.
+	PageSize int
+	// PageBreak is optionally called before rendering each table page except
+	// the first, such as for waiting on user input or emitting a form feed.
+	PageBreak func(w io.Writer, page int) error
+}
+
+// Render renders the specified value to the writer in the renderer's output
+// format. Table formats require the value to implement [Tabular]. Render
+// returns an error if the value cannot be rendered in the output format.
+func (r Renderer) Render(w io.Writer, v interface{}) error {
+	if r.Redact {
+		v = redacted(v)
+	}
+	switch r.Format {
+	case Table, Wide:
+		t, ok := v.(Tabular)
+		if !ok {
+			return fmt.Errorf("cannot render %T as table", v)
+		}
+		return r.renderTable(w, t)
+	case JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case YAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+	return fmt.Errorf("invalid output format %q", string(r.Format))
+}
+
+// renderTable renders the tabular value with aligned columns, rendering empty
+// cells as "-". Columns are aligned on the display widths of the unstyled
+// cells, taking wide characters into account, so that neither wide characters
+// nor escape sequences of a styler throw off the alignment.
+func (r Renderer) renderTable(w io.Writer, t Tabular) error {
+	wide := r.Format == Wide
+	header := t.Header(wide)
+	rows := t.Rows(wide)
+	widths := []int{}
+	for _, row := range append([][]string{header}, rows...) {
+		for idx, cell := range row {
+			if cell == "" {
+				cell = "-"
+				row[idx] = cell
+			}
+			if idx == len(widths) {
+				widths = append(widths, 0)
+			}
+			if n := displayWidth(cell); n > widths[idx] {
+				widths[idx] = n
+			}
+		}
+	}
+	pagesize := r.PageSize
+	if pagesize <= 0 {
+		pagesize = len(rows)
+	}
+	for page, first := 0, 0; page == 0 || first < len(rows); page, first = page+1, first+pagesize {
+		if page > 0 && r.PageBreak != nil {
+			if err := r.PageBreak(w, page); err != nil {
+				return err
+			}
+		}
+		last := first + pagesize
+		if last > len(rows) {
+			last = len(rows)
+		}
+		if err := r.renderRow(w, header, header, widths, false); err != nil {
+			return err
+		}
+		for _, row := range rows[first:last] {
+			if err := r.renderRow(w, header, row, widths, r.Styler != nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// renderRow renders a single table row with its cells padded to the
+// specified column widths, optionally styling the cells.
+func (r Renderer) renderRow(w io.Writer, header []string, row []string, widths []int, styled bool) error {
+	var line strings.Builder
+	for idx, cell := range row {
+		padding := 0
+		if idx < len(row)-1 {
+			padding = widths[idx] - displayWidth(cell) + 2
+		}
+		if styled && idx < len(header) {
+			cell = r.Styler.StyleCell(header[idx], cell)
+		}
+		line.WriteString(cell)
+		line.WriteString(strings.Repeat(" ", padding))
+	}
+	line.WriteByte('\n')
+	_, err := io.WriteString(w, line.String())
+	return err
+}
+
+// redacted returns a redacted copy of the value, if it is a caps report.
+func redacted(v interface{}) interface{} {
+	switch v := v.(type) {
+	case FindingsTable:
+		return FindingsTable(caps.Findings(v).Redacted())
+	case caps.Findings:
+		return v.Redacted()
+	case InspectionsTable:
+		return InspectionsTable(redactedInspections(v))
+	case []caps.TaskInspection:
+		return redactedInspections(v)
+	case caps.TaskInspection:
+		return v.Redacted()
+	case Matrix:
+		v.Tasks = redactedInspections(v.Tasks)
+		return v
+	}
+	return v
+}
+
+func redactedInspections(inspections []caps.TaskInspection) []caps.TaskInspection {
+	redacted := make([]caps.TaskInspection, len(inspections))
+	for idx, insp := range inspections {
+		redacted[idx] = insp.Redacted()
+	}
+	return redacted
+}
+
+// FindingsTable renders findings, with their remediation hints in wide mode.
+// It marshals to JSON and YAML the same as [caps.Findings].
+type FindingsTable caps.Findings
+
+// Findings returns the findings for rendering, including as tables.
+func Findings(findings caps.Findings) FindingsTable { return FindingsTable(findings) }
+
+// Header returns the column headers.
+func (t FindingsTable) Header(wide bool) []string {
+	header := []string{"SEVERITY", "SUBJECT", "MESSAGE"}
+	if wide {
+		header = append(header, "REMEDIATION")
+	}
+	return header
+}
+
+// Rows returns a row per finding.
+func (t FindingsTable) Rows(wide bool) [][]string {
+	rows := make([][]string, 0, len(t))
+	for _, finding := range t {
+		row := []string{finding.Severity.String(), finding.Subject, finding.Message}
+		if wide {
+			row = append(row, finding.Remediation)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// MarshalJSON marshals the findings the same as [caps.Findings].
+func (t FindingsTable) MarshalJSON() ([]byte, error) {
+	return caps.Findings(t).MarshalJSON()
+}
+
+// InspectionsTable renders task inspections, with their permitted and
+// inheritable capabilities, as well as the skipped aspects, in wide mode. TIDs
+// are followed by the TIDs inside the tasks' own PID namespaces in parentheses,
+// if different.
+type InspectionsTable []caps.TaskInspection
+
+// Inspections returns the task inspections for rendering, including as
+// tables.
+func Inspections(inspections ...caps.TaskInspection) InspectionsTable {
+	return InspectionsTable(inspections)
+}
+
+// Header returns the column headers.
+func (t InspectionsTable) Header(wide bool) []string {
+	if wide {
+		return []string{"TID", "EFFECTIVE", "PERMITTED", "INHERITABLE", "EXECUTABLE", "SKIPPED"}
+	}
+	return []string{"TID", "EFFECTIVE", "EXECUTABLE", "SKIPPED"}
+}
+
+// Rows returns a row per task inspection.
+func (t InspectionsTable) Rows(wide bool) [][]string {
+	rows := make([][]string, 0, len(t))
+	for _, insp := range t {
+		skipped := make([]string, 0, len(insp.Skipped))
+		for _, d := range insp.Skipped {
+			if wide && d.Capability != "" {
+				skipped = append(skipped, string(d.Aspect)+" (needs "+d.Capability+")")
+				continue
+			}
+			skipped = append(skipped, string(d.Aspect))
+		}
+		tid := fmt.Sprint(insp.TID)
+		if insp.State != nil {
+			if nstid, _, ok := insp.State.InnermostIDs(); ok && nstid != insp.TID {
+				tid += fmt.Sprintf(" (%d)", nstid)
+			}
+		}
+		if wide {
+			rows = append(rows, []string{tid,
+				insp.Capabilities.Effective.String(),
+				insp.Capabilities.Permitted.String(),
+				insp.Capabilities.Inheritable.String(),
+				insp.Executable,
+				strings.Join(skipped, ", ")})
+			continue
+		}
+		rows = append(rows, []string{tid,
+			insp.Capabilities.Effective.String(),
+			insp.Executable,
+			strings.Join(skipped, ", ")})
+	}
+	return rows
+}
+
+// DiffTable renders the differences between two task capabilities-related
+// states, with a row per changed aspect; in wide mode, unchanged aspects are
+// rendered too. It marshals to JSON and YAML the same as [caps.StateDiff].
+type DiffTable caps.StateDiff
+
+// Diff returns the state differences for rendering, including as tables.
+func Diff(diff caps.StateDiff) DiffTable { return DiffTable(diff) }
+
+// Header returns the column headers.
+func (t DiffTable) Header(wide bool) []string {
+	return []string{"ASPECT", "ADDED", "REMOVED"}
+}
+
+// Rows returns a row per changed aspect, or per aspect in wide mode.
+func (t DiffTable) Rows(wide bool) [][]string {
+	rows := [][]string{}
+	for _, set := range []struct {
+		name string
+		diff caps.SetDiff
+	}{
+		{"effective", t.Effective},
+		{"permitted", t.Permitted},
+		{"inheritable", t.Inheritable},
+		{"bounding", t.Bounding},
+		{"ambient", t.Ambient},
+	} {
+		if wide || !set.diff.Empty() {
+			rows = append(rows, []string{set.name, set.diff.Added.String(), set.diff.Removed.String()})
+		}
+	}
+	if wide || t.SecurebitsSet != 0 || t.SecurebitsCleared != 0 {
+		rows = append(rows, []string{"securebits",
+			strings.Join(caps.SecurebitsNames(t.SecurebitsSet), ", "),
+			strings.Join(caps.SecurebitsNames(t.SecurebitsCleared), ", ")})
+	}
+	if wide || t.NoNewPrivsChanged {
+		row := []string{"no_new_privs", "", ""}
+		if t.NoNewPrivsChanged {
+			if t.NoNewPrivs {
+				row[1] = "no_new_privs"
+			} else {
+				row[2] = "no_new_privs"
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
@@ -0,0 +1,251 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package render
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/thediveo/caps"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var findings = caps.Findings{
+	{Severity: caps.SeverityError, Subject: "mount", Message: "binary /opt/foo resides on a filesystem mounted nosuid",
+		Remediation: "move the binary"},
+	{Severity: caps.SeverityInfo, Subject: "CAP_SYS_PTRACE", Message: "CAP_SYS_PTRACE is not effective"},
+}
+
+func capsOf(capnos ...int) caps.CapabilitiesSet {
+	set := caps.NewCapabilitiesSet()
+	for _, capno := range capnos {
+		set.Add(capno)
+	}
+	return set
+}
+
+func render(r Renderer, v interface{}) string {
+	var buff bytes.Buffer
+	Expect(r.Render(&buff, v)).To(Succeed())
+	return buff.String()
+}
+
+var _ = Describe("rendering", func() {
+
+	It("parses output formats from command line flags", func() {
+		Expect(ParseFormat("wide")).To(Equal(Wide))
+		Expect(ParseFormat("xml")).Error().To(HaveOccurred())
+
+		format := Table
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		fs.Var(&format, "output", "output format")
+		Expect(fs.Parse([]string{"--output", "yaml"})).To(Succeed())
+		Expect(format).To(Equal(YAML))
+		Expect(format.String()).To(Equal("yaml"))
+		Expect(fs.Parse([]string{"--output", "xml"})).NotTo(Succeed())
+	})
+
+	It("renders findings as tables", func() {
+		Expect(render(Renderer{Format: Table}, Findings(findings))).To(Equal(
+			"SEVERITY  SUBJECT         MESSAGE\n" +
+				"error     mount           binary /opt/foo resides on a filesystem mounted nosuid\n" +
+				"info      CAP_SYS_PTRACE  CAP_SYS_PTRACE is not effective\n"))
+		Expect(render(Renderer{Format: Wide, Redact: true}, Findings(findings))).To(Equal(
+			"SEVERITY  SUBJECT         MESSAGE                                                   REMEDIATION\n" +
+				"error     mount           binary <redacted> resides on a filesystem mounted nosuid  move the binary\n" +
+				"info      CAP_SYS_PTRACE  CAP_SYS_PTRACE is not effective                           -\n"))
+	})
+
+	It("renders findings as JSON and YAML", func() {
+		Expect(render(Renderer{Format: JSON}, Findings(findings))).To(MatchJSON(
+			Successful(findings.MarshalJSON())))
+		Expect(render(Renderer{Format: JSON}, Findings(nil))).To(MatchJSON(`[]`))
+		Expect(render(Renderer{Format: YAML, Redact: true}, findings)).To(MatchYAML(`
+- severity: error
+  subject: mount
+  message: binary <redacted> resides on a filesystem mounted nosuid
+  remediation: move the binary
+- severity: info
+  subject: CAP_SYS_PTRACE
+  message: CAP_SYS_PTRACE is not effective
+  remediation: ""
+`))
+	})
+
+	It("renders task inspections", func() {
+		effective := caps.NewCapabilitiesSet()
+		effective.Add(caps.CAP_NET_RAW)
+		inspections := Inspections(caps.TaskInspection{
+			TID:          42,
+			Capabilities: caps.TaskCapabilities{Effective: effective, Permitted: effective},
+			Executable:   "/usr/bin/foo",
+			Skipped: []caps.Degradation{
+				{Aspect: caps.InspectPidfd, Reason: "nope"},
+				{Aspect: caps.InspectState, Capability: "CAP_SYS_PTRACE", Reason: "nope"},
+			},
+		})
+		Expect(render(Renderer{Format: Table}, inspections)).To(Equal(
+			"TID  EFFECTIVE    EXECUTABLE    SKIPPED\n" +
+				"42   CAP_NET_RAW  /usr/bin/foo  pidfd, state\n"))
+		Expect(render(Renderer{Format: Wide, Redact: true}, inspections)).To(Equal(
+			"TID  EFFECTIVE    PERMITTED    INHERITABLE  EXECUTABLE  SKIPPED\n" +
+				"42   CAP_NET_RAW  CAP_NET_RAW  -            <redacted>  pidfd, state (needs CAP_SYS_PTRACE)\n"))
+	})
+
+	It("renders TIDs inside PID namespaces", func() {
+		inspections := Inspections(
+			caps.TaskInspection{TID: 42, State: &caps.State{NSpid: []int{42, 1}, NStgid: []int{42, 1}}},
+			caps.TaskInspection{TID: 666, State: &caps.State{NSpid: []int{666}, NStgid: []int{666}}})
+		Expect(render(Renderer{Format: Table}, inspections)).To(Equal(
+			"TID     EFFECTIVE  EXECUTABLE  SKIPPED\n" +
+				"42 (1)  -          -           -\n" +
+				"666     -          -           -\n"))
+	})
+
+	It("renders state differences", func() {
+		before := caps.State{TaskCapabilities: caps.TaskCapabilities{Effective: capsOf(caps.CAP_NET_RAW), Permitted: capsOf(caps.CAP_NET_RAW)}}
+		after := caps.State{
+			TaskCapabilities: caps.TaskCapabilities{Effective: capsOf(caps.CAP_SYS_ADMIN), Permitted: capsOf(caps.CAP_NET_RAW)},
+			NoNewPrivs:       true,
+		}
+		diff := Diff(caps.DiffStates(before, after))
+		Expect(render(Renderer{Format: Table}, diff)).To(Equal(
+			"ASPECT        ADDED          REMOVED\n" +
+				"effective     CAP_SYS_ADMIN  CAP_NET_RAW\n" +
+				"no_new_privs  no_new_privs   -\n"))
+		Expect(render(Renderer{Format: Wide}, diff)).To(ContainSubstring(
+			"permitted     -              -\n"))
+		Expect(render(Renderer{Format: Table}, Diff(caps.DiffStates(after, after)))).To(Equal(
+			"ASPECT  ADDED  REMOVED\n"))
+	})
+
+	It("styles table cells after aligning columns", func() {
+		Expect(render(Renderer{Format: Table, Styler: ANSI{}}, Findings(findings))).To(Equal(
+			"SEVERITY  SUBJECT         MESSAGE\n" +
+				"\x1b[31merror\x1b[0m     mount           binary /opt/foo resides on a filesystem mounted nosuid\n" +
+				"info      CAP_SYS_PTRACE  CAP_SYS_PTRACE is not effective\n"))
+
+		inspections := Inspections(caps.TaskInspection{
+			TID:          42,
+			Capabilities: caps.TaskCapabilities{Effective: capsOf(caps.CAP_NET_BROADCAST, caps.CAP_SYS_ADMIN, caps.CAP_NET_RAW)},
+		})
+		styled := render(Renderer{Format: Table, Styler: ANSI{}}, inspections)
+		Expect(styled).To(ContainSubstring(
+			"\x1b[2mCAP_NET_BROADCAST\x1b[0m, CAP_NET_RAW, \x1b[1m\x1b[31mCAP_SYS_ADMIN\x1b[0m  -"))
+		Expect(regexp.MustCompile("\x1b\\[[0-9]+m").ReplaceAllString(styled, "")).To(Equal(
+			render(Renderer{Format: Table}, inspections)))
+
+		diff := Diff(caps.DiffStates(
+			caps.State{TaskCapabilities: caps.TaskCapabilities{Effective: capsOf(caps.CAP_CHOWN)}},
+			caps.State{TaskCapabilities: caps.TaskCapabilities{Effective: capsOf(caps.CAP_NET_RAW)}}))
+		Expect(render(Renderer{Format: Table, Styler: ANSI{}}, diff)).To(Equal(
+			"ASPECT     ADDED        REMOVED\n" +
+				"effective  \x1b[33mCAP_NET_RAW\x1b[0m  \x1b[32mCAP_CHOWN\x1b[0m\n"))
+	})
+
+	It("styles only terminal output", func() {
+		Expect(TerminalStyler(&bytes.Buffer{})).To(BeNil())
+		f := Successful(os.CreateTemp("", "render-*"))
+		defer os.Remove(f.Name())
+		defer f.Close()
+		Expect(TerminalStyler(f)).To(BeNil())
+
+		tty, err := os.OpenFile("/dev/null", os.O_WRONLY, 0)
+		Expect(err).NotTo(HaveOccurred())
+		defer tty.Close()
+		if _, ok := os.LookupEnv("NO_COLOR"); !ok {
+			Expect(TerminalStyler(tty)).To(Equal(ANSI{}))
+		}
+		GinkgoT().Setenv("NO_COLOR", "")
+		Expect(TerminalStyler(tty)).To(BeNil())
+	})
+
+	It("renders capability matrices", func() {
+		matrix := CapabilitiesMatrix(
+			caps.TaskInspection{TID: 1, Executable: "/sbin/init", Capabilities: caps.TaskCapabilities{
+				Effective: capsOf(caps.CAP_SYS_ADMIN), Permitted: capsOf(caps.CAP_SYS_ADMIN, caps.CAP_NET_RAW)}},
+			caps.TaskInspection{TID: 42, Executable: "/usr/bin/ping", Capabilities: caps.TaskCapabilities{
+				Effective: capsOf(caps.CAP_NET_RAW), Permitted: capsOf(caps.CAP_NET_RAW), Inheritable: capsOf(caps.CAP_NET_RAW)}})
+		Expect(render(Renderer{Format: Table}, matrix)).To(Equal(
+			"TID  NET_RAW  SYS_ADMIN\n" +
+				"1    p        e\n" +
+				"42   e        -\n"))
+		Expect(render(Renderer{Format: Wide, Redact: true}, matrix)).To(Equal(
+			"TID  EXECUTABLE  NET_RAW  SYS_ADMIN\n" +
+				"1    <redacted>  p        ep\n" +
+				"42   <redacted>  epi      -\n"))
+
+		matrix.Capabilities = capsOf(caps.CAP_CHOWN, caps.CAP_NET_RAW)
+		Expect(render(Renderer{Format: Table}, matrix)).To(Equal(
+			"TID  CHOWN  NET_RAW\n" +
+				"1    -      p\n" +
+				"42   -      e\n"))
+
+		styled := render(Renderer{Format: Table, Styler: ANSI{}}, CapabilitiesMatrix(matrix.Tasks...))
+		Expect(styled).To(ContainSubstring("\x1b[1m\x1b[31me\x1b[0m\n"))
+		Expect(styled).To(HavePrefix("TID  NET_RAW  SYS_ADMIN\n"))
+	})
+
+	It("aligns wide characters", func() {
+		inspections := Inspections(
+			caps.TaskInspection{TID: 1, Executable: "/opt/日本/bin"},
+			caps.TaskInspection{TID: 2, Executable: "/opt/cafe\u0301/bin"})
+		Expect(render(Renderer{Format: Table}, inspections)).To(Equal(
+			"TID  EFFECTIVE  EXECUTABLE     SKIPPED\n" +
+				"1    -          /opt/日本/bin  -\n" +
+				"2    -          /opt/cafe\u0301/bin  -\n"))
+	})
+
+	It("pages tables", func() {
+		pages := []int{}
+		r := Renderer{Format: Table, PageSize: 2, PageBreak: func(w io.Writer, page int) error {
+			pages = append(pages, page)
+			_, err := io.WriteString(w, "\f")
+			return err
+		}}
+		inspections := Inspections(
+			caps.TaskInspection{TID: 1}, caps.TaskInspection{TID: 2}, caps.TaskInspection{TID: 1000})
+		Expect(render(r, inspections)).To(Equal(
+			"TID   EFFECTIVE  EXECUTABLE  SKIPPED\n" +
+				"1     -          -           -\n" +
+				"2     -          -           -\n" +
+				"\f" +
+				"TID   EFFECTIVE  EXECUTABLE  SKIPPED\n" +
+				"1000  -          -           -\n"))
+		Expect(pages).To(ConsistOf(1))
+
+		Expect(render(r, Inspections())).To(Equal(
+			"TID  EFFECTIVE  EXECUTABLE  SKIPPED\n"))
+
+		r.PageBreak = func(io.Writer, int) error { return errors.New("user quit") }
+		Expect(r.Render(io.Discard, inspections)).To(MatchError("user quit"))
+	})
+
+	It("rejects unrenderable values", func() {
+		Expect(Renderer{Format: Table}.Render(io.Discard, 42)).To(
+			MatchError(ContainSubstring("cannot render int as table")))
+		Expect(Renderer{Format: "xml"}.Render(io.Discard, 42)).To(
+			MatchError(ContainSubstring("invalid output format")))
+	})
+
+})
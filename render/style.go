@@ -0,0 +1,129 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package render
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/thediveo/caps"
+)
+
+// Styler styles table cells for terminal output, such as highlighting risky
+// capabilities, drift, and policy violations. Stylers must not change the
+// visible text of cells, as table columns get aligned on the unstyled cells.
+type Styler interface {
+	// StyleCell returns the cell in the column with the specified header,
+	// styled for output.
+	StyleCell(header, cell string) string
+}
+
+// ANSI escape sequences used by [ANSI].
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiFaint  = "\x1b[2m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+)
+
+// ANSI styles table cells using ANSI terminal color escape sequences, based on
+// the severities of findings and the status of capabilities (see
+// [caps.StatusOf]):
+//   - error findings in red, warning findings in yellow,
+//   - overloaded capabilities, such as CAP_SYS_ADMIN, in bold red,
+//   - unused capabilities faint,
+//   - added capabilities (drift) in yellow and removed capabilities in green,
+//   - held capabilities in [Matrix] columns the same as their names.
+type ANSI struct{}
+
+var _ Styler = ANSI{}
+
+// StyleCell returns the cell with ANSI color escape sequences, depending on
+// its column header and contents.
+func (ANSI) StyleCell(header, cell string) string {
+	switch header {
+	case "SEVERITY":
+		switch cell {
+		case caps.SeverityError.String():
+			return ansiRed + cell + ansiReset
+		case caps.SeverityWarning.String():
+			return ansiYellow + cell + ansiReset
+		}
+	case "EFFECTIVE", "PERMITTED", "INHERITABLE":
+		return styleNames(cell, "")
+	case "ADDED":
+		return styleNames(cell, ansiYellow)
+	case "REMOVED":
+		return styleNames(cell, ansiGreen)
+	}
+	if cell != "-" && strings.ToUpper(header) == header {
+		if capno, err := caps.CapabilityByName("CAP_" + header); err == nil {
+			return styleCapability(capno, cell, "")
+		}
+	}
+	return cell
+}
+
+// styleNames styles the individual capability names in a cell with names
+// separated by ", ", highlighting overloaded and unused capabilities, and
+// otherwise using the specified default style, if any. Names not referring to
+// capabilities are left unstyled.
+func styleNames(cell string, style string) string {
+	names := strings.Split(cell, ", ")
+	for idx, name := range names {
+		if capno, err := caps.CapabilityByName(name); err == nil {
+			names[idx] = styleCapability(capno, name, style)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// styleCapability styles the text depending on the status of the specified
+// capability, falling back to the specified default style, if any.
+func styleCapability(capno int, text string, style string) string {
+	switch status, _ := caps.StatusOf(capno); status {
+	case caps.StatusOverloaded:
+		return ansiBold + ansiRed + text + ansiReset
+	case caps.StatusUnused:
+		return ansiFaint + text + ansiReset
+	}
+	if style == "" {
+		return text
+	}
+	return style + text + ansiReset
+}
+
+// TerminalStyler returns the [ANSI] styler if the writer is a terminal and
+// the user did not opt out of colored output by setting the NO_COLOR
+// environment variable; otherwise, it returns nil for plain output.
+func TerminalStyler(w io.Writer) Styler {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return nil
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return nil
+	}
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+	return ANSI{}
+}
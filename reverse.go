@@ -18,6 +18,12 @@ package caps
 
 // Reverse returns a copy of the specified slice with the sequence of elements
 // reversed.
+//
+// Deprecated: Reverse isn't specific to capabilities. Use
+// [CapabilitiesSet.NamesByNumberDesc] for listing capability names in
+// descending capability number order, [CapabilitiesSet.WordsBigEndianFirst]
+// for the word order used by textual representations, or
+// golang.org/x/exp/slices.Reverse for general in-place reversal.
 func Reverse[S ~[]E, E any](s S) S {
 	scopy := make(S, len(s))
 	copy(scopy, s)
@@ -0,0 +1,131 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import "encoding/json"
+
+// SARIFRun describes the findings of a single tool run for rendering in the
+// Static Analysis Results Interchange Format (SARIF) 2.1.0, optionally about a
+// specific artifact, such as a binary checked for its file capabilities.
+type SARIFRun struct {
+	Tool     string   // name of the tool producing the findings
+	Version  string   // optional version of the tool
+	Artifact string   // optional URI or path of the artifact the findings are about
+	Findings Findings // findings of this run
+}
+
+// The subset of the SARIF 2.1.0 object model needed for rendering findings.
+type (
+	sarifLog struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool    sarifTool     `json:"tool"`
+		Results []sarifResult `json:"results"`
+	}
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	sarifDriver struct {
+		Name    string      `json:"name"`
+		Version string      `json:"version,omitempty"`
+		Rules   []sarifRule `json:"rules"`
+	}
+	sarifRule struct {
+		ID string `json:"id"`
+	}
+	sarifResult struct {
+		RuleID     string            `json:"ruleId"`
+		RuleIndex  int               `json:"ruleIndex"`
+		Level      string            `json:"level"`
+		Message    sarifMessage      `json:"message"`
+		Locations  []sarifLocation   `json:"locations,omitempty"`
+		Properties map[string]string `json:"properties,omitempty"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifLocation struct {
+		PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	}
+	sarifPhysicalLocation struct {
+		ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	}
+	sarifArtifactLocation struct {
+		URI string `json:"uri"`
+	}
+)
+
+// sarifLevels maps finding severities to SARIF result levels.
+var sarifLevels = [...]string{
+	SeverityInfo:    "note",
+	SeverityWarning: "warning",
+	SeverityError:   "error",
+}
+
+// MarshalSARIF renders the findings of the specified runs as a SARIF 2.1.0
+// log, so that they can be ingested by code-scanning dashboards, CI
+// pipelines, and ticketing integrations. Finding subjects become the rules of
+// a run, and remediation hints become "remediation" result properties.
+func MarshalSARIF(runs ...SARIFRun) ([]byte, error) {
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    make([]sarifRun, 0, len(runs)),
+	}
+	for _, run := range runs {
+		srun := sarifRun{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:    run.Tool,
+				Version: run.Version,
+				Rules:   []sarifRule{},
+			}},
+			Results: make([]sarifResult, 0, len(run.Findings)),
+		}
+		rules := map[string]int{}
+		for _, finding := range run.Findings {
+			ruleIdx, ok := rules[finding.Subject]
+			if !ok {
+				ruleIdx = len(srun.Tool.Driver.Rules)
+				rules[finding.Subject] = ruleIdx
+				srun.Tool.Driver.Rules = append(srun.Tool.Driver.Rules, sarifRule{ID: finding.Subject})
+			}
+			result := sarifResult{
+				RuleID:    finding.Subject,
+				RuleIndex: ruleIdx,
+				Level:     "none",
+				Message:   sarifMessage{Text: finding.Message},
+			}
+			if finding.Severity >= 0 && int(finding.Severity) < len(sarifLevels) {
+				result.Level = sarifLevels[finding.Severity]
+			}
+			if run.Artifact != "" {
+				result.Locations = []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: run.Artifact},
+				}}}
+			}
+			if finding.Remediation != "" {
+				result.Properties = map[string]string{"remediation": finding.Remediation}
+			}
+			srun.Results = append(srun.Results, result)
+		}
+		log.Runs = append(log.Runs, srun)
+	}
+	return json.Marshal(log)
+}
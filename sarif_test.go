@@ -0,0 +1,81 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("SARIF", func() {
+
+	It("renders an empty log", func() {
+		Expect(MarshalSARIF()).To(MatchJSON(`{
+			"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+			"version": "2.1.0",
+			"runs": []
+		}`))
+	})
+
+	It("renders findings", func() {
+		findings := Findings{
+			errorFinding("mount", "binary resides on a filesystem mounted nosuid").
+				withRemediation("move the binary to a filesystem mounted without nosuid"),
+			infoFinding("CAP_SYS_PTRACE", "CAP_SYS_PTRACE is not effective"),
+			warningFinding("mount", "foo"),
+		}
+		b := Successful(MarshalSARIF(
+			SARIFRun{Tool: "capcheck", Version: "1.2.3", Artifact: "bin/helper", Findings: findings},
+			SARIFRun{Tool: "other"},
+		))
+		Expect(b).To(MatchJSON(`{
+			"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+			"version": "2.1.0",
+			"runs": [
+				{
+					"tool": {"driver": {
+						"name": "capcheck",
+						"version": "1.2.3",
+						"rules": [{"id": "mount"}, {"id": "CAP_SYS_PTRACE"}]
+					}},
+					"results": [
+						{
+							"ruleId": "mount", "ruleIndex": 0, "level": "error",
+							"message": {"text": "binary resides on a filesystem mounted nosuid"},
+							"locations": [{"physicalLocation": {"artifactLocation": {"uri": "bin/helper"}}}],
+							"properties": {"remediation": "move the binary to a filesystem mounted without nosuid"}
+						},
+						{
+							"ruleId": "CAP_SYS_PTRACE", "ruleIndex": 1, "level": "note",
+							"message": {"text": "CAP_SYS_PTRACE is not effective"},
+							"locations": [{"physicalLocation": {"artifactLocation": {"uri": "bin/helper"}}}]
+						},
+						{
+							"ruleId": "mount", "ruleIndex": 0, "level": "warning",
+							"message": {"text": "foo"},
+							"locations": [{"physicalLocation": {"artifactLocation": {"uri": "bin/helper"}}}]
+						}
+					]
+				},
+				{
+					"tool": {"driver": {"name": "other", "rules": []}},
+					"results": []
+				}
+			]
+		}`))
+	})
+
+})
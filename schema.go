@@ -0,0 +1,34 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import _ "embed"
+
+//go:embed schema.json
+var jsonSchema []byte
+
+// JSONSchema returns the JSON schema describing the JSON representations of
+// this package's types, such as [CapabilitiesSet], [TaskCapabilities],
+// [State], [Findings], and [Policy]. The individual types are defined in the
+// schema's “$defs” section, named after their Go types, so downstream
+// pipelines can validate and generate code against them, for instance, using
+// “schema.json#/$defs/State”.
+func JSONSchema() []byte {
+	schema := make([]byte, len(jsonSchema))
+	copy(schema, jsonSchema)
+	return schema
+}
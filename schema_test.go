@@ -0,0 +1,97 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"encoding/json"
+	"regexp"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+// schemaDef returns the named definition from the JSON schema.
+func schemaDef(name string) map[string]interface{} {
+	var schema struct {
+		Defs map[string]map[string]interface{} `json:"$defs"`
+	}
+	Expect(json.Unmarshal(JSONSchema(), &schema)).To(Succeed())
+	def, ok := schema.Defs[name]
+	Expect(ok).To(BeTrue(), "missing schema definition %q", name)
+	return def
+}
+
+// expectSchemaProperties expects the JSON object representation of v to only
+// have the properties of the named schema definition, as well as all required
+// properties.
+func expectSchemaProperties(name string, v interface{}) {
+	def := schemaDef(name)
+	var obj map[string]interface{}
+	Expect(json.Unmarshal(Successful(json.Marshal(v)), &obj)).To(Succeed())
+	props := def["properties"].(map[string]interface{})
+	for key := range obj {
+		Expect(props).To(HaveKey(key), "%s has undeclared property %q", name, key)
+	}
+	if required, ok := def["required"].([]interface{}); ok {
+		for _, key := range required {
+			Expect(obj).To(HaveKey(key), "%s lacks required property %q", name, key)
+		}
+	}
+}
+
+var _ = Describe("JSON schema", func() {
+
+	It("returns an independent copy", func() {
+		schema := JSONSchema()
+		schema[0] = 'x'
+		Expect(JSONSchema()[0]).To(Equal(byte('{')))
+	})
+
+	It("describes the JSON outputs", func() {
+		state := Successful(StateOf(0))
+		expectSchemaProperties("State", state)
+		expectSchemaProperties("TaskCapabilities", state.TaskCapabilities)
+		expectSchemaProperties("Finding", errorFinding("foo", "bar").withRemediation("baz"))
+		expectSchemaProperties("Policy", Policy{})
+		expectSchemaProperties("OCICapabilities", OCICapabilities{
+			Bounding: []string{"CAP_CHOWN"}, Effective: []string{"CAP_CHOWN"},
+			Inheritable: []string{"CAP_CHOWN"}, Permitted: []string{"CAP_CHOWN"},
+			Ambient: []string{"CAP_CHOWN"},
+		})
+	})
+
+	It("describes capabilities sets", func() {
+		def := schemaDef("CapabilitiesSet")
+		oneOf := def["oneOf"].([]interface{})
+		names := regexp.MustCompile(oneOf[0].(map[string]interface{})["items"].(map[string]interface{})["pattern"].(string))
+		for _, name := range AllCapabilities().Names() {
+			Expect(names.MatchString(name)).To(BeTrue(), name)
+		}
+		hex := regexp.MustCompile(oneOf[1].(map[string]interface{})["pattern"].(string))
+		var compact string
+		Expect(json.Unmarshal(Successful(AllCapabilities().MarshalCompactJSON()), &compact)).To(Succeed())
+		Expect(hex.MatchString(compact)).To(BeTrue(), compact)
+	})
+
+	It("describes severities", func() {
+		enum := schemaDef("Severity")["enum"].([]interface{})
+		Expect(enum).To(HaveLen(len(severityNames)))
+		for idx, name := range severityNames {
+			Expect(enum[idx]).To(Equal(name))
+		}
+	})
+
+})
@@ -0,0 +1,221 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+
+	"github.com/thediveo/caps/errno"
+	"golang.org/x/sys/unix"
+)
+
+// seccomp(2) operations, flags, and filter return values.
+const (
+	seccompSetModeFilter   = 1
+	seccompFilterFlagTsync = 1
+
+	seccompRetAllow = 0x7fff0000
+	seccompRetErrno = 0x00050000
+)
+
+// Offsets into the seccomp_data passed to seccomp filters.
+const (
+	seccompDataNr   = 0
+	seccompDataArch = 4
+	seccompDataArgs = 16
+)
+
+// sysSetxattrat is the number of the setxattrat(2) syscall added in Linux
+// 6.13, which has the same number on all architectures supported by Go.
+const sysSetxattrat = 463
+
+// x32SyscallBit marks syscalls of the x32 ABI on amd64, which share the
+// AUDIT_ARCH_X86_64 architecture.
+const x32SyscallBit = 0x40000000
+
+// seccompArchs maps Go architectures to their audit architectures, as well as
+// their endianness.
+var seccompArchs = map[string]struct {
+	auditArch uint32
+	bigEndian bool
+}{
+	"386":      {unix.AUDIT_ARCH_I386, false},
+	"amd64":    {unix.AUDIT_ARCH_X86_64, false},
+	"arm":      {unix.AUDIT_ARCH_ARM, false},
+	"arm64":    {unix.AUDIT_ARCH_AARCH64, false},
+	"loong64":  {unix.AUDIT_ARCH_LOONGARCH64, false},
+	"mips64le": {unix.AUDIT_ARCH_MIPSEL64, false},
+	"ppc64":    {unix.AUDIT_ARCH_PPC64, true},
+	"ppc64le":  {unix.AUDIT_ARCH_PPC64LE, false},
+	"riscv64":  {unix.AUDIT_ARCH_RISCV64, false},
+	"s390x":    {unix.AUDIT_ARCH_S390X, true},
+}
+
+// SealPrivileges installs a seccomp filter on all threads of the calling
+// process that denies any further capability changes with EPERM, as a
+// belt-and-braces lockdown after the final privilege setup, complementing
+// irreversibly dropped capabilities. The filter denies:
+//   - capset(2), so that capabilities can neither be raised nor dropped
+//     anymore,
+//   - prctl(PR_CAP_AMBIENT, PR_CAP_AMBIENT_RAISE, ...),
+//   - the setxattr(2) family of syscalls, as seccomp filters cannot inspect
+//     attribute names and thus cannot single out "security.capability",
+//   - all syscalls of other architectures and ABIs, such as 32-bit syscalls on
+//     64-bit architectures, so that the filter cannot be bypassed using them.
+//
+// Installing a seccomp filter either requires CAP_SYS_ADMIN or the
+// no_new_privs flag to be set; without CAP_SYS_ADMIN in the effective set,
+// SealPrivileges thus sets no_new_privs for all threads of the calling
+// process. The filter cannot be removed and is inherited by child processes.
+// If installing the filter fails after no_new_privs has been set for the
+// calling thread, SealPrivileges keeps the calling Go routine locked to this
+// thread, so that the thread gets thrown away when the Go routine finishes.
+//
+// While the test guard is active, SealPrivileges fails with [ErrTestGuard]
+// unless explicitly allowed, see [SetTestGuard].
+func SealPrivileges() error {
+	if err := guard(GuardSealPrivileges); err != nil {
+		return err
+	}
+	filter, err := sealFilter(runtime.GOARCH)
+	if err != nil {
+		return err
+	}
+	runtime.LockOSThread()
+	// Once no_new_privs has been set on this thread, but the seccomp filter
+	// failed to synchronize it to all other threads, this thread differs from
+	// its siblings. It then must stay locked, so that the Go runtime throws it
+	// away when the calling Go routine finishes.
+	tainted := false
+	defer func() {
+		if tainted {
+			discardThisTask()
+			return
+		}
+		runtime.UnlockOSThread()
+	}()
+	taskcaps, err := OfThisTask()
+	if err != nil {
+		return err
+	}
+	if !taskcaps.Effective.Has(CAP_SYS_ADMIN) {
+		if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+			return fmt.Errorf("cannot set no_new_privs: %w", err)
+		}
+		tainted = true
+	}
+	prog := unix.SockFprog{
+		Len:    uint16(len(filter)),
+		Filter: &filter[0],
+	}
+	r, _, e := unix.Syscall(unix.SYS_SECCOMP,
+		seccompSetModeFilter, seccompFilterFlagTsync, uintptr(unsafe.Pointer(&prog)))
+	runtime.KeepAlive(filter)
+	if e != 0 {
+		return errno.Wrap("seccomp", e)
+	}
+	if r != 0 {
+		return fmt.Errorf("cannot synchronize seccomp filter to thread %d", r)
+	}
+	// Synchronizing the filter also set no_new_privs on all threads.
+	tainted = false
+	return nil
+}
+
+// Jump targets of sealing filter instructions.
+const (
+	sealNext = iota
+	sealAllow
+	sealDeny
+)
+
+// sealFilter returns the seccomp filter program denying capability changes
+// for the specified Go architecture. As the syscall numbers are always those
+// of the architecture this package has been built for, the architecture must
+// be runtime.GOARCH, except for testing.
+func sealFilter(goarch string) ([]unix.SockFilter, error) {
+	arch, ok := seccompArchs[goarch]
+	if !ok {
+		return nil, fmt.Errorf("unsupported architecture %s", goarch)
+	}
+	// The lower 32 bits of 64-bit syscall arguments.
+	arg := func(idx int) uint32 {
+		offset := uint32(seccompDataArgs + 8*idx)
+		if arch.bigEndian {
+			offset += 4
+		}
+		return offset
+	}
+	type insn struct {
+		code   uint16
+		k      uint32
+		jt, jf int
+	}
+	const (
+		ld  = unix.BPF_LD | unix.BPF_W | unix.BPF_ABS
+		jeq = unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K
+		jge = unix.BPF_JMP | unix.BPF_JGE | unix.BPF_K
+	)
+	insns := []insn{
+		{code: ld, k: seccompDataArch},
+		{code: jeq, k: arch.auditArch, jf: sealDeny},
+		{code: ld, k: seccompDataNr},
+	}
+	if goarch == "amd64" {
+		insns = append(insns, insn{code: jge, k: x32SyscallBit, jt: sealDeny})
+	}
+	for _, nr := range []uint32{
+		unix.SYS_CAPSET,
+		unix.SYS_SETXATTR, unix.SYS_LSETXATTR, unix.SYS_FSETXATTR, sysSetxattrat,
+	} {
+		insns = append(insns, insn{code: jeq, k: nr, jt: sealDeny})
+	}
+	insns = append(insns,
+		insn{code: jeq, k: unix.SYS_PRCTL, jf: sealAllow},
+		insn{code: ld, k: arg(0)},
+		insn{code: jeq, k: unix.PR_CAP_AMBIENT, jf: sealAllow},
+		insn{code: ld, k: arg(1)},
+		insn{code: jeq, k: unix.PR_CAP_AMBIENT_RAISE, jt: sealDeny, jf: sealAllow},
+	)
+	// The final two instructions allow and deny, so resolve the jump targets
+	// into offsets relative to the instruction following the jump.
+	allowIdx, denyIdx := len(insns), len(insns)+1
+	target := func(idx, jump int) uint8 {
+		switch jump {
+		case sealAllow:
+			return uint8(allowIdx - idx - 1)
+		case sealDeny:
+			return uint8(denyIdx - idx - 1)
+		}
+		return 0
+	}
+	filter := make([]unix.SockFilter, 0, len(insns)+2)
+	for idx, in := range insns {
+		filter = append(filter, unix.SockFilter{
+			Code: in.code,
+			Jt:   target(idx, in.jt),
+			Jf:   target(idx, in.jf),
+			K:    in.k,
+		})
+	}
+	filter = append(filter,
+		unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: seccompRetAllow},
+		unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: seccompRetErrno | uint32(unix.EPERM)})
+	return filter, nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+// sealChildEnv is the environment variable telling a re-executed test binary
+// to seal its privileges and report which operations still work, as sealing
+// cannot be undone in the test process itself.
+const sealChildEnv = "CAPS_TEST_SEAL_CHILD"
+
+func init() {
+	path := os.Getenv(sealChildEnv)
+	if path == "" {
+		return
+	}
+	errstr := func(err error) string {
+		if err == nil {
+			return "ok"
+		}
+		return err.Error()
+	}
+	fmt.Printf("seal: %s\n", errstr(SealPrivileges()))
+	taskcaps, err := OfThisTask()
+	fmt.Printf("capget: %s\n", errstr(err))
+	fmt.Printf("capset: %s\n", errstr(SetForThisTask(taskcaps)))
+	fmt.Printf("ambient: %s\n", errstr(unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_RAISE, CAP_NET_RAW, 0, 0)))
+	_, err = unix.PrctlRetInt(unix.PR_GET_NO_NEW_PRIVS, 0, 0, 0, 0)
+	fmt.Printf("prctl: %s\n", errstr(err))
+	fmt.Printf("setxattr: %s\n", errstr(unix.Setxattr(path, "user.foo", []byte("bar"), 0)))
+	err = CheckSeccomp()
+	fmt.Printf("seccomp: %s %t\n", errstr(err), errors.Is(err, ErrBlockedBySeccomp))
+	os.Exit(0)
+}
+
+var _ = Describe("sealing privileges", func() {
+
+	It("generates filters for big-endian architectures", func() {
+		Expect(sealFilter("pdp11")).Error().To(MatchError(ContainSubstring("unsupported architecture")))
+		little := Successful(sealFilter("arm64"))
+		big := Successful(sealFilter("s390x"))
+		Expect(big).To(HaveLen(len(little)))
+		idx := len(big) - 4 // loading the lower 32 bits of the second argument
+		Expect(big[idx].K).To(Equal(little[idx].K + 4))
+	})
+
+	It("denies capability changes after sealing", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "file")
+		Expect(os.WriteFile(path, nil, 0644)).To(Succeed())
+		cmd := exec.Command(os.Args[0])
+		cmd.Env = append(os.Environ(), sealChildEnv+"="+path)
+		out := string(Successful(cmd.Output()))
+		Expect(strings.Split(strings.TrimSpace(out), "\n")).To(Equal([]string{
+			"seal: ok",
+			"capget: ok",
+			"capset: capset: operation not permitted",
+			"ambient: operation not permitted",
+			"prctl: ok",
+			"setxattr: operation not permitted",
+			"seccomp: cannot use capset (blocked by seccomp filter) true",
+		}))
+	})
+
+})
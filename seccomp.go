@@ -0,0 +1,120 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"runtime"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrBlockedBySeccomp indicates that a syscall needed by this package has been
+// blocked by a seccomp filter, such as the default seccomp profiles of
+// container engines.
+var ErrBlockedBySeccomp = errors.New("blocked by seccomp filter")
+
+// seccompError wraps a syscall error that has been caused by a seccomp filter,
+// additionally matching [ErrBlockedBySeccomp].
+type seccompError struct {
+	err error
+}
+
+// Error returns the wrapped error's message, marked as caused by seccomp.
+func (e *seccompError) Error() string {
+	return e.err.Error() + " (" + ErrBlockedBySeccomp.Error() + ")"
+}
+
+// Unwrap returns the wrapped error.
+func (e *seccompError) Unwrap() error { return e.err }
+
+// Is returns true if the target is ErrBlockedBySeccomp.
+func (e *seccompError) Is(target error) bool { return target == ErrBlockedBySeccomp }
+
+// blockedBySeccomp returns the specified syscall error wrapped as blocked by
+// seccomp, if it is ENOSYS or EPERM and the calling thread is subject to
+// seccomp filtering. Otherwise, the error is returned unchanged.
+func blockedBySeccomp(err error) error {
+	if err == nil ||
+		!(errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EPERM)) ||
+		!seccompFiltered() {
+		return err
+	}
+	return &seccompError{err: err}
+}
+
+// seccompFiltered returns true if the calling thread is subject to seccomp
+// filters, according to the "Seccomp:" field of its /proc status.
+func seccompFiltered() bool {
+	status, err := os.ReadFile(ownProcPath("thread-self/status"))
+	if err != nil {
+		return false
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(status))
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "Seccomp:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "Seccomp:")) == "2"
+		}
+	}
+	return false
+}
+
+// CheckSeccomp probes whether a seccomp filter blocks the syscalls this
+// package needs: capget(2), capset(2), and prctl(2) for the bounding and
+// ambient capabilities. CheckSeccomp returns nil if the calling thread isn't
+// subject to seccomp filters or all probes succeed, and otherwise an error
+// wrapping [ErrBlockedBySeccomp] that names the blocked syscalls.
+//
+// The probes are harmless, such as setting the unchanged capabilities, and run
+// on a throw-away OS-level thread instead of a forked child process, as Go
+// programs cannot safely fork without executing.
+func CheckSeccomp() error {
+	if !seccompFiltered() {
+		return nil
+	}
+	blocked := make(chan []string)
+	go func() {
+		// Never unlock this thread, so that it gets thrown away when this Go
+		// routine finishes.
+		runtime.LockOSThread()
+		var names []string
+		probe := func(name string, err error) {
+			if errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EPERM) {
+				names = append(names, name)
+			}
+		}
+		taskcaps, err := OfThisTask()
+		probe("capget", err)
+		if err == nil {
+			probe("capset", SetForThisTask(taskcaps))
+		}
+		_, err = unix.PrctlRetInt(unix.PR_CAPBSET_READ, 0, 0, 0, 0)
+		probe("prctl(PR_CAPBSET_READ)", err)
+		_, err = unix.PrctlRetInt(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_IS_SET, 0, 0, 0)
+		probe("prctl(PR_CAP_AMBIENT)", err)
+		discardThisTask()
+		blocked <- names
+	}()
+	if names := <-blocked; len(names) != 0 {
+		return &seccompError{err: errors.New("cannot use " + strings.Join(names, ", "))}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/thediveo/caps/errno"
+	"golang.org/x/sys/unix"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeSeccompProc returns the root of a fake /proc with a thread-self/status
+// containing the specified seccomp mode, if any.
+func fakeSeccompProc(mode string) string {
+	root := GinkgoT().TempDir()
+	Expect(os.MkdirAll(filepath.Join(root, "thread-self"), 0755)).To(Succeed())
+	status := "Name:\tfoo\n"
+	if mode != "" {
+		status += "Seccomp:\t" + mode + "\nSeccomp_filters:\t1\n"
+	}
+	Expect(os.WriteFile(filepath.Join(root, "thread-self/status"), []byte(status), 0644)).To(Succeed())
+	return root
+}
+
+var _ = Describe("seccomp interference", func() {
+
+	BeforeEach(func() {
+		cfg := CurrentConfig()
+		DeferCleanup(func() { Configure(cfg) })
+	})
+
+	DescribeTable("detects seccomp filtering",
+		func(mode string, filtered bool) {
+			useProc(fakeSeccompProc(mode))
+			Expect(seccompFiltered()).To(Equal(filtered))
+		},
+		Entry("no seccomp field", "", false),
+		Entry("disabled", "0", false),
+		Entry("strict", "1", false),
+		Entry("filtered", "2", true),
+	)
+
+	It("marks errors caused by seccomp", func() {
+		useProc(fakeSeccompProc("2"))
+		err := blockedBySeccomp(errno.Wrap("capget", unix.EPERM))
+		Expect(err).To(MatchError(ErrBlockedBySeccomp))
+		Expect(err).To(MatchError(unix.EPERM))
+		Expect(err.Error()).To(Equal("capget: operation not permitted (blocked by seccomp filter)"))
+		Expect(blockedBySeccomp(errno.Wrap("capget", unix.EINVAL))).NotTo(
+			MatchError(ErrBlockedBySeccomp))
+		Expect(blockedBySeccomp(nil)).To(Succeed())
+
+		useProc(fakeSeccompProc("0"))
+		Expect(errors.Is(blockedBySeccomp(errno.Wrap("capget", unix.ENOSYS)), ErrBlockedBySeccomp)).To(BeFalse())
+		Expect(CheckSeccomp()).To(Succeed())
+	})
+
+	It("doesn't report harmless seccomp filters", func() {
+		Expect(CheckSeccomp()).To(Succeed())
+	})
+
+})
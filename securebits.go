@@ -0,0 +1,70 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import "strconv"
+
+// Securebits of a task, see also capabilities(7) and
+// include/uapi/linux/securebits.h. The “locked” securebits prevent changing
+// their corresponding securebits.
+const (
+	// SECBIT_NOROOT disables granting capabilities when executing
+	// set-user-ID-root programs, or when a task with UID 0 executes a program.
+	SECBIT_NOROOT        = 1 << 0
+	SECBIT_NOROOT_LOCKED = 1 << 1
+	// SECBIT_NO_SETUID_FIXUP stops the kernel from adjusting the capabilities
+	// when the effective and filesystem UIDs switch between zero and nonzero.
+	SECBIT_NO_SETUID_FIXUP        = 1 << 2
+	SECBIT_NO_SETUID_FIXUP_LOCKED = 1 << 3
+	// SECBIT_KEEP_CAPS keeps the permitted capabilities when switching all
+	// UIDs from zero to nonzero.
+	SECBIT_KEEP_CAPS        = 1 << 4
+	SECBIT_KEEP_CAPS_LOCKED = 1 << 5
+	// SECBIT_NO_CAP_AMBIENT_RAISE disallows raising ambient capabilities.
+	SECBIT_NO_CAP_AMBIENT_RAISE        = 1 << 6
+	SECBIT_NO_CAP_AMBIENT_RAISE_LOCKED = 1 << 7
+)
+
+// securebitNames maps the bit numbers of the securebits to their names.
+var securebitNames = [...]string{
+	0: "SECBIT_NOROOT",
+	1: "SECBIT_NOROOT_LOCKED",
+	2: "SECBIT_NO_SETUID_FIXUP",
+	3: "SECBIT_NO_SETUID_FIXUP_LOCKED",
+	4: "SECBIT_KEEP_CAPS",
+	5: "SECBIT_KEEP_CAPS_LOCKED",
+	6: "SECBIT_NO_CAP_AMBIENT_RAISE",
+	7: "SECBIT_NO_CAP_AMBIENT_RAISE_LOCKED",
+}
+
+// SecurebitsNames returns the names of the specified securebits, sorted by
+// increasing bit number. Unknown securebits are named by their bit number,
+// such as "SECBIT_8".
+func SecurebitsNames(bits uint) []string {
+	names := []string{}
+	for bit := 0; bits != 0; bit++ {
+		if bits&1 != 0 {
+			if bit < len(securebitNames) {
+				names = append(names, securebitNames[bit])
+			} else {
+				names = append(names, "SECBIT_"+strconv.Itoa(bit))
+			}
+		}
+		bits >>= 1
+	}
+	return names
+}
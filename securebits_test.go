@@ -0,0 +1,32 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("securebits", func() {
+
+	It("names securebits", func() {
+		Expect(SecurebitsNames(0)).To(BeEmpty())
+		Expect(SecurebitsNames(SECBIT_NOROOT | SECBIT_KEEP_CAPS_LOCKED | 1<<9)).To(Equal([]string{
+			"SECBIT_NOROOT", "SECBIT_KEEP_CAPS_LOCKED", "SECBIT_9"}))
+		Expect(SecurebitsNames(SECBIT_NO_CAP_AMBIENT_RAISE_LOCKED)).To(Equal([]string{
+			"SECBIT_NO_CAP_AMBIENT_RAISE_LOCKED"}))
+	})
+
+})
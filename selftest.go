@@ -0,0 +1,131 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// SelfTestReport reports which capabilities-related kernel features work on
+// the kernel we're currently running on. A nil error for a particular feature
+// means that the feature works, otherwise the error returned when probing the
+// feature.
+type SelfTestReport struct {
+	KernelCapabilityVersion uint32 // native capabilities version of the kernel
+	LastCapability          int    // highest capability number of the kernel
+	Capget                  error  // getting task capabilities using capget(2)
+	Capset                  error  // setting task capabilities using capset(2)
+	BoundingSet             error  // reading the bounding set using prctl(2)
+	AmbientSet              error  // reading the ambient set using prctl(2)
+	Securebits              error  // reading the securebits using prctl(2)
+	SecurebitsConsistency   error  // prctl(2) and /proc agreeing on the securebits
+	NoNewPrivs              error  // reading the no_new_privs flag using prctl(2)
+}
+
+// OK returns true if all features work.
+func (r SelfTestReport) OK() bool {
+	return r.Capget == nil && r.Capset == nil &&
+		r.BoundingSet == nil && r.AmbientSet == nil &&
+		r.Securebits == nil && r.SecurebitsConsistency == nil &&
+		r.NoNewPrivs == nil
+}
+
+// SelfTest probes the capabilities-related kernel features this package relies
+// on and returns a report of which features work, which is useful as a startup
+// check on exotic kernels, sandboxes such as gVisor, WSL, or in
+// seccomp-restricted environments. SelfTest returns an error only if
+// capabilities cannot be queried at all, as this package then is unusable.
+//
+// SelfTest additionally cross-checks the securebits read using prctl(2)
+// against the securebits shown in /proc on (patched) kernels that expose them
+// there, reporting any discrepancy instead of silently trusting either view.
+//
+// The probes are run on a separate, throw-away OS-level thread, so any
+// capabilities changes on that thread cannot leak into the calling process.
+// When setting capabilities, the probes only set the task's current
+// capabilities again.
+func SelfTest() (SelfTestReport, error) {
+	done := make(chan SelfTestReport)
+	go func() {
+		// Never unlock this thread, so that it gets thrown away when this Go
+		// routine finishes.
+		runtime.LockOSThread()
+		report := selfTest()
+		discardThisTask()
+		done <- report
+	}()
+	report := <-done
+	return report, report.Capget
+}
+
+// selfTest runs the feature probes on the current task.
+func selfTest() SelfTestReport {
+	report := SelfTestReport{
+		KernelCapabilityVersion: KernelCapabilityVersion(),
+		LastCapability:          LastCapability(),
+	}
+	var taskcaps TaskCapabilities
+	taskcaps, report.Capget = OfThisTask()
+	if report.Capget == nil {
+		report.Capset = SetForThisTask(taskcaps)
+	} else {
+		report.Capset = report.Capget
+	}
+	_, report.BoundingSet = unix.PrctlRetInt(unix.PR_CAPBSET_READ, CAP_CHOWN, 0, 0, 0)
+	_, report.AmbientSet = unix.PrctlRetInt(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_IS_SET, CAP_CHOWN, 0, 0)
+	var securebits int
+	securebits, report.Securebits = unix.PrctlRetInt(unix.PR_GET_SECUREBITS, 0, 0, 0, 0)
+	if report.Securebits == nil {
+		if status, err := os.ReadFile(ownProcPath("thread-self/status")); err == nil {
+			report.SecurebitsConsistency = reconcileSecurebits(uint(securebits), status)
+		}
+	}
+	_, report.NoNewPrivs = unix.PrctlRetInt(unix.PR_GET_NO_NEW_PRIVS, 0, 0, 0, 0)
+	return report
+}
+
+// reconcileSecurebits checks that the securebits as read using prctl(2) match
+// the securebits shown in the specified /proc/[tid]/status contents, returning
+// an error if not. Mainline kernels don't show the securebits in the task
+// status, but some patched kernels do as a hexadecimal “Securebits:” field; if
+// there is no such field, there is nothing to reconcile.
+func reconcileSecurebits(securebits uint, status []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(status))
+	for scanner.Scan() {
+		key, value, ok := bytes.Cut(scanner.Bytes(), []byte(":"))
+		if !ok || string(key) != "Securebits" {
+			continue
+		}
+		procbits, err := strconv.ParseUint(string(bytes.TrimSpace(value)), 16, 32)
+		if err != nil {
+			return fmt.Errorf("invalid Securebits field in task status: %w", err)
+		}
+		if uint(procbits) != securebits {
+			return fmt.Errorf("securebits mismatch: prctl reports 0x%02x, but /proc reports 0x%02x",
+				securebits, procbits)
+		}
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"syscall"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("self-test", func() {
+
+	It("reports working features", func() {
+		report := Successful(SelfTest())
+		Expect(report.OK()).To(BeTrue(), "%+v", report)
+		Expect(report.KernelCapabilityVersion).To(Equal(KernelCapabilityVersion()))
+		Expect(report.LastCapability).To(Equal(LastCapability()))
+	})
+
+	It("reports failing features", func() {
+		Expect(SelfTestReport{AmbientSet: syscall.EINVAL}.OK()).To(BeFalse())
+		Expect(SelfTestReport{SecurebitsConsistency: syscall.EINVAL}.OK()).To(BeFalse())
+	})
+
+	It("reconciles securebits", func() {
+		Expect(reconcileSecurebits(0, []byte(taskStatus))).To(Succeed())
+		Expect(reconcileSecurebits(SECBIT_KEEP_CAPS,
+			[]byte("CapEff:\t0000000000000000\nSecurebits:\t10\n"))).To(Succeed())
+		Expect(reconcileSecurebits(SECBIT_KEEP_CAPS,
+			[]byte("Securebits:\t00\n"))).To(MatchError(
+			"securebits mismatch: prctl reports 0x10, but /proc reports 0x00"))
+		Expect(reconcileSecurebits(0, []byte("Securebits:\tfoo\n"))).To(
+			MatchError(ContainSubstring("invalid Securebits field")))
+	})
+
+})
@@ -0,0 +1,262 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// State represents the complete capabilities-related state of a task: its
+// effective, permitted, and inheritable capabilities, as well as its bounding
+// and ambient capabilities, its securebits, and the no_new_privs flag.
+type State struct {
+	TaskCapabilities `yaml:",inline"`
+	Bounding         CapabilitiesSet `yaml:"bounding"`
+	Ambient          CapabilitiesSet `yaml:"ambient"`
+	Securebits       uint            `yaml:"securebits"` // only known for the calling task
+	NoNewPrivs       bool            `yaml:"nonewprivs"`
+	// NSpid and NStgid are the thread (task) IDs and thread group (process)
+	// IDs of the task in all PID namespaces it is a member of, starting with
+	// the PID namespace of the proc filesystem read, down to the task's own
+	// PID namespace. They allow correlating tasks seen from the host with
+	// tasks seen inside containers. Both are nil when unknown, such as with
+	// kernels before 4.1.
+	NSpid  []int `json:",omitempty" yaml:"nspid,omitempty"`
+	NStgid []int `json:",omitempty" yaml:"nstgid,omitempty"`
+}
+
+// StateOf returns the capabilities-related state of the task with the
+// specified tid, where tid 0 refers to the calling task. The state is read
+// from /proc/[tid]/status, where a non-zero tid is in the PID namespace of
+// [Config.ProcRoot]. If the state cannot be read, an error is returned
+// instead, together with a zero state.
+//
+// As the kernel doesn't expose the securebits of tasks in /proc, the
+// securebits are only read for the calling task, and are zero otherwise.
+//
+// The capabilities sets of the state are taken from a pool of sets; when
+// repeatedly reading the states of many tasks, call [State.Release] on states
+// not needed anymore in order to reduce garbage collection pressure.
+func StateOf(tid int) (State, error) {
+	if tid == 0 {
+		return ownStateOf(0)
+	}
+	return stateFrom(config.Load().ProcRoot, tid)
+}
+
+// ownStateOf returns the capabilities-related state of the task with the
+// specified tid in the caller's own PID namespace, such as a tid returned by
+// gettid(2), independent of [Config.ProcRoot]. As with [StateOf], tid 0
+// refers to the calling task.
+func ownStateOf(tid int) (State, error) {
+	return stateFrom(ownProcRoot, tid)
+}
+
+// stateFrom returns the capabilities-related state of the task with the
+// specified tid, read from the proc filesystem mounted at procroot. Only when
+// reading from the caller's own proc filesystem a tid can be identified as the
+// calling task, so that its securebits can be read.
+func stateFrom(procroot string, tid int) (State, error) {
+	name := "thread-self/status"
+	if tid != 0 {
+		name = strconv.Itoa(tid) + "/status"
+	}
+	status, err := os.ReadFile(procroot + "/" + name)
+	if err != nil {
+		return State{}, err
+	}
+	state, err := parseStatus(status)
+	if err != nil {
+		return State{}, err
+	}
+	if procroot == ownProcRoot && (tid == 0 || tid == unix.Gettid()) {
+		if bits, err := unix.PrctlRetInt(unix.PR_GET_SECUREBITS, 0, 0, 0, 0); err == nil {
+			state.Securebits = uint(bits)
+		}
+	}
+	return state, nil
+}
+
+// CanonicalFormatVersion is the version of the textual snapshot format
+// produced by [State.Canonical]. The format of a particular version is
+// guaranteed to stay stable across versions of this package.
+const CanonicalFormatVersion = 1
+
+// Canonical returns a canonical, versioned textual snapshot of this task state,
+// which is guaranteed to stay stable across versions of this package and thus
+// is suitable for golden-file tests of privilege-dropping code. The snapshot
+// consists of a version line, followed by lines for the effective, permitted,
+// inheritable, bounding, and ambient capabilities, in this order, with the
+// capability numbers in increasing order and separated by commas, then the
+// numbers of the securebits set in the same notation, and finally the
+// no_new_privs flag as 0 or 1:
+//
+//	caps-state v1
+//	effective: 10
+//	permitted: 10
+//	inheritable:
+//	bounding: 0,10
+//	ambient:
+//	securebits: 4
+//	nonewprivs: 1
+func (s State) Canonical() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "caps-state v%d\n", CanonicalFormatVersion)
+	for _, set := range []struct {
+		name string
+		caps CapabilitiesSet
+	}{
+		{"effective", s.Effective},
+		{"permitted", s.Permitted},
+		{"inheritable", s.Inheritable},
+		{"bounding", s.Bounding},
+		{"ambient", s.Ambient},
+	} {
+		b.WriteString(set.name)
+		b.WriteByte(':')
+		writeCanonicalNumbers(&b, set.caps.Numbers())
+	}
+	var bits []int
+	for bit := 0; bit < strconv.IntSize; bit++ {
+		if s.Securebits&(1<<bit) != 0 {
+			bits = append(bits, bit)
+		}
+	}
+	b.WriteString("securebits:")
+	writeCanonicalNumbers(&b, bits)
+	nnp := 0
+	if s.NoNewPrivs {
+		nnp = 1
+	}
+	fmt.Fprintf(&b, "nonewprivs: %d\n", nnp)
+	return b.String()
+}
+
+// writeCanonicalNumbers writes the specified numbers separated by commas and
+// terminates the line.
+func writeCanonicalNumbers(b *strings.Builder, numbers []int) {
+	for idx, number := range numbers {
+		if idx == 0 {
+			b.WriteByte(' ')
+		} else {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Itoa(number))
+	}
+	b.WriteByte('\n')
+}
+
+// parseStatus returns the capabilities-related state from the contents of a
+// /proc/[tid]/status file.
+func parseStatus(status []byte) (State, error) {
+	var state State
+	fields := [...]struct {
+		key   string
+		set   *CapabilitiesSet
+		found bool
+	}{
+		{key: "CapInh", set: &state.Inheritable},
+		{key: "CapPrm", set: &state.Permitted},
+		{key: "CapEff", set: &state.Effective},
+		{key: "CapBnd", set: &state.Bounding},
+		{key: "CapAmb", set: &state.Ambient},
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(status))
+	for scanner.Scan() {
+		key, value, ok := bytes.Cut(scanner.Bytes(), []byte(":"))
+		if !ok {
+			continue
+		}
+		value = bytes.TrimSpace(value)
+		switch string(key) {
+		case "NoNewPrivs":
+			state.NoNewPrivs = string(value) == "1"
+			continue
+		case "NSpid", "NStgid":
+			ids, err := parseNSids(value)
+			if err != nil {
+				state.Release()
+				return State{}, fmt.Errorf("invalid %s field in task status: %w", key, err)
+			}
+			if string(key) == "NSpid" {
+				state.NSpid = ids
+			} else {
+				state.NStgid = ids
+			}
+			continue
+		}
+		for idx := range fields {
+			field := &fields[idx]
+			if field.found || string(key) != field.key {
+				continue
+			}
+			caps, err := capabilitiesFromHexInto(GetSet(), string(value))
+			if err != nil {
+				state.Release()
+				return State{}, fmt.Errorf("invalid %s field in task status: %w", field.key, err)
+			}
+			*field.set = caps
+			field.found = true
+			break
+		}
+	}
+	for _, field := range fields {
+		if field.found {
+			continue
+		}
+		// CapAmb has been introduced only with Linux 4.3, so accept it
+		// missing, but none of the others.
+		if field.key == "CapAmb" {
+			state.Ambient = GetSet()
+			continue
+		}
+		state.Release()
+		return State{}, fmt.Errorf("missing %s field in task status", field.key)
+	}
+	return state, nil
+}
+
+// parseNSids returns the IDs of a task in its PID namespaces, as listed in the
+// NSpid and NStgid fields of a task status.
+func parseNSids(value []byte) ([]int, error) {
+	fields := bytes.Fields(value)
+	ids := make([]int, 0, len(fields))
+	for _, field := range fields {
+		id, err := strconv.Atoi(string(field))
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// InnermostIDs returns the thread (task) ID and thread group (process) ID of
+// the task in its own PID namespace, such as inside its container, if known.
+func (s State) InnermostIDs() (tid, tgid int, ok bool) {
+	if len(s.NSpid) == 0 || len(s.NStgid) == 0 {
+		return 0, 0, false
+	}
+	return s.NSpid[len(s.NSpid)-1], s.NStgid[len(s.NStgid)-1], true
+}
@@ -0,0 +1,124 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"os"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+const taskStatus = `Name:	cat
+Umask:	0022
+State:	R (running)
+Tgid:	4242
+Pid:	4243
+NStgid:	4242	1
+NSpid:	4243	2
+CapInh:	0000000000000000
+CapPrm:	000001ffffffffff
+CapEff:	000001ffffffffff
+CapBnd:	000001fffeffffff
+CapAmb:	0000000000000400
+NoNewPrivs:	1
+Seccomp:	0
+`
+
+var _ = Describe("task state", func() {
+
+	It("parses the task status", func() {
+		state := Successful(parseStatus([]byte(taskStatus)))
+		Expect(state.Inheritable.Names()).To(BeEmpty())
+		Expect(state.Effective.Has(CAP_CHECKPOINT_RESTORE)).To(BeTrue())
+		Expect(state.Permitted).To(Equal(state.Effective))
+		Expect(state.Bounding.Has(CAP_SYS_RESOURCE)).To(BeFalse())
+		Expect(state.Bounding.Has(CAP_SYS_ADMIN)).To(BeTrue())
+		Expect(state.Ambient.Names()).To(ConsistOf("CAP_NET_BIND_SERVICE"))
+		Expect(state.NoNewPrivs).To(BeTrue())
+		Expect(state.NSpid).To(Equal([]int{4243, 2}))
+		Expect(state.NStgid).To(Equal([]int{4242, 1}))
+		tid, tgid, ok := state.InnermostIDs()
+		Expect(ok).To(BeTrue())
+		Expect(tid).To(Equal(2))
+		Expect(tgid).To(Equal(1))
+	})
+
+	It("accepts a missing ambient set", func() {
+		state := Successful(parseStatus([]byte("CapInh:	00\nCapPrm:	00\nCapEff:	00\nCapBnd:	00\n")))
+		Expect(state.Ambient).NotTo(BeNil())
+		Expect(state.Ambient).To(BeEmpty())
+		Expect(state.NSpid).To(BeNil())
+		_, _, ok := state.InnermostIDs()
+		Expect(ok).To(BeFalse())
+	})
+
+	DescribeTable("rejects invalid task status",
+		func(status string) {
+			Expect(parseStatus([]byte(status))).Error().To(HaveOccurred())
+		},
+		Entry("missing fields", "CapInh:	0\n"),
+		Entry("invalid field", "CapInh:	xyz\nCapPrm:	00\nCapEff:	00\nCapBnd:	00\n"),
+		Entry("invalid NSpid", "NSpid:	42	foo\nCapInh:	00\nCapPrm:	00\nCapEff:	00\nCapBnd:	00\n"),
+	)
+
+	It("returns canonical snapshots", func() {
+		state := Successful(parseStatus([]byte(taskStatus)))
+		state.Effective = NewCapabilitiesSet()
+		state.Effective.Add(CAP_NET_BIND_SERVICE, 63)
+		state.Permitted = state.Effective.Clone()
+		state.Bounding = CapabilitiesSet{0x401, 0, 0}
+		state.Securebits = 0x11
+		Expect(state.Canonical()).To(Equal(`caps-state v1
+effective: 10,63
+permitted: 10,63
+inheritable:
+bounding: 0,10
+ambient: 10
+securebits: 0,4
+nonewprivs: 1
+`))
+		Expect(State{}.Canonical()).To(HaveSuffix("ambient:\nsecurebits:\nnonewprivs: 0\n"))
+	})
+
+	It("distinguishes canonical snapshots by securebits", func() {
+		state := Successful(parseStatus([]byte(taskStatus)))
+		other := state
+		other.Securebits = 1 << 4
+		Expect(other.Canonical()).NotTo(Equal(state.Canonical()))
+	})
+
+	It("reads the state of the current task", func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		state := Successful(StateOf(0))
+		taskcaps := Successful(OfThisTask())
+		Expect(state.Effective.normalized()).To(Equal(taskcaps.Effective.normalized()))
+		Expect(state.Permitted.normalized()).To(Equal(taskcaps.Permitted.normalized()))
+		Expect(state.Inheritable.normalized()).To(Equal(taskcaps.Inheritable.normalized()))
+		Expect(state.Bounding).NotTo(BeEmpty())
+		securebits := Successful(unix.PrctlRetInt(unix.PR_GET_SECUREBITS, 0, 0, 0, 0))
+		Expect(state.Securebits).To(Equal(uint(securebits)))
+		Expect(state.NSpid).To(ContainElement(unix.Gettid()))
+
+		Expect(StateOf(os.Getpid())).Error().NotTo(HaveOccurred())
+		Expect(StateOf(-1)).Error().To(HaveOccurred())
+	})
+
+})
@@ -0,0 +1,91 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+// SysAdminAdvice tells whether an operation that (historically) required
+// CAP_SYS_ADMIN can instead be carried out using fine-grained capabilities,
+// or whether CAP_SYS_ADMIN is still unavoidable.
+type SysAdminAdvice struct {
+	Operation   Operation       // operation this advice is about
+	Replacement CapabilitiesSet // fine-grained capabilities replacing CAP_SYS_ADMIN; empty if unavoidable
+	Since       string          // kernel version introducing the replacement, if known
+	Supported   bool            // the kernel we're running on supports the replacement
+	Unavoidable bool            // CAP_SYS_ADMIN is still required
+	Note        string          // additional guidance, if any
+}
+
+// sysAdminDecomposition describes how operations that once required
+// CAP_SYS_ADMIN have been moved to more fine-grained capabilities over time,
+// or where CAP_SYS_ADMIN still is the only option.
+var sysAdminDecomposition = map[Operation]struct {
+	since string
+	note  string
+}{
+	OpLoadBPF: {since: "5.8",
+		note: "networking programs additionally need CAP_NET_ADMIN, tracing programs CAP_PERFMON"},
+	OpPerfMonitoring:    {since: "5.8"},
+	OpCheckpointRestore: {since: "5.9"},
+	OpReadKernelLog:     {since: "2.6.37"},
+	OpMount: {
+		note: "unprivileged mounts of some filesystem types, such as tmpfs, proc, and overlay, " +
+			"are possible inside a user namespace owned by the caller"},
+	OpCreateRestrictedUserNamespace: {},
+	OpCreateNamespace: {
+		note: "non-user namespaces can be created inside a newly created user namespace instead"},
+	OpJoinNamespace: {},
+	OpSetHostname: {
+		note: "setting the host name of a UTS namespace owned by the caller's user namespace suffices"},
+	OpInjectTerminalInput: {
+		note: "since 6.2, TIOCSTI can be disabled altogether using the dev.tty.legacy_tiocsti sysctl"},
+}
+
+// AdviseSysAdmin returns advice for each of the specified operations about
+// whether it can be carried out without CAP_SYS_ADMIN, using more fine-grained
+// capabilities instead, such as CAP_BPF, CAP_PERFMON, or
+// CAP_CHECKPOINT_RESTORE. The advice reflects the kernel's ongoing
+// decomposition of CAP_SYS_ADMIN and reports where CAP_SYS_ADMIN is still
+// unavoidable. Operations that never required CAP_SYS_ADMIN are reported with
+// their required capabilities as replacement.
+func AdviseSysAdmin(ops ...Operation) []SysAdminAdvice {
+	advice := make([]SysAdminAdvice, 0, len(ops))
+	for _, op := range ops {
+		decomp := sysAdminDecomposition[op]
+		a := SysAdminAdvice{
+			Operation:   op,
+			Replacement: NewCapabilitiesSet(),
+			Since:       decomp.since,
+			Supported:   true,
+			Note:        Localize(decomp.note),
+		}
+		for _, capno := range operationCaps[op] {
+			if capno == CAP_SYS_ADMIN {
+				a.Unavoidable = true
+				continue
+			}
+			a.Replacement.Add(capno)
+			if capno > LastCapability() {
+				a.Supported = false
+			}
+		}
+		if a.Unavoidable {
+			a.Replacement = NewCapabilitiesSet()
+			a.Supported = false
+		}
+		advice = append(advice, a)
+	}
+	return advice
+}
@@ -0,0 +1,57 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CAP_SYS_ADMIN decomposition", func() {
+
+	BeforeEach(func() {
+		cfg := CurrentConfig()
+		DeferCleanup(func() { Configure(cfg) })
+	})
+
+	It("advises fine-grained replacements", func() {
+		advice := AdviseSysAdmin(OpLoadBPF, OpPerfMonitoring, OpChroot)
+		Expect(advice).To(HaveLen(3))
+		Expect(advice[0].Operation).To(Equal(OpLoadBPF))
+		Expect(advice[0].Replacement.Names()).To(ConsistOf("CAP_BPF"))
+		Expect(advice[0].Since).To(Equal("5.8"))
+		Expect(advice[0].Unavoidable).To(BeFalse())
+		Expect(advice[0].Note).To(ContainSubstring("CAP_NET_ADMIN"))
+		Expect(advice[1].Replacement.Names()).To(ConsistOf("CAP_PERFMON"))
+		Expect(advice[2].Replacement.Names()).To(ConsistOf("CAP_SYS_CHROOT"))
+		Expect(advice[2].Supported).To(BeTrue())
+	})
+
+	It("reports unavoidable CAP_SYS_ADMIN", func() {
+		for _, a := range AdviseSysAdmin(OpMount, OpCreateNamespace, OpJoinNamespace, OpInjectTerminalInput) {
+			Expect(a.Unavoidable).To(BeTrue(), a.Operation.String())
+			Expect(a.Replacement).To(BeEmpty())
+			Expect(a.Supported).To(BeFalse())
+		}
+	})
+
+	It("reports unsupported replacements", func() {
+		Configure(Config{LastCapability: CAP_AUDIT_READ})
+		advice := AdviseSysAdmin(OpCheckpointRestore)
+		Expect(advice[0].Replacement.Names()).To(ConsistOf("CAP_CHECKPOINT_RESTORE", "CAP_SYS_PTRACE"))
+		Expect(advice[0].Supported).To(BeFalse())
+	})
+
+})
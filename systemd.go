@@ -0,0 +1,136 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// SystemdCapabilities represents the capabilities-related directives of a
+// systemd service unit, see also [systemd.exec(5)].
+//
+// [systemd.exec(5)]: https://www.freedesktop.org/software/systemd/man/systemd.exec.html#Capabilities
+type SystemdCapabilities struct {
+	Ambient  CapabilitiesSet // AmbientCapabilities=
+	Bounding CapabilitiesSet // CapabilityBoundingSet=
+}
+
+// SystemdCapabilitiesOf returns the systemd directives equivalent to the
+// ambient and bounding capabilities of the specified task state, so that a
+// service unit can reproduce the live state of an existing process.
+func SystemdCapabilitiesOf(state State) SystemdCapabilities {
+	return SystemdCapabilities{
+		Ambient:  state.Ambient.Clone(),
+		Bounding: state.Bounding.Clone(),
+	}
+}
+
+// Directives returns the AmbientCapabilities= and CapabilityBoundingSet=
+// directives for use in the [Service] section of a systemd unit, one directive
+// per line.
+func (s SystemdCapabilities) Directives() string {
+	return "AmbientCapabilities=" + strings.Join(s.Ambient.Names(), " ") + "\n" +
+		"CapabilityBoundingSet=" + strings.Join(s.Bounding.Names(), " ") + "\n"
+}
+
+// ParseSystemdCapabilities parses the AmbientCapabilities= and
+// CapabilityBoundingSet= directives from the specified systemd unit text,
+// ignoring any other lines. The directive values are evaluated as systemd does
+// using [ApplySystemdValue], which additionally resolves the names of
+// registered bundles (see [RegisterBundle]). Directives not present result in
+// empty sets.
+func ParseSystemdCapabilities(unit string) (SystemdCapabilities, error) {
+	var s SystemdCapabilities
+	scanner := bufio.NewScanner(strings.NewReader(unit))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		var set *CapabilitiesSet
+		switch strings.TrimSpace(key) {
+		case "AmbientCapabilities":
+			set = &s.Ambient
+		case "CapabilityBoundingSet":
+			set = &s.Bounding
+		default:
+			continue
+		}
+		caps, err := ApplySystemdValue(*set, value)
+		if err != nil {
+			return SystemdCapabilities{}, fmt.Errorf("invalid %s directive: %w", key, err)
+		}
+		*set = caps
+	}
+	if s.Ambient == nil {
+		s.Ambient = NewCapabilitiesSet()
+	}
+	if s.Bounding == nil {
+		s.Bounding = NewCapabilitiesSet()
+	}
+	return s, nil
+}
+
+// ApplySystemdValue applies the value of an AmbientCapabilities= or
+// CapabilityBoundingSet= directive to the current capabilities set, returning
+// the resulting set; current is nil if the directive hasn't been assigned
+// before. The value follows systemd's syntax:
+//   - a space-separated list of capability names, or names of registered
+//     bundles (see [RegisterBundle]),
+//   - optionally prefixed with "~" to invert the list, that is, all
+//     capabilities except those listed,
+//   - the empty string resets to the empty set, discarding all prior
+//     assignments.
+//
+// The first assignment, as well as assignments of "~" on its own, replace the
+// current set; further assignments are merged: capabilities listed are added,
+// while inverted lists remove the listed capabilities.
+func ApplySystemdValue(current CapabilitiesSet, value string) (CapabilitiesSet, error) {
+	value = strings.TrimSpace(value)
+	invert := strings.HasPrefix(value, "~")
+	if invert {
+		value = value[1:]
+	}
+	if value == "" && !invert {
+		return NewCapabilitiesSet(), nil
+	}
+	listed := NewCapabilitiesSet()
+	for _, name := range strings.Fields(value) {
+		caps, err := namedCapabilities(name, CapabilityByName)
+		if err != nil {
+			return nil, err
+		}
+		listed.addSet(caps)
+	}
+	if current == nil || len(listed.normalized()) == 0 {
+		if invert {
+			all := AllCapabilities()
+			all.dropSet(listed)
+			return all, nil
+		}
+		return listed, nil
+	}
+	result := current.Clone()
+	if invert {
+		result.dropSet(listed)
+	} else {
+		result.addSet(listed)
+	}
+	return result, nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("systemd directives", func() {
+
+	It("emits directives for a task state", func() {
+		state := Successful(parseStatus([]byte(taskStatus)))
+		state.Bounding = NewCapabilitiesSet()
+		state.Bounding.Add(CAP_NET_BIND_SERVICE, CAP_CHOWN)
+		Expect(SystemdCapabilitiesOf(state).Directives()).To(Equal(
+			"AmbientCapabilities=CAP_NET_BIND_SERVICE\n" +
+				"CapabilityBoundingSet=CAP_CHOWN CAP_NET_BIND_SERVICE\n"))
+	})
+
+	It("parses directives", func() {
+		s := Successful(ParseSystemdCapabilities(`[Service]
+ExecStart=/usr/bin/foo
+AmbientCapabilities=CAP_NET_BIND_SERVICE
+CapabilityBoundingSet=CAP_NET_BIND_SERVICE cap_chown
+CapabilityBoundingSet=CAP_NET_RAW
+`))
+		Expect(s.Ambient.Names()).To(ConsistOf("CAP_NET_BIND_SERVICE"))
+		Expect(s.Bounding.Names()).To(ConsistOf("CAP_NET_BIND_SERVICE", "CAP_CHOWN", "CAP_NET_RAW"))
+
+		s = Successful(ParseSystemdCapabilities(""))
+		Expect(s.Ambient).To(BeEmpty())
+		Expect(s.Bounding).To(BeEmpty())
+	})
+
+	DescribeTable("applies directive values",
+		func(values []string, expected func() CapabilitiesSet) {
+			var caps CapabilitiesSet
+			for _, value := range values {
+				caps = Successful(ApplySystemdValue(caps, value))
+			}
+			Expect(caps.Names()).To(ConsistOf(expected().Names()))
+		},
+		Entry("names", []string{"CAP_CHOWN CAP_NET_RAW"}, func() CapabilitiesSet {
+			return Successful(CapabilitiesFromNames([]string{"CAP_CHOWN", "CAP_NET_RAW"}))
+		}),
+		Entry("merged names", []string{"CAP_CHOWN", "CAP_NET_RAW"}, func() CapabilitiesSet {
+			return Successful(CapabilitiesFromNames([]string{"CAP_CHOWN", "CAP_NET_RAW"}))
+		}),
+		Entry("inverted", []string{"~CAP_SYS_ADMIN"}, func() CapabilitiesSet {
+			return Successful(EvalExpression(nil, "all,-CAP_SYS_ADMIN"))
+		}),
+		Entry("merged inverted", []string{"CAP_CHOWN CAP_NET_RAW", "~CAP_NET_RAW"}, func() CapabilitiesSet {
+			return Successful(CapabilitiesFromNames([]string{"CAP_CHOWN"}))
+		}),
+		Entry("tilde only", []string{"CAP_CHOWN", "~"}, AllCapabilities),
+		Entry("reset", []string{"CAP_CHOWN", "", "CAP_NET_RAW"}, func() CapabilitiesSet {
+			return Successful(CapabilitiesFromNames([]string{"CAP_NET_RAW"}))
+		}),
+		Entry("reset only", []string{"CAP_CHOWN", ""}, NewCapabilitiesSet),
+	)
+
+	It("round-trips directives", func() {
+		s := SystemdCapabilities{Ambient: NewCapabilitiesSet(), Bounding: NewCapabilitiesSet()}
+		s.Ambient.Add(CAP_NET_RAW)
+		s.Bounding.Add(CAP_NET_RAW, CAP_SYS_ADMIN)
+		parsed := Successful(ParseSystemdCapabilities(s.Directives()))
+		Expect(parsed.Ambient.Names()).To(Equal(s.Ambient.Names()))
+		Expect(parsed.Bounding.Names()).To(Equal(s.Bounding.Names()))
+	})
+
+	It("rejects invalid directives", func() {
+		Expect(ParseSystemdCapabilities("AmbientCapabilities=CAP_FOOBAR")).Error().To(
+			MatchError(ContainSubstring("invalid AmbientCapabilities directive")))
+	})
+
+})
@@ -0,0 +1,179 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"sort"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// TaintedThread describes an OS-level thread of this process whose
+// capabilities have been modified through this package.
+type TaintedThread struct {
+	TID      int              // thread (task) ID
+	Baseline TaskCapabilities // capabilities before the first modification
+	Current  TaskCapabilities // capabilities after the latest modification
+}
+
+// taint is a tainted thread in the registry of tainted threads, together with
+// the thread's start time, telling apart threads that reuse the TID of a
+// terminated thread.
+type taint struct {
+	TaintedThread
+	started uint64
+}
+
+// taints is the registry of tainted threads, keyed by their TIDs. Entries of
+// terminated threads are pruned whenever a new thread gets tainted, so the
+// registry never grows beyond the number of live tainted threads plus the
+// threads terminated since the latest pruning. Additionally, this package
+// removes the entries of its own throw-away threads before they terminate,
+// see [discardThisTask].
+var taints = struct {
+	sync.Mutex
+	m map[int]*taint
+}{m: map[int]*taint{}}
+
+// TaintedThreads returns the OS-level threads of this process whose
+// capabilities currently differ from the capabilities they had before they
+// were modified through this package, sorted by their TIDs. Threads changing
+// their capabilities back to their baseline are no longer tainted, and neither
+// are threads that have terminated in the meantime.
+//
+// Capabilities changed by other means than this package, such as by directly
+// calling capset(2), go unnoticed. Changing the capabilities of all threads of
+// this process at once, as done by [SetupFor], establishes a new baseline for
+// all threads, so afterwards no threads are tainted.
+func TaintedThreads() []TaintedThread {
+	taints.Lock()
+	defer taints.Unlock()
+	pruneTaints()
+	threads := make([]TaintedThread, 0, len(taints.m))
+	for tid, taint := range taints.m {
+		if started, err := taskStartTime(tid); err != nil || started != taint.started {
+			delete(taints.m, tid) // TID has been reused by a new thread.
+			continue
+		}
+		threads = append(threads, TaintedThread{
+			TID:      taint.TID,
+			Baseline: taint.Baseline.Clone(),
+			Current:  taint.Current.Clone(),
+		})
+	}
+	sort.Slice(threads, func(i, j int) bool { return threads[i].TID < threads[j].TID })
+	return threads
+}
+
+// pruneTaints removes the entries of terminated threads from the registry of
+// tainted threads; the caller must hold the registry lock.
+func pruneTaints() {
+	pid := unix.Getpid()
+	for tid := range taints.m {
+		if unix.Tgkill(pid, tid, 0) == unix.ESRCH {
+			delete(taints.m, tid)
+		}
+	}
+}
+
+// taintThisTask records that the capabilities of the calling task are about to
+// be changed, remembering its current capabilities as its baseline if the task
+// isn't tainted yet. It returns the TID of the calling task.
+func taintThisTask() int {
+	tid := unix.Gettid()
+	taints.Lock()
+	_, ok := taints.m[tid]
+	taints.Unlock()
+	if ok {
+		return tid
+	}
+	ensureBaseline()
+	before, err := OfThisTask()
+	if err != nil {
+		return tid
+	}
+	started, err := taskStartTime(tid)
+	if err != nil {
+		return tid
+	}
+	taints.Lock()
+	pruneTaints()
+	taints.m[tid] = &taint{TaintedThread: TaintedThread{TID: tid, Baseline: before}, started: started}
+	taints.Unlock()
+	return tid
+}
+
+// recordTaint records the capabilities the specified task has been changed to,
+// untainting the task when it returned to its baseline.
+func recordTaint(tid int, current TaskCapabilities) {
+	taints.Lock()
+	defer taints.Unlock()
+	taint, ok := taints.m[tid]
+	if !ok {
+		return
+	}
+	if equalSets(taint.Baseline.Effective, current.Effective) &&
+		equalSets(taint.Baseline.Permitted, current.Permitted) &&
+		equalSets(taint.Baseline.Inheritable, current.Inheritable) {
+		delete(taints.m, tid)
+		return
+	}
+	taint.Current = current.Clone()
+}
+
+// equalSets returns true if both capabilities sets contain the same
+// capabilities, independent of trailing zero words.
+func equalSets(a, b CapabilitiesSet) bool {
+	a, b = a.normalized(), b.normalized()
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+// discardThisTask forgets about the calling task, as its locked thread is about
+// to be thrown away when its Go routine finishes.
+func discardThisTask() {
+	tid := unix.Gettid()
+	taints.Lock()
+	delete(taints.m, tid)
+	taints.Unlock()
+}
+
+// abandonTaint forgets about the specified task if its capabilities haven't
+// been successfully changed yet.
+func abandonTaint(tid int) {
+	taints.Lock()
+	defer taints.Unlock()
+	if taint, ok := taints.m[tid]; ok && taint.Current.Effective == nil {
+		delete(taints.m, tid)
+	}
+}
+
+// untaintAll forgets about all tainted threads, as all threads of this process
+// now share the same new baseline.
+func untaintAll() {
+	taints.Lock()
+	taints.m = map[int]*taint{}
+	taints.Unlock()
+}
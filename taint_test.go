@@ -0,0 +1,133 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"runtime"
+
+	"github.com/thediveo/caps/capstest"
+	"golang.org/x/sys/unix"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+// taintedTIDs returns the TIDs of the currently tainted threads.
+func taintedTIDs() []int {
+	tids := []int{}
+	for _, taint := range TaintedThreads() {
+		tids = append(tids, taint.TID)
+	}
+	return tids
+}
+
+var _ = Describe("tainted threads", func() {
+
+	BeforeEach(func() {
+		capstest.RequirePermitted(GinkgoT(), CAP_CHOWN, CAP_NET_RAW)
+	})
+
+	It("tracks and untaints threads", func() {
+		var tid int
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			// Never unlock this thread, so that it gets thrown away when this
+			// Go routine finishes.
+			runtime.LockOSThread()
+			tid = unix.Gettid()
+			Expect(taintedTIDs()).NotTo(ContainElement(tid))
+
+			baseline := Successful(SetEffectiveCaps(CAP_CHOWN))
+			tainted := TaintedThreads()
+			Expect(tainted).To(ContainElement(HaveField("TID", tid)))
+			for _, taint := range tainted {
+				if taint.TID == tid {
+					Expect(taint.Baseline.Effective.Has(CAP_NET_RAW)).To(BeTrue())
+					Expect(taint.Current.Effective.Names()).To(ConsistOf("CAP_CHOWN"))
+				}
+			}
+
+			Expect(SetForThisTask(baseline)).To(Succeed())
+			Expect(taintedTIDs()).NotTo(ContainElement(tid))
+
+			Expect(SetEffectiveCaps(CAP_NET_RAW)).Error().NotTo(HaveOccurred())
+			Expect(taintedTIDs()).To(ContainElement(tid))
+		}()
+		<-done
+		Eventually(taintedTIDs).Should(Not(ContainElement(tid)))
+	})
+
+	It("doesn't taint threads on failing changes", func() {
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			runtime.LockOSThread()
+			taskcaps := Successful(OfThisTask())
+			taskcaps.Effective.Add(CAP_NET_RAW)
+			taskcaps.Permitted.Drop(CAP_NET_RAW) // effective must be a subset
+			Expect(SetForThisTask(taskcaps)).NotTo(Succeed())
+			Expect(taintedTIDs()).NotTo(ContainElement(unix.Gettid()))
+		}()
+		<-done
+	})
+
+	It("doesn't leak entries of throw-away threads", func() {
+		taints.Lock()
+		before := len(taints.m)
+		taints.Unlock()
+		for i := 0; i < 200; i++ {
+			Expect(Raised(func() error { return nil }, CAP_CHOWN)).To(Succeed())
+		}
+		taints.Lock()
+		defer taints.Unlock()
+		Expect(len(taints.m)).To(BeNumerically("<=", before))
+	})
+
+	It("prunes terminated threads and reused TIDs", func() {
+		tids := make(chan int)
+		go func() {
+			runtime.LockOSThread() // throw away
+			tids <- unix.Gettid()
+		}()
+		dead := <-tids
+		Eventually(func() error {
+			return unix.Tgkill(unix.Getpid(), dead, 0)
+		}).Should(MatchError(unix.ESRCH))
+		taints.Lock()
+		taints.m[dead] = &taint{TaintedThread: TaintedThread{TID: dead}}
+		taints.Unlock()
+
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			runtime.LockOSThread() // throw away
+			tid := unix.Gettid()
+			Expect(SetEffectiveCaps(CAP_CHOWN)).Error().NotTo(HaveOccurred())
+			taints.Lock()
+			Expect(taints.m).NotTo(HaveKey(dead))
+			Expect(taints.m).To(HaveKey(tid))
+			taints.m[tid].started++ // pretend that the TID got reused
+			taints.Unlock()
+			Expect(taintedTIDs()).NotTo(ContainElement(tid))
+		}()
+		<-done
+	})
+
+})
@@ -20,6 +20,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"unsafe"
 
 	"github.com/thediveo/caps/errno"
@@ -37,9 +38,9 @@ import (
 // and [SetEffectiveCaps], and with the result obtained then calling
 // [SetTaskCaps].
 type TaskCapabilities struct {
-	Effective   CapabilitiesSet
-	Permitted   CapabilitiesSet
-	Inheritable CapabilitiesSet
+	Effective   CapabilitiesSet `yaml:"effective"`
+	Permitted   CapabilitiesSet `yaml:"permitted"`
+	Inheritable CapabilitiesSet `yaml:"inheritable"`
 }
 
 // Clone returns an independent clone of the task capabilities. Modifications to
@@ -89,9 +90,51 @@ const capDataElements = LINUX_CAPABILITY_U32S_3
 // KernelCapabilityVersion returns the version of the capabilities user-space
 // data structure that the Linux kernel we're just running on "natively" uses.
 // In case the version could not properly be detected, 0 is returned instead.
-func KernelCapabilityVersion() uint32 { return linuxCapabilityVersion }
+// See [KernelCapabilityVersionInfo] for details about the version negotiation.
+// A version set in [Config.KernelCapabilityVersion] takes precedence.
+func KernelCapabilityVersion() uint32 {
+	if version := config.Load().KernelCapabilityVersion; version != 0 {
+		return version
+	}
+	return linuxCapabilityVersion.Load()
+}
+
+var linuxCapabilityVersion atomic.Uint32
+
+// LastCapability returns the number of the highest capability supported by the
+// kernel we're now running on. This value might differ from
+// [MaxCapabilityNumber] that is known to this package. A capability set in
+// [Config.LastCapability] takes precedence.
+func LastCapability() int {
+	if last := config.Load().LastCapability; last > 0 {
+		return last
+	}
+	return int(lastCapability.Load())
+}
+
+var lastCapability atomic.Int32
+
+func init() {
+	RefreshKernelInfo()
+}
 
-var linuxCapabilityVersion uint32
+// RefreshKernelInfo probes the kernel we're running on again for its native
+// capabilities version and its highest supported capability, as returned by
+// [KernelCapabilityVersion] and [LastCapability], returning true if any of
+// them has changed. The kernel information is otherwise probed only once
+// when this package initializes, so long-running processes surviving kernel
+// live updates, or tests running against different kernels, should call
+// RefreshKernelInfo in order to not work with stale information.
+// RefreshKernelInfo is safe for concurrent use.
+func RefreshKernelInfo() bool {
+	version := probeCapabilityVersion()
+	last := probeLastCapability()
+	changed := linuxCapabilityVersion.Swap(version) != version
+	if lastCapability.Swap(int32(last)) != int32(last) {
+		changed = true
+	}
+	return changed
+}
 
 // As can be glanced from (when you know it's there)
 // https://elixir.bootlin.com/linux/v6.1/source/kernel/capability.c#L100, the
@@ -99,7 +142,7 @@ var linuxCapabilityVersion uint32
 // user-space data structure when trying to get capabilities using a
 // non-existing version; the best bet is 0, as this is a version that was never
 // used, nor will ever be used.
-func init() {
+func probeCapabilityVersion() uint32 {
 	var capHeader = unix.CapUserHeader{Version: 0} // never was, won't ever be.
 
 	_, _, _ = unix.RawSyscall(
@@ -107,22 +150,19 @@ func init() {
 		uintptr(unsafe.Pointer(&capHeader)),
 		0,
 		0)
-	linuxCapabilityVersion = capHeader.Version // now "should have been" changed by the kernel.
+	return capHeader.Version // now "should have been" changed by the kernel.
 }
 
-// LastCapability returns the number of the highest capability supported by the
-// kernel we're now running on. This value might differ from
-// [MaxCapabilityNumber] that is known to this package.
-func LastCapability() int { return lastCapability }
-
-var lastCapability int
-
-func init() {
+// probeLastCapability returns the highest capability supported by the kernel
+// we're running on, falling back to [MaxCapabilityNumber] if it cannot be
+// determined.
+func probeLastCapability() int {
 	contents, _ := os.ReadFile("/proc/sys/kernel/cap_last_cap")
-	lastCapability, _ = strconv.Atoi(strings.TrimSuffix(string(contents), "\n"))
-	if lastCapability == 0 {
-		lastCapability = MaxCapabilityNumber
+	last, _ := strconv.Atoi(strings.TrimSuffix(string(contents), "\n"))
+	if last == 0 {
+		last = MaxCapabilityNumber
 	}
+	return last
 }
 
 // OfThisTask returns the effective, permitted and inheritable capability sets
@@ -137,7 +177,7 @@ func OfThisTask() (taskcaps TaskCapabilities, err error) {
 // an error is returned instead with a zero set of capabilities.
 func OfTask(tid int) (taskcaps TaskCapabilities, err error) {
 	var capHeader = unix.CapUserHeader{
-		Version: unix.LINUX_CAPABILITY_VERSION_3,
+		Version: LINUX_CAPABILITY_VERSION_3,
 		Pid:     int32(tid),
 	}
 	var capData [capDataElements]unix.CapUserData
@@ -148,26 +188,21 @@ func OfTask(tid int) (taskcaps TaskCapabilities, err error) {
 		uintptr(unsafe.Pointer(&capData[0])),
 		0)
 	if e != 0 {
-		return TaskCapabilities{}, errno.Error(e)
-	}
-
-	caps := CapabilitiesSet(make([]uint32, capDataElements))
-	for idx := 0; idx < capDataElements; idx++ {
-		caps[idx] = capData[idx].Effective
-	}
-	taskcaps.Effective = caps
-
-	caps = CapabilitiesSet(make([]uint32, capDataElements))
-	for idx := 0; idx < capDataElements; idx++ {
-		caps[idx] = capData[idx].Permitted
+		// capget never fails with EPERM or ENOSYS on its own.
+		return TaskCapabilities{}, blockedBySeccomp(errno.Wrap("capget", e))
 	}
-	taskcaps.Permitted = caps
 
-	caps = CapabilitiesSet(make([]uint32, capDataElements))
+	// Allocate the words of all three sets in one go; the sets are capped so
+	// that growing one set never overwrites the words of the next set.
+	words := make([]uint32, 3*capDataElements)
+	taskcaps.Effective = CapabilitiesSet(words[0:capDataElements:capDataElements])
+	taskcaps.Permitted = CapabilitiesSet(words[capDataElements : 2*capDataElements : 2*capDataElements])
+	taskcaps.Inheritable = CapabilitiesSet(words[2*capDataElements:])
 	for idx := 0; idx < capDataElements; idx++ {
-		caps[idx] = capData[idx].Inheritable
+		taskcaps.Effective[idx] = capData[idx].Effective
+		taskcaps.Permitted[idx] = capData[idx].Permitted
+		taskcaps.Inheritable[idx] = capData[idx].Inheritable
 	}
-	taskcaps.Inheritable = caps
 
 	return
 }
@@ -181,31 +216,56 @@ func SetForThisTask(taskcaps TaskCapabilities) error {
 // SetForTask sets the capability sets (effective, permitted and inheritable)
 // for the specified task.
 func SetForTask(tid int, taskcaps TaskCapabilities) error {
+	// Only the kernel knows for sure which thread the calling Go routine runs
+	// on when it isn't locked to its thread, so always pass zero for the
+	// calling task and use its TID only for tracking tainted threads.
+	self := tid == 0 || tid == unix.Gettid()
+	var tainted int
+	if self {
+		tid = 0
+		tainted = taintThisTask()
+	}
 	var capHeader = unix.CapUserHeader{
-		Version: unix.LINUX_CAPABILITY_VERSION_3,
+		Version: LINUX_CAPABILITY_VERSION_3,
 		Pid:     int32(tid),
 	}
-	var capData [capDataElements]unix.CapUserData
-
-	for idx := 0; idx < capDataElements; idx++ {
-		if idx < len(taskcaps.Effective) {
-			capData[idx].Effective = taskcaps.Effective[idx]
-		}
-		if idx < len(taskcaps.Permitted) {
-			capData[idx].Permitted = taskcaps.Permitted[idx]
-		}
-		if idx < len(taskcaps.Inheritable) {
-			capData[idx].Inheritable = taskcaps.Inheritable[idx]
-		}
-	}
+	capData := taskcaps.capUserData()
 
 	_, _, e := unix.RawSyscall(
 		unix.SYS_CAPSET,
 		uintptr(unsafe.Pointer(&capHeader)),
 		uintptr(unsafe.Pointer(&capData[0])),
 		0)
+	if self && (e != 0 || unix.Gettid() != tainted) {
+		// Either nothing changed, or the Go routine has been moved to a
+		// different thread in the meantime, so we cannot tell which thread
+		// got changed.
+		abandonTaint(tainted)
+	} else if self {
+		recordTaint(tainted, taskcaps)
+	}
 	if e != 0 {
-		return errno.Error(e)
+		if e == unix.ENOSYS {
+			return blockedBySeccomp(errno.Wrap("capset", e))
+		}
+		return errno.Wrap("capset", e)
 	}
 	return nil
 }
+
+// capUserData returns the task capabilities in the capset(2) user-space
+// representation.
+func (t TaskCapabilities) capUserData() (capData [capDataElements]unix.CapUserData) {
+	for idx := 0; idx < capDataElements; idx++ {
+		if idx < len(t.Effective) {
+			capData[idx].Effective = t.Effective[idx]
+		}
+		if idx < len(t.Permitted) {
+			capData[idx].Permitted = t.Permitted[idx]
+		}
+		if idx < len(t.Inheritable) {
+			capData[idx].Inheritable = t.Inheritable[idx]
+		}
+	}
+	return
+}
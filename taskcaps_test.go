@@ -16,10 +16,11 @@ package caps
 
 import (
 	"fmt"
-	"os"
 	"runtime"
 	"syscall"
 
+	"github.com/thediveo/caps/capstest"
+	"github.com/thediveo/caps/errno"
 	"golang.org/x/sys/unix"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -29,18 +30,37 @@ import (
 
 var _ = Describe("task capabilities", func() {
 
+	It("refreshes kernel information", func() {
+		defer lastCapability.Store(lastCapability.Load())
+		defer linuxCapabilityVersion.Store(linuxCapabilityVersion.Load())
+		last, version := LastCapability(), KernelCapabilityVersion()
+		Expect(RefreshKernelInfo()).To(BeFalse())
+
+		lastCapability.Store(CAP_AUDIT_READ)
+		linuxCapabilityVersion.Store(0)
+		Expect(RefreshKernelInfo()).To(BeTrue())
+		Expect(LastCapability()).To(Equal(last))
+		Expect(KernelCapabilityVersion()).To(Equal(version))
+	})
+
 	It("returns an error when asking capabilities of a non-existing task", func() {
 		Expect(OfTask(-1)).Error().To(MatchError(syscall.EINVAL))
 	})
 
 	It("returns an error when trying to set the capabilities of a non-existing task", func() {
 		Expect(SetForTask(-1, TaskCapabilities{})).Error().To(MatchError(syscall.EPERM))
+		Expect(SetForTask(-1, TaskCapabilities{})).Error().To(MatchError(errno.ErrPermission))
+	})
+
+	It("returns independent capabilities sets", func() {
+		taskcaps := Successful(OfThisTask())
+		permitted := taskcaps.Permitted.Clone()
+		taskcaps.Effective.Add(MaxCapabilityNumber + 64)
+		Expect(taskcaps.Permitted).To(Equal(permitted))
 	})
 
 	It("drops and reinstates capabilities", func() {
-		if os.Getuid() != 0 {
-			Skip("needs root")
-		}
+		capstest.RequirePermitted(GinkgoT(), CAP_NET_RAW)
 		done := make(chan struct{})
 		go func() {
 			defer GinkgoRecover()
@@ -66,9 +86,7 @@ var _ = Describe("task capabilities", func() {
 	})
 
 	It("sets the effective capabilities", func() {
-		if os.Getuid() != 0 {
-			Skip("needs root")
-		}
+		capstest.RequirePermitted(GinkgoT(), CAP_NET_RAW, CAP_SYS_ADMIN)
 		done := make(chan struct{})
 		go func() {
 			defer GinkgoRecover()
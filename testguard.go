@@ -0,0 +1,77 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrTestGuard indicates that an irreversible operation on the calling process
+// has been refused as the test guard is active, see [SetTestGuard].
+var ErrTestGuard = errors.New("irreversible operation refused by test guard")
+
+// Names of the irreversible operations on the calling process, for allowing
+// them in [SetTestGuard]:
+//   - GuardSealPrivileges: sealing privileges using a seccomp filter, see
+//     [SealPrivileges].
+//   - GuardSetupFor: irreversibly dropping the permitted capabilities of all
+//     threads, see [SetupFor].
+const (
+	GuardSealPrivileges = "seal-privileges"
+	GuardSetupFor       = "setup-for"
+)
+
+// TB is the subset of [testing.TB] used by [SetTestGuard]; it is also satisfied
+// by Ginkgo's GinkgoT().
+type TB interface {
+	Helper()
+	Cleanup(func())
+}
+
+// testGuard holds the names of the allowed irreversible operations while the
+// test guard is active, and is nil otherwise.
+var testGuard atomic.Pointer[map[string]bool]
+
+// SetTestGuard activates the test guard for the duration of the specified
+// test, protecting the process running the test suite – and thus developers'
+// machines – from accidentally getting locked down. While the test guard is
+// active, irreversible operations on the calling process, such as setting
+// no_new_privs and installing seccomp filters when sealing privileges, or
+// dropping the permitted capabilities of all threads, fail with [ErrTestGuard]
+// instead, unless explicitly allowed by passing their names, such as
+// [GuardSealPrivileges] and [GuardSetupFor].
+func SetTestGuard(t TB, allow ...string) {
+	t.Helper()
+	allowed := make(map[string]bool, len(allow))
+	for _, op := range allow {
+		allowed[op] = true
+	}
+	previous := testGuard.Swap(&allowed)
+	t.Cleanup(func() { testGuard.Store(previous) })
+}
+
+// guard returns nil if the specified irreversible operation is allowed, and
+// otherwise an error wrapping [ErrTestGuard].
+func guard(op string) error {
+	allowed := testGuard.Load()
+	if allowed == nil || (*allowed)[op] {
+		return nil
+	}
+	return fmt.Errorf("%s: %w", op, ErrTestGuard)
+}
@@ -0,0 +1,67 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// cleanupTB records the cleanup functions registered with it.
+type cleanupTB struct {
+	cleanups []func()
+}
+
+func (t *cleanupTB) Helper() {}
+
+func (t *cleanupTB) Cleanup(fn func()) { t.cleanups = append(t.cleanups, fn) }
+
+var _ = Describe("test guard", func() {
+
+	It("refuses irreversible operations unless allowed", func() {
+		Expect(guard(GuardSealPrivileges)).To(Succeed())
+
+		outer := &cleanupTB{}
+		SetTestGuard(outer)
+		Expect(guard(GuardSealPrivileges)).To(MatchError(ErrTestGuard))
+		Expect(SealPrivileges()).To(MatchError(ErrTestGuard))
+
+		inner := &cleanupTB{}
+		SetTestGuard(inner, GuardSealPrivileges)
+		Expect(guard(GuardSealPrivileges)).To(Succeed())
+		Expect(guard("foo")).To(MatchError(ErrTestGuard))
+
+		Expect(inner.cleanups).To(HaveLen(1))
+		inner.cleanups[0]()
+		Expect(guard(GuardSealPrivileges)).To(MatchError(ErrTestGuard))
+		Expect(outer.cleanups).To(HaveLen(1))
+		outer.cleanups[0]()
+		Expect(guard(GuardSealPrivileges)).To(Succeed())
+	})
+
+	It("works with GinkgoT", func() {
+		SetTestGuard(GinkgoT())
+		Expect(SealPrivileges()).To(MatchError(ErrTestGuard))
+	})
+
+	It("refuses setting up for operations", func() {
+		SetTestGuard(GinkgoT(), GuardSealPrivileges)
+		Expect(SetupFor(OpOpenRawSocket)).To(MatchError(ErrTestGuard))
+		Expect(guard(GuardSetupFor)).To(MatchError(ErrTestGuard))
+		SetTestGuard(GinkgoT(), GuardSetupFor)
+		Expect(guard(GuardSetupFor)).To(Succeed())
+	})
+
+})
@@ -0,0 +1,104 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrWrongThread is returned when a [ThreadHandle] is used on another OS-level
+// thread (kernel task) than the one it has been created on.
+var ErrWrongThread = errors.New("capabilities operation on wrong thread")
+
+// ThreadHandle performs capget(2) and capset(2) operations strictly on the
+// kernel task (OS-level thread) it has been created on, verifying using
+// gettid(2) on every call that it is still used on the same thread. This gives
+// cgo and FFI embedders mixing cgo threads and Go threads hard guarantees
+// about which task is affected.
+//
+// Go code must lock its Go routine to its OS-level thread using
+// [runtime.LockOSThread] before calling [OnCurrentThread] and for as long as
+// it uses the handle; Go routines called from C code already are locked.
+type ThreadHandle struct {
+	tid int
+}
+
+// OnCurrentThread returns a handle for capability operations on the current
+// kernel task.
+func OnCurrentThread() *ThreadHandle {
+	return &ThreadHandle{tid: unix.Gettid()}
+}
+
+// TID returns the task ID of the kernel task this handle is bound to.
+func (h *ThreadHandle) TID() int { return h.tid }
+
+// verify returns an error wrapping [ErrWrongThread] if called on another
+// kernel task than the one the handle is bound to.
+func (h *ThreadHandle) verify() error {
+	if tid := unix.Gettid(); tid != h.tid {
+		return fmt.Errorf("%w: handle bound to task %d, but called on task %d",
+			ErrWrongThread, h.tid, tid)
+	}
+	return nil
+}
+
+// Capabilities returns the effective, permitted and inheritable capability
+// sets of the kernel task this handle is bound to.
+func (h *ThreadHandle) Capabilities() (TaskCapabilities, error) {
+	if err := h.verify(); err != nil {
+		return TaskCapabilities{}, err
+	}
+	return OfTask(h.tid)
+}
+
+// SetCapabilities sets the effective, permitted and inheritable capability
+// sets of the kernel task this handle is bound to.
+func (h *ThreadHandle) SetCapabilities(taskcaps TaskCapabilities) error {
+	if err := h.verify(); err != nil {
+		return err
+	}
+	return SetForTask(h.tid, taskcaps)
+}
+
+// AddEffective adds the specified capabilities to the effective set of the
+// kernel task this handle is bound to, returning the previous capability
+// sets.
+func (h *ThreadHandle) AddEffective(capno int, morecapnos ...int) (TaskCapabilities, error) {
+	capsbefore, err := h.Capabilities()
+	if err != nil {
+		return TaskCapabilities{}, err
+	}
+	newcaps := capsbefore.Clone()
+	newcaps.Effective.Add(capno, morecapnos...)
+	return capsbefore, h.SetCapabilities(newcaps)
+}
+
+// DropEffective drops the specified capabilities from the effective set of
+// the kernel task this handle is bound to, returning the previous capability
+// sets.
+func (h *ThreadHandle) DropEffective(capno int, morecapnos ...int) (TaskCapabilities, error) {
+	capsbefore, err := h.Capabilities()
+	if err != nil {
+		return TaskCapabilities{}, err
+	}
+	newcaps := capsbefore.Clone()
+	newcaps.Effective.Drop(capno, morecapnos...)
+	return capsbefore, h.SetCapabilities(newcaps)
+}
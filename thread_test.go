@@ -0,0 +1,65 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"runtime"
+
+	"github.com/thediveo/caps/capstest"
+	"golang.org/x/sys/unix"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("thread handles", func() {
+
+	It("operates on the thread it is bound to", func() {
+		capstest.RequirePermitted(GinkgoT(), CAP_NET_RAW)
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			// Never unlock this thread, so that it gets thrown away when this
+			// Go routine finishes.
+			runtime.LockOSThread()
+			h := OnCurrentThread()
+			Expect(h.TID()).To(Equal(unix.Gettid()))
+			before := Successful(h.DropEffective(CAP_NET_RAW))
+			Expect(before.Effective.Has(CAP_NET_RAW)).To(BeTrue())
+			Expect(Successful(OfThisTask()).Effective.Has(CAP_NET_RAW)).To(BeFalse())
+			Expect(Successful(h.AddEffective(CAP_NET_RAW)).Effective.Has(CAP_NET_RAW)).To(BeFalse())
+			Expect(Successful(h.Capabilities()).Effective.Has(CAP_NET_RAW)).To(BeTrue())
+		}()
+		<-done
+	})
+
+	It("refuses to operate on other threads", func() {
+		var h *ThreadHandle
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			runtime.LockOSThread()
+			h = OnCurrentThread()
+		}()
+		<-done
+		Expect(h.Capabilities()).Error().To(MatchError(ErrWrongThread))
+		Expect(h.SetCapabilities(TaskCapabilities{})).To(MatchError(ErrWrongThread))
+		Expect(h.AddEffective(CAP_CHOWN)).Error().To(MatchError(ErrWrongThread))
+		Expect(h.DropEffective(CAP_CHOWN)).Error().To(MatchError(ErrWrongThread))
+	})
+
+})
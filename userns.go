@@ -0,0 +1,189 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// IDMapping maps a range of user or group IDs inside a user namespace to a
+// range of IDs in the parent user namespace, as written to
+// /proc/[pid]/uid_map and /proc/[pid]/gid_map.
+type IDMapping struct {
+	ContainerID uint32 // first ID inside the user namespace
+	HostID      uint32 // first ID in the parent user namespace
+	Size        uint32 // number of IDs in the range
+}
+
+// ChildStep is a single step in setting up a task in a freshly created user
+// namespace, such as a child cloned with CLONE_NEWUSER using clone3(2), before
+// it executes its payload. The pid identifies the task to set up, where pid 0
+// refers to the calling task itself.
+//
+// Steps are composable and are usually run using [RunChildSteps], either by
+// the parent for its child, or by the child for itself.
+type ChildStep func(pid int) error
+
+// RunChildSteps runs the specified steps in the order given for the task with
+// the specified pid (0 for the calling task), stopping at the first failing
+// step. The kernel imposes several ordering rules:
+//   - the uid_map and gid_map files can be written only once,
+//   - in order to write gid_map without CAP_SETGID in the parent user
+//     namespace, setgroups must first be denied using [DenySetgroups],
+//   - capabilities in the new user namespace can only be set after the user
+//     ID mapping has been written, as otherwise exec'ing the payload will
+//     drop all capabilities.
+//
+// Thus, the usual order is [DenySetgroups] (if necessary), [WriteUIDMap],
+// [WriteGIDMap], and finally [SetCaps].
+func RunChildSteps(pid int, steps ...ChildStep) error {
+	for idx, step := range steps {
+		if err := step(pid); err != nil {
+			return fmt.Errorf("child setup step #%d failed: %w", idx+1, err)
+		}
+	}
+	return nil
+}
+
+// DenySetgroups returns a step that writes "deny" to /proc/[pid]/setgroups,
+// which is required before writing the gid_map without CAP_SETGID in the
+// parent user namespace.
+func DenySetgroups() ChildStep {
+	return func(pid int) error {
+		return writeProcFile(pid, "setgroups", "deny")
+	}
+}
+
+// WriteUIDMap returns a step that writes the specified user ID mappings to
+// /proc/[pid]/uid_map.
+func WriteUIDMap(mappings ...IDMapping) ChildStep {
+	return func(pid int) error {
+		return writeProcFile(pid, "uid_map", formatIDMappings(mappings))
+	}
+}
+
+// WriteGIDMap returns a step that writes the specified group ID mappings to
+// /proc/[pid]/gid_map.
+func WriteGIDMap(mappings ...IDMapping) ChildStep {
+	return func(pid int) error {
+		return writeProcFile(pid, "gid_map", formatIDMappings(mappings))
+	}
+}
+
+// SetCaps returns a step that sets the specified task capabilities. As Linux
+// doesn't allow setting the capabilities of other tasks, this step must be run
+// by the child itself with pid 0, and fails otherwise.
+func SetCaps(taskcaps TaskCapabilities) ChildStep {
+	return func(pid int) error {
+		if pid != 0 {
+			return fmt.Errorf("cannot set capabilities of other task %d", pid)
+		}
+		return SetForThisTask(taskcaps)
+	}
+}
+
+// formatIDMappings returns the textual representation of the ID mappings as
+// expected by the uid_map and gid_map files.
+func formatIDMappings(mappings []IDMapping) string {
+	var b strings.Builder
+	for _, m := range mappings {
+		fmt.Fprintf(&b, "%d %d %d\n", m.ContainerID, m.HostID, m.Size)
+	}
+	return b.String()
+}
+
+// writeProcFile writes the contents to the specified file in the /proc
+// directory of the task with the specified pid, or the calling task if pid is
+// 0. The contents are written using a single write, as required by the ID map
+// files.
+func writeProcFile(pid int, name string, contents string) error {
+	dir := "self"
+	if pid != 0 {
+		dir = strconv.Itoa(pid)
+	}
+	return os.WriteFile(ownProcPath(dir+"/"+name), []byte(contents), 0)
+}
+
+// parseIDMappings parses the textual representation of ID mappings, as found
+// in the uid_map and gid_map files.
+func parseIDMappings(text string) ([]IDMapping, error) {
+	var mappings []IDMapping
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("malformed ID mapping %q", line)
+		}
+		var ids [3]uint32
+		for idx, field := range fields {
+			id, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("malformed ID mapping %q", line)
+			}
+			ids[idx] = uint32(id)
+		}
+		mappings = append(mappings, IDMapping{ContainerID: ids[0], HostID: ids[1], Size: ids[2]})
+	}
+	return mappings, nil
+}
+
+// userNamespaceNotes details why some of the capabilities in
+// initialUserNamespaceOnly don't work outside the initial user namespace.
+var userNamespaceNotes = map[int]string{
+	CAP_MKNOD:      "the kernel refuses creating device nodes outside the initial user namespace",
+	CAP_SYS_TIME:   "the system clock isn't namespaced, so setting it needs CAP_SYS_TIME in the initial user namespace",
+	CAP_SYS_MODULE: "loading kernel modules affects the whole host, so it needs CAP_SYS_MODULE in the initial user namespace",
+}
+
+// AdviseUserNamespace returns warnings about those capabilities in caps that
+// have no effect when granted inside a user namespace other than the initial
+// user namespace, such as CAP_MKNOD or CAP_SYS_TIME. If initial is true, the
+// capabilities get granted in the initial user namespace and thus all work, so
+// there are no findings.
+func AdviseUserNamespace(caps CapabilitiesSet, initial bool) Findings {
+	if initial {
+		return nil
+	}
+	var findings Findings
+	for _, capno := range initialUserNamespaceOnly {
+		if !caps.Has(capno) {
+			continue
+		}
+		name := capabilityName(capno)
+		f := warningFinding(name,
+			"%s has no effect in a user namespace other than the initial user namespace", name)
+		if note, ok := userNamespaceNotes[capno]; ok {
+			f = warningFinding(name, "%s has no effect: %s", name, Localize(note))
+		}
+		findings = append(findings, f.withRemediation("do not grant %s", name))
+	}
+	return findings
+}
+
+// AdviseCurrentUserNamespace works like [AdviseUserNamespace], but with the
+// user namespace of the calling task as the target.
+func AdviseCurrentUserNamespace(caps CapabilitiesSet) Findings {
+	// A user namespace without a root user mapping is never the initial one;
+	// in case of other errors we rather err on the side of warning.
+	initial, _ := initialUserNamespace()
+	return AdviseUserNamespace(caps, initial)
+}
@@ -0,0 +1,115 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("user namespace child setup", func() {
+
+	It("formats ID mappings", func() {
+		Expect(formatIDMappings([]IDMapping{
+			{ContainerID: 0, HostID: 1000, Size: 1},
+			{ContainerID: 1, HostID: 100000, Size: 65536},
+		})).To(Equal("0 1000 1\n1 100000 65536\n"))
+	})
+
+	It("stops at the first failing step", func() {
+		calls := 0
+		step := func(pid int) error { calls++; return nil }
+		failing := func(pid int) error { return errors.New("D'OH!") }
+		Expect(RunChildSteps(42, step, failing, step)).To(
+			MatchError("child setup step #2 failed: D'OH!"))
+		Expect(calls).To(Equal(1))
+	})
+
+	It("refuses to set capabilities of other tasks", func() {
+		Expect(SetCaps(TaskCapabilities{})(42)).To(HaveOccurred())
+	})
+
+	It("sets up a child in a new user namespace", func() {
+		if inUserNamespaceUnlessRoot() {
+			return
+		}
+		cmd := exec.Command("/bin/sleep", "10")
+		cmd.SysProcAttr = &syscall.SysProcAttr{Cloneflags: syscall.CLONE_NEWUSER}
+		if err := cmd.Start(); err != nil {
+			Skip("cannot create user namespace: " + err.Error())
+		}
+		defer func() {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+		}()
+		pid := cmd.Process.Pid
+		Expect(RunChildSteps(pid,
+			DenySetgroups(),
+			WriteUIDMap(IDMapping{ContainerID: 0, HostID: uint32(os.Getuid()), Size: 1}),
+			WriteGIDMap(IDMapping{ContainerID: 0, HostID: uint32(os.Getgid()), Size: 1}),
+		)).To(Succeed())
+		procdir := "/proc/" + strconv.Itoa(pid) + "/"
+		uidmap, err := os.ReadFile(procdir + "uid_map")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(strings.Fields(string(uidmap))).To(Equal([]string{"0", strconv.Itoa(os.Getuid()), "1"}))
+		gidmap, err := os.ReadFile(procdir + "gid_map")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(strings.Fields(string(gidmap))).To(Equal([]string{"0", strconv.Itoa(os.Getgid()), "1"}))
+		setgroups, err := os.ReadFile(procdir + "setgroups")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(strings.TrimSpace(string(setgroups))).To(Equal("deny"))
+
+		Expect(RunChildSteps(pid, WriteUIDMap(IDMapping{Size: 1}))).NotTo(Succeed())
+	})
+
+})
+
+var _ = Describe("user namespace advisories", func() {
+
+	BeforeEach(func() {
+		cfg := CurrentConfig()
+		DeferCleanup(func() { Configure(cfg) })
+	})
+
+	It("warns about capabilities ineffective in child user namespaces", func() {
+		caps := capset(CAP_CHOWN, CAP_MKNOD, CAP_SYS_TIME, CAP_SYSLOG)
+		Expect(AdviseUserNamespace(caps, true)).To(BeEmpty())
+		findings := AdviseUserNamespace(caps, false)
+		Expect(findings).To(HaveLen(3))
+		Expect(findings.Severity()).To(Equal(SeverityWarning))
+		Expect(findings[0].Subject).To(Equal("CAP_SYS_TIME"))
+		Expect(findings[0].Message).To(ContainSubstring("system clock"))
+		Expect(findings[1].Subject).To(Equal("CAP_MKNOD"))
+		Expect(findings[1].Message).To(ContainSubstring("device nodes"))
+		Expect(findings[2].Subject).To(Equal("CAP_SYSLOG"))
+		Expect(findings[2].Remediation).To(Equal("do not grant CAP_SYSLOG"))
+	})
+
+	It("advises on the current user namespace", func() {
+		caps := capset(CAP_MKNOD)
+		useProc(fakeUIDMapProc("0 0 4294967295\n"))
+		Expect(AdviseCurrentUserNamespace(caps)).To(BeEmpty())
+		useProc(fakeUIDMapProc("0 100000 65536\n"))
+		Expect(AdviseCurrentUserNamespace(caps)).To(HaveLen(1))
+	})
+
+})
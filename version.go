@@ -0,0 +1,84 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+// VersionInfo describes the result of negotiating the version of the
+// capabilities user-space data structure between the Linux kernel and this
+// package.
+type VersionInfo struct {
+	Native        uint32 // version natively used by the kernel; 0 if undetected
+	UsedByPackage uint32 // version used by this package when calling capget/capset
+	Downgraded    bool   // this package uses an older version than the kernel's native version
+}
+
+// KernelCapabilityVersionInfo returns details about the capabilities user-space
+// data structure version natively used by the Linux kernel we're running on and
+// the version this package uses when getting and setting capabilities. Use
+// [VersionInfo.Warnings] to check for any issues with the version negotiation.
+func KernelCapabilityVersionInfo() VersionInfo {
+	return versionInfo(KernelCapabilityVersion())
+}
+
+// versionInfo returns the version negotiation result for the specified native
+// kernel version.
+func versionInfo(native uint32) VersionInfo {
+	return VersionInfo{
+		Native:        native,
+		UsedByPackage: LINUX_CAPABILITY_VERSION_3,
+		Downgraded:    native > LINUX_CAPABILITY_VERSION_3,
+	}
+}
+
+// Warnings returns human-readable warnings about the version negotiation, such
+// as when the kernel's native version could not be detected, or when this
+// package had to fall back to an older version than the kernel natively uses.
+// If there are no issues, Warnings returns an empty list.
+func (v VersionInfo) Warnings() []string {
+	warnings := []string{}
+	switch {
+	case v.Native == 0:
+		warnings = append(warnings,
+			Localize("could not detect the kernel's native capabilities version"))
+	case v.Downgraded:
+		warnings = append(warnings, localizef(
+			"kernel natively uses capabilities version 0x%08x, downgraded to version 0x%08x; "+
+				"capabilities beyond the %d supported words are not visible",
+			v.Native, v.UsedByPackage, capDataElements))
+	case v.Native < v.UsedByPackage:
+		warnings = append(warnings, localizef(
+			"kernel natively uses capabilities version 0x%08x, older than version 0x%08x used by this package",
+			v.Native, v.UsedByPackage))
+	}
+	return warnings
+}
+
+// WordsForVersion returns the number of 32-bit words per capabilities set in
+// the user-space data structure of the specified capabilities version, such as
+// [LINUX_CAPABILITY_VERSION_3], or 0 for unknown versions. Low-level interop
+// code needs this layout knowledge, for instance, when decoding capability
+// blobs in audit records.
+func WordsForVersion(v uint32) int {
+	switch v {
+	case LINUX_CAPABILITY_VERSION_1:
+		return LINUX_CAPABILITY_U32S_1
+	case LINUX_CAPABILITY_VERSION_2:
+		return LINUX_CAPABILITY_U32S_2
+	case LINUX_CAPABILITY_VERSION_3:
+		return LINUX_CAPABILITY_U32S_3
+	}
+	return 0
+}
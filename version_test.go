@@ -0,0 +1,62 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"golang.org/x/sys/unix"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("kernel capabilities version", func() {
+
+	It("reports the negotiated version", func() {
+		info := KernelCapabilityVersionInfo()
+		Expect(info.Native).To(Equal(KernelCapabilityVersion()))
+		Expect(info.UsedByPackage).To(Equal(uint32(unix.LINUX_CAPABILITY_VERSION_3)))
+		Expect(info.Downgraded).To(BeFalse())
+		Expect(info.Warnings()).To(BeEmpty())
+	})
+
+	DescribeTable("warns about negotiation issues",
+		func(native uint32, downgraded bool, warning string) {
+			info := versionInfo(native)
+			Expect(info.Downgraded).To(Equal(downgraded))
+			Expect(info.Warnings()).To(ConsistOf(ContainSubstring(warning)))
+		},
+		Entry("undetected", uint32(0), false, "could not detect"),
+		Entry("newer kernel", uint32(0x20240101), true, "downgraded"),
+		Entry("older kernel", uint32(unix.LINUX_CAPABILITY_VERSION_1), false, "older than"),
+	)
+
+	It("matches the kernel's versions", func() {
+		Expect(LINUX_CAPABILITY_VERSION_1).To(Equal(unix.LINUX_CAPABILITY_VERSION_1))
+		Expect(LINUX_CAPABILITY_VERSION_2).To(Equal(unix.LINUX_CAPABILITY_VERSION_2))
+		Expect(LINUX_CAPABILITY_VERSION_3).To(Equal(unix.LINUX_CAPABILITY_VERSION_3))
+	})
+
+	DescribeTable("returns the words per set",
+		func(version uint32, words int) {
+			Expect(WordsForVersion(version)).To(Equal(words))
+		},
+		Entry(nil, uint32(LINUX_CAPABILITY_VERSION_1), 1),
+		Entry(nil, uint32(LINUX_CAPABILITY_VERSION_2), 2),
+		Entry(nil, uint32(LINUX_CAPABILITY_VERSION_3), 2),
+		Entry(nil, uint32(0), 0),
+		Entry(nil, uint32(0x20240101), 0),
+	)
+
+})
@@ -0,0 +1,322 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package caps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/thediveo/caps/internal/diag"
+)
+
+// ErrWorkerPoolClosed is returned when submitting jobs to a closed
+// [WorkerPool].
+var ErrWorkerPoolClosed = errors.New("worker pool closed")
+
+// WorkerProfile describes a group of workers in a [WorkerPool] all having the
+// same effective capabilities.
+type WorkerProfile struct {
+	Capabilities CapabilitiesSet // effective capabilities of the workers
+	Workers      int             // number of workers
+}
+
+// WorkerPool runs jobs on workers, where each worker is a Go routine locked to
+// its own OS-level thread, having the effective capabilities of its profile
+// raised. Jobs get routed to the workers by the capabilities they require.
+// For instance, a pool might consist of two CAP_NET_RAW workers and one
+// CAP_SYS_PTRACE worker.
+//
+// After each job, a worker restores its effective, permitted and inheritable
+// capabilities, so that jobs changing them cannot affect later jobs. If the
+// capabilities cannot be restored, the worker's thread gets thrown away and
+// replaced by a fresh worker. The same happens to workers whose jobs have been
+// abandoned by cancelling their contexts (see [WorkerPool.SubmitContext]), as
+// well as to workers whose jobs call [runtime.Goexit], with [ErrGoexit]
+// then being returned to the submitter.
+type WorkerPool struct {
+	profiles []*workerProfile
+	mu       sync.RWMutex
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// workerProfile is a group of workers with the same effective capabilities,
+// receiving their jobs from the same channel.
+type workerProfile struct {
+	caps CapabilitiesSet
+	jobs chan *workerJob
+}
+
+// workerJob is a job to be run by a worker, with the job's result to be sent
+// to done.
+type workerJob struct {
+	ctx   context.Context
+	fn    func(ctx context.Context) error
+	done  chan<- jobResult
+	state atomic.Int32
+}
+
+// States of a workerJob.
+const (
+	jobPending int32 = iota
+	jobRunning
+	jobFinished
+	jobAbandoned
+)
+
+// jobResult is the outcome of running a job, either an error or a panic.
+type jobResult struct {
+	err   error
+	panic interface{}
+}
+
+// NewWorkerPool returns a new worker pool with workers as described by the
+// specified profiles. NewWorkerPool returns an error if the capabilities of
+// any profile cannot be set, such as when they aren't in the permitted set;
+// this is a [MultiError] of the errors of all workers that failed to start.
+//
+// The workers are started from the calling Go routine's OS-level thread, so
+// they initially inherit its capabilities. Close the worker pool when not
+// needed anymore.
+func NewWorkerPool(profiles ...WorkerProfile) (*WorkerPool, error) {
+	p := &WorkerPool{}
+	started := make(chan error)
+	workers := 0
+	for _, profile := range profiles {
+		wp := &workerProfile{
+			caps: profile.Capabilities.Clone(),
+			jobs: make(chan *workerJob),
+		}
+		p.profiles = append(p.profiles, wp)
+		for idx := 0; idx < profile.Workers; idx++ {
+			p.wg.Add(1)
+			go p.work(wp, started)
+			workers++
+		}
+	}
+	var errs MultiError
+	for ; workers > 0; workers-- {
+		if err := <-started; err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) != 0 {
+		p.Close()
+		return nil, errs
+	}
+	return p, nil
+}
+
+// Submit runs fn on a worker having (at least) the specified capabilities
+// effective, returning the error returned by fn. Submit blocks until fn has
+// finished. If multiple profiles have the required capabilities, the profile
+// with the least capabilities gets used. Any panic inside fn is propagated to
+// the caller of Submit.
+//
+// Submit returns an error if the pool has no worker with the required
+// capabilities, and [ErrWorkerPoolClosed] if the pool has been closed.
+func (p *WorkerPool) Submit(fn func() error, capno int, morecapnos ...int) error {
+	return p.SubmitContext(context.Background(),
+		func(context.Context) error { return fn() }, capno, morecapnos...)
+}
+
+// SubmitContext works like [WorkerPool.Submit], but additionally passes the
+// specified context to fn and stops waiting for a worker or for fn to finish
+// when the context is done, returning the context's error.
+//
+// As Go routines cannot be stopped from the outside, fn should watch its
+// context and return early when it is done. A job abandoned while running
+// isn't trusted anymore, so when it finally finishes, its worker restores its
+// capabilities and then throws away its OS-level thread. In the meantime, a
+// fresh worker takes over, so that stuck jobs cannot exhaust the pool.
+func (p *WorkerPool) SubmitContext(ctx context.Context, fn func(ctx context.Context) error, capno int, morecapnos ...int) error {
+	required := NewCapabilitiesSet()
+	required.Add(capno, morecapnos...)
+	wp := p.route(required)
+	if wp == nil {
+		return fmt.Errorf("no worker with capabilities %s", required)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done := make(chan jobResult, 1)
+	job := &workerJob{ctx: ctx, fn: fn, done: done}
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return ErrWorkerPoolClosed
+	}
+	select {
+	case wp.jobs <- job:
+		p.mu.RUnlock()
+	case <-ctx.Done():
+		p.mu.RUnlock()
+		return ctx.Err()
+	}
+	var res jobResult
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		if job.state.CompareAndSwap(jobPending, jobAbandoned) {
+			return ctx.Err()
+		}
+		if job.state.CompareAndSwap(jobRunning, jobAbandoned) {
+			diag.Log("abandoning cancelled job, replacing worker",
+				"capabilities", wp.caps.String(), "error", ctx.Err())
+			p.wg.Add(1)
+			go p.work(wp, nil)
+			return ctx.Err()
+		}
+		// The job has just finished, so use its result.
+		res = <-done
+	}
+	if res.panic != nil {
+		panic(res.panic)
+	}
+	return res.err
+}
+
+// Close stops all workers after they have finished their current jobs, and
+// waits for them to terminate. Close can safely be called multiple times.
+func (p *WorkerPool) Close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		for _, wp := range p.profiles {
+			close(wp.jobs)
+		}
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+// route returns the profile with the least capabilities having all the
+// required capabilities, or nil if there is no such profile.
+func (p *WorkerPool) route(required CapabilitiesSet) *workerProfile {
+	var best *workerProfile
+	bestCount := 0
+	for _, wp := range p.profiles {
+		missing := required.Clone()
+		missing.dropSet(wp.caps)
+		if !isEmptySet(missing) {
+			continue
+		}
+		if count := len(wp.caps.Numbers()); best == nil || count < bestCount {
+			best, bestCount = wp, count
+		}
+	}
+	return best
+}
+
+// work runs jobs of the specified profile on a locked OS-level thread with
+// the profile's capabilities effective, until the profile's job channel gets
+// closed. If started isn't nil, work reports to it whether the worker could
+// set up its capabilities.
+func (p *WorkerPool) work(wp *workerProfile, started chan<- error) {
+	var current *workerJob
+	defer func() {
+		discardThisTask()
+		// If we're still running a job, it called runtime.Goexit and our
+		// locked thread is about to be thrown away, so let a fresh worker
+		// take over, unless this has already happened because the job has
+		// been abandoned.
+		if current != nil && current.state.CompareAndSwap(jobRunning, jobFinished) {
+			current.done <- jobResult{err: ErrGoexit}
+			p.wg.Add(1)
+			go p.work(wp, nil)
+		}
+		p.wg.Done()
+	}()
+	// Never unlock this thread, so that it gets thrown away when this Go
+	// routine finishes.
+	runtime.LockOSThread()
+	baseline, err := setEffectiveSet(wp.caps)
+	if started != nil {
+		if err != nil {
+			err = fmt.Errorf("cannot start worker with capabilities %s: %w", wp.caps.String(), err)
+		}
+		started <- err
+	} else if err != nil {
+		diag.Log("cannot replace worker", "capabilities", wp.caps.String(), "error", err)
+	}
+	if err != nil {
+		return
+	}
+	for job := range wp.jobs {
+		if !job.state.CompareAndSwap(jobPending, jobRunning) {
+			continue // abandoned before it got the chance to run.
+		}
+		current = job
+		res := runJob(job.ctx, job.fn)
+		current = nil
+		err := SetForThisTask(baseline)
+		if !job.state.CompareAndSwap(jobRunning, jobFinished) {
+			// The job has been abandoned and a fresh worker has already
+			// taken over, so throw away this thread.
+			return
+		}
+		job.done <- res
+		if err != nil {
+			diag.Log("cannot restore worker capabilities, replacing worker",
+				"capabilities", wp.caps.String(), "error", err)
+			p.wg.Add(1)
+			go p.work(wp, nil)
+			return
+		}
+	}
+}
+
+// runJob runs fn with the specified context, returning its error or a panic
+// inside fn.
+func runJob(ctx context.Context, fn func(ctx context.Context) error) (res jobResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			res.panic = r
+		}
+	}()
+	res.err = fn(ctx)
+	return
+}
+
+// setEffectiveSet sets the effective capabilities of the current task to
+// exactly the specified set, returning the task's new capabilities.
+func setEffectiveSet(effective CapabilitiesSet) (TaskCapabilities, error) {
+	taskcaps, err := OfThisTask()
+	if err != nil {
+		return TaskCapabilities{}, err
+	}
+	taskcaps.Effective = effective.Clone()
+	if err := SetForThisTask(taskcaps); err != nil {
+		return TaskCapabilities{}, err
+	}
+	// The kernel silently ignores capabilities it doesn't support, so make
+	// sure that all capabilities have been set.
+	if taskcaps, err = OfThisTask(); err != nil {
+		return TaskCapabilities{}, err
+	}
+	missing := effective.Clone()
+	missing.dropSet(taskcaps.Effective)
+	if names := missing.Names(); len(names) != 0 {
+		return TaskCapabilities{}, fmt.Errorf("capabilities not raised: %s", strings.Join(names, ", "))
+	}
+	return taskcaps, nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/thediveo/caps/capstest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+// effectiveNames returns the names of the effective capabilities of the
+// current task.
+func effectiveNames() []string {
+	return Successful(OfThisTask()).Effective.Names()
+}
+
+var _ = Describe("capability-aware worker pool", func() {
+
+	var pool *WorkerPool
+
+	BeforeEach(func() {
+		capstest.RequirePermitted(GinkgoT(), CAP_NET_RAW, CAP_SYS_PTRACE)
+		netraw := NewCapabilitiesSet()
+		netraw.Add(CAP_NET_RAW)
+		ptrace := NewCapabilitiesSet()
+		ptrace.Add(CAP_SYS_PTRACE)
+		both := NewCapabilitiesSet()
+		both.Add(CAP_NET_RAW, CAP_SYS_PTRACE)
+		pool = Successful(NewWorkerPool(
+			WorkerProfile{Capabilities: both, Workers: 1},
+			WorkerProfile{Capabilities: netraw, Workers: 2},
+			WorkerProfile{Capabilities: ptrace, Workers: 1},
+		))
+		DeferCleanup(pool.Close)
+	})
+
+	It("routes jobs by required capabilities", func() {
+		var names []string
+		job := func() error { names = effectiveNames(); return nil }
+
+		Expect(pool.Submit(job, CAP_NET_RAW)).To(Succeed())
+		Expect(names).To(ConsistOf("CAP_NET_RAW"))
+		Expect(pool.Submit(job, CAP_SYS_PTRACE)).To(Succeed())
+		Expect(names).To(ConsistOf("CAP_SYS_PTRACE"))
+		Expect(pool.Submit(job, CAP_SYS_PTRACE, CAP_NET_RAW)).To(Succeed())
+		Expect(names).To(ConsistOf("CAP_NET_RAW", "CAP_SYS_PTRACE"))
+
+		Expect(pool.Submit(job, CAP_SYS_ADMIN)).To(MatchError(ContainSubstring("no worker")))
+	})
+
+	It("passes errors and panics", func() {
+		Expect(pool.Submit(func() error { return errors.New("D'oh!") }, CAP_NET_RAW)).To(
+			MatchError("D'oh!"))
+		Expect(func() {
+			_ = pool.Submit(func() error { panic("D'oh!") }, CAP_SYS_PTRACE)
+		}).To(PanicWith("D'oh!"))
+		Expect(pool.Submit(func() error { return nil }, CAP_SYS_PTRACE)).To(Succeed())
+	})
+
+	It("restores worker capabilities after each job", func() {
+		Expect(pool.Submit(func() error {
+			_, err := AddEffectiveCaps(CAP_SYS_ADMIN)
+			return err
+		}, CAP_SYS_PTRACE)).To(Succeed())
+		var names []string
+		Expect(pool.Submit(func() error { names = effectiveNames(); return nil }, CAP_SYS_PTRACE)).To(Succeed())
+		Expect(names).To(ConsistOf("CAP_SYS_PTRACE"))
+	})
+
+	It("doesn't run jobs with done contexts", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		called := false
+		Expect(pool.SubmitContext(ctx, func(context.Context) error {
+			called = true
+			return nil
+		}, CAP_NET_RAW)).To(MatchError(context.Canceled))
+		Expect(called).To(BeFalse())
+	})
+
+	It("passes contexts to jobs", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		Expect(pool.SubmitContext(ctx, func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}, CAP_NET_RAW)).To(MatchError(context.DeadlineExceeded))
+	})
+
+	It("replaces workers of abandoned jobs", func() {
+		stuck := make(chan struct{})
+		running := make(chan struct{})
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-running
+			cancel()
+		}()
+		Expect(pool.SubmitContext(ctx, func(context.Context) error {
+			if _, err := AddEffectiveCaps(CAP_SYS_ADMIN); err != nil {
+				return err
+			}
+			close(running)
+			<-stuck
+			return nil
+		}, CAP_SYS_PTRACE)).To(MatchError(context.Canceled))
+		defer close(stuck)
+
+		var names []string
+		Expect(pool.Submit(func() error { names = effectiveNames(); return nil }, CAP_SYS_PTRACE)).To(Succeed())
+		Expect(names).To(ConsistOf("CAP_SYS_PTRACE"))
+	})
+
+	It("rejects jobs after closing", func() {
+		pool.Close()
+		pool.Close()
+		Expect(pool.Submit(func() error { return nil }, CAP_NET_RAW)).To(
+			MatchError(ErrWorkerPoolClosed))
+	})
+
+	It("fails for unavailable capabilities", func() {
+		resource := NewCapabilitiesSet()
+		resource.Add(CAP_SYS_RESOURCE)
+		_, err := NewWorkerPool(WorkerProfile{Capabilities: resource, Workers: 2})
+		Expect(err).To(MatchError(ContainSubstring("cannot start worker with capabilities CAP_SYS_RESOURCE")))
+		Expect(err).To(BeAssignableToTypeOf(MultiError{}))
+		Expect(err.(MultiError)).To(HaveLen(2))
+	})
+
+})
@@ -0,0 +1,115 @@
+// Copyright 2023 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package caps
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+
+	"gopkg.in/yaml.v3"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("zero values", func() {
+
+	It("treats nil capabilities sets as empty sets", func() {
+		var c CapabilitiesSet
+		Expect(c.Has(CAP_CHOWN)).To(BeFalse())
+		Expect(c.Names()).To(BeEmpty())
+		Expect(c.Numbers()).To(BeEmpty())
+		Expect(c.NamesByNumberDesc()).To(BeEmpty())
+		Expect(c.SortedNames()).To(BeEmpty())
+		Expect(c.String()).To(BeEmpty())
+		Expect(c.Hex()).To(Equal("0000000000000000"))
+		Expect(c.Uint64()).To(BeZero())
+		Expect(c.WordsBigEndianFirst()).To(BeEmpty())
+		Expect(c.Clone()).NotTo(BeNil())
+		Expect(c.Compact()).NotTo(BeNil())
+		Expect(DiffSets(c, NewCapabilitiesSet()).Empty()).To(BeTrue())
+		Expect(AggregateUnion([]CapabilitiesSet{c, nil})).To(BeEmpty())
+		Expect(AggregateIntersection([]CapabilitiesSet{c, nil})).To(BeEmpty())
+
+		c.Drop(CAP_CHOWN)
+		Expect(c).To(BeNil())
+		c.Clear()
+		Expect(c).To(BeEmpty())
+		var d CapabilitiesSet
+		d.Add(CAP_KILL)
+		Expect(d.Names()).To(ConsistOf("CAP_KILL"))
+
+		Expect(func() { c.Has(-1) }).To(Panic())
+	})
+
+	It("marshals nil capabilities sets like empty sets", func() {
+		var c CapabilitiesSet
+		empty := NewCapabilitiesSet()
+		Expect(json.Marshal(c)).To(Equal(Successful(json.Marshal(empty))))
+		Expect(c.MarshalCompactJSON()).To(Equal(Successful(empty.MarshalCompactJSON())))
+		Expect(yaml.Marshal(c)).To(Equal(Successful(yaml.Marshal(empty))))
+		Expect(c.MarshalCBOR()).To(Equal(Successful(empty.MarshalCBOR())))
+		Expect(c.Value()).To(Equal(Successful(empty.Value())))
+		var buff bytes.Buffer
+		Expect(gob.NewEncoder(&buff).Encode(c)).To(Succeed())
+		var decoded CapabilitiesSet
+		Expect(gob.NewDecoder(&buff).Decode(&decoded)).To(Succeed())
+		Expect(decoded.Numbers()).To(BeEmpty())
+	})
+
+	It("handles zero task capabilities", func() {
+		var tc TaskCapabilities
+		clone := tc.Clone()
+		Expect(clone.Effective).NotTo(BeNil())
+		Expect(clone.Permitted).NotTo(BeNil())
+		Expect(clone.Inheritable).NotTo(BeNil())
+		Expect(tc.Text()).To(Equal("="))
+		Expect(Successful(ParseText([]byte(tc.Text()))).Text()).To(Equal("="))
+		Expect(tc.Getpcaps(42)).To(Equal("42: =\n"))
+		Expect(tc.capUserData()).To(BeZero())
+		Expect(json.Marshal(tc)).To(MatchJSON(`{"Effective":[],"Permitted":[],"Inheritable":[]}`))
+		Expect(tc.MarshalCBOR()).NotTo(BeEmpty())
+	})
+
+	It("handles zero states", func() {
+		var s State
+		Expect(s.Canonical()).To(ContainSubstring("effective:\n"))
+		Expect(DiffStates(s, State{}).Empty()).To(BeTrue())
+		Expect(SystemdCapabilitiesOf(s).Directives()).To(Equal(
+			"AmbientCapabilities=\nCapabilityBoundingSet=\n"))
+		next := Successful(ExecTransition(s, nil))
+		Expect(DiffStates(s, next).Empty()).To(BeTrue())
+		Expect(ExplainExec(s, &FileCapabilities{}).Severity()).To(Equal(SeverityInfo))
+		Expect(json.Marshal(s)).NotTo(BeEmpty())
+		s.Release()
+		s.Release()
+	})
+
+	It("handles zero policies", func() {
+		var p Policy
+		Expect(EvaluatePolicy(p, OCICapabilities{}).Allowed).To(BeTrue())
+		decision := EvaluatePolicy(p, OCICapabilities{Effective: []string{"CAP_CHOWN"}})
+		Expect(decision.Allowed).To(BeFalse())
+		Expect(decision.Reasons).To(ConsistOf("effective: CAP_CHOWN is not allowed"))
+		b := Successful(json.Marshal(p))
+		var q Policy
+		Expect(json.Unmarshal(b, &q)).To(Succeed())
+		Expect(q.Allowed.Numbers()).To(BeEmpty())
+		Expect(q.Denied.Numbers()).To(BeEmpty())
+	})
+
+})